@@ -0,0 +1,107 @@
+package apibara
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const StreamDataMethod = "/apibara.node.v1alpha2.Stream/StreamData"
+
+var streamDataDesc = &grpc.StreamDesc{
+	StreamName:    "StreamData",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// codec marshals the messages of this package, registered under the grpc "proto" name
+type codec struct{}
+
+type message interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("apibara codec: unsupported message %T", v)
+	}
+	return m.Marshal()
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("apibara codec: unsupported message %T", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+type Client struct {
+	conn      *grpc.ClientConn
+	authToken string
+}
+
+// NewClient connects to a DNA stream, with TLS for https:// urls
+func NewClient(url string, authToken string) (*Client, error) {
+	target := url
+	creds := insecure.NewCredentials()
+	if strings.HasPrefix(url, "https://") {
+		target = strings.TrimPrefix(url, "https://")
+		creds = credentials.NewTLS(&tls.Config{})
+	} else {
+		target = strings.TrimPrefix(url, "http://")
+	}
+
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds), grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, authToken: authToken}, nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+type Stream struct {
+	stream grpc.ClientStream
+}
+
+// StreamData opens the stream and sends the configuring request
+func (c *Client) StreamData(ctx context.Context, request *StreamDataRequest) (*Stream, error) {
+	if c.authToken != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.authToken)
+	}
+	stream, err := c.conn.NewStream(ctx, streamDataDesc, StreamDataMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(request); err != nil {
+		return nil, err
+	}
+	return &Stream{stream: stream}, nil
+}
+
+func (s *Stream) Recv() (*StreamDataResponse, error) {
+	response := &StreamDataResponse{}
+	if err := s.stream.RecvMsg(response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (s *Stream) Close() error {
+	return s.stream.CloseSend()
+}
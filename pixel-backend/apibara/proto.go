@@ -0,0 +1,469 @@
+package apibara
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Messages of the Apibara DNA v1alpha2 stream ( apibara.node.v1alpha2 ) and of its Starknet
+// data ( apibara.starknet.v1alpha2 ), encoded with protowire. Only the fields used by the
+// indexer are kept, unknown fields are skipped.
+
+type DataFinality int32
+
+const (
+	FinalityUnknown   DataFinality = 0
+	FinalityPending   DataFinality = 1
+	FinalityAccepted  DataFinality = 2
+	FinalityFinalized DataFinality = 3
+)
+
+type Cursor struct {
+	OrderKey  uint64
+	UniqueKey []byte
+}
+
+type StreamDataRequest struct {
+	StreamId       uint64
+	BatchSize      uint64
+	StartingCursor *Cursor
+	Finality       DataFinality
+	Filter         []byte
+}
+
+type Invalidate struct {
+	Cursor *Cursor
+}
+
+type Data struct {
+	Cursor    *Cursor
+	EndCursor *Cursor
+	Finality  DataFinality
+	Data      [][]byte // encoded Blocks
+}
+
+// StreamDataResponse holds one of Invalidate, Data or a heartbeat
+type StreamDataResponse struct {
+	StreamId   uint64
+	Invalidate *Invalidate
+	Data       *Data
+	Heartbeat  bool
+}
+
+// FieldElement is a felt as 32 big endian bytes
+type FieldElement [32]byte
+
+func FieldElementFromHex(value string) (FieldElement, error) {
+	var felt FieldElement
+	number, ok := new(big.Int).SetString(value, 0)
+	if !ok || number.Sign() < 0 || number.BitLen() > 256 {
+		return felt, fmt.Errorf("invalid field element %s", value)
+	}
+	number.FillBytes(felt[:])
+	return felt, nil
+}
+
+// Hex returns the felt as 0x followed by 64 hex chars, as the webhook indexer sends them
+func (f FieldElement) Hex() string {
+	return fmt.Sprintf("0x%x", f[:])
+}
+
+type EventFilter struct {
+	FromAddress *FieldElement
+	Keys        []FieldElement
+}
+
+// HeaderFilter sends the block header, only with the blocks holding other data when weak
+type HeaderFilter struct {
+	Weak bool
+}
+
+type Filter struct {
+	Header *HeaderFilter
+	Events []EventFilter
+}
+
+type Event struct {
+	FromAddress FieldElement
+	Keys        []FieldElement
+	Data        []FieldElement
+	Index       uint64
+}
+
+type BlockHeader struct {
+	BlockNumber uint64
+	Timestamp   time.Time
+}
+
+type Block struct {
+	Status int32
+	Header *BlockHeader
+	Events []Event
+}
+
+// Encoding
+
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+func appendVarint(b []byte, num protowire.Number, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, value)
+}
+
+func (c *Cursor) Marshal() []byte {
+	b := appendVarint(nil, 1, c.OrderKey)
+	if len(c.UniqueKey) > 0 {
+		b = appendMessage(b, 2, c.UniqueKey)
+	}
+	return b
+}
+
+func (f FieldElement) Marshal() []byte {
+	var b []byte
+	for limb := 0; limb < 4; limb++ {
+		b = protowire.AppendTag(b, protowire.Number(limb+1), protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, binary.BigEndian.Uint64(f[limb*8:limb*8+8]))
+	}
+	return b
+}
+
+func (r *StreamDataRequest) Marshal() ([]byte, error) {
+	b := appendVarint(nil, 1, r.StreamId)
+	b = appendVarint(b, 2, r.BatchSize)
+	if r.StartingCursor != nil {
+		b = appendMessage(b, 3, r.StartingCursor.Marshal())
+	}
+	b = appendVarint(b, 4, uint64(r.Finality))
+	if r.Filter != nil {
+		b = appendMessage(b, 5, r.Filter)
+	}
+	return b, nil
+}
+
+func (r *StreamDataResponse) Marshal() ([]byte, error) {
+	b := appendVarint(nil, 1, r.StreamId)
+	switch {
+	case r.Invalidate != nil:
+		var invalidate []byte
+		if r.Invalidate.Cursor != nil {
+			invalidate = appendMessage(nil, 1, r.Invalidate.Cursor.Marshal())
+		}
+		b = appendMessage(b, 2, invalidate)
+	case r.Data != nil:
+		var data []byte
+		if r.Data.Cursor != nil {
+			data = appendMessage(data, 1, r.Data.Cursor.Marshal())
+		}
+		if r.Data.EndCursor != nil {
+			data = appendMessage(data, 2, r.Data.EndCursor.Marshal())
+		}
+		data = appendVarint(data, 3, uint64(r.Data.Finality))
+		for _, block := range r.Data.Data {
+			data = appendMessage(data, 4, block)
+		}
+		b = appendMessage(b, 3, data)
+	default:
+		b = appendMessage(b, 4, nil)
+	}
+	return b, nil
+}
+
+func (f *Filter) Marshal() ([]byte, error) {
+	var b []byte
+	if f.Header != nil {
+		var header []byte
+		if f.Header.Weak {
+			header = appendVarint(header, 1, 1)
+		}
+		b = appendMessage(b, 1, header)
+	}
+	for _, eventFilter := range f.Events {
+		var event []byte
+		if eventFilter.FromAddress != nil {
+			event = appendMessage(event, 1, eventFilter.FromAddress.Marshal())
+		}
+		for _, key := range eventFilter.Keys {
+			event = appendMessage(event, 2, key.Marshal())
+		}
+		b = appendMessage(b, 4, event)
+	}
+	return b, nil
+}
+
+func (block *Block) Marshal() ([]byte, error) {
+	b := appendVarint(nil, 1, uint64(block.Status))
+	if block.Header != nil {
+		header := appendVarint(nil, 3, block.Header.BlockNumber)
+		// google.protobuf.Timestamp
+		timestamp := appendVarint(nil, 1, uint64(block.Header.Timestamp.Unix()))
+		timestamp = appendVarint(timestamp, 2, uint64(block.Header.Timestamp.Nanosecond()))
+		header = appendMessage(header, 6, timestamp)
+		b = appendMessage(b, 2, header)
+	}
+	for _, event := range block.Events {
+		var e []byte
+		e = appendMessage(e, 1, event.FromAddress.Marshal())
+		for _, key := range event.Keys {
+			e = appendMessage(e, 2, key.Marshal())
+		}
+		for _, data := range event.Data {
+			e = appendMessage(e, 3, data.Marshal())
+		}
+		e = appendVarint(e, 4, event.Index)
+		// EventWithTransaction.event
+		b = appendMessage(b, 5, appendMessage(nil, 3, e))
+	}
+	return b, nil
+}
+
+// Decoding
+
+// fieldFunc handles one field, values are the varint / fixed64 value or the bytes
+type fieldFunc func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error
+
+func walk(b []byte, handle fieldFunc) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var value uint64
+		var bytes []byte
+		switch typ {
+		case protowire.VarintType:
+			value, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			value, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := handle(num, typ, value, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cursor) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		switch num {
+		case 1:
+			c.OrderKey = value
+		case 2:
+			c.UniqueKey = append([]byte{}, bytes...)
+		}
+		return nil
+	})
+}
+
+func unmarshalCursor(b []byte) (*Cursor, error) {
+	cursor := &Cursor{}
+	return cursor, cursor.Unmarshal(b)
+}
+
+func (f *FieldElement) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		if num >= 1 && num <= 4 && typ == protowire.Fixed64Type {
+			binary.BigEndian.PutUint64(f[(num-1)*8:num*8], value)
+		}
+		return nil
+	})
+}
+
+func unmarshalFieldElement(b []byte) (FieldElement, error) {
+	var felt FieldElement
+	return felt, felt.Unmarshal(b)
+}
+
+func (r *StreamDataRequest) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		var err error
+		switch num {
+		case 1:
+			r.StreamId = value
+		case 2:
+			r.BatchSize = value
+		case 3:
+			r.StartingCursor, err = unmarshalCursor(bytes)
+		case 4:
+			r.Finality = DataFinality(value)
+		case 5:
+			r.Filter = append([]byte{}, bytes...)
+		}
+		return err
+	})
+}
+
+func (r *StreamDataResponse) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		switch num {
+		case 1:
+			r.StreamId = value
+		case 2:
+			r.Invalidate = &Invalidate{}
+			return walk(bytes, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+				var err error
+				if num == 1 {
+					r.Invalidate.Cursor, err = unmarshalCursor(bytes)
+				}
+				return err
+			})
+		case 3:
+			r.Data = &Data{}
+			return walk(bytes, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+				var err error
+				switch num {
+				case 1:
+					r.Data.Cursor, err = unmarshalCursor(bytes)
+				case 2:
+					r.Data.EndCursor, err = unmarshalCursor(bytes)
+				case 3:
+					r.Data.Finality = DataFinality(value)
+				case 4:
+					r.Data.Data = append(r.Data.Data, append([]byte{}, bytes...))
+				}
+				return err
+			})
+		case 4:
+			r.Heartbeat = true
+		}
+		return nil
+	})
+}
+
+func (f *Filter) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		if num == 1 {
+			f.Header = &HeaderFilter{}
+			return walk(bytes, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+				if num == 1 {
+					f.Header.Weak = value != 0
+				}
+				return nil
+			})
+		}
+		if num != 4 {
+			return nil
+		}
+		eventFilter := EventFilter{}
+		err := walk(bytes, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+			switch num {
+			case 1:
+				felt, err := unmarshalFieldElement(bytes)
+				if err != nil {
+					return err
+				}
+				eventFilter.FromAddress = &felt
+			case 2:
+				felt, err := unmarshalFieldElement(bytes)
+				if err != nil {
+					return err
+				}
+				eventFilter.Keys = append(eventFilter.Keys, felt)
+			}
+			return nil
+		})
+		f.Events = append(f.Events, eventFilter)
+		return err
+	})
+}
+
+func unmarshalEvent(b []byte) (Event, error) {
+	event := Event{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		switch num {
+		case 1:
+			felt, err := unmarshalFieldElement(bytes)
+			if err != nil {
+				return err
+			}
+			event.FromAddress = felt
+		case 2, 3:
+			felt, err := unmarshalFieldElement(bytes)
+			if err != nil {
+				return err
+			}
+			if num == 2 {
+				event.Keys = append(event.Keys, felt)
+			} else {
+				event.Data = append(event.Data, felt)
+			}
+		case 4:
+			event.Index = value
+		}
+		return nil
+	})
+	return event, err
+}
+
+func unmarshalBlockHeader(b []byte) (*BlockHeader, error) {
+	header := &BlockHeader{}
+	var seconds, nanos uint64
+	err := walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		switch num {
+		case 3:
+			header.BlockNumber = value
+		case 6:
+			return walk(bytes, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+				switch num {
+				case 1:
+					seconds = value
+				case 2:
+					nanos = value
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	header.Timestamp = time.Unix(int64(seconds), int64(nanos)).UTC()
+	return header, err
+}
+
+func (block *Block) Unmarshal(b []byte) error {
+	return walk(b, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+		switch num {
+		case 1:
+			block.Status = int32(value)
+		case 2:
+			header, err := unmarshalBlockHeader(bytes)
+			if err != nil {
+				return err
+			}
+			block.Header = header
+		case 5:
+			// EventWithTransaction, only the event is kept
+			return walk(bytes, func(num protowire.Number, typ protowire.Type, value uint64, bytes []byte) error {
+				if num != 3 {
+					return nil
+				}
+				event, err := unmarshalEvent(bytes)
+				if err != nil {
+					return err
+				}
+				block.Events = append(block.Events, event)
+				return nil
+			})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,172 @@
+package apibara
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func mustFelt(t *testing.T, value string) FieldElement {
+	t.Helper()
+	felt, err := FieldElementFromHex(value)
+	if err != nil {
+		t.Fatalf("FieldElementFromHex(%s): %v", value, err)
+	}
+	return felt
+}
+
+func TestFieldElementHex(t *testing.T) {
+	felt := mustFelt(t, "0xABC")
+	if want := "0x" + strings.Repeat("0", 61) + "abc"; felt.Hex() != want {
+		t.Errorf("Hex = %s, want %s", felt.Hex(), want)
+	}
+
+	// Full width felts use the four 64 bit limbs
+	full := mustFelt(t, "0x0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	var decoded FieldElement
+	if err := decoded.Unmarshal(full.Marshal()); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if decoded != full {
+		t.Errorf("round trip = %s, want %s", decoded.Hex(), full.Hex())
+	}
+
+	for _, invalid := range []string{"zz", "-0x1", "0x1" + strings.Repeat("0", 64)} {
+		if _, err := FieldElementFromHex(invalid); err == nil {
+			t.Errorf("FieldElementFromHex(%s) expected an error", invalid)
+		}
+	}
+}
+
+func TestStreamDataRequestRoundTrip(t *testing.T) {
+	request := &StreamDataRequest{
+		StreamId:       3,
+		BatchSize:      10,
+		StartingCursor: &Cursor{OrderKey: 12345, UniqueKey: []byte{0xde, 0xad}},
+		Finality:       FinalityAccepted,
+		Filter:         []byte{0x01, 0x02},
+	}
+
+	encoded, err := request.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	decoded := &StreamDataRequest{}
+	if err := decoded.Unmarshal(encoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(request, decoded) {
+		t.Errorf("round trip = %+v, want %+v", decoded, request)
+	}
+}
+
+func TestStreamDataResponseRoundTrip(t *testing.T) {
+	block := &Block{
+		Status: 2,
+		Header: &BlockHeader{BlockNumber: 812345, Timestamp: time.Unix(1718000000, 500).UTC()},
+		Events: []Event{
+			{
+				FromAddress: mustFelt(t, "0x1234"),
+				Keys:        []FieldElement{mustFelt(t, "0x99"), mustFelt(t, "0x1")},
+				Data:        []FieldElement{mustFelt(t, "0xff0000")},
+				Index:       7,
+			},
+			{
+				FromAddress: mustFelt(t, "0x1234"),
+				Keys:        []FieldElement{mustFelt(t, "0x98")},
+				Index:       8,
+			},
+		},
+	}
+	encodedBlock, err := block.Marshal()
+	if err != nil {
+		t.Fatalf("Block.Marshal: %v", err)
+	}
+
+	responses := []*StreamDataResponse{
+		{
+			StreamId: 1,
+			Data: &Data{
+				Cursor:    &Cursor{OrderKey: 10},
+				EndCursor: &Cursor{OrderKey: 11, UniqueKey: []byte{0x01}},
+				Finality:  FinalityPending,
+				Data:      [][]byte{encodedBlock},
+			},
+		},
+		{StreamId: 1, Invalidate: &Invalidate{Cursor: &Cursor{OrderKey: 9, UniqueKey: []byte{0x02}}}},
+		{StreamId: 1, Heartbeat: true},
+	}
+
+	for _, response := range responses {
+		encoded, err := response.Marshal()
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		decoded := &StreamDataResponse{}
+		if err := decoded.Unmarshal(encoded); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		if !reflect.DeepEqual(response, decoded) {
+			t.Errorf("round trip = %+v, want %+v", decoded, response)
+		}
+	}
+
+	decodedBlock := &Block{}
+	if err := decodedBlock.Unmarshal(encodedBlock); err != nil {
+		t.Fatalf("Block.Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(block, decodedBlock) {
+		t.Errorf("block round trip = %+v, want %+v", decodedBlock, block)
+	}
+}
+
+func TestFilterRoundTrip(t *testing.T) {
+	address := mustFelt(t, "0x1234")
+	filter := &Filter{Header: &HeaderFilter{Weak: true}, Events: []EventFilter{
+		{FromAddress: &address, Keys: []FieldElement{mustFelt(t, "0x99")}},
+		{Keys: []FieldElement{mustFelt(t, "0x98"), mustFelt(t, "0x1")}},
+	}}
+
+	encoded, err := filter.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	decoded := &Filter{}
+	if err := decoded.Unmarshal(encoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(filter, decoded) {
+		t.Errorf("round trip = %+v, want %+v", decoded, filter)
+	}
+}
+
+func TestUnmarshalSkipsUnknownFields(t *testing.T) {
+	cursor := &Cursor{OrderKey: 42, UniqueKey: []byte{0x0a}}
+	encoded := cursor.Marshal()
+	encoded = protowire.AppendTag(encoded, 99, protowire.VarintType)
+	encoded = protowire.AppendVarint(encoded, 1)
+	encoded = protowire.AppendTag(encoded, 100, protowire.BytesType)
+	encoded = protowire.AppendBytes(encoded, []byte("unknown"))
+
+	decoded := &Cursor{}
+	if err := decoded.Unmarshal(encoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(cursor, decoded) {
+		t.Errorf("decoded = %+v, want %+v", decoded, cursor)
+	}
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	request := &StreamDataRequest{StartingCursor: &Cursor{OrderKey: 1, UniqueKey: []byte{0x01, 0x02}}}
+	encoded, err := request.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := (&StreamDataRequest{}).Unmarshal(encoded[:len(encoded)-1]); err == nil {
+		t.Errorf("expected an error for a truncated message")
+	}
+}
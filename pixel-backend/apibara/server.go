@@ -0,0 +1,33 @@
+package apibara
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+// StreamHandler serves one StreamData call until the context is done, send pushes a response to the client
+type StreamHandler func(ctx context.Context, request *StreamDataRequest, send func(*StreamDataResponse) error) error
+
+// NewServer returns a grpc server answering StreamData with the handler, used to run a local
+// fake stream in place of an Apibara node
+func NewServer(handler StreamHandler) *grpc.Server {
+	return grpc.NewServer(
+		grpc.ForceServerCodec(codec{}),
+		grpc.UnknownServiceHandler(func(srv any, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			if method != StreamDataMethod {
+				return fmt.Errorf("unknown method %s", method)
+			}
+
+			request := &StreamDataRequest{}
+			if err := stream.RecvMsg(request); err != nil {
+				return err
+			}
+			return handler(stream.Context(), request, func(response *StreamDataResponse) error {
+				return stream.SendMsg(response)
+			})
+		}),
+	)
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Admin API keys have the form afk_<keyId>_<secret>, only sha256(secret) is stored.
+// Keys come from the backend config ( bootstrap, immutable ) or from the AdminKeys table.
+
+const (
+	ScopeAll            = "*"
+	ScopeCanvasWrite    = "canvas:write"
+	ScopeQuestsWrite    = "quests:write"
+	ScopeFactionsWrite  = "factions:write"
+	ScopeContractsWrite = "contracts:write"
+	ScopeIndexerWrite   = "indexer:write"
+	ScopeAdminKeys      = "admin:keys"
+
+	adminKeyPrefix     = "afk"
+	maxAuditPayloadLen = 4096
+)
+
+var AdminScopes = []string{
+	ScopeAll,
+	ScopeCanvasWrite,
+	ScopeQuestsWrite,
+	ScopeFactionsWrite,
+	ScopeContractsWrite,
+	ScopeIndexerWrite,
+	ScopeAdminKeys,
+}
+
+type AdminKey struct {
+	KeyId      string     `json:"keyId"`
+	Name       string     `json:"name"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  *time.Time `json:"createdAt"`
+	RotatedAt  *time.Time `json:"rotatedAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	FromConfig bool       `json:"fromConfig"`
+}
+
+func (k *AdminKey) HasScope(scope string) bool {
+	for _, keyScope := range k.Scopes {
+		if keyScope == ScopeAll || keyScope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func IsValidAdminScope(scope string) bool {
+	for _, validScope := range AdminScopes {
+		if scope == validScope {
+			return true
+		}
+	}
+	return false
+}
+
+func HashAdminSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+func parseAdminKey(apiKey string) (string, string, error) {
+	parts := strings.Split(apiKey, "_")
+	if len(parts) != 3 || parts[0] != adminKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", fmt.Errorf("malformed admin key")
+	}
+	return parts[1], parts[2], nil
+}
+
+func formatAdminKey(keyId string, secret string) string {
+	return adminKeyPrefix + "_" + keyId + "_" + secret
+}
+
+func getAdminKey(keyId string) (*AdminKey, error) {
+	for _, configKey := range core.AFKBackend.BackendConfig.Admin.Keys {
+		if configKey.Id == keyId {
+			return &AdminKey{
+				KeyId:      configKey.Id,
+				Name:       configKey.Id,
+				KeyHash:    strings.ToLower(configKey.Hash),
+				Scopes:     configKey.Scopes,
+				FromConfig: true,
+			}, nil
+		}
+	}
+
+	return core.PostgresQueryOne[AdminKey]("SELECT key_id, name, key_hash, scopes, created_at, rotated_at, revoked_at, last_used_at, false as from_config FROM AdminKeys WHERE key_id = $1", keyId)
+}
+
+// AuthenticateAdminKey resolves a raw api key to its stored admin key
+func AuthenticateAdminKey(apiKey string) (*AdminKey, error) {
+	keyId, secret, err := parseAdminKey(apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	adminKey, err := getAdminKey(keyId)
+	if err != nil {
+		return nil, fmt.Errorf("unknown admin key")
+	}
+	if adminKey.RevokedAt != nil {
+		return nil, fmt.Errorf("admin key revoked")
+	}
+
+	hash := HashAdminSecret(secret)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(adminKey.KeyHash)) != 1 {
+		return nil, fmt.Errorf("invalid admin key")
+	}
+
+	if !adminKey.FromConfig {
+		_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE AdminKeys SET last_used_at = CURRENT_TIMESTAMP WHERE key_id = $1", keyId)
+		if err != nil {
+			fmt.Println("Failed to update admin key last used time:", err)
+		}
+	}
+
+	return adminKey, nil
+}
+
+func newAdminSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// CreateAdminKey stores a new key and returns it in plain text, it cannot be retrieved later
+func CreateAdminKey(name string, scopes []string) (string, *AdminKey, error) {
+	for _, scope := range scopes {
+		if !IsValidAdminScope(scope) {
+			return "", nil, fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+
+	idBytes := make([]byte, 6)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", nil, err
+	}
+	keyId := hex.EncodeToString(idBytes)
+
+	secret, err := newAdminSecret()
+	if err != nil {
+		return "", nil, err
+	}
+
+	adminKey, err := core.PostgresQueryOne[AdminKey]("INSERT INTO AdminKeys (key_id, name, key_hash, scopes) VALUES ($1, $2, $3, $4) RETURNING key_id, name, key_hash, scopes, created_at, rotated_at, revoked_at, last_used_at, false as from_config", keyId, name, HashAdminSecret(secret), scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return formatAdminKey(keyId, secret), adminKey, nil
+}
+
+// RotateAdminKey replaces the secret of a key, keeping its id and scopes
+func RotateAdminKey(keyId string) (string, error) {
+	secret, err := newAdminSecret()
+	if err != nil {
+		return "", err
+	}
+
+	tag, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE AdminKeys SET key_hash = $1, rotated_at = CURRENT_TIMESTAMP WHERE key_id = $2 AND revoked_at IS NULL", HashAdminSecret(secret), keyId)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", fmt.Errorf("admin key not found or revoked")
+	}
+
+	return formatAdminKey(keyId, secret), nil
+}
+
+func RevokeAdminKey(keyId string) error {
+	tag, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE AdminKeys SET revoked_at = CURRENT_TIMESTAMP WHERE key_id = $1 AND revoked_at IS NULL", keyId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("admin key not found or already revoked")
+	}
+	return nil
+}
+
+func ListAdminKeys() ([]AdminKey, error) {
+	keys, err := core.PostgresQuery[AdminKey]("SELECT key_id, name, key_hash, scopes, created_at, rotated_at, revoked_at, last_used_at, false as from_config FROM AdminKeys ORDER BY created_at ASC")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, configKey := range core.AFKBackend.BackendConfig.Admin.Keys {
+		keys = append(keys, AdminKey{
+			KeyId:      configKey.Id,
+			Name:       configKey.Id,
+			Scopes:     configKey.Scopes,
+			FromConfig: true,
+		})
+	}
+	return keys, nil
+}
+
+func auditPayload(payload []byte) string {
+	if !utf8.Valid(payload) || strings.ContainsRune(string(payload), 0) {
+		return fmt.Sprintf("<binary %d bytes>", len(payload))
+	}
+	if len(payload) > maxAuditPayloadLen {
+		return strings.ToValidUTF8(string(payload[:maxAuditPayloadLen]), "") + "...<truncated>"
+	}
+	return string(payload)
+}
+
+type AdminCall struct {
+	KeyId      string
+	Scope      string
+	Method     string
+	Path       string
+	Query      string
+	Payload    []byte
+	Authorized bool
+}
+
+// RecordAdminCall writes an entry to the admin audit log
+func RecordAdminCall(call AdminCall) error {
+	var keyId *string
+	if call.KeyId != "" {
+		keyId = &call.KeyId
+	}
+
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO AdminAuditLog (key_id, scope, method, path, query, payload, authorized) VALUES ($1, $2, $3, $4, $5, $6, $7)", keyId, call.Scope, call.Method, call.Path, call.Query, auditPayload(call.Payload), call.Authorized)
+	return err
+}
+
+type AdminAuditEntry struct {
+	Key        int        `json:"key"`
+	KeyId      *string    `json:"keyId"`
+	Scope      string     `json:"scope"`
+	Method     string     `json:"method"`
+	Path       string     `json:"path"`
+	Query      string     `json:"query"`
+	Payload    string     `json:"payload"`
+	Authorized bool       `json:"authorized"`
+	Time       *time.Time `json:"time"`
+}
+
+func ListAdminAuditLog(keyId string, limit int, offset int) ([]AdminAuditEntry, error) {
+	if keyId != "" {
+		return core.PostgresQuery[AdminAuditEntry]("SELECT * FROM AdminAuditLog WHERE key_id = $1 ORDER BY key DESC LIMIT $2 OFFSET $3", keyId, limit, offset)
+	}
+	return core.PostgresQuery[AdminAuditEntry]("SELECT * FROM AdminAuditLog ORDER BY key DESC LIMIT $1 OFFSET $2", limit, offset)
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
+)
+
+// Wallet sign-in : the user requests a challenge, signs it as SNIP-12 typed data
+// with their account, and exchanges the signature for a short-lived session token.
+
+const (
+	defaultDomainName    = "AFK Pixel"
+	defaultDomainVersion = "1"
+	defaultChainId       = "SN_MAIN"
+	defaultChallengeTtl  = 300
+	defaultSessionTtl    = 3600
+
+	challengeStatement = "Sign in to AFK Pixel"
+)
+
+type Challenge struct {
+	Address   string
+	Nonce     string
+	ExpiresAt int64
+}
+
+type Session struct {
+	Token     string `json:"token"`
+	Address   string `json:"address"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+type contextKey struct{}
+
+func challengeKey(address string) string {
+	return fmt.Sprintf("auth-challenge-%s", address)
+}
+
+func sessionKey(token string) string {
+	return fmt.Sprintf("auth-session-%s", token)
+}
+
+func challengeTtl() time.Duration {
+	ttl := core.AFKBackend.BackendConfig.Auth.ChallengeTtl
+	if ttl <= 0 {
+		ttl = defaultChallengeTtl
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func sessionTtl() time.Duration {
+	ttl := core.AFKBackend.BackendConfig.Auth.SessionTtl
+	if ttl <= 0 {
+		ttl = defaultSessionTtl
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func randomHex(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// NewChallenge creates a one-time nonce for the address, replacing any previous one
+func NewChallenge(address string) (*Challenge, error) {
+	nonce, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := challengeTtl()
+	challenge := &Challenge{
+		Address:   address,
+		Nonce:     "0x" + nonce,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+
+	err = core.AFKBackend.Databases.Redis.Set(context.Background(), challengeKey(address), challenge.redisValue(), ttl).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// TypedData is the SNIP-12 message the wallet is asked to sign for the challenge
+func (c *Challenge) TypedData() *starknet.TypedData {
+	authConfig := core.AFKBackend.BackendConfig.Auth
+	domainName := authConfig.DomainName
+	if domainName == "" {
+		domainName = defaultDomainName
+	}
+	domainVersion := authConfig.DomainVersion
+	if domainVersion == "" {
+		domainVersion = defaultDomainVersion
+	}
+	chainId := authConfig.ChainId
+	if chainId == "" {
+		chainId = defaultChainId
+	}
+
+	return &starknet.TypedData{
+		Types: map[string][]starknet.TypedDataField{
+			starknet.TypedDataDomainType: {
+				{Name: "name", Type: "felt"},
+				{Name: "version", Type: "felt"},
+				{Name: "chainId", Type: "felt"},
+			},
+			"AuthChallenge": {
+				{Name: "statement", Type: "felt"},
+				{Name: "address", Type: "felt"},
+				{Name: "nonce", Type: "felt"},
+				{Name: "expiresAt", Type: "felt"},
+			},
+		},
+		PrimaryType: "AuthChallenge",
+		Domain: map[string]interface{}{
+			"name":    domainName,
+			"version": domainVersion,
+			"chainId": chainId,
+		},
+		Message: map[string]interface{}{
+			"statement": challengeStatement,
+			"address":   "0x" + c.Address,
+			"nonce":     c.Nonce,
+			"expiresAt": strconv.FormatInt(c.ExpiresAt, 10),
+		},
+	}
+}
+
+// redisValue stores the nonce with the expiry, as nonce:expiresAt
+func (c *Challenge) redisValue() string {
+	return c.Nonce + ":" + strconv.FormatInt(c.ExpiresAt, 10)
+}
+
+func parseChallenge(address string, value string) (*Challenge, error) {
+	nonce, expiresAtStr, found := strings.Cut(value, ":")
+	if !found {
+		return nil, fmt.Errorf("malformed challenge")
+	}
+	expiresAt, err := strconv.ParseInt(expiresAtStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed challenge")
+	}
+	return &Challenge{Address: address, Nonce: nonce, ExpiresAt: expiresAt}, nil
+}
+
+// Expired tells if the challenge can no longer be redeemed at the time
+func (c *Challenge) Expired(now time.Time) bool {
+	return now.Unix() > c.ExpiresAt
+}
+
+func loadChallenge(address string) (*Challenge, error) {
+	// GETDEL so a challenge can only be redeemed once
+	value, err := core.AFKBackend.Databases.Redis.GetDel(context.Background(), challengeKey(address)).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("no pending challenge")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return parseChallenge(address, value)
+}
+
+// VerifyChallenge checks the signature of the pending challenge against the account contract
+// and opens a new session on success
+func VerifyChallenge(address string, signature []string) (*Session, error) {
+	if len(signature) == 0 {
+		return nil, fmt.Errorf("missing signature")
+	}
+
+	challenge, err := loadChallenge(address)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Expired(time.Now()) {
+		return nil, fmt.Errorf("challenge expired")
+	}
+
+	messageHash, err := challenge.TypedData().MessageHash("0x" + address)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureFelts := make([]*big.Int, len(signature))
+	for idx, value := range signature {
+		signatureFelts[idx], err = starknet.ParseFelt(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	valid, err := starknet.IsValidSignature(context.Background(), "0x"+address, messageHash, signatureFelts)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+	if !valid {
+		return nil, fmt.Errorf("invalid signature")
+	}
+
+	return newSession(address)
+}
+
+func newSession(address string) (*Session, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := sessionTtl()
+	err = core.AFKBackend.Databases.Redis.Set(context.Background(), sessionKey(token), address, ttl).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		Token:     token,
+		Address:   address,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}, nil
+}
+
+// GetSessionAddress returns the address bound to a live session token
+func GetSessionAddress(token string) (string, error) {
+	address, err := core.AFKBackend.Databases.Redis.Get(context.Background(), sessionKey(token)).Result()
+	if err == redis.Nil {
+		return "", fmt.Errorf("session not found")
+	} else if err != nil {
+		return "", err
+	}
+	return address, nil
+}
+
+func RevokeSession(token string) error {
+	return core.AFKBackend.Databases.Redis.Del(context.Background(), sessionKey(token)).Err()
+}
+
+func WithAddress(ctx context.Context, address string) context.Context {
+	return context.WithValue(ctx, contextKey{}, address)
+}
+
+// AddressFromContext returns the authenticated address injected by the auth middleware
+func AddressFromContext(ctx context.Context) (string, bool) {
+	address, ok := ctx.Value(contextKey{}).(string)
+	return address, ok && address != ""
+}
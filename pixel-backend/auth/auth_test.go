@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+func setAuthConfig(authConfig config.AuthConfig) {
+	backendConfig := config.DefaultBackendConfig
+	backendConfig.Auth = authConfig
+	core.AFKBackend = &core.Backend{BackendConfig: &backendConfig}
+}
+
+func TestTtls(t *testing.T) {
+	setAuthConfig(config.AuthConfig{})
+	if challengeTtl() != defaultChallengeTtl*time.Second || sessionTtl() != defaultSessionTtl*time.Second {
+		t.Errorf("ttls = %s, %s, want the defaults", challengeTtl(), sessionTtl())
+	}
+
+	setAuthConfig(config.AuthConfig{ChallengeTtl: 60, SessionTtl: 120})
+	if challengeTtl() != time.Minute || sessionTtl() != 2*time.Minute {
+		t.Errorf("ttls = %s, %s, want 1m, 2m", challengeTtl(), sessionTtl())
+	}
+}
+
+func TestChallengeExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	challenge := &Challenge{Address: "0123", Nonce: "0xabcd", ExpiresAt: now.Add(5 * time.Minute).Unix()}
+
+	stored, err := parseChallenge(challenge.Address, challenge.redisValue())
+	if err != nil {
+		t.Fatalf("parseChallenge: %v", err)
+	}
+	if *stored != *challenge {
+		t.Errorf("stored challenge = %+v, want %+v", stored, challenge)
+	}
+
+	if stored.Expired(now) || stored.Expired(now.Add(5*time.Minute)) {
+		t.Errorf("challenge expired before its expiry")
+	}
+	if !stored.Expired(now.Add(5*time.Minute + time.Second)) {
+		t.Errorf("challenge not expired after its expiry")
+	}
+
+	for _, malformed := range []string{"", "0xabcd", "0xabcd:soon"} {
+		if _, err := parseChallenge("0123", malformed); err == nil {
+			t.Errorf("parseChallenge(%q) expected an error", malformed)
+		}
+	}
+}
+
+func TestChallengeMessageHash(t *testing.T) {
+	setAuthConfig(config.DefaultBackendConfig.Auth)
+	challenge := &Challenge{Address: "0123", Nonce: "0xabcd", ExpiresAt: 1700000300}
+
+	hash, err := challenge.TypedData().MessageHash("0x0123")
+	if err != nil {
+		t.Fatalf("MessageHash: %v", err)
+	}
+	again, _ := challenge.TypedData().MessageHash("0x0123")
+	if hash.Cmp(again) != 0 {
+		t.Errorf("message hash is not deterministic")
+	}
+
+	// The nonce, the expiry and the chain are signed
+	for _, changed := range []*Challenge{
+		{Address: "0123", Nonce: "0xabce", ExpiresAt: challenge.ExpiresAt},
+		{Address: "0123", Nonce: challenge.Nonce, ExpiresAt: challenge.ExpiresAt + 1},
+	} {
+		other, _ := changed.TypedData().MessageHash("0x0123")
+		if other.Cmp(hash) == 0 {
+			t.Errorf("challenge %+v has the same hash", changed)
+		}
+	}
+	setAuthConfig(config.AuthConfig{ChainId: "SN_MAIN"})
+	if other, _ := challenge.TypedData().MessageHash("0x0123"); other.Cmp(hash) == 0 {
+		t.Errorf("chain id is not part of the hash")
+	}
+}
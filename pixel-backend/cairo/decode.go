@@ -0,0 +1,316 @@
+package cairo
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
+)
+
+// Decoding of Cairo event keys / data into Go structs described with `cairo` tags :
+//
+//	type FactionCreated struct {
+//		FactionId  uint64   `cairo:"key"`
+//		Name       string   `cairo:"data,shortstring"`
+//		Leader     string   `cairo:"data,address"`
+//		Joinable   bool     `cairo:"data"`
+//		TokenId    *big.Int `cairo:"data,u256"`
+//		Colors     []uint32 `cairo:"data"`
+//	}
+//
+// The first tag option is the section ( key or data ), fields are read in order from their
+// section, the event selector ( keys[0] ) is skipped. The Cairo type follows from the Go type :
+//
+//	uint* / int*  felt252 or u8..u128 that must fit in the Go type
+//	bool          felt 0 or 1
+//	*big.Int      felt252
+//	string        the felt as emitted ( 0x prefixed hex )
+//	slice         Span<T>, a length felt followed by the elements, options apply to the elements
+//	struct        its fields in order, in the section of the parent field
+//
+// and the options change how felts are read :
+//
+//	u256         two felts ( low, high ), for *big.Int and integers
+//	address      ContractAddress, string of 64 lowercase hex chars without 0x as stored in postgres
+//	shortstring  felt252 short string, string with the null bytes trimmed
+//	bytearray    ByteArray, 31 byte words followed by a pending word and its length, as a string
+//
+// Untagged fields and fields tagged `cairo:"-"` are left as is. Every read is bounds checked,
+// so a malformed event returns an error instead of panicking.
+
+const (
+	SectionKey  = "key"
+	SectionData = "data"
+
+	OptionU256        = "u256"
+	OptionAddress     = "address"
+	OptionShortString = "shortstring"
+	OptionByteArray   = "bytearray"
+
+	// Upper bound on span lengths, a bad length felt must not allocate gigabytes
+	maxSpanLength = 1 << 16
+
+	// Bytes in a full ByteArray word
+	byteArrayWordSize = 31
+)
+
+var bigIntType = reflect.TypeOf((*big.Int)(nil))
+
+// Felts is a cursor over a list of felts
+type Felts struct {
+	name   string
+	values []string
+	pos    int
+}
+
+func NewFelts(name string, values []string) *Felts {
+	return &Felts{name: name, values: values}
+}
+
+func (f *Felts) Remaining() int {
+	return len(f.values) - f.pos
+}
+
+func (f *Felts) NextRaw() (string, error) {
+	if f.pos >= len(f.values) {
+		return "", fmt.Errorf("%s: missing felt at index %d, only %d", f.name, f.pos, len(f.values))
+	}
+	value := f.values[f.pos]
+	f.pos++
+	return value, nil
+}
+
+func (f *Felts) Next() (*big.Int, error) {
+	value, err := f.NextRaw()
+	if err != nil {
+		return nil, err
+	}
+	felt, err := starknet.ParseFelt(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s[%d]: %w", f.name, f.pos-1, err)
+	}
+	return felt, nil
+}
+
+// NextU256 reads a u256 as its low and high u128 felts
+func (f *Felts) NextU256() (*big.Int, error) {
+	low, err := f.Next()
+	if err != nil {
+		return nil, err
+	}
+	high, err := f.Next()
+	if err != nil {
+		return nil, err
+	}
+	if low.BitLen() > 128 || high.BitLen() > 128 {
+		return nil, fmt.Errorf("%s[%d]: u256 limbs out of range", f.name, f.pos-2)
+	}
+	return new(big.Int).Add(low, new(big.Int).Lsh(high, 128)), nil
+}
+
+// NextByteArray reads a ByteArray : the count of full words, the words of 31 bytes, then the
+// pending word and its length in bytes
+func (f *Felts) NextByteArray() (string, error) {
+	count, err := f.Next()
+	if err != nil {
+		return "", err
+	}
+	if !count.IsInt64() || int(count.Int64()) > f.Remaining() {
+		return "", fmt.Errorf("%s: byte array of %s words exceeds the %d remaining felts", f.name, count.String(), f.Remaining())
+	}
+
+	var builder strings.Builder
+	for idx := 0; idx < int(count.Int64()); idx++ {
+		word, err := f.Next()
+		if err != nil {
+			return "", err
+		}
+		if word.BitLen() > byteArrayWordSize*8 {
+			return "", fmt.Errorf("%s[%d]: byte array word out of range", f.name, f.pos-1)
+		}
+		builder.Write(word.FillBytes(make([]byte, byteArrayWordSize)))
+	}
+
+	pending, err := f.Next()
+	if err != nil {
+		return "", err
+	}
+	pendingLen, err := f.Next()
+	if err != nil {
+		return "", err
+	}
+	if !pendingLen.IsInt64() || pendingLen.Int64() >= byteArrayWordSize || pending.BitLen() > int(pendingLen.Int64())*8 {
+		return "", fmt.Errorf("%s[%d]: invalid byte array pending word", f.name, f.pos-2)
+	}
+	builder.Write(pending.FillBytes(make([]byte, pendingLen.Int64())))
+	return builder.String(), nil
+}
+
+// DecodeEvent decodes the keys ( selector included ) and data of an event into out, a pointer to a struct
+func DecodeEvent(keys []string, data []string, out interface{}) error {
+	value := reflect.ValueOf(out)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cairo: decode target must be a pointer to a struct, got %T", out)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("cairo: event without selector")
+	}
+
+	sections := map[string]*Felts{
+		SectionKey:  NewFelts("keys", keys[1:]),
+		SectionData: NewFelts("data", data),
+	}
+	return decodeStruct(value.Elem(), sections, "")
+}
+
+func decodeStruct(value reflect.Value, sections map[string]*Felts, section string) error {
+	structType := value.Type()
+	for idx := 0; idx < structType.NumField(); idx++ {
+		field := structType.Field(idx)
+		tag, ok := field.Tag.Lookup("cairo")
+		if !ok || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+		fieldSection := section
+		if options[0] != "" {
+			fieldSection = options[0]
+		}
+		felts, ok := sections[fieldSection]
+		if !ok {
+			return fmt.Errorf("cairo: field %s has no section, tag key or data", field.Name)
+		}
+
+		if err := decodeValue(value.Field(idx), felts, sections, fieldSection, options[1:]); err != nil {
+			return fmt.Errorf("%s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+func hasOption(options []string, option string) bool {
+	for _, o := range options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeValue(value reflect.Value, felts *Felts, sections map[string]*Felts, section string, options []string) error {
+	if value.Type() == bigIntType {
+		var felt *big.Int
+		var err error
+		if hasOption(options, OptionU256) {
+			felt, err = felts.NextU256()
+		} else {
+			felt, err = felts.Next()
+		}
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(felt))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var felt *big.Int
+		var err error
+		if hasOption(options, OptionU256) {
+			felt, err = felts.NextU256()
+		} else {
+			felt, err = felts.Next()
+		}
+		if err != nil {
+			return err
+		}
+		return setInteger(value, felt)
+	case reflect.Bool:
+		felt, err := felts.Next()
+		if err != nil {
+			return err
+		}
+		if felt.BitLen() > 1 {
+			return fmt.Errorf("invalid bool felt %s", starknet.FeltToHex(felt))
+		}
+		value.SetBool(felt.Sign() != 0)
+		return nil
+	case reflect.String:
+		return decodeString(value, felts, options)
+	case reflect.Slice:
+		length, err := felts.Next()
+		if err != nil {
+			return err
+		}
+		if !length.IsInt64() || length.Int64() > maxSpanLength || int(length.Int64()) > felts.Remaining() {
+			return fmt.Errorf("span length %s exceeds the %d remaining felts", length.String(), felts.Remaining())
+		}
+		count := int(length.Int64())
+		slice := reflect.MakeSlice(value.Type(), count, count)
+		for idx := 0; idx < count; idx++ {
+			if err := decodeValue(slice.Index(idx), felts, sections, section, options); err != nil {
+				return fmt.Errorf("[%d]: %w", idx, err)
+			}
+		}
+		value.Set(slice)
+		return nil
+	case reflect.Struct:
+		return decodeStruct(value, sections, section)
+	}
+	return fmt.Errorf("unsupported type %s", value.Type())
+}
+
+func setInteger(value reflect.Value, felt *big.Int) error {
+	bits := value.Type().Bits()
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if felt.BitLen() >= bits {
+			return fmt.Errorf("felt %s overflows %s", starknet.FeltToHex(felt), value.Type())
+		}
+		value.SetInt(felt.Int64())
+	default:
+		if felt.BitLen() > bits {
+			return fmt.Errorf("felt %s overflows %s", starknet.FeltToHex(felt), value.Type())
+		}
+		value.SetUint(felt.Uint64())
+	}
+	return nil
+}
+
+func decodeString(value reflect.Value, felts *Felts, options []string) error {
+	switch {
+	case hasOption(options, OptionAddress):
+		felt, err := felts.Next()
+		if err != nil {
+			return err
+		}
+		value.SetString(fmt.Sprintf("%064x", felt))
+	case hasOption(options, OptionShortString):
+		felt, err := felts.Next()
+		if err != nil {
+			return err
+		}
+		if felt.BitLen() > 248 {
+			return fmt.Errorf("felt %s is not a short string", starknet.FeltToHex(felt))
+		}
+		value.SetString(starknet.DecodeShortString(felt))
+	case hasOption(options, OptionByteArray):
+		decoded, err := felts.NextByteArray()
+		if err != nil {
+			return err
+		}
+		value.SetString(decoded)
+	default:
+		raw, err := felts.NextRaw()
+		if err != nil {
+			return err
+		}
+		value.SetString(raw)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package cairo
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const selector = "0x1"
+
+type decodedEvent struct {
+	Id      uint64   `cairo:"key"`
+	User    string   `cairo:"key,address"`
+	Amount  *big.Int `cairo:"data,u256"`
+	TokenId uint64   `cairo:"data,u256"`
+	Name    string   `cairo:"data,shortstring"`
+	Joined  bool     `cairo:"data"`
+	Colors  []uint32 `cairo:"data"`
+	Ignored string
+}
+
+func TestDecodeEvent(t *testing.T) {
+	keys := []string{selector, "0x2a", "0xABC"}
+	data := []string{
+		"0x1", "0x2", // Amount, 1 + 2 << 128
+		"0x7", "0x0", // TokenId
+		"0x616c696365", // "alice"
+		"0x1",
+		"0x3", "0xff0000", "0xff00", "0xff",
+	}
+
+	var event decodedEvent
+	if err := DecodeEvent(keys, data, &event); err != nil {
+		t.Fatalf("DecodeEvent: %v", err)
+	}
+
+	if event.Id != 42 {
+		t.Errorf("Id = %d, want 42", event.Id)
+	}
+	if want := strings.Repeat("0", 61) + "abc"; event.User != want {
+		t.Errorf("User = %s, want %s", event.User, want)
+	}
+	wantAmount := new(big.Int).Add(big.NewInt(1), new(big.Int).Lsh(big.NewInt(2), 128))
+	if event.Amount.Cmp(wantAmount) != 0 {
+		t.Errorf("Amount = %s, want %s", event.Amount, wantAmount)
+	}
+	if event.TokenId != 7 {
+		t.Errorf("TokenId = %d, want 7", event.TokenId)
+	}
+	if event.Name != "alice" {
+		t.Errorf("Name = %q, want alice", event.Name)
+	}
+	if !event.Joined {
+		t.Errorf("Joined = false, want true")
+	}
+	if len(event.Colors) != 3 || event.Colors[0] != 0xff0000 || event.Colors[1] != 0xff00 || event.Colors[2] != 0xff {
+		t.Errorf("Colors = %v, want [ff0000 ff00 ff]", event.Colors)
+	}
+}
+
+func TestDecodeU256(t *testing.T) {
+	type amount struct {
+		Value uint64 `cairo:"data,u256"`
+	}
+
+	tests := []struct {
+		name    string
+		data    []string
+		want    uint64
+		wantErr bool
+	}{
+		{name: "low only", data: []string{"0xffffffffffffffff", "0x0"}, want: 1<<64 - 1},
+		{name: "high overflows uint64", data: []string{"0x0", "0x1"}, wantErr: true},
+		{name: "low limb above u128", data: []string{"0x" + strings.Repeat("f", 33), "0x0"}, wantErr: true},
+		{name: "missing high limb", data: []string{"0x1"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out amount
+			err := DecodeEvent([]string{selector}, test.data, &out)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d", out.Value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeEvent: %v", err)
+			}
+			if out.Value != test.want {
+				t.Errorf("Value = %d, want %d", out.Value, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeShortString(t *testing.T) {
+	type named struct {
+		Name string `cairo:"data,shortstring"`
+	}
+
+	tests := []struct {
+		name    string
+		felt    string
+		want    string
+		wantErr bool
+	}{
+		{name: "ascii", felt: "0x68656c6c6f", want: "hello"},
+		{name: "empty", felt: "0x0", want: ""},
+		{name: "31 chars", felt: "0x" + strings.Repeat("61", 31), want: strings.Repeat("a", 31)},
+		{name: "over 31 bytes", felt: "0x1" + strings.Repeat("00", 31), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out named
+			err := DecodeEvent([]string{selector}, []string{test.felt}, &out)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", out.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeEvent: %v", err)
+			}
+			if out.Name != test.want {
+				t.Errorf("Name = %q, want %q", out.Name, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSpan(t *testing.T) {
+	type point struct {
+		X int64 `cairo:"data"`
+		Y int64 `cairo:"data"`
+	}
+	type spans struct {
+		Points []point  `cairo:"data"`
+		Users  []string `cairo:"data,address"`
+		Tail   int64    `cairo:"data"`
+		Empty  []uint32 `cairo:"data"`
+	}
+
+	data := []string{
+		"0x2", "0x1", "0x2", "0x3", "0x4",
+		"0x1", "0x5",
+		"0x9",
+		"0x0",
+	}
+	var out spans
+	if err := DecodeEvent([]string{selector}, data, &out); err != nil {
+		t.Fatalf("DecodeEvent: %v", err)
+	}
+	if len(out.Points) != 2 || out.Points[0] != (point{1, 2}) || out.Points[1] != (point{3, 4}) {
+		t.Errorf("Points = %v, want [{1 2} {3 4}]", out.Points)
+	}
+	if len(out.Users) != 1 || out.Users[0] != strings.Repeat("0", 63)+"5" {
+		t.Errorf("Users = %v", out.Users)
+	}
+	if out.Tail != 9 {
+		t.Errorf("Tail = %d, want 9", out.Tail)
+	}
+	if out.Empty == nil || len(out.Empty) != 0 {
+		t.Errorf("Empty = %v, want an empty slice", out.Empty)
+	}
+
+	// A length past the remaining felts must fail before allocating
+	var bad spans
+	if err := DecodeEvent([]string{selector}, []string{"0xffffffff", "0x1"}, &bad); err == nil {
+		t.Errorf("expected an error for a span longer than the data")
+	}
+}
+
+func TestDecodeByteArray(t *testing.T) {
+	type stored struct {
+		Value string `cairo:"data,bytearray"`
+	}
+
+	// 33 bytes : one full word of 31 bytes and a pending word of 2
+	value := strings.Repeat("a", 31) + "bc"
+	data := []string{"0x1", "0x" + strings.Repeat("61", 31), "0x6263", "0x2"}
+
+	var out stored
+	if err := DecodeEvent([]string{selector}, data, &out); err != nil {
+		t.Fatalf("DecodeEvent: %v", err)
+	}
+	if out.Value != value {
+		t.Errorf("Value = %q, want %q", out.Value, value)
+	}
+
+	var bad stored
+	if err := DecodeEvent([]string{selector}, []string{"0x0", "0x6263", "0x1"}, &bad); err == nil {
+		t.Errorf("expected an error for a pending word longer than its length")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	type target struct {
+		Small uint8 `cairo:"key"`
+		Flag  bool  `cairo:"data"`
+	}
+
+	tests := []struct {
+		name string
+		keys []string
+		data []string
+	}{
+		{name: "no selector", keys: []string{}, data: []string{"0x1"}},
+		{name: "missing key", keys: []string{selector}, data: []string{"0x1"}},
+		{name: "missing data", keys: []string{selector, "0x1"}, data: []string{}},
+		{name: "overflow", keys: []string{selector, "0x100"}, data: []string{"0x1"}},
+		{name: "bad bool", keys: []string{selector, "0x1"}, data: []string{"0x2"}},
+		{name: "not a felt", keys: []string{selector, "zz"}, data: []string{"0x1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out target
+			if err := DecodeEvent(test.keys, test.data, &out); err == nil {
+				t.Errorf("expected an error, got %+v", out)
+			}
+		})
+	}
+
+	if err := DecodeEvent([]string{selector}, nil, target{}); err == nil {
+		t.Errorf("expected an error for a non pointer target")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/video"
 )
 
 func isFlagSet(name string) bool {
@@ -27,6 +28,7 @@ func main() {
 	backendConfigFilename := flag.String("backend-config", config.DefaultBackendConfigPath, "Backend config file")
 	production := flag.Bool("production", false, "Production mode")
 	admin := flag.Bool("admin", false, "Admin mode")
+	metricsAddr := flag.String("metrics-addr", "", "Address serving the /debug/vars metrics, e.g. 127.0.0.1:9100, disabled when empty")
 
 	flag.Parse()
 
@@ -60,7 +62,12 @@ func main() {
 
 	core.AFKBackend = core.NewBackend(databases, roundsConfig, canvasConfig, backendConfig, *admin)
 
+	if _, err := video.FailStaleTimelapses(); err != nil {
+		panic(err)
+	}
+
 	routes.InitRoutes()
 
+	core.StartMetrics(*metricsAddr)
 	core.AFKBackend.Start(core.AFKBackend.BackendConfig.Port)
 }
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/joho/godotenv"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
@@ -27,6 +33,8 @@ func main() {
 	// databaseConfigFilename := flag.String("database-config", config.DefaultDatabaseConfigPath, "Database config file")
 	backendConfigFilename := flag.String("backend-config", config.DefaultBackendConfigPath, "Backend config file")
 	production := flag.Bool("production", false, "Production mode")
+	source := flag.String("source", "webhook", "Indexer messages source: webhook ( POST /consume-indexer-msg ) or stream ( Apibara DNA stream from the backend config )")
+	metricsAddr := flag.String("metrics-addr", "", "Address serving the /debug/vars metrics, e.g. 127.0.0.1:9100, disabled when empty")
 
 	flag.Parse()
 
@@ -60,11 +68,49 @@ func main() {
 
 	core.AFKBackend = core.NewBackend(databases, roundsConfig, canvasConfig, backendConfig, false)
 
+	if err := indexer.SeedContractAddresses(); err != nil {
+		panic(err)
+	}
+
 	routes.InitBaseRoutes()
 	indexer.InitIndexerRoutes()
 	routes.InitNFTStaticRoutes()
 	routes.InitWorldsStaticRoutes()
-	indexer.StartMessageProcessor()
 
-	core.AFKBackend.Start(core.AFKBackend.BackendConfig.ConsumerPort)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	processorDone := indexer.StartMessageProcessor(ctx)
+	indexer.StartReconciler(ctx)
+
+	var streamDone <-chan struct{}
+	switch *source {
+	case "webhook":
+	case "stream":
+		streamDone, err = indexer.StartStreamConsumer(ctx, backendConfig.Stream)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		panic(fmt.Sprintf("unknown source %s", *source))
+	}
+
+	core.StartMetrics(*metricsAddr)
+	serverErr := core.AFKBackend.StartWithContext(ctx, core.AFKBackend.BackendConfig.ConsumerPort)
+	if serverErr != nil {
+		// Stop the workers too, the consumer can not run without its port
+		stop()
+	}
+
+	// Let the processor finish the message in progress before closing the databases
+	<-processorDone
+	if *source == "stream" {
+		<-streamDone
+	}
+	if serverErr != nil {
+		databases.Close()
+		fmt.Println("Consumer stopped:", serverErr)
+		os.Exit(1)
+	}
+	fmt.Println("Consumer stopped")
 }
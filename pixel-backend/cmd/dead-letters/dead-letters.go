@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
+)
+
+// Inspect and replay the indexer dead letters
+//
+//	dead-letters list [-status failed] [-limit 50] [-offset 0]
+//	dead-letters show <key>
+//	dead-letters replay <key>...
+//	dead-letters replay -all
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: dead-letters [config flags] <list|show|replay> [args]")
+	fmt.Fprintln(os.Stderr, "  list [-status failed] [-limit 50] [-offset 0]")
+	fmt.Fprintln(os.Stderr, "  show <key>")
+	fmt.Fprintln(os.Stderr, "  replay <key>... | replay -all")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func printJson(value interface{}) {
+	valueJson, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(valueJson))
+}
+
+func parseKey(arg string) int64 {
+	key, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid dead letter key:", arg)
+		os.Exit(2)
+	}
+	return key
+}
+
+func list(args []string) {
+	listFlags := flag.NewFlagSet("list", flag.ExitOnError)
+	status := listFlags.String("status", indexer.DeadLetterStatusFailed, "Dead letter status, empty for all")
+	limit := listFlags.Int("limit", 50, "Max dead letters")
+	offset := listFlags.Int("offset", 0, "Dead letters to skip")
+	listFlags.Parse(args)
+
+	deadLetters, err := indexer.ListDeadLetters(*status, *limit, *offset)
+	if err != nil {
+		panic(err)
+	}
+	for _, deadLetter := range deadLetters {
+		fmt.Printf("%d\t%s\t%s\t%s\tblock %d\tattempts %d\t%s\n", deadLetter.Key, deadLetter.Status, deadLetter.Kind, deadLetter.Processor, deadLetter.OrderKey, deadLetter.Attempts, deadLetter.Error)
+	}
+}
+
+func show(args []string) {
+	if len(args) != 1 {
+		usage()
+	}
+
+	deadLetter, err := indexer.GetDeadLetter(parseKey(args[0]))
+	if err != nil {
+		panic(err)
+	}
+	printJson(deadLetter)
+}
+
+func replay(args []string) {
+	replayFlags := flag.NewFlagSet("replay", flag.ExitOnError)
+	all := replayFlags.Bool("all", false, "Replay every failed dead letter")
+	replayFlags.Parse(args)
+
+	var keys []int64
+	if *all {
+		deadLetters, err := indexer.ListDeadLetters(indexer.DeadLetterStatusFailed, 100000, 0)
+		if err != nil {
+			panic(err)
+		}
+		for _, deadLetter := range deadLetters {
+			keys = append(keys, deadLetter.Key)
+		}
+	} else {
+		for _, arg := range replayFlags.Args() {
+			keys = append(keys, parseKey(arg))
+		}
+	}
+	if len(keys) == 0 {
+		usage()
+	}
+
+	failed := 0
+	for _, key := range keys {
+		if _, err := indexer.ReplayDeadLetter(key); err != nil {
+			fmt.Println("Dead letter", key, "failed:", err)
+			failed++
+			continue
+		}
+		fmt.Println("Dead letter", key, "replayed")
+	}
+	fmt.Println("Replayed", len(keys)-failed, "of", len(keys), "dead letters")
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func main() {
+	godotenv.Load()
+
+	roundsConfigFilename := flag.String("rounds-config", config.DefaultRoundsConfigPath, "Rounds config file")
+	canvasConfigFilename := flag.String("canvas-config", config.DefaultCanvasConfigPath, "Canvas config file")
+	backendConfigFilename := flag.String("backend-config", config.DefaultBackendConfigPath, "Backend config file")
+	flag.Usage = usage
+
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	roundsConfig, err := config.LoadRoundsConfig(*roundsConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	canvasConfig, err := config.LoadCanvasConfig(*canvasConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databaseConfig, err := config.LoadDatabaseConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	backendConfig, err := config.LoadBackendConfig(*backendConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databases := core.NewDatabases(databaseConfig)
+	defer databases.Close()
+
+	core.AFKBackend = core.NewBackend(databases, roundsConfig, canvasConfig, backendConfig, false)
+
+	command, args := flag.Arg(0), flag.Args()[1:]
+	switch command {
+	case "list":
+		list(args)
+	case "show":
+		show(args)
+	case "replay":
+		replay(args)
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/apibara"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
+)
+
+// Local stand-in for an Apibara DNA stream: serves indexer messages from a JSON file ( array of
+// webhook payloads ) to the consumer run with -source stream, then keeps the stream open.
+
+var finalities = map[string]apibara.DataFinality{
+	indexer.DATA_STATUS_PENDING:   apibara.FinalityPending,
+	indexer.DATA_STATUS_ACCEPTED:  apibara.FinalityAccepted,
+	indexer.DATA_STATUS_FINALIZED: apibara.FinalityFinalized,
+}
+
+var blockStatuses = map[string]int32{
+	"BLOCK_STATUS_PENDING":        1,
+	"BLOCK_STATUS_ACCEPTED_ON_L2": 2,
+	"BLOCK_STATUS_ACCEPTED_ON_L1": 3,
+	"BLOCK_STATUS_REJECTED":       4,
+}
+
+func main() {
+	address := flag.String("address", "localhost:7171", "Listen address")
+	messagesFilename := flag.String("messages", "", "JSON file with the indexer messages to stream")
+	flag.Parse()
+
+	if *messagesFilename == "" {
+		fmt.Println("Usage: fake-stream -messages <file> [-address host:port]")
+		os.Exit(1)
+	}
+
+	file, err := os.ReadFile(*messagesFilename)
+	if err != nil {
+		panic(err)
+	}
+	var messages []indexer.IndexerMessage
+	if err := json.Unmarshal(file, &messages); err != nil {
+		panic(err)
+	}
+
+	responses := make([]*apibara.StreamDataResponse, 0, len(messages))
+	for _, message := range messages {
+		response, err := streamResponse(message)
+		if err != nil {
+			panic(err)
+		}
+		responses = append(responses, response)
+	}
+
+	server := apibara.NewServer(func(ctx context.Context, request *apibara.StreamDataRequest, send func(*apibara.StreamDataResponse) error) error {
+		startingBlock := uint64(0)
+		if request.StartingCursor != nil {
+			startingBlock = request.StartingCursor.OrderKey + 1
+		}
+		fmt.Println("Stream opened from block", startingBlock, "finality", request.Finality)
+
+		sent := 0
+		for _, response := range responses {
+			if response.Data.Cursor.OrderKey < startingBlock {
+				continue
+			}
+			if response.Data.Finality < request.Finality {
+				continue
+			}
+			if err := send(response); err != nil {
+				return err
+			}
+			sent++
+		}
+		fmt.Println("Sent", sent, "messages")
+
+		// Heartbeat until the client leaves
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				if err := send(&apibara.StreamDataResponse{Heartbeat: true}); err != nil {
+					return err
+				}
+			}
+		}
+	})
+
+	listener, err := net.Listen("tcp", *address)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Fake stream listening on", *address)
+	if err := server.Serve(listener); err != nil {
+		panic(err)
+	}
+}
+
+func streamResponse(message indexer.IndexerMessage) (*apibara.StreamDataResponse, error) {
+	finality, ok := finalities[message.Data.Finality]
+	if !ok {
+		return nil, fmt.Errorf("unknown finality %s", message.Data.Finality)
+	}
+	cursor, err := streamCursor(message.Data.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	endCursor, err := streamCursor(message.Data.EndCursor)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &apibara.Data{Cursor: cursor, EndCursor: endCursor, Finality: finality}
+	for _, batch := range message.Data.Batch {
+		block := apibara.Block{Status: blockStatuses[batch.Status]}
+		if batch.Header != nil {
+			blockNumber, err := strconv.ParseUint(batch.Header.BlockNumber.String(), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid block number %s", batch.Header.BlockNumber)
+			}
+			block.Header = &apibara.BlockHeader{BlockNumber: blockNumber, Timestamp: batch.Header.Timestamp}
+		}
+		for _, event := range batch.Events {
+			streamEvent := apibara.Event{}
+			streamEvent.FromAddress, err = apibara.FieldElementFromHex(event.Event.FromAddress)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range event.Event.Keys {
+				felt, err := apibara.FieldElementFromHex(key)
+				if err != nil {
+					return nil, err
+				}
+				streamEvent.Keys = append(streamEvent.Keys, felt)
+			}
+			for _, value := range event.Event.Data {
+				felt, err := apibara.FieldElementFromHex(value)
+				if err != nil {
+					return nil, err
+				}
+				streamEvent.Data = append(streamEvent.Data, felt)
+			}
+			block.Events = append(block.Events, streamEvent)
+		}
+		encodedBlock, err := block.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		data.Data = append(data.Data, encodedBlock)
+	}
+	return &apibara.StreamDataResponse{Data: data}, nil
+}
+
+func streamCursor(cursor indexer.IndexerCursor) (*apibara.Cursor, error) {
+	uniqueKeyHex := strings.TrimPrefix(cursor.UniqueKey, "0x")
+	if len(uniqueKeyHex)%2 != 0 {
+		uniqueKeyHex = "0" + uniqueKeyHex
+	}
+	uniqueKey, err := hex.DecodeString(uniqueKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor %s: %w", cursor.UniqueKey, err)
+	}
+	return &apibara.Cursor{OrderKey: uint64(cursor.OrderKey), UniqueKey: uniqueKey}, nil
+}
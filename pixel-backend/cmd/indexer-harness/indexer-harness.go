@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+// Feeds synthetic pending / accepted / finalized pixel placed messages through the indexer, as
+// the consumer receives them, then checks the Pixels rows and the redis canvas. It writes to the
+// configured databases and moves the indexer cursor forward : only run it on scratch databases.
+
+const pixelPlacedSelector = "0x02d7b50ebf415606d77c7e7842546fc13f8acfbfd16f7bcf2bc2d08f54114c23"
+
+// Registered as the art-peace contract, emits the harness events
+const harnessContract = "0xafa0"
+
+type pixel struct {
+	position int
+	color    int64
+}
+
+type harnessMessage struct {
+	finality    string
+	block       int    // offset from the first block of the scenario
+	uniqueKey   string // defaults to the block
+	fromAddress string // defaults to harnessContract
+	batches     [][]pixel
+}
+
+type scenario struct {
+	name     string
+	messages []harnessMessage
+	expected map[int]int64 // position ( offset ) -> color, the other positions must be unset
+}
+
+const scenarioPositions = 16
+
+var scenarios = []scenario{
+	{
+		name: "accepted and finalized confirm the pending block",
+		messages: []harnessMessage{
+			{finality: indexer.DATA_STATUS_PENDING, batches: [][]pixel{{{0, 1}, {1, 2}}}},
+			{finality: indexer.DATA_STATUS_ACCEPTED, batches: [][]pixel{{{0, 1}, {1, 2}}}},
+			{finality: indexer.DATA_STATUS_FINALIZED, batches: [][]pixel{{{0, 1}, {1, 2}}}},
+		},
+		expected: map[int]int64{0: 1, 1: 2},
+	},
+	{
+		name: "accepted block drops a pending event",
+		messages: []harnessMessage{
+			{finality: indexer.DATA_STATUS_PENDING, batches: [][]pixel{{{0, 1}, {1, 2}}}},
+			{finality: indexer.DATA_STATUS_ACCEPTED, batches: [][]pixel{{{0, 1}}}},
+		},
+		expected: map[int]int64{0: 1},
+	},
+	{
+		name: "accepted block replaces the tail across batches",
+		messages: []harnessMessage{
+			{finality: indexer.DATA_STATUS_PENDING, batches: [][]pixel{{{0, 1}}, {{1, 2}, {2, 3}}}},
+			{finality: indexer.DATA_STATUS_ACCEPTED, batches: [][]pixel{{{0, 1}}, {{1, 4}}}},
+		},
+		expected: map[int]int64{0: 1, 1: 4},
+	},
+	{
+		name: "pending block updated before being accepted",
+		messages: []harnessMessage{
+			{finality: indexer.DATA_STATUS_PENDING, batches: [][]pixel{{{0, 1}}}},
+			{finality: indexer.DATA_STATUS_PENDING, batches: [][]pixel{{{0, 1}, {1, 2}}}},
+			{finality: indexer.DATA_STATUS_PENDING, batches: [][]pixel{{{1, 3}}}},
+			{finality: indexer.DATA_STATUS_ACCEPTED, batches: [][]pixel{{{1, 3}}}},
+		},
+		expected: map[int]int64{1: 3},
+	},
+	{
+		name: "accepted block on another parent",
+		messages: []harnessMessage{
+			{finality: indexer.DATA_STATUS_PENDING, uniqueKey: "0xa", batches: [][]pixel{{{0, 1}, {1, 2}}}},
+			{finality: indexer.DATA_STATUS_ACCEPTED, uniqueKey: "0xb", batches: [][]pixel{{{0, 1}}}},
+		},
+		expected: map[int]int64{0: 1},
+	},
+	{
+		name: "orphaned pending block",
+		messages: []harnessMessage{
+			{finality: indexer.DATA_STATUS_PENDING, batches: [][]pixel{{{0, 1}}}},
+			{finality: indexer.DATA_STATUS_ACCEPTED, block: 1, batches: [][]pixel{{{1, 2}}}},
+		},
+		expected: map[int]int64{1: 2},
+	},
+	{
+		name: "event from another contract is quarantined",
+		messages: []harnessMessage{
+			{finality: indexer.DATA_STATUS_PENDING, fromAddress: "0xbad", batches: [][]pixel{{{0, 1}}}},
+			{finality: indexer.DATA_STATUS_ACCEPTED, fromAddress: "0xbad", batches: [][]pixel{{{0, 1}}}},
+		},
+		expected: map[int]int64{},
+	},
+}
+
+type harness struct {
+	address       string
+	basePosition  int
+	baseBlock     int
+	canvasKey     string
+	bitfieldWidth uint
+}
+
+func (h *harness) event(fromAddress string, position int, color int64) map[string]interface{} {
+	return map[string]interface{}{
+		"event": map[string]interface{}{
+			"fromAddress": fromAddress,
+			"keys":        []string{pixelPlacedSelector, "0x" + h.address, fmt.Sprintf("0x%x", position), "0x0"},
+			"data":        []string{fmt.Sprintf("0x%x", color)},
+		},
+	}
+}
+
+func (h *harness) send(message harnessMessage, firstPosition int, firstBlock int) error {
+	block := firstBlock + message.block
+	uniqueKey := message.uniqueKey
+	if uniqueKey == "" {
+		uniqueKey = fmt.Sprintf("0x%x", block)
+	}
+	fromAddress := message.fromAddress
+	if fromAddress == "" {
+		fromAddress = harnessContract
+	}
+
+	batches := []interface{}{}
+	for _, batch := range message.batches {
+		events := []interface{}{}
+		for _, p := range batch {
+			events = append(events, h.event(fromAddress, firstPosition+p.position, p.color))
+		}
+		batches = append(batches, map[string]interface{}{"status": message.finality, "events": events})
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{
+			"cursor":     map[string]interface{}{"orderKey": block, "uniqueKey": uniqueKey},
+			"end_cursor": map[string]interface{}{"orderKey": block + 1, "uniqueKey": uniqueKey},
+			"finality":   message.finality,
+			"batch":      batches,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/consume-indexer-msg", bytes.NewReader(body))
+	recorder := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusOK {
+		return fmt.Errorf("consume-indexer-msg returned %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Same priorities as the message processor
+	for indexer.TryProcessFinalizedMessages() || indexer.TryProcessAcceptedMessages() || indexer.TryProcessPendingMessage() {
+	}
+	return nil
+}
+
+func (h *harness) reset(firstPosition int) error {
+	ctx := context.Background()
+	_, err := core.AFKBackend.Databases.Postgres.Exec(ctx, "DELETE FROM Pixels WHERE address = $1 AND position >= $2 AND position < $3", h.address, firstPosition, firstPosition+scenarioPositions)
+	if err != nil {
+		return err
+	}
+	bitfieldType := fmt.Sprintf("u%d", h.bitfieldWidth)
+	for position := firstPosition; position < firstPosition+scenarioPositions; position++ {
+		err := core.AFKBackend.Databases.Redis.BitField(ctx, h.canvasKey, "SET", bitfieldType, uint(position)*h.bitfieldWidth, 0).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type positionColor struct {
+	Position int   `json:"position"`
+	Color    int64 `json:"color"`
+}
+
+type positionCount struct {
+	Position int `json:"position"`
+	Count    int `json:"count"`
+}
+
+func (h *harness) check(s scenario, firstPosition int, firstBlock int, lastBlock int) []string {
+	var failures []string
+
+	rows, err := core.PostgresQuery[positionColor]("SELECT DISTINCT ON (position) position, color FROM Pixels WHERE address = $1 AND position >= $2 AND position < $3 ORDER BY position, block_number DESC, key DESC", h.address, firstPosition, firstPosition+scenarioPositions)
+	if err != nil {
+		return []string{fmt.Sprint("query latest pixels: ", err)}
+	}
+	counts, err := core.PostgresQuery[positionCount]("SELECT position, COUNT(*) AS count FROM Pixels WHERE address = $1 AND position >= $2 AND position < $3 GROUP BY position", h.address, firstPosition, firstPosition+scenarioPositions)
+	if err != nil {
+		return []string{fmt.Sprint("query pixel counts: ", err)}
+	}
+	rowCounts := make(map[int]int)
+	for _, row := range counts {
+		rowCounts[row.Position-firstPosition] = row.Count
+	}
+	latest := make(map[int]int64)
+	for _, row := range rows {
+		latest[row.Position-firstPosition] = row.Color
+	}
+
+	canvas, err := core.AFKBackend.Databases.Redis.Get(context.Background(), h.canvasKey).Bytes()
+	if err != nil {
+		return []string{fmt.Sprint("get canvas: ", err)}
+	}
+
+	for offset := 0; offset < scenarioPositions; offset++ {
+		expectedColor, expected := s.expected[offset]
+		if expected && rowCounts[offset] != 1 {
+			failures = append(failures, fmt.Sprintf("position %d: %d Pixels rows, expected 1", offset, rowCounts[offset]))
+		}
+		if !expected && rowCounts[offset] != 0 {
+			failures = append(failures, fmt.Sprintf("position %d: %d Pixels rows, expected none", offset, rowCounts[offset]))
+		}
+		if expected && latest[offset] != expectedColor {
+			failures = append(failures, fmt.Sprintf("position %d: postgres color %d, expected %d", offset, latest[offset], expectedColor))
+		}
+		redisColor := routeutils.GetCanvasPixelColor(canvas, h.bitfieldWidth, uint(firstPosition+offset))
+		if redisColor != expectedColor {
+			failures = append(failures, fmt.Sprintf("position %d: redis color %d, expected %d", offset, redisColor, expectedColor))
+		}
+	}
+
+	left, err := core.PostgresQuery[int]("SELECT COUNT(*) FROM IndexerMessages WHERE order_key >= $1 AND order_key <= $2 AND finality != $3", firstBlock, lastBlock, indexer.DATA_STATUS_PENDING)
+	if err != nil {
+		return append(failures, fmt.Sprint("query indexer messages: ", err))
+	}
+	if left[0] != 0 {
+		failures = append(failures, fmt.Sprintf("%d accepted / finalized messages left unprocessed", left[0]))
+	}
+	return failures
+}
+
+func main() {
+	godotenv.Load()
+
+	roundsConfigFilename := flag.String("rounds-config", config.DefaultRoundsConfigPath, "Rounds config file")
+	canvasConfigFilename := flag.String("canvas-config", config.DefaultCanvasConfigPath, "Canvas config file")
+	backendConfigFilename := flag.String("backend-config", config.DefaultBackendConfigPath, "Backend config file")
+	scratch := flag.Bool("scratch", false, "Confirm the configured databases are scratch databases")
+	address := flag.String("address", "00000000000000000000000000000000000000000000000000000000000afafa", "Address placing the harness pixels")
+	basePosition := flag.Int("position", -1, "First canvas position used, defaults to the end of the canvas")
+
+	flag.Parse()
+
+	if !*scratch {
+		fmt.Println("The harness writes pixels and moves the indexer cursor, run it with -scratch on scratch databases")
+		os.Exit(2)
+	}
+
+	roundsConfig, err := config.LoadRoundsConfig(*roundsConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	canvasConfig, err := config.LoadCanvasConfig(*canvasConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databaseConfig, err := config.LoadDatabaseConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	backendConfig, err := config.LoadBackendConfig(*backendConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databases := core.NewDatabases(databaseConfig)
+	defer databases.Close()
+
+	core.AFKBackend = core.NewBackend(databases, roundsConfig, canvasConfig, backendConfig, false)
+
+	indexer.InitIndexerRoutes()
+	if err := indexer.LoadIndexerState(); err != nil {
+		panic(err)
+	}
+	if err := indexer.SetContractAddress(indexer.ContractArtPeace, harnessContract); err != nil {
+		panic(err)
+	}
+
+	canvasSize := int(canvasConfig.Canvas.Width * canvasConfig.Canvas.Height)
+	if *basePosition < 0 {
+		*basePosition = canvasSize - len(scenarios)*scenarioPositions
+	}
+	if *basePosition < 0 || *basePosition+len(scenarios)*scenarioPositions > canvasSize {
+		fmt.Println("Harness positions do not fit in the canvas")
+		os.Exit(2)
+	}
+
+	h := &harness{
+		address:       *address,
+		basePosition:  *basePosition,
+		baseBlock:     indexer.GetLastCursor().OrderKey + 1,
+		canvasKey:     fmt.Sprintf("canvas-%s", canvasConfig.Round),
+		bitfieldWidth: canvasConfig.ColorsBitWidth,
+	}
+
+	failed := 0
+	block := h.baseBlock
+	for idx, s := range scenarios {
+		firstPosition := h.basePosition + idx*scenarioPositions
+		if err := h.reset(firstPosition); err != nil {
+			panic(err)
+		}
+
+		lastBlock := block
+		var failures []string
+		for _, message := range s.messages {
+			if err := h.send(message, firstPosition, block); err != nil {
+				failures = append(failures, err.Error())
+				break
+			}
+			lastBlock = max(lastBlock, block+message.block)
+		}
+		if len(failures) == 0 {
+			failures = h.check(s, firstPosition, block, lastBlock)
+		}
+		block = lastBlock + 1
+
+		if len(failures) == 0 {
+			fmt.Println("PASS", s.name)
+			continue
+		}
+		failed++
+		fmt.Println("FAIL", s.name)
+		for _, failure := range failures {
+			fmt.Println("   ", failure)
+		}
+	}
+
+	fmt.Printf("%d/%d scenarios passed\n", len(scenarios)-failed, len(scenarios))
+	if failed > 0 {
+		databases.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
+)
+
+// Rebuilds a redis canvas from the pixels in postgres and prints the differences with the live
+// canvas, -swap replaces the live canvas with the rebuilt one
+//
+//	rebuild-canvas [-world <id>] [-diffs 20] [-swap]
+
+func main() {
+	godotenv.Load()
+
+	roundsConfigFilename := flag.String("rounds-config", config.DefaultRoundsConfigPath, "Rounds config file")
+	canvasConfigFilename := flag.String("canvas-config", config.DefaultCanvasConfigPath, "Canvas config file")
+	backendConfigFilename := flag.String("backend-config", config.DefaultBackendConfigPath, "Backend config file")
+	worldId := flag.String("world", "", "World id, the main canvas if empty")
+	maxDiffs := flag.Int("diffs", 20, "Differences printed")
+	swap := flag.Bool("swap", false, "Replace the redis canvas with the rebuilt one")
+
+	flag.Parse()
+
+	roundsConfig, err := config.LoadRoundsConfig(*roundsConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	canvasConfig, err := config.LoadCanvasConfig(*canvasConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databaseConfig, err := config.LoadDatabaseConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	backendConfig, err := config.LoadBackendConfig(*backendConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databases := core.NewDatabases(databaseConfig)
+	defer databases.Close()
+
+	core.AFKBackend = core.NewBackend(databases, roundsConfig, canvasConfig, backendConfig, false)
+
+	rebuild, err := indexer.RebuildCanvas(*worldId, *swap)
+	if rebuild != nil {
+		if rebuild.RedisMissing {
+			fmt.Println(rebuild.CanvasKey, "is missing from redis")
+		}
+		fmt.Println(rebuild.CanvasKey, ":", rebuild.DiffCount, "of", rebuild.Size, "pixels differ from postgres")
+		for i, diff := range rebuild.Diffs {
+			if i >= *maxDiffs {
+				fmt.Println("  ...")
+				break
+			}
+			fmt.Printf("  position %d: redis %d, postgres %d\n", diff.Position, diff.Redis, diff.Postgres)
+		}
+		if rebuild.Swapped {
+			fmt.Println("Swapped the rebuilt canvas in, re-applied", rebuild.Replayed, "recent pixels")
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Rebuild failed:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/video"
+)
+
+// Renders a timelapse of the current round or a world from the pixel history, like
+// /generate-timelapse, and writes it to the timelapses directory
+//
+//	video-gen [-world <id>] [-format gif|apng] [-every-pixels 100 | -every-seconds 60] [-scale 2]
+
+func main() {
+	godotenv.Load()
+
+	roundsConfigFilename := flag.String("rounds-config", config.DefaultRoundsConfigPath, "Rounds config file")
+	canvasConfigFilename := flag.String("canvas-config", config.DefaultCanvasConfigPath, "Canvas config file")
+	backendConfigFilename := flag.String("backend-config", config.DefaultBackendConfigPath, "Backend config file")
+	worldId := flag.Int("world", -1, "World id, the main canvas if unset")
+	format := flag.String("format", video.FormatGif, "Output format: gif or apng")
+	everyPixels := flag.Int("every-pixels", 0, "Take a frame every N placements")
+	everySeconds := flag.Int("every-seconds", 0, "Take a frame every N seconds of pixel time")
+	scale := flag.Int("scale", 1, "Image pixels per canvas pixel")
+	frameDelayMs := flag.Int("frame-delay", 0, "Frame delay in ms")
+	maxFrames := flag.Int("max-frames", 0, "Maximum frames, the interval is widened to fit")
+
+	flag.Parse()
+
+	roundsConfig, err := config.LoadRoundsConfig(*roundsConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	canvasConfig, err := config.LoadCanvasConfig(*canvasConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databaseConfig, err := config.LoadDatabaseConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	backendConfig, err := config.LoadBackendConfig(*backendConfigFilename)
+	if err != nil {
+		panic(err)
+	}
+
+	databases := core.NewDatabases(databaseConfig)
+	defer databases.Close()
+
+	core.AFKBackend = core.NewBackend(databases, roundsConfig, canvasConfig, backendConfig, false)
+
+	if _, err := video.FailStaleTimelapses(); err != nil {
+		panic(err)
+	}
+
+	history := routeutils.MainCanvasHistory()
+	if *worldId >= 0 {
+		history, err = routeutils.WorldCanvasHistory(*worldId)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "World not found:", err)
+			os.Exit(1)
+		}
+	}
+
+	timelapse, err := video.CreateTimelapse(history, video.TimelapseOptions{
+		Format:       *format,
+		EveryPixels:  *everyPixels,
+		EverySeconds: *everySeconds,
+		Scale:        *scale,
+		FrameDelayMs: *frameDelayMs,
+		MaxFrames:    *maxFrames,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid timelapse:", err)
+		os.Exit(1)
+	}
+
+	if err := video.RunTimelapse(timelapse, history); err != nil {
+		fmt.Fprintln(os.Stderr, "Timelapse failed:", err)
+		os.Exit(1)
+	}
+	fmt.Println(timelapse.CanvasKey, ":", timelapse.Pixels, "pixels in", timelapse.Frames, "frames, written to", filepath.Join(video.TimelapsesDir, timelapse.File))
+}
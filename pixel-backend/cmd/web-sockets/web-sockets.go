@@ -27,6 +27,7 @@ func main() {
 	// databaseConfigFilename := flag.String("database-config", config.DefaultDatabaseConfigPath, "Database config file")
 	backendConfigFilename := flag.String("backend-config", config.DefaultBackendConfigPath, "Backend config file")
 	production := flag.Bool("production", false, "Production mode")
+	metricsAddr := flag.String("metrics-addr", "", "Address serving the /debug/vars metrics, e.g. 127.0.0.1:9100, disabled when empty")
 
 	flag.Parse()
 
@@ -63,7 +64,11 @@ func main() {
 	routes.InitBaseRoutes()
 	routes.InitWebsocketRoutes()
 	go routes.StartWebsocketServer()
+	if core.AFKBackend.BackendConfig.WebSocket.Transport == config.WsTransportRedis {
+		go routes.StartWebsocketSubscriber()
+	}
 
+	core.StartMetrics(*metricsAddr)
 	fmt.Println("Starting websocket server on port", core.AFKBackend.BackendConfig.WsPort)
 	core.AFKBackend.Start(core.AFKBackend.BackendConfig.WsPort)
 }
@@ -32,9 +32,19 @@ type BackendScriptsConfig struct {
 	UnfavoriteStencilDevnet     string `json:"unfavorite_stencil_devnet"`
 }
 
+// Transport between the consumer and the web-sockets servers
+const (
+	WsTransportRedis = "redis" // pub/sub, fans out to every replica
+	WsTransportHttp  = "http"  // POST to a single server at ws_host:ws_port/ws-msg
+)
+
 type WebSocketConfig struct {
-	ReadBufferSize  int `json:"read_buffer_size"`
-	WriteBufferSize int `json:"write_buffer_size"`
+	ReadBufferSize  int    `json:"read_buffer_size"`
+	WriteBufferSize int    `json:"write_buffer_size"`
+	Transport       string `json:"transport"`
+	SendBufferSize  int    `json:"send_buffer_size"` // messages queued per client before it is evicted
+	WriteWait       int    `json:"write_wait"`       // seconds
+	PongWait        int    `json:"pong_wait"`        // seconds
 }
 
 type HttpConfig struct {
@@ -43,6 +53,44 @@ type HttpConfig struct {
 	AllowHeaders []string `json:"allow_headers"`
 }
 
+type AuthConfig struct {
+	DomainName    string `json:"domain_name"`
+	DomainVersion string `json:"domain_version"`
+	ChainId       string `json:"chain_id"`
+	ChallengeTtl  int    `json:"challenge_ttl"`
+	SessionTtl    int    `json:"session_ttl"`
+}
+
+// Quest claims signed by the backend key, set with QUEST_CLAIM_SIGNER_PRIVATE_KEY
+type QuestClaimsConfig struct {
+	ClaimTtl      int `json:"claim_ttl"`      // seconds a signed claim stays valid
+	ClaimInterval int `json:"claim_interval"` // minimum seconds between the claims signed for a user
+}
+
+type AdminKeyConfig struct {
+	Id     string   `json:"id"`
+	Hash   string   `json:"hash"` // sha256 hex of the key secret
+	Scopes []string `json:"scopes"`
+}
+
+type AdminConfig struct {
+	Keys []AdminKeyConfig `json:"keys"`
+}
+
+type StreamContractConfig struct {
+	Address   string   `json:"address"`
+	Selectors []string `json:"selectors"` // empty for every event the indexer handles
+}
+
+// Apibara DNA stream read by the consumer with -source stream, in place of the webhook
+type StreamConfig struct {
+	Url           string                 `json:"url"`
+	Finality      string                 `json:"finality"` // pending, accepted or finalized
+	BatchSize     uint64                 `json:"batch_size"`
+	StartingBlock uint64                 `json:"starting_block"` // used until a cursor is stored
+	Contracts     []StreamContractConfig `json:"contracts"`
+}
+
 type BackendConfig struct {
 	Host         string               `json:"host"`
 	Port         int                  `json:"port"`
@@ -53,6 +101,10 @@ type BackendConfig struct {
 	Production   bool                 `json:"production"`
 	WebSocket    WebSocketConfig      `json:"websocket"`
 	Http         HttpConfig           `json:"http_config"`
+	Auth         AuthConfig           `json:"auth"`
+	Admin        AdminConfig          `json:"admin"`
+	Stream       StreamConfig         `json:"stream"`
+	QuestClaims  QuestClaimsConfig    `json:"quest_claims"`
 }
 
 var DefaultBackendConfig = BackendConfig{
@@ -91,12 +143,32 @@ var DefaultBackendConfig = BackendConfig{
 	WebSocket: WebSocketConfig{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		Transport:       WsTransportRedis,
+		SendBufferSize:  256,
+		WriteWait:       10,
+		PongWait:        60,
 	},
 	Http: HttpConfig{
 		AllowOrigin:  []string{"*"},
 		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders: []string{"Content-Type"},
 	},
+	Auth: AuthConfig{
+		DomainName:    "AFK Pixel",
+		DomainVersion: "1",
+		ChainId:       "SN_SEPOLIA",
+		ChallengeTtl:  300,
+		SessionTtl:    3600,
+	},
+	Stream: StreamConfig{
+		Url:       "http://localhost:7171",
+		Finality:  "accepted",
+		BatchSize: 1,
+	},
+	QuestClaims: QuestClaimsConfig{
+		ClaimTtl:      600,
+		ClaimInterval: 10,
+	},
 }
 
 var DefaultBackendConfigPath = "./configs/backend.config.json"
@@ -1,19 +1,18 @@
 package core
 
 import (
+	"context"
+	"expvar"
 	"fmt"
 	"net/http"
-	"sync"
-
-	"github.com/gorilla/websocket"
+	"time"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
 )
 
 type Backend struct {
-	Databases         *Databases
-	WSConnections     []*websocket.Conn
-	WSConnectionsLock sync.Mutex
+	Databases *Databases
+	WSHub     *WSHub
 
 	RoundsConfig  *config.RoundsConfig
 	CanvasConfig  *config.CanvasConfig
@@ -28,6 +27,7 @@ var AFKBackend *Backend
 func NewBackend(databases *Databases, roundsConfig *config.RoundsConfig, canvasConfig *config.CanvasConfig, backendConfig *config.BackendConfig, adminMode bool) *Backend {
 	return &Backend{
 		Databases:     databases,
+		WSHub:         NewWSHub(backendConfig.WebSocket),
 		RoundsConfig:  roundsConfig,
 		CanvasConfig:  canvasConfig,
 		BackendConfig: backendConfig,
@@ -35,10 +35,61 @@ func NewBackend(databases *Databases, roundsConfig *config.RoundsConfig, canvasC
 	}
 }
 
+// Importing expvar registers /debug/vars on the default mux, the public servers hide it
+// and the metrics are only served on the listener of StartMetrics
+const metricsPath = "/debug/vars"
+
+func publicHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == metricsPath {
+			http.NotFound(w, r)
+			return
+		}
+		http.DefaultServeMux.ServeHTTP(w, r)
+	})
+}
+
+// StartMetrics serves the expvar metrics on their own address, meant to be bound to
+// localhost or a private network, nothing is started when addr is empty
+func StartMetrics(addr string) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, expvar.Handler())
+	go func() {
+		fmt.Println("Serving metrics on", addr+metricsPath)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Println("Metrics server error:", err)
+		}
+	}()
+}
+
 func (b *Backend) Start(port int) {
 	fmt.Println("Listening on port", port)
-	http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+	http.ListenAndServe(fmt.Sprintf(":%d", port), publicHandler())
+	fmt.Println("Port closed")
+}
+
+// StartWithContext serves until ctx is done, then gives in flight requests a few seconds to finish.
+// It returns the listen error when the server could not start or stopped on its own.
+func (b *Backend) StartWithContext(ctx context.Context, port int) error {
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: publicHandler()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Println("Listening on port", port)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		fmt.Println("Server error:", err)
+		return err
+	}
 	fmt.Println("Port closed")
+	return nil
 }
 
 func (b *Backend) GetBackendUrl() string {
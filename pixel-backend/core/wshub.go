@@ -0,0 +1,261 @@
+package core
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+)
+
+// WSHub tracks the web-socket clients, each client has its own writer goroutine fed by
+// a buffered channel so a slow client never blocks a broadcast. A client whose buffer
+// is full is evicted and has to reconnect.
+
+const (
+	defaultWsSendBufferSize = 256
+	defaultWsWriteWait      = 10
+	defaultWsPongWait       = 60
+	defaultWsReadLimit      = 4096
+)
+
+var (
+	wsClientsGauge   = expvar.NewInt("ws_clients")
+	wsEvictedClients = expvar.NewInt("ws_evicted_clients")
+)
+
+type WSHub struct {
+	clients map[*WSClient]bool
+	lock    sync.RWMutex
+
+	sendBufferSize int
+	writeWait      time.Duration
+	pongWait       time.Duration
+	pingPeriod     time.Duration
+}
+
+type WSClient struct {
+	hub  *WSHub
+	conn *websocket.Conn
+	send chan []byte
+
+	// Clients receive every message until their first subscribe
+	filtered      bool
+	subscriptions map[string]*wsTopic
+	subsLock      sync.Mutex
+}
+
+// A topic is a world scope with either all message types or a set of them
+type wsTopic struct {
+	allTypes bool
+	types    map[string]bool
+}
+
+// Topic scopes, other scopes are world ids
+const (
+	WSScopeMain      = ""  // messages without a worldId, ie the main canvas
+	WSScopeAllWorlds = "*" // every message
+)
+
+func NewWSHub(wsConfig config.WebSocketConfig) *WSHub {
+	sendBufferSize := wsConfig.SendBufferSize
+	if sendBufferSize <= 0 {
+		sendBufferSize = defaultWsSendBufferSize
+	}
+	writeWait := wsConfig.WriteWait
+	if writeWait <= 0 {
+		writeWait = defaultWsWriteWait
+	}
+	pongWait := wsConfig.PongWait
+	if pongWait <= 0 {
+		pongWait = defaultWsPongWait
+	}
+
+	return &WSHub{
+		clients:        make(map[*WSClient]bool),
+		sendBufferSize: sendBufferSize,
+		writeWait:      time.Duration(writeWait) * time.Second,
+		pongWait:       time.Duration(pongWait) * time.Second,
+		// Ping before the peer's read deadline expires
+		pingPeriod: time.Duration(pongWait) * time.Second * 9 / 10,
+	}
+}
+
+// Register adds a connection to the hub and starts its writer
+func (h *WSHub) Register(conn *websocket.Conn) *WSClient {
+	client := &WSClient{
+		hub:  h,
+		conn: conn,
+		send: make(chan []byte, h.sendBufferSize),
+
+		subscriptions: make(map[string]*wsTopic),
+	}
+
+	h.lock.Lock()
+	h.clients[client] = true
+	h.lock.Unlock()
+	wsClientsGauge.Add(1)
+
+	go client.writePump()
+	return client
+}
+
+// Unregister removes a client, its writer closes the connection once the buffer is drained
+func (h *WSHub) Unregister(client *WSClient) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+		wsClientsGauge.Add(-1)
+	}
+}
+
+// Clients returns a snapshot of the registered clients
+func (h *WSHub) Clients() []*WSClient {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	clients := make([]*WSClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// Subscribe adds message types to a scope, no types means all of them
+func (c *WSClient) Subscribe(scope string, types []string) {
+	c.subsLock.Lock()
+	defer c.subsLock.Unlock()
+
+	c.filtered = true
+	topic, ok := c.subscriptions[scope]
+	if !ok {
+		topic = &wsTopic{types: make(map[string]bool)}
+		c.subscriptions[scope] = topic
+	}
+	if len(types) == 0 {
+		topic.allTypes = true
+		return
+	}
+	for _, messageType := range types {
+		topic.types[messageType] = true
+	}
+}
+
+// Unsubscribe removes message types from a scope, no types drops the whole scope
+func (c *WSClient) Unsubscribe(scope string, types []string) {
+	c.subsLock.Lock()
+	defer c.subsLock.Unlock()
+
+	c.filtered = true
+	topic, ok := c.subscriptions[scope]
+	if !ok {
+		return
+	}
+	if len(types) == 0 {
+		delete(c.subscriptions, scope)
+		return
+	}
+	for _, messageType := range types {
+		delete(topic.types, messageType)
+	}
+	if !topic.allTypes && len(topic.types) == 0 {
+		delete(c.subscriptions, scope)
+	}
+}
+
+// Wants reports whether a message of the given world scope and type should reach the client
+func (c *WSClient) Wants(scope string, messageType string) bool {
+	c.subsLock.Lock()
+	defer c.subsLock.Unlock()
+
+	if !c.filtered {
+		return true
+	}
+	for _, topicScope := range []string{scope, WSScopeAllWorlds} {
+		topic, ok := c.subscriptions[topicScope]
+		if ok && (topic.allTypes || topic.types[messageType]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Send queues a message for this client only, evicting it if its buffer is full
+func (c *WSClient) Send(message []byte) {
+	c.hub.lock.RLock()
+	_, registered := c.hub.clients[c]
+	queued := false
+	if registered {
+		select {
+		case c.send <- message:
+			queued = true
+		default:
+		}
+	}
+	c.hub.lock.RUnlock()
+
+	if registered && !queued {
+		fmt.Println("Evicting slow websocket client", c.conn.RemoteAddr())
+		wsEvictedClients.Add(1)
+		c.hub.Unregister(c)
+	}
+}
+
+// ReadMessages runs the read loop of the client until the connection fails or
+// the pong deadline is missed, then unregisters it
+func (c *WSClient) ReadMessages(handle func(messageType int, message []byte)) {
+	defer c.hub.Unregister(c)
+
+	c.conn.SetReadLimit(defaultWsReadLimit)
+	c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		c.conn.SetReadDeadline(time.Now().Add(c.hub.pongWait))
+		return nil
+	})
+
+	for {
+		messageType, message, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				fmt.Println("Websocket read error:", err)
+			}
+			return
+		}
+		handle(messageType, message)
+	}
+}
+
+func (c *WSClient) writePump() {
+	ticker := time.NewTicker(c.hub.pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				c.hub.Unregister(c)
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.hub.writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.hub.Unregister(c)
+				return
+			}
+		}
+	}
+}
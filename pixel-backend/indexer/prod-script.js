@@ -4,6 +4,8 @@ export const config = {
   network: "starknet",
   finality: "DATA_STATUS_PENDING",
   filter: {
+    // The consumer stamps the pixels with the block number and timestamp
+    header: { weak: true },
     events: [
       {
         // New Day Event
@@ -284,6 +286,26 @@ export const config = {
         includeReverted: false,
         includeTransaction: false,
         includeReceipt: false
+      },
+      {
+        // Memecoin Created Event
+        fromAddress: Deno.env.get("UNRUGGABLE_FACTORY_CONTRACT_ADDRESS"),
+        keys: [
+          "0x01be539d3a1327d450ab9b7a754f7708ea94f67182f2506217cafff2d694f8e1"
+        ],
+        includeReverted: false,
+        includeTransaction: false,
+        includeReceipt: false
+      },
+      {
+        // Memecoin Launched Event
+        fromAddress: Deno.env.get("UNRUGGABLE_FACTORY_CONTRACT_ADDRESS"),
+        keys: [
+          "0x0257c7875ae0eb2f487e258997d033dde6441d55296281bc727bc1e64b833cfd"
+        ],
+        includeReverted: false,
+        includeTransaction: false,
+        includeReceipt: false
       }
     ]
   },
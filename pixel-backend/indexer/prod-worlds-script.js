@@ -4,6 +4,8 @@ export const config = {
   network: "starknet",
   finality: "DATA_STATUS_PENDING",
   filter: {
+    // The consumer stamps the pixels with the block number and timestamp
+    header: { weak: true },
     events: [
       {
         // Canvas Created Event
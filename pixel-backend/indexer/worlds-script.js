@@ -5,6 +5,8 @@ export const config = {
   // finality: "DATA_STATUS_PENDING",
   finality: "DATA_STATUS_FINALIZED",
   filter: {
+    // The consumer stamps the pixels with the block number and timestamp
+    header: { weak: true },
     events: [
       {
         // Canvas Created Event
@@ -218,6 +220,7 @@ export default function transform(block) {
   return [
     {
       status: "DATA_STATUS_FINALIZED",
+      header: block.header,
       events: events
     }
   ];
@@ -1,11 +1,16 @@
 -- TODO: unique, ... constraints
 
 CREATE TABLE Pixels (
+  -- Insertion order, several pixels of a transaction share the same time
+  key bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
   address char(64) NOT NULL,
   position integer NOT NULL,
   day integer NOT NULL,
   color integer NOT NULL,
   time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  -- Block of the event, block_time is NULL when the indexer sent no block header
+  block_number bigint NOT NULL,
+  block_time timestamp,
   metadata JSONB DEFAULT NULL,
   shield JSONB DEFAULT NULL
 );
@@ -14,6 +19,9 @@ CREATE INDEX pixels_position_index ON Pixels (position);
 CREATE INDEX pixels_day_index ON Pixels (day);
 CREATE INDEX pixels_color_index ON Pixels (color);
 CREATE INDEX pixels_time_index ON Pixels (time);
+CREATE INDEX pixels_block_number_index ON Pixels (block_number);
+-- Latest pixel of a position, in chain order
+CREATE INDEX pixels_latest_index ON Pixels (position, block_number DESC, key DESC);
 
 CREATE TABLE LastPlacedTime (
   address char(64) NOT NULL,
@@ -81,6 +89,13 @@ CREATE INDEX dailyQuestsClaimParams_day_index_index ON DailyQuestsClaimParams (d
 CREATE INDEX dailyQuestsClaimParams_quest_id_index ON DailyQuestsClaimParams (quest_id);
 CREATE INDEX dailyQuestsClaimParams_claim_key_index ON DailyQuestsClaimParams (claim_key);
 
+-- Recurring daily quest templates of the quests config, written into DailyQuests when a day starts
+CREATE TABLE RecurringQuests (
+  template_id integer NOT NULL PRIMARY KEY,
+  config JSONB NOT NULL,
+  placeholders JSONB
+);
+
 -- Table for storing the daily quests that the user has completed
 CREATE TABLE UserDailyQuests (
   -- Postgres auto-incrementing primary key
@@ -172,7 +187,7 @@ CREATE INDEX colorVotes_color_key_index ON ColorVotes (color_key);
 
 CREATE TABLE TemplateData (
   key int PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
-  hash text NOT NULL,
+  hash text NOT NULL UNIQUE,
   data bytea NOT NULL
 );
 
@@ -359,17 +374,22 @@ CREATE INDEX worldFavorites_world_id_index ON WorldFavorites (world_id);
 CREATE INDEX worldFavorites_user_index ON WorldFavorites (user_address);
 
 CREATE TABLE WorldsPixels (
+  key bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
   world_id integer NOT NULL,
   address char(64) NOT NULL,
   position integer NOT NULL,
   color integer NOT NULL,
-  time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+  time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  block_number bigint NOT NULL,
+  block_time timestamp
 );
 CREATE INDEX worldspixels_world_id_index ON WorldsPixels (world_id);
 CREATE INDEX worldspixels_address_index ON WorldsPixels (address);
 CREATE INDEX worldspixels_position_index ON WorldsPixels (position);
 CREATE INDEX worldspixels_color_index ON WorldsPixels (color);
 CREATE INDEX worldspixels_time_index ON WorldsPixels (time);
+CREATE INDEX worldspixels_block_number_index ON WorldsPixels (world_id, block_number);
+CREATE INDEX worldspixels_latest_index ON WorldsPixels (world_id, position, block_number DESC, key DESC);
 
 CREATE TABLE WorldsLastPlacedTime (
   world_id integer NOT NULL,
@@ -422,4 +442,177 @@ CREATE TABLE stencil_images (
 );
 
 CREATE INDEX idx_hash ON stencil_images (hash);
-CREATE INDEX idx_ipfs_hash ON stencil_images (ipfs_hash);
\ No newline at end of file
+CREATE INDEX idx_ipfs_hash ON stencil_images (ipfs_hash);
+
+CREATE TABLE AdminKeys (
+  key_id text NOT NULL PRIMARY KEY,
+  name text NOT NULL,
+  -- sha256 hex of the key secret
+  key_hash char(64) NOT NULL,
+  scopes text[] NOT NULL,
+  created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  rotated_at timestamp,
+  revoked_at timestamp,
+  last_used_at timestamp
+);
+
+CREATE TABLE AdminAuditLog (
+  key int PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+  key_id text,
+  scope text NOT NULL,
+  method text NOT NULL,
+  path text NOT NULL,
+  query text NOT NULL,
+  payload text NOT NULL,
+  authorized boolean NOT NULL,
+  time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX adminAuditLog_key_id_index ON AdminAuditLog (key_id);
+CREATE INDEX adminAuditLog_time_index ON AdminAuditLog (time);
+
+-- Indexer messages received but not processed yet, and the last processed pending message
+CREATE TABLE IndexerMessages (
+  key bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+  finality text NOT NULL,
+  order_key bigint NOT NULL,
+  unique_key text NOT NULL,
+  message JSONB NOT NULL,
+  processed boolean NOT NULL DEFAULT false,
+  received_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX indexerMessages_finality_index ON IndexerMessages (finality);
+
+-- Single row with the cursor of the last processed message
+CREATE TABLE IndexerCursor (
+  id integer PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+  order_key bigint NOT NULL,
+  unique_key text NOT NULL,
+  updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Single row with the end cursor of the last message received from the DNA stream ( stream source )
+CREATE TABLE IndexerStreamCursor (
+  id integer PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+  order_key bigint NOT NULL,
+  unique_key text NOT NULL,
+  updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Redis writes of committed indexer events, removed once applied
+CREATE TABLE RedisOutbox (
+  key bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+  ops JSONB NOT NULL,
+  attempts integer NOT NULL DEFAULT 0,
+  created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexer events without a handler or whose handler failed, kept for inspection and replay
+CREATE TABLE IndexerDeadLetters (
+  key bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+  kind text NOT NULL,
+  processor text NOT NULL,
+  event_key text NOT NULL,
+  from_address text NOT NULL,
+  keys text[] NOT NULL,
+  data text[] NOT NULL,
+  order_key bigint NOT NULL,
+  unique_key text NOT NULL,
+  finality text NOT NULL,
+  block_number bigint NOT NULL,
+  -- Unix seconds, NULL when the event came without its block header
+  block_timestamp bigint,
+  error text NOT NULL,
+  attempts integer NOT NULL DEFAULT 1,
+  status text NOT NULL DEFAULT 'failed',
+  created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  replayed_at timestamp
+);
+CREATE INDEX indexerDeadLetters_status_index ON IndexerDeadLetters (status);
+
+-- Address of each contract whose events are indexed, see routes/indexer/contracts.go
+CREATE TABLE ContractAddresses (
+  name text PRIMARY KEY,
+  address text NOT NULL,
+  updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Indexer events emitted by an address other than the contract handling their selector
+CREATE TABLE IndexerQuarantine (
+  key bigint PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+  event_key text NOT NULL,
+  from_address text NOT NULL,
+  keys text[] NOT NULL,
+  data text[] NOT NULL,
+  -- sha256 of the keys and data, the arrays can be too large for a btree index
+  event_hash text NOT NULL,
+  order_key bigint NOT NULL,
+  unique_key text NOT NULL,
+  finality text NOT NULL,
+  block_number bigint NOT NULL,
+  block_timestamp bigint,
+  reason text NOT NULL,
+  seen integer NOT NULL DEFAULT 1,
+  status text NOT NULL DEFAULT 'quarantined',
+  created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  replayed_at timestamp
+);
+CREATE UNIQUE INDEX indexerQuarantine_event_index ON IndexerQuarantine (unique_key, from_address, event_hash);
+
+-- Snapshots of the canvases built from the pixel history, see routes/utils/history.go
+CREATE TABLE CanvasKeyframes (
+  canvas_key text NOT NULL,
+  -- Last block of the pixels in the keyframe, and the time of its latest pixel
+  block_number bigint NOT NULL,
+  taken_at timestamp NOT NULL,
+  canvas bytea NOT NULL,
+  PRIMARY KEY (canvas_key, block_number)
+);
+
+-- Timelapses rendered from the pixel history, see video/timelapse.go
+CREATE TABLE Timelapses (
+  id integer PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+  canvas_key text NOT NULL,
+  format text NOT NULL,
+  every_pixels integer NOT NULL,
+  every_seconds integer NOT NULL,
+  scale integer NOT NULL,
+  frame_delay_ms integer NOT NULL,
+  max_frames integer NOT NULL,
+  status text NOT NULL DEFAULT 'pending',
+  frames integer NOT NULL DEFAULT 0,
+  pixels integer NOT NULL DEFAULT 0,
+  file text NOT NULL DEFAULT '',
+  error text NOT NULL DEFAULT '',
+  created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  started_at timestamp,
+  finished_at timestamp
+);
+CREATE INDEX timelapses_canvas_key_index ON Timelapses (canvas_key);
+
+-- Addresses allowed to complete an authority quest, filled with /add-quest-allow-list
+CREATE TABLE DailyQuestsAllowList (
+  day_index integer NOT NULL,
+  quest_id integer NOT NULL,
+  user_address char(64) NOT NULL,
+  PRIMARY KEY (day_index, quest_id, user_address)
+);
+
+CREATE TABLE MainQuestsAllowList (
+  quest_id integer NOT NULL,
+  user_address char(64) NOT NULL,
+  PRIMARY KEY (quest_id, user_address)
+);
+
+-- Memecoins from the unruggable factory events, checked by the unruggable quests
+CREATE TABLE Memecoins (
+  address char(64) PRIMARY KEY,
+  owner char(64),
+  name text,
+  symbol text,
+  initial_supply text,
+  launched boolean NOT NULL DEFAULT false,
+  quote_token char(64),
+  exchange_name text,
+  launched_at timestamp
+);
+CREATE INDEX memecoins_owner_index ON Memecoins (owner);
@@ -0,0 +1,314 @@
+package quests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Quests config, as in configs/quests.config.json. initParams are the quest contract constructor
+// params, the ones listed in storeParams are stored as the quest inputs. Params starting with $
+// are placeholders :
+//
+//	$REWARD              the quest reward
+//	$DAY_IDX             the day index of a daily quest ( day - 1 )
+//	$SCALED              the scaled value of a recurring quest for the day
+//	$<NAME>_CONTRACT     the address registered for the contract, see contractPlaceholders
+//	$<NAME>              any entry of the config placeholders
+//
+// Stored params must resolve to integers ( decimal or 0x hex ) fitting the input_value column.
+
+type ClaimParamConfig struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Example string `json:"example"`
+	Input   bool   `json:"input"`
+}
+
+type QuestContractConfig struct {
+	Type        string             `json:"type"`
+	InitParams  []string           `json:"initParams"`
+	StoreParams []int              `json:"storeParams"`
+	ClaimParams []ClaimParamConfig `json:"claimParams"`
+}
+
+type QuestConfig struct {
+	Name           string              `json:"name"`
+	Description    string              `json:"description"`
+	Reward         int                 `json:"reward"`
+	ContractConfig QuestContractConfig `json:"questContract"`
+}
+
+type DailyQuestConfig struct {
+	Day    int           `json:"day"`
+	Quests []QuestConfig `json:"quests"`
+}
+
+// Value of a recurring quest for a day, base + perDay * ( day - startDay ) capped at max if set
+type QuestScaleConfig struct {
+	Base   int `json:"base"`
+	PerDay int `json:"perDay"`
+	Max    int `json:"max"`
+}
+
+// Daily quest written for every day from startDay ( default 1 ) to endDay ( 0 for no end ) when
+// the day starts. {day} and {scaled} in the name and description are replaced.
+type RecurringQuestConfig struct {
+	QuestConfig
+	StartDay int              `json:"startDay"`
+	EndDay   int              `json:"endDay"`
+	Scale    QuestScaleConfig `json:"scale"`
+}
+
+type QuestsConfig struct {
+	Placeholders map[string]string `json:"placeholders"`
+	DailyQuests  struct {
+		DailyQuestsCount int                    `json:"dailyQuestsCount"`
+		Quests           []DailyQuestConfig     `json:"dailyQuests"`
+		Recurring        []RecurringQuestConfig `json:"recurring"`
+	} `json:"daily"`
+	MainQuests struct {
+		Quests []QuestConfig `json:"mainQuests"`
+	} `json:"main"`
+}
+
+// Contract placeholders and the ContractAddresses name they resolve to
+var contractPlaceholders = map[string]string{
+	"$ART_PEACE_CONTRACT":      "art-peace",
+	"$CANVAS_FACTORY_CONTRACT": "canvas-factory",
+	"$USERNAME_STORE_CONTRACT": "username-store",
+	"$CANVAS_NFT_CONTRACT":     "canvas-nft",
+	"$UNRUGGABLE_CONTRACT":     "unruggable-factory",
+}
+
+var claimParamTypes = map[string]bool{
+	"int":     true,
+	"address": true,
+	"felt":    true,
+}
+
+// QuestDefinition is a quest of the config with its params resolved, as stored in postgres
+type QuestDefinition struct {
+	IsDaily     bool               `json:"isDaily"`
+	DayIndex    int                `json:"dayIndex"`
+	QuestId     int                `json:"questId"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Reward      int                `json:"reward"`
+	QuestType   string             `json:"questType"`
+	Inputs      []int              `json:"inputs"`
+	ClaimParams []ClaimParamConfig `json:"claimParams"`
+}
+
+// QuestConfigErrors lists every problem found in a config
+type QuestConfigErrors []string
+
+func (e QuestConfigErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+func LoadQuestsConfig(path string) (*QuestsConfig, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := QuestsConfig{}
+	if err := json.Unmarshal(file, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Resolve validates the config and returns its quests, contracts maps contract names to their
+// registered address. The error is a QuestConfigErrors when the config is invalid.
+func (c *QuestsConfig) Resolve(contracts map[string]string) ([]QuestDefinition, error) {
+	errs := QuestConfigErrors{}
+	definitions := make([]QuestDefinition, 0)
+
+	days := make(map[int]bool)
+	for _, daily := range c.DailyQuests.Quests {
+		path := fmt.Sprintf("daily day %d", daily.Day)
+		if daily.Day < 1 {
+			errs = append(errs, path+": day must start at 1")
+			continue
+		}
+		if days[daily.Day] {
+			errs = append(errs, path+": day listed twice")
+			continue
+		}
+		days[daily.Day] = true
+		if c.DailyQuests.DailyQuestsCount > 0 && len(daily.Quests) > c.DailyQuests.DailyQuestsCount {
+			errs = append(errs, fmt.Sprintf("%s: %d quests, more than dailyQuestsCount %d", path, len(daily.Quests), c.DailyQuests.DailyQuestsCount))
+		}
+
+		if len(daily.Quests) > RecurringQuestIdOffset {
+			errs = append(errs, fmt.Sprintf("%s: more than %d quests", path, RecurringQuestIdOffset))
+		}
+
+		for idx, quest := range daily.Quests {
+			definition := QuestDefinition{IsDaily: true, DayIndex: daily.Day - 1, QuestId: idx}
+			errs = append(errs, c.resolveQuest(&definition, quest, contracts, nil, fmt.Sprintf("%s quest %d", path, idx))...)
+			definitions = append(definitions, definition)
+		}
+	}
+
+	// Recurring quests are resolved for their first day to check them
+	for idx, recurring := range c.DailyQuests.Recurring {
+		path := fmt.Sprintf("recurring quest %d", idx)
+		if recurring.StartDay < 0 || (recurring.EndDay != 0 && recurring.EndDay < recurring.StartDay) {
+			errs = append(errs, path+": invalid day range")
+			continue
+		}
+		if recurring.Scale.Max < 0 {
+			errs = append(errs, path+": negative scale max")
+		}
+		_, recurringErrs := c.resolveRecurringQuest(recurring, idx, recurring.firstDay()-1, contracts)
+		errs = append(errs, recurringErrs...)
+	}
+
+	for idx, quest := range c.MainQuests.Quests {
+		definition := QuestDefinition{QuestId: idx}
+		errs = append(errs, c.resolveQuest(&definition, quest, contracts, nil, fmt.Sprintf("main quest %d", idx))...)
+		definitions = append(definitions, definition)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return definitions, nil
+}
+
+func (r *RecurringQuestConfig) firstDay() int {
+	if r.StartDay < 1 {
+		return 1
+	}
+	return r.StartDay
+}
+
+// ActiveOn tells if the quest is written for the day index
+func (r *RecurringQuestConfig) ActiveOn(dayIndex int) bool {
+	day := dayIndex + 1
+	return day >= r.firstDay() && (r.EndDay == 0 || day <= r.EndDay)
+}
+
+// ScaledValue is the $SCALED value for the day index
+func (r *RecurringQuestConfig) ScaledValue(dayIndex int) int {
+	value := r.Scale.Base + r.Scale.PerDay*(dayIndex+1-r.firstDay())
+	if r.Scale.Max > 0 && value > r.Scale.Max {
+		value = r.Scale.Max
+	}
+	return value
+}
+
+// resolveRecurringQuest resolves the recurring quest idx for a day, its quest id follows
+// RecurringQuestIdOffset
+func (c *QuestsConfig) resolveRecurringQuest(recurring RecurringQuestConfig, idx int, dayIndex int, contracts map[string]string) (QuestDefinition, []string) {
+	scaled := strconv.Itoa(recurring.ScaledValue(dayIndex))
+	day := strconv.Itoa(dayIndex + 1)
+	quest := recurring.QuestConfig
+	quest.Name = strings.NewReplacer("{day}", day, "{scaled}", scaled).Replace(quest.Name)
+	quest.Description = strings.NewReplacer("{day}", day, "{scaled}", scaled).Replace(quest.Description)
+
+	definition := QuestDefinition{IsDaily: true, DayIndex: dayIndex, QuestId: RecurringQuestIdOffset + idx}
+	errs := c.resolveQuest(&definition, quest, contracts, map[string]string{"$SCALED": scaled}, fmt.Sprintf("recurring quest %d day %s", idx, day))
+	return definition, errs
+}
+
+func (c *QuestsConfig) resolveQuest(definition *QuestDefinition, quest QuestConfig, contracts map[string]string, vars map[string]string, path string) []string {
+	errs := make([]string, 0)
+	definition.Name = quest.Name
+	definition.Description = quest.Description
+	definition.Reward = quest.Reward
+	definition.QuestType = quest.ContractConfig.Type
+	definition.Inputs = make([]int, 0, len(quest.ContractConfig.StoreParams))
+	definition.ClaimParams = quest.ContractConfig.ClaimParams
+	if definition.ClaimParams == nil {
+		definition.ClaimParams = []ClaimParamConfig{}
+	}
+
+	if quest.Name == "" {
+		errs = append(errs, path+": missing name")
+	}
+	if quest.Reward < 0 {
+		errs = append(errs, path+": negative reward")
+	}
+	questType, ok := OnchainQuestTypes[quest.ContractConfig.Type]
+	if !ok {
+		errs = append(errs, fmt.Sprintf("%s: unknown quest type %q", path, quest.ContractConfig.Type))
+	}
+
+	resolved := make([]string, len(quest.ContractConfig.InitParams))
+	invalid := make(map[int]bool)
+	for idx, param := range quest.ContractConfig.InitParams {
+		value, err := c.resolveParam(param, definition, contracts, vars)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: init param %d: %s", path, idx, err))
+			invalid[idx] = true
+		}
+		resolved[idx] = value
+	}
+
+	for _, storeParam := range quest.ContractConfig.StoreParams {
+		if storeParam < 0 || storeParam >= len(resolved) {
+			errs = append(errs, fmt.Sprintf("%s: store param %d out of the %d init params", path, storeParam, len(resolved)))
+			continue
+		}
+		if invalid[storeParam] {
+			continue
+		}
+		if resolved[storeParam] == "" {
+			errs = append(errs, fmt.Sprintf("%s: store param %d ( %s ) has no value", path, storeParam, quest.ContractConfig.InitParams[storeParam]))
+			continue
+		}
+		value, err := strconv.ParseInt(resolved[storeParam], 0, 64)
+		if err != nil || value < math.MinInt32 || value > math.MaxInt32 {
+			errs = append(errs, fmt.Sprintf("%s: store param %d ( %s ) is not a 32 bit integer", path, storeParam, quest.ContractConfig.InitParams[storeParam]))
+			continue
+		}
+		definition.Inputs = append(definition.Inputs, int(value))
+	}
+	if ok && len(definition.Inputs) < QuestInputsNeeded[questType] {
+		errs = append(errs, fmt.Sprintf("%s: %s needs %d stored params, got %d", path, quest.ContractConfig.Type, QuestInputsNeeded[questType], len(definition.Inputs)))
+	}
+
+	for idx, claimParam := range quest.ContractConfig.ClaimParams {
+		if !claimParamTypes[claimParam.Type] {
+			errs = append(errs, fmt.Sprintf("%s: claim param %d has unknown type %q", path, idx, claimParam.Type))
+		}
+		if claimParam.Name == "" {
+			errs = append(errs, fmt.Sprintf("%s: claim param %d is missing a name", path, idx))
+		}
+	}
+	return errs
+}
+
+func (c *QuestsConfig) resolveParam(param string, definition *QuestDefinition, contracts map[string]string, vars map[string]string) (string, error) {
+	if !strings.HasPrefix(param, "$") {
+		return param, nil
+	}
+	if value, ok := vars[param]; ok {
+		return value, nil
+	}
+
+	switch param {
+	case "$REWARD":
+		return strconv.Itoa(definition.Reward), nil
+	case "$DAY_IDX":
+		if !definition.IsDaily {
+			return "", fmt.Errorf("$DAY_IDX in a main quest")
+		}
+		return strconv.Itoa(definition.DayIndex), nil
+	}
+	if value, ok := c.Placeholders[strings.TrimPrefix(param, "$")]; ok {
+		return value, nil
+	}
+	if name, ok := contractPlaceholders[param]; ok {
+		// Empty when not registered, only an error for stored params
+		return contracts[name], nil
+	}
+	return "", fmt.Errorf("unknown placeholder %s", param)
+}
@@ -0,0 +1,169 @@
+package quests
+
+import (
+	"strings"
+	"testing"
+)
+
+func testContracts() map[string]string {
+	contracts := make(map[string]string)
+	for _, name := range contractPlaceholders {
+		contracts[name] = "0x1"
+	}
+	return contracts
+}
+
+func TestResolveShippedConfigs(t *testing.T) {
+	for _, path := range []string{"../configs/quests.config.json", "../configs/production-quests.config.json"} {
+		config, err := LoadQuestsConfig(path)
+		if err != nil {
+			t.Fatalf("LoadQuestsConfig(%s): %v", path, err)
+		}
+		if _, err := config.Resolve(testContracts()); err != nil {
+			t.Errorf("Resolve(%s): %v", path, err)
+		}
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	config := &QuestsConfig{Placeholders: map[string]string{"PIXELS": "0x14"}}
+	config.DailyQuests.Quests = []DailyQuestConfig{{
+		Day: 3,
+		Quests: []QuestConfig{{
+			Name:   "Vote",
+			Reward: 5,
+			ContractConfig: QuestContractConfig{
+				Type:        "VoteQuest",
+				InitParams:  []string{"$ART_PEACE_CONTRACT", "$REWARD", "$DAY_IDX"},
+				StoreParams: []int{2},
+			},
+		}},
+	}}
+	config.MainQuests.Quests = []QuestConfig{{
+		Name:   "Pixels",
+		Reward: 10,
+		ContractConfig: QuestContractConfig{
+			Type:        "PixelQuest",
+			InitParams:  []string{"$ART_PEACE_CONTRACT", "$REWARD", "$PIXELS", "0", "0", "0", "0"},
+			StoreParams: []int{2, 3, 4, 5, 6},
+		},
+	}}
+
+	definitions, err := config.Resolve(testContracts())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(definitions) != 2 {
+		t.Fatalf("%d definitions, want 2", len(definitions))
+	}
+
+	daily := definitions[0]
+	if !daily.IsDaily || daily.DayIndex != 2 || daily.QuestId != 0 || daily.QuestType != "VoteQuest" {
+		t.Errorf("daily = %+v", daily)
+	}
+	if len(daily.Inputs) != 1 || daily.Inputs[0] != 2 {
+		t.Errorf("daily inputs = %v, want the day index [2]", daily.Inputs)
+	}
+	main := definitions[1]
+	if main.IsDaily || len(main.Inputs) != 5 || main.Inputs[0] != 20 {
+		t.Errorf("main = %+v, want 5 inputs starting with 20", main)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	quest := func(questType string, initParams []string, storeParams []int) QuestConfig {
+		return QuestConfig{Name: "Quest", ContractConfig: QuestContractConfig{Type: questType, InitParams: initParams, StoreParams: storeParams}}
+	}
+	cases := []struct {
+		name  string
+		quest QuestConfig
+		want  string
+	}{
+		{"unknown type", quest("MissingQuest", nil, nil), "unknown quest type"},
+		{"store param out of range", quest("HodlQuest", []string{"1"}, []int{1}), "out of the 1 init params"},
+		{"unregistered contract", quest("HodlQuest", []string{"$CANVAS_NFT_CONTRACT"}, []int{0}), "has no value"},
+		{"day index in a main quest", quest("HodlQuest", []string{"$DAY_IDX"}, []int{0}), "$DAY_IDX in a main quest"},
+		{"unknown placeholder", quest("HodlQuest", []string{"$MISSING"}, []int{0}), "unknown placeholder"},
+		{"not an integer", quest("HodlQuest", []string{"abc"}, []int{0}), "not a 32 bit integer"},
+		{"missing inputs", quest("PixelQuest", []string{"1"}, []int{0}), "needs 5 stored params"},
+		// An invalid stored param leaves the quest short of inputs
+		{"invalid input counted", quest("HodlQuest", []string{"0x1" + strings.Repeat("0", 10)}, []int{0}), "needs 1 stored params, got 0"},
+	}
+	for _, c := range cases {
+		config := &QuestsConfig{}
+		config.MainQuests.Quests = []QuestConfig{c.quest}
+		_, err := config.Resolve(map[string]string{})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: error = %v, want %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestResolveDailyDays(t *testing.T) {
+	vote := QuestConfig{Name: "Vote", ContractConfig: QuestContractConfig{Type: "VoteQuest", InitParams: []string{"$DAY_IDX"}, StoreParams: []int{0}}}
+	config := &QuestsConfig{}
+	config.DailyQuests.DailyQuestsCount = 1
+	config.DailyQuests.Quests = []DailyQuestConfig{
+		{Day: 0, Quests: []QuestConfig{vote}},
+		{Day: 1, Quests: []QuestConfig{vote, vote}},
+		{Day: 1, Quests: []QuestConfig{vote}},
+	}
+	_, err := config.Resolve(map[string]string{})
+	if err == nil {
+		t.Fatalf("expected errors")
+	}
+	for _, want := range []string{"day must start at 1", "more than dailyQuestsCount 1", "day listed twice"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v, want %q", err, want)
+		}
+	}
+}
+
+func TestRecurringQuest(t *testing.T) {
+	recurring := RecurringQuestConfig{
+		QuestConfig: QuestConfig{
+			Name:   "Place {scaled} pixels on day {day}",
+			Reward: 3,
+			ContractConfig: QuestContractConfig{
+				Type:        "PixelQuest",
+				InitParams:  []string{"$SCALED", "1", "$DAY_IDX", "0", "0"},
+				StoreParams: []int{0, 1, 2, 3, 4},
+			},
+		},
+		StartDay: 2,
+		EndDay:   5,
+		Scale:    QuestScaleConfig{Base: 10, PerDay: 5, Max: 20},
+	}
+
+	for dayIndex, want := range map[int]bool{0: false, 1: true, 4: true, 5: false} {
+		if got := recurring.ActiveOn(dayIndex); got != want {
+			t.Errorf("ActiveOn(%d) = %v, want %v", dayIndex, got, want)
+		}
+	}
+	for dayIndex, want := range map[int]int{1: 10, 2: 15, 3: 20, 4: 20} {
+		if got := recurring.ScaledValue(dayIndex); got != want {
+			t.Errorf("ScaledValue(%d) = %d, want %d", dayIndex, got, want)
+		}
+	}
+
+	config := &QuestsConfig{}
+	definition, errs := config.resolveRecurringQuest(recurring, 1, 2, map[string]string{})
+	if len(errs) > 0 {
+		t.Fatalf("resolveRecurringQuest: %v", errs)
+	}
+	if definition.QuestId != RecurringQuestIdOffset+1 || definition.DayIndex != 2 || !definition.IsDaily {
+		t.Errorf("definition = %+v", definition)
+	}
+	if definition.Name != "Place 15 pixels on day 3" {
+		t.Errorf("name = %q", definition.Name)
+	}
+	if len(definition.Inputs) != 5 || definition.Inputs[0] != 15 || definition.Inputs[2] != 2 {
+		t.Errorf("inputs = %v, want [15 1 2 0 0]", definition.Inputs)
+	}
+
+	recurring.EndDay = 1
+	config.DailyQuests.Recurring = []RecurringQuestConfig{recurring}
+	if _, err := config.Resolve(map[string]string{}); err == nil || !strings.Contains(err.Error(), "invalid day range") {
+		t.Errorf("error = %v, want an invalid day range", err)
+	}
+}
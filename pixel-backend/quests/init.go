@@ -0,0 +1,325 @@
+package quests
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Quest initialization compares the config with the stored quests and only writes the quests
+// that are new or changed, all in one transaction. Quests are identified by their position in
+// the config ( day and index for daily quests, index for main quests ), stored quests missing
+// from the config are reported but kept since users may have claimed them.
+
+const (
+	QuestChangeCreate    = "create"
+	QuestChangeUpdate    = "update"
+	QuestChangeUnchanged = "unchanged"
+	QuestChangeOrphaned  = "orphaned"
+)
+
+type QuestChange struct {
+	Action   string           `json:"action"`
+	IsDaily  bool             `json:"isDaily"`
+	DayIndex int              `json:"dayIndex"`
+	QuestId  int              `json:"questId"`
+	Name     string           `json:"name"`
+	Fields   []string         `json:"fields,omitempty"` // changed fields of an update
+	Quest    *QuestDefinition `json:"quest,omitempty"`  // config quest of a create / update
+}
+
+type QuestInitPlan struct {
+	DryRun       bool          `json:"dryRun"`
+	Created      int           `json:"created"`
+	Updated      int           `json:"updated"`
+	Unchanged    int           `json:"unchanged"`
+	Orphaned     int           `json:"orphaned"`
+	Recurring    int           `json:"recurring"`    // recurring quests stored
+	Materialized int           `json:"materialized"` // recurring quests written for the current day
+	Changes      []QuestChange `json:"changes"`
+}
+
+type questKey struct {
+	isDaily  bool
+	dayIndex int
+	questId  int
+}
+
+type storedQuestRow struct {
+	DayIndex    int    `json:"dayIndex"`
+	QuestId     int    `json:"questId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Reward      int    `json:"reward"`
+	QuestType   string `json:"questType"`
+}
+
+type storedInputRow struct {
+	DayIndex   int `json:"dayIndex"`
+	QuestId    int `json:"questId"`
+	InputValue int `json:"inputValue"`
+}
+
+type storedClaimParamRow struct {
+	DayIndex  int    `json:"dayIndex"`
+	QuestId   int    `json:"questId"`
+	ClaimType string `json:"claimType"`
+	Name      string `json:"name"`
+	Example   string `json:"example"`
+	Input     bool   `json:"input"`
+}
+
+// loadStoredQuests reads the daily and main quests in the same shape as the config
+func loadStoredQuests(ctx context.Context, tx pgx.Tx) (map[questKey]*QuestDefinition, []questKey, error) {
+	stored := make(map[questKey]*QuestDefinition)
+	order := make([]questKey, 0)
+
+	for _, isDaily := range []bool{true, false} {
+		// Recurring quests are written per day from their template, not part of the diff
+		authored := " WHERE quest_id < " + strconv.Itoa(RecurringQuestIdOffset)
+		questsQuery := "SELECT day_index, quest_id, name, description, reward, quest_type FROM DailyQuests" + authored + " ORDER BY day_index, quest_id"
+		inputsQuery := "SELECT day_index, quest_id, input_value FROM DailyQuestsInput" + authored + " ORDER BY day_index, quest_id, input_key"
+		claimParamsQuery := "SELECT day_index, quest_id, claim_type, name, COALESCE(example, '') AS example, input FROM DailyQuestsClaimParams" + authored + " ORDER BY day_index, quest_id, claim_key"
+		if !isDaily {
+			questsQuery = "SELECT 0 AS day_index, key - 1 AS quest_id, name, description, reward, quest_type FROM MainQuests ORDER BY key"
+			inputsQuery = "SELECT 0 AS day_index, quest_id, input_value FROM MainQuestsInput ORDER BY quest_id, input_key"
+			claimParamsQuery = "SELECT 0 AS day_index, quest_id, claim_type, name, COALESCE(example, '') AS example, input FROM MainQuestsClaimParams ORDER BY quest_id, claim_key"
+		}
+
+		var questRows []storedQuestRow
+		if err := pgxscan.Select(ctx, tx, &questRows, questsQuery); err != nil {
+			return nil, nil, err
+		}
+		for _, row := range questRows {
+			key := questKey{isDaily: isDaily, dayIndex: row.DayIndex, questId: row.QuestId}
+			stored[key] = &QuestDefinition{
+				IsDaily:     isDaily,
+				DayIndex:    row.DayIndex,
+				QuestId:     row.QuestId,
+				Name:        row.Name,
+				Description: row.Description,
+				Reward:      row.Reward,
+				QuestType:   row.QuestType,
+				Inputs:      []int{},
+				ClaimParams: []ClaimParamConfig{},
+			}
+			order = append(order, key)
+		}
+
+		var inputRows []storedInputRow
+		if err := pgxscan.Select(ctx, tx, &inputRows, inputsQuery); err != nil {
+			return nil, nil, err
+		}
+		for _, row := range inputRows {
+			if quest, ok := stored[questKey{isDaily: isDaily, dayIndex: row.DayIndex, questId: row.QuestId}]; ok {
+				quest.Inputs = append(quest.Inputs, row.InputValue)
+			}
+		}
+
+		var claimParamRows []storedClaimParamRow
+		if err := pgxscan.Select(ctx, tx, &claimParamRows, claimParamsQuery); err != nil {
+			return nil, nil, err
+		}
+		for _, row := range claimParamRows {
+			if quest, ok := stored[questKey{isDaily: isDaily, dayIndex: row.DayIndex, questId: row.QuestId}]; ok {
+				quest.ClaimParams = append(quest.ClaimParams, ClaimParamConfig{Type: row.ClaimType, Name: row.Name, Example: row.Example, Input: row.Input})
+			}
+		}
+	}
+	return stored, order, nil
+}
+
+func changedQuestFields(stored *QuestDefinition, quest *QuestDefinition) []string {
+	fields := make([]string, 0)
+	if stored.Name != quest.Name {
+		fields = append(fields, "name")
+	}
+	if stored.Description != quest.Description {
+		fields = append(fields, "description")
+	}
+	if stored.Reward != quest.Reward {
+		fields = append(fields, "reward")
+	}
+	if stored.QuestType != quest.QuestType {
+		fields = append(fields, "questType")
+	}
+	if !reflect.DeepEqual(stored.Inputs, quest.Inputs) {
+		fields = append(fields, "inputs")
+	}
+	if !reflect.DeepEqual(stored.ClaimParams, quest.ClaimParams) {
+		fields = append(fields, "claimParams")
+	}
+	return fields
+}
+
+func contractAddresses(ctx context.Context, tx pgx.Tx) (map[string]string, error) {
+	type contractAddress struct {
+		Name    string `json:"name"`
+		Address string `json:"address"`
+	}
+	var rows []contractAddress
+	if err := pgxscan.Select(ctx, tx, &rows, "SELECT name, address FROM ContractAddresses"); err != nil {
+		return nil, err
+	}
+	contracts := make(map[string]string, len(rows))
+	for _, row := range rows {
+		contracts[row.Name] = row.Address
+	}
+	return contracts, nil
+}
+
+// InitQuests validates the config and plans the quest changes, then writes them unless dryRun.
+// Concurrent inits are serialized on the quest tables.
+func InitQuests(config *QuestsConfig, dryRun bool) (*QuestInitPlan, error) {
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if !dryRun {
+		_, err = tx.Exec(ctx, "LOCK TABLE DailyQuests, MainQuests IN SHARE ROW EXCLUSIVE MODE")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	contracts, err := contractAddresses(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	definitions, err := config.Resolve(contracts)
+	if err != nil {
+		return nil, err
+	}
+	stored, storedOrder, err := loadStoredQuests(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &QuestInitPlan{DryRun: dryRun, Changes: make([]QuestChange, 0)}
+	inConfig := make(map[questKey]bool, len(definitions))
+	for idx := range definitions {
+		quest := &definitions[idx]
+		key := questKey{isDaily: quest.IsDaily, dayIndex: quest.DayIndex, questId: quest.QuestId}
+		inConfig[key] = true
+
+		change := QuestChange{IsDaily: quest.IsDaily, DayIndex: quest.DayIndex, QuestId: quest.QuestId, Name: quest.Name}
+		if current, ok := stored[key]; !ok {
+			change.Action = QuestChangeCreate
+			change.Quest = quest
+			plan.Created++
+		} else if fields := changedQuestFields(current, quest); len(fields) > 0 {
+			change.Action = QuestChangeUpdate
+			change.Fields = fields
+			change.Quest = quest
+			plan.Updated++
+		} else {
+			change.Action = QuestChangeUnchanged
+			plan.Unchanged++
+		}
+		plan.Changes = append(plan.Changes, change)
+
+		if dryRun || change.Action == QuestChangeUnchanged {
+			continue
+		}
+		if err := writeQuest(ctx, tx, quest); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range storedOrder {
+		if inConfig[key] {
+			continue
+		}
+		quest := stored[key]
+		plan.Changes = append(plan.Changes, QuestChange{Action: QuestChangeOrphaned, IsDaily: quest.IsDaily, DayIndex: quest.DayIndex, QuestId: quest.QuestId, Name: quest.Name})
+		plan.Orphaned++
+	}
+
+	plan.Recurring = len(config.DailyQuests.Recurring)
+	if dryRun {
+		return plan, nil
+	}
+
+	if err := storeRecurringQuests(ctx, tx, config); err != nil {
+		return nil, err
+	}
+	// Later days are written when they start
+	var currentDay *int
+	if err := tx.QueryRow(ctx, "SELECT MAX(day_index) FROM Days").Scan(&currentDay); err != nil {
+		return nil, err
+	}
+	if currentDay != nil {
+		plan.Materialized, err = materializeRecurringQuests(ctx, tx, *currentDay)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// writeQuest upserts the quest and replaces its inputs and claim params
+func writeQuest(ctx context.Context, tx pgx.Tx, quest *QuestDefinition) error {
+	var err error
+	if quest.IsDaily {
+		_, err = tx.Exec(ctx, "INSERT INTO DailyQuests (name, description, reward, day_index, quest_id, quest_type) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (day_index, quest_id) DO UPDATE SET name = $1, description = $2, reward = $3, quest_type = $6", quest.Name, quest.Description, quest.Reward, quest.DayIndex, quest.QuestId, quest.QuestType)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, "DELETE FROM DailyQuestsInput WHERE day_index = $1 AND quest_id = $2", quest.DayIndex, quest.QuestId); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, "DELETE FROM DailyQuestsClaimParams WHERE day_index = $1 AND quest_id = $2", quest.DayIndex, quest.QuestId); err != nil {
+			return err
+		}
+		for idx, input := range quest.Inputs {
+			_, err = tx.Exec(ctx, "INSERT INTO DailyQuestsInput (day_index, quest_id, input_key, input_value) VALUES ($1, $2, $3, $4)", quest.DayIndex, quest.QuestId, idx, input)
+			if err != nil {
+				return err
+			}
+		}
+		for idx, claimParam := range quest.ClaimParams {
+			_, err = tx.Exec(ctx, "INSERT INTO DailyQuestsClaimParams (day_index, quest_id, claim_key, claim_type, name, example, input) VALUES ($1, $2, $3, $4, $5, $6, $7)", quest.DayIndex, quest.QuestId, idx, claimParam.Type, claimParam.Name, claimParam.Example, claimParam.Input)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Main quest ids are key - 1
+	_, err = tx.Exec(ctx, "INSERT INTO MainQuests (key, name, description, reward, quest_type) OVERRIDING SYSTEM VALUE VALUES ($1, $2, $3, $4, $5) ON CONFLICT (key) DO UPDATE SET name = $2, description = $3, reward = $4, quest_type = $5", quest.QuestId+1, quest.Name, quest.Description, quest.Reward, quest.QuestType)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, "DELETE FROM MainQuestsInput WHERE quest_id = $1", quest.QuestId); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, "DELETE FROM MainQuestsClaimParams WHERE quest_id = $1", quest.QuestId); err != nil {
+		return err
+	}
+	for idx, input := range quest.Inputs {
+		_, err = tx.Exec(ctx, "INSERT INTO MainQuestsInput (quest_id, input_key, input_value) VALUES ($1, $2, $3)", quest.QuestId, idx, input)
+		if err != nil {
+			return err
+		}
+	}
+	for idx, claimParam := range quest.ClaimParams {
+		_, err = tx.Exec(ctx, "INSERT INTO MainQuestsClaimParams (quest_id, claim_key, claim_type, name, example, input) VALUES ($1, $2, $3, $4, $5, $6)", quest.QuestId, idx, claimParam.Type, claimParam.Name, claimParam.Example, claimParam.Input)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,5 +1,15 @@
 package quests
 
+// Stored params read by the quest checks, quests with fewer are rejected by the config
+var QuestInputsNeeded = map[int]int{
+	HodlQuestType:     1,
+	NFTMintQuestType:  2,
+	PixelQuestType:    5,
+	TemplateQuestType: 1,
+	VoteQuestType:     1,
+	StreakQuestType:   1,
+}
+
 type PixelQuestInputs struct {
 	PixelsNeeded uint32
 	IsDaily      bool
@@ -21,6 +31,15 @@ type NFTQuestInputs struct {
 	ClaimDay uint32
 }
 
+type StreakQuestInputs struct {
+	DaysNeeded int
+}
+
+type TemplateQuestInputs struct {
+	TemplateId    int
+	PercentNeeded int
+}
+
 func NewPixelQuestInputs(encodedInputs []int) *PixelQuestInputs {
 	return &PixelQuestInputs{
 		PixelsNeeded: uint32(encodedInputs[0]),
@@ -49,3 +68,24 @@ func NewNFTQuestInputs(encodedInputs []int) *NFTQuestInputs {
 		ClaimDay: uint32(encodedInputs[1]),
 	}
 }
+
+// NewTemplateQuestInputs reads [templateId, percentNeeded], the percent defaults to 100
+func NewTemplateQuestInputs(encodedInputs []int) *TemplateQuestInputs {
+	inputs := &TemplateQuestInputs{
+		TemplateId:    -1,
+		PercentNeeded: 100,
+	}
+	if len(encodedInputs) > 0 {
+		inputs.TemplateId = encodedInputs[0]
+	}
+	if len(encodedInputs) > 1 && encodedInputs[1] > 0 && encodedInputs[1] <= 100 {
+		inputs.PercentNeeded = encodedInputs[1]
+	}
+	return inputs
+}
+
+func NewStreakQuestInputs(encodedInputs []int) *StreakQuestInputs {
+	return &StreakQuestInputs{
+		DaysNeeded: encodedInputs[0],
+	}
+}
@@ -0,0 +1,369 @@
+package quests
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Quest progress engine : the counters read by the quest checks are loaded for a user in two
+// aggregate queries and cached in a Redis hash. The indexer increments the cached counters as
+// pixels, votes and mints come in, and drops the hash on the other events changing them.
+// Every update also bumps a generation key, so stats loaded before an event are never cached.
+// Template quests depend on the other users' pixels and are still checked one by one.
+
+const userStatsTtl = 15 * time.Minute
+
+// Stats hash fields
+const (
+	statsLoadedField       = "loaded" // marks a cached hash, even with no counters
+	statsPixelsField       = "pixels"
+	statsNftsField         = "nfts"
+	statsExtraPixelsField  = "extraPixels"
+	statsUsernameField     = "username"
+	statsFactionField      = "faction"
+	statsChainFactionField = "chainFaction"
+	statsMemecoinsField    = "memecoins"
+	statsColorsField       = "colorsTotal" // palette size, set on each evaluation and not cached
+	statsPixelColorPrefix  = "pixels:c"
+)
+
+func statsDayField(prefix string, day int) string {
+	return fmt.Sprintf("%s:d%d", prefix, day)
+}
+
+func statsPixelColorField(color int) string {
+	return statsPixelColorPrefix + strconv.Itoa(color)
+}
+
+func statsPixelDayColorField(day int, color int) string {
+	return fmt.Sprintf("pixels:d%d:c%d", day, color)
+}
+
+func statsDailyAllowField(dayIndex int, questId int) string {
+	return fmt.Sprintf("allow:d%d:q%d", dayIndex, questId)
+}
+
+func statsMainAllowField(questId int) string {
+	return fmt.Sprintf("allow:m%d", questId)
+}
+
+func userStatsKey(user string) string {
+	return "quest-stats:" + user
+}
+
+func userStatsGenKey(user string) string {
+	return "quest-stats-gen:" + user
+}
+
+// UserStats are the counters of a user the quest progress is computed from
+type UserStats map[string]int
+
+// distinctColors counts the colors the user placed, like the rainbow quest
+func (s UserStats) distinctColors() int {
+	colors := 0
+	for field, count := range s {
+		if strings.HasPrefix(field, statsPixelColorPrefix) && count > 0 {
+			colors++
+		}
+	}
+	return colors
+}
+
+type pixelStatsRow struct {
+	Day   int `json:"day"`
+	Color int `json:"color"`
+	Count int `json:"count"`
+}
+
+type userStatsRow struct {
+	Field string `json:"field"`
+	Count int    `json:"count"`
+}
+
+const userStatsQuery = `SELECT 'nfts:d' || day_index AS field, COUNT(*) AS count FROM NFTs WHERE minter = $1 GROUP BY day_index
+UNION ALL SELECT 'votes:d' || day_index, COUNT(*) FROM ColorVotes WHERE user_address = $1 GROUP BY day_index
+UNION ALL SELECT 'allow:d' || day_index || ':q' || quest_id, COUNT(*) FROM DailyQuestsAllowList WHERE user_address = $1 GROUP BY day_index, quest_id
+UNION ALL SELECT 'allow:m' || quest_id, COUNT(*) FROM MainQuestsAllowList WHERE user_address = $1 GROUP BY quest_id
+UNION ALL SELECT 'extraPixels', COALESCE(SUM(available), 0) FROM ExtraPixels WHERE address = $1
+UNION ALL SELECT 'username', COUNT(*) FROM Users WHERE address = $1
+UNION ALL SELECT 'faction', COUNT(*) FROM FactionMembersInfo WHERE user_address = $1
+UNION ALL SELECT 'chainFaction', COUNT(*) FROM ChainFactionMembersInfo WHERE user_address = $1
+UNION ALL SELECT 'memecoins', COUNT(*) FROM Memecoins WHERE owner = $1 AND launched = true`
+
+func loadUserStats(user string) (UserStats, error) {
+	stats := UserStats{statsLoadedField: 1}
+
+	pixelRows, err := core.PostgresQuery[pixelStatsRow]("SELECT day, color, COUNT(*) AS count FROM Pixels WHERE address = $1 GROUP BY day, color", user)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range pixelRows {
+		stats[statsPixelsField] += row.Count
+		stats[statsDayField(statsPixelsField, row.Day)] += row.Count
+		stats[statsPixelColorField(row.Color)] += row.Count
+		stats[statsPixelDayColorField(row.Day, row.Color)] += row.Count
+	}
+
+	rows, err := core.PostgresQuery[userStatsRow](userStatsQuery, user)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		stats[row.Field] += row.Count
+		if strings.HasPrefix(row.Field, statsNftsField+":d") {
+			stats[statsNftsField] += row.Count
+		}
+	}
+	return stats, nil
+}
+
+// Caches loaded stats unless an update happened since the generation was read
+// KEYS[1] stats, KEYS[2] generation, ARGV[1] generation read, ARGV[2] ttl, then field / value pairs
+var storeUserStatsScript = redis.NewScript(`
+if (redis.call('GET', KEYS[2]) or '') ~= ARGV[1] then
+  return 0
+end
+redis.call('DEL', KEYS[1])
+for i = 3, #ARGV, 2 do
+  redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+// Bumps the generation and updates the cached stats if any
+// KEYS[1] stats, KEYS[2] generation, ARGV[1] ttl, ARGV[2] number of increments, then field /
+// value pairs, the increments first and the values to set after
+var updateUserStatsScript = redis.NewScript(`
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], ARGV[1])
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return 0
+end
+local increments = tonumber(ARGV[2])
+for i = 3, #ARGV, 2 do
+  if (i - 3) / 2 < increments then
+    redis.call('HINCRBY', KEYS[1], ARGV[i], ARGV[i + 1])
+  else
+    redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+  end
+end
+return 1
+`)
+
+// GetUserStats returns the cached stats of the user, loading them on a miss
+func GetUserStats(user string) (UserStats, error) {
+	ctx := context.Background()
+	client := core.AFKBackend.Databases.Redis
+
+	cached, err := client.HGetAll(ctx, userStatsKey(user)).Result()
+	if err == nil && len(cached) > 0 {
+		stats := make(UserStats, len(cached))
+		for field, value := range cached {
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quest stats field %s: %w", field, err)
+			}
+			stats[field] = count
+		}
+		return stats, nil
+	}
+
+	// Read the generation before loading, a concurrent update makes the load stale
+	generation, genErr := client.Get(ctx, userStatsGenKey(user)).Result()
+	if genErr == redis.Nil {
+		generation, genErr = "", nil
+	}
+
+	stats, err := loadUserStats(user)
+	if err != nil {
+		return nil, err
+	}
+	if genErr != nil {
+		// Redis is down, serve the stats uncached
+		return stats, nil
+	}
+
+	args := []interface{}{generation, int(userStatsTtl.Seconds())}
+	for field, count := range stats {
+		args = append(args, field, count)
+	}
+	if err := storeUserStatsScript.Run(ctx, client, []string{userStatsKey(user), userStatsGenKey(user)}, args...).Err(); err != nil {
+		fmt.Println("Error caching quest stats", user, err)
+	}
+	return stats, nil
+}
+
+func updateUserStats(user string, increments map[string]int, values map[string]int) {
+	args := []interface{}{int(userStatsTtl.Seconds()), len(increments)}
+	for field, increment := range increments {
+		args = append(args, field, increment)
+	}
+	for field, value := range values {
+		args = append(args, field, value)
+	}
+
+	ctx := context.Background()
+	client := core.AFKBackend.Databases.Redis
+	err := updateUserStatsScript.Run(ctx, client, []string{userStatsKey(user), userStatsGenKey(user)}, args...).Err()
+	if err != nil {
+		// Drop the stats rather than leave them stale, the ttl covers a Redis outage
+		fmt.Println("Error updating quest stats", user, err)
+		client.Del(ctx, userStatsKey(user))
+	}
+}
+
+// RecordPixelPlaced counts a main canvas pixel in the user's cached stats
+func RecordPixelPlaced(user string, day int, color int) {
+	updateUserStats(user, map[string]int{
+		statsPixelsField:                     1,
+		statsDayField(statsPixelsField, day): 1,
+		statsPixelColorField(color):          1,
+		statsPixelDayColorField(day, color):  1,
+	}, nil)
+}
+
+// RecordColorVote sets the user's vote of the day, a vote change keeps a single vote
+func RecordColorVote(user string, dayIndex int) {
+	updateUserStats(user, nil, map[string]int{
+		statsDayField("votes", dayIndex): 1,
+	})
+}
+
+// RecordNFTMinted counts an NFT minted by the user
+func RecordNFTMinted(user string, dayIndex int) {
+	updateUserStats(user, map[string]int{
+		statsNftsField:                          1,
+		statsDayField(statsNftsField, dayIndex): 1,
+	}, nil)
+}
+
+// InvalidateUserStats drops the cached stats of the user, reloaded on the next read. Used by
+// the reverts and the changes not counted incrementally.
+func InvalidateUserStats(user string) {
+	ctx := context.Background()
+	_, err := core.AFKBackend.Databases.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Incr(ctx, userStatsGenKey(user))
+		pipe.Expire(ctx, userStatsGenKey(user), userStatsTtl)
+		pipe.Del(ctx, userStatsKey(user))
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error invalidating quest stats", user, err)
+	}
+}
+
+// Progress of the quest types computed from the user stats, the others are checked directly
+var QuestStatsProgress = map[int]func(*Quest, UserStats) (int, int){
+	AuthorityQuestType:    authorityProgress,
+	HodlQuestType:         hodlProgress,
+	NFTMintQuestType:      nftProgress,
+	PixelQuestType:        pixelProgress,
+	RainbowQuestType:      rainbowProgress,
+	UnruggableQuestType:   unruggableProgress,
+	VoteQuestType:         voteProgress,
+	FactionQuestType:      factionProgress,
+	ChainFactionQuestType: chainFactionProgress,
+	UsernameQuestType:     usernameProgress,
+}
+
+func authorityProgress(q *Quest, stats UserStats) (int, int) {
+	if q.IsDaily {
+		return stats[statsDailyAllowField(q.DayIndex, q.QuestId)], 1
+	}
+	return stats[statsMainAllowField(q.QuestId)], 1
+}
+
+func hodlProgress(q *Quest, stats UserStats) (int, int) {
+	return stats[statsExtraPixelsField], NewHodlQuestInputs(q.InputData).Amount
+}
+
+func nftProgress(q *Quest, stats UserStats) (int, int) {
+	nftQuestInputs := NewNFTQuestInputs(q.InputData)
+	if nftQuestInputs.IsDaily {
+		return stats[statsDayField(statsNftsField, int(nftQuestInputs.ClaimDay))], 1
+	}
+	return stats[statsNftsField], 1
+}
+
+func pixelProgress(q *Quest, stats UserStats) (int, int) {
+	pixelQuestInputs := NewPixelQuestInputs(q.InputData)
+	day := int(pixelQuestInputs.ClaimDay)
+	color := int(pixelQuestInputs.Color)
+	needed := int(pixelQuestInputs.PixelsNeeded)
+	switch {
+	case pixelQuestInputs.IsDaily && pixelQuestInputs.IsColor:
+		return stats[statsPixelDayColorField(day, color)], needed
+	case pixelQuestInputs.IsDaily:
+		return stats[statsDayField(statsPixelsField, day)], needed
+	case pixelQuestInputs.IsColor:
+		return stats[statsPixelColorField(color)], needed
+	default:
+		return stats[statsPixelsField], needed
+	}
+}
+
+func rainbowProgress(q *Quest, stats UserStats) (int, int) {
+	return stats.distinctColors(), stats[statsColorsField]
+}
+
+func unruggableProgress(q *Quest, stats UserStats) (int, int) {
+	return stats[statsMemecoinsField], 1
+}
+
+func voteProgress(q *Quest, stats UserStats) (int, int) {
+	return stats[statsDayField("votes", int(NewVoteQuestInputs(q.InputData).DayIndex))], 1
+}
+
+func factionProgress(q *Quest, stats UserStats) (int, int) {
+	return stats[statsFactionField], 1
+}
+
+func chainFactionProgress(q *Quest, stats UserStats) (int, int) {
+	return stats[statsChainFactionField], 1
+}
+
+func usernameProgress(q *Quest, stats UserStats) (int, int) {
+	return stats[statsUsernameField], 1
+}
+
+type QuestProgress struct {
+	Progress int `json:"progress"`
+	Needed   int `json:"needed"`
+}
+
+// EvaluateQuests returns the progress of the user on each quest, reading the user stats once.
+// Without stats ( Postgres error ) the quests are checked one by one.
+func EvaluateQuests(user string, quests []*Quest) []QuestProgress {
+	stats, err := GetUserStats(user)
+	if err != nil {
+		fmt.Println("Error getting quest stats", user, err)
+	}
+	if stats != nil {
+		// Colors are added for every user, the palette size is not part of the user's cache
+		colorsTotal, err := core.PostgresQueryOne[int]("SELECT COUNT(*) FROM Colors")
+		if err != nil {
+			fmt.Println("Error getting the palette size", err)
+			stats = nil
+		} else {
+			stats[statsColorsField] = *colorsTotal
+		}
+	}
+
+	results := make([]QuestProgress, len(quests))
+	for idx, quest := range quests {
+		if progress, ok := QuestStatsProgress[quest.Type]; ok && stats != nil {
+			results[idx].Progress, results[idx].Needed = progress(quest, stats)
+		} else {
+			results[idx].Progress, results[idx].Needed = quest.CheckStatus(user)
+		}
+	}
+	return results
+}
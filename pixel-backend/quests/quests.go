@@ -1,6 +1,10 @@
 package quests
 
-import "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+import (
+	"fmt"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
 
 // Quest types
 const (
@@ -15,6 +19,7 @@ const (
 	ChainFactionQuestType
 	FactionQuestType
 	UsernameQuestType
+	StreakQuestType
 )
 
 var OnchainQuestTypes = map[string]int{
@@ -29,11 +34,15 @@ var OnchainQuestTypes = map[string]int{
 	"ChainFactionQuest": ChainFactionQuestType,
 	"FactionQuest":      FactionQuestType,
 	"UsernameQuest":     UsernameQuestType,
+	"StreakQuest":       StreakQuestType, // checked by the backend, claimed with a signed claim
 }
 
 type Quest struct {
 	Type      int
 	InputData []int
+	QuestId   int
+	IsDaily   bool
+	DayIndex  int // daily quests only
 }
 
 func (q *Quest) GetType() int {
@@ -59,6 +68,9 @@ func NewDailyQuest(questIdx int, dayIdx int) *Quest {
 	return &Quest{
 		Type:      questType,
 		InputData: questInputData,
+		QuestId:   questIdx,
+		IsDaily:   true,
+		DayIndex:  dayIdx,
 	}
 }
 
@@ -73,13 +85,21 @@ func NewDailyQuestWithType(questIdx int, questTypeStr string, dayIdx int) *Quest
 	return &Quest{
 		Type:      questType,
 		InputData: questInputData,
+		QuestId:   questIdx,
+		IsDaily:   true,
+		DayIndex:  dayIdx,
 	}
 }
 
 func NewTodayQuestWithType(questIdx int, questTypeStr string) *Quest {
 	questType := OnchainQuestTypes[questTypeStr]
 
-	questInputData, err := core.PostgresQuery[int]("SELECT input_value FROM DailyQuestsInput WHERE day_index = (SELECT MAX(day_index) FROM Days) AND quest_id = $1 ORDER BY input_key", questIdx)
+	dayIdx, err := core.PostgresQueryOne[int]("SELECT MAX(day_index) FROM Days")
+	if err != nil {
+		return nil
+	}
+
+	questInputData, err := core.PostgresQuery[int]("SELECT input_value FROM DailyQuestsInput WHERE day_index = $1 AND quest_id = $2 ORDER BY input_key", *dayIdx, questIdx)
 	if err != nil {
 		return nil
 	}
@@ -87,11 +107,14 @@ func NewTodayQuestWithType(questIdx int, questTypeStr string) *Quest {
 	return &Quest{
 		Type:      questType,
 		InputData: questInputData,
+		QuestId:   questIdx,
+		IsDaily:   true,
+		DayIndex:  *dayIdx,
 	}
 }
 
 func NewMainQuest(questIdx int) *Quest {
-	questTypeString, err := core.PostgresQueryOne[string]("SELECT quest_type FROM MainQuests WHERE quest_id = $1", questIdx)
+	questTypeString, err := core.PostgresQueryOne[string]("SELECT quest_type FROM MainQuests WHERE key = $1 + 1", questIdx)
 	if err != nil {
 		return nil
 	}
@@ -105,6 +128,7 @@ func NewMainQuest(questIdx int) *Quest {
 	return &Quest{
 		Type:      questType,
 		InputData: questInputData,
+		QuestId:   questIdx,
 	}
 }
 
@@ -119,5 +143,60 @@ func NewMainQuestWithType(questIdx int, questTypeStr string) *Quest {
 	return &Quest{
 		Type:      questType,
 		InputData: questInputData,
+		QuestId:   questIdx,
+	}
+}
+
+type questRow struct {
+	QuestId   int    `json:"questId"`
+	QuestType string `json:"questType"`
+	DayIndex  int    `json:"dayIndex"`
+	InputData []int  `json:"inputData"`
+}
+
+func questsFromRows(rows []questRow, isDaily bool) []*Quest {
+	quests := make([]*Quest, 0, len(rows))
+	for _, row := range rows {
+		quests = append(quests, &Quest{
+			Type:      OnchainQuestTypes[row.QuestType],
+			InputData: row.InputData,
+			QuestId:   row.QuestId,
+			IsDaily:   isDaily,
+			DayIndex:  row.DayIndex,
+		})
+	}
+	return quests
+}
+
+const dailyQuestsQuery = `SELECT q.quest_id, q.quest_type, q.day_index, COALESCE(array_agg(i.input_value ORDER BY i.input_key) FILTER (WHERE i.input_key IS NOT NULL), '{}') AS input_data
+FROM DailyQuests q LEFT JOIN DailyQuestsInput i ON i.day_index = q.day_index AND i.quest_id = q.quest_id
+WHERE q.day_index = %s GROUP BY q.day_index, q.quest_id, q.quest_type ORDER BY q.quest_id`
+
+// LoadDailyQuests loads the quests of a day with their inputs in one query
+func LoadDailyQuests(dayIdx int) ([]*Quest, error) {
+	rows, err := core.PostgresQuery[questRow](fmt.Sprintf(dailyQuestsQuery, "$1"), dayIdx)
+	if err != nil {
+		return nil, err
+	}
+	return questsFromRows(rows, true), nil
+}
+
+// LoadTodayQuests loads the quests of the current day with their inputs in one query
+func LoadTodayQuests() ([]*Quest, error) {
+	rows, err := core.PostgresQuery[questRow](fmt.Sprintf(dailyQuestsQuery, "(SELECT MAX(day_index) FROM Days)"))
+	if err != nil {
+		return nil, err
+	}
+	return questsFromRows(rows, true), nil
+}
+
+// LoadMainQuests loads the main quests with their inputs in one query
+func LoadMainQuests() ([]*Quest, error) {
+	rows, err := core.PostgresQuery[questRow](`SELECT q.key - 1 AS quest_id, q.quest_type, 0 AS day_index, COALESCE(array_agg(i.input_value ORDER BY i.input_key) FILTER (WHERE i.input_key IS NOT NULL), '{}') AS input_data
+FROM MainQuests q LEFT JOIN MainQuestsInput i ON i.quest_id = q.key - 1
+GROUP BY q.key, q.quest_type ORDER BY q.key`)
+	if err != nil {
+		return nil, err
 	}
+	return questsFromRows(rows, false), nil
 }
@@ -0,0 +1,140 @@
+package quests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Recurring quests are stored by /init-quests in RecurringQuests and written into DailyQuests
+// when a day starts ( NewDay event ) or when the current day is re-initialized. Their quest ids
+// start at RecurringQuestIdOffset so they never collide with the quests authored per day, they
+// are claimed with the signed claims since no quest contract is deployed for them.
+
+const RecurringQuestIdOffset = 100
+
+type recurringQuestRow struct {
+	TemplateId   int    `json:"templateId"`
+	Config       []byte `json:"config"`
+	Placeholders []byte `json:"placeholders"`
+}
+
+// storeRecurringQuests replaces the stored recurring quests with the config ones
+func storeRecurringQuests(ctx context.Context, tx pgx.Tx, config *QuestsConfig) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM RecurringQuests"); err != nil {
+		return err
+	}
+
+	placeholders, err := json.Marshal(config.Placeholders)
+	if err != nil {
+		return err
+	}
+	for idx, recurring := range config.DailyQuests.Recurring {
+		recurringJson, err := json.Marshal(recurring)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, "INSERT INTO RecurringQuests (template_id, config, placeholders) VALUES ($1, $2, $3)", idx, recurringJson, placeholders)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// materializeRecurringQuests writes the recurring quests active on the day which are not
+// written yet, returning how many were written
+func materializeRecurringQuests(ctx context.Context, tx pgx.Tx, dayIndex int) (int, error) {
+	var rows []recurringQuestRow
+	if err := pgxscan.Select(ctx, tx, &rows, "SELECT template_id, config, placeholders FROM RecurringQuests ORDER BY template_id"); err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	contracts, err := contractAddresses(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for _, row := range rows {
+		var recurring RecurringQuestConfig
+		if err := json.Unmarshal(row.Config, &recurring); err != nil {
+			return written, fmt.Errorf("recurring quest %d: %w", row.TemplateId, err)
+		}
+		if !recurring.ActiveOn(dayIndex) {
+			continue
+		}
+
+		var exists bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM DailyQuests WHERE day_index = $1 AND quest_id = $2)", dayIndex, RecurringQuestIdOffset+row.TemplateId).Scan(&exists)
+		if err != nil {
+			return written, err
+		}
+		if exists {
+			continue
+		}
+
+		config := QuestsConfig{}
+		if err := json.Unmarshal(row.Placeholders, &config.Placeholders); err != nil {
+			return written, fmt.Errorf("recurring quest %d placeholders: %w", row.TemplateId, err)
+		}
+		definition, errs := config.resolveRecurringQuest(recurring, row.TemplateId, dayIndex, contracts)
+		if len(errs) > 0 {
+			return written, QuestConfigErrors(errs)
+		}
+		if err := writeQuest(ctx, tx, &definition); err != nil {
+			return written, err
+		}
+		written++
+	}
+	return written, nil
+}
+
+// MaterializeRecurringQuests writes the recurring quests of a new day
+func MaterializeRecurringQuests(dayIndex int) (int, error) {
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Serialized with /init-quests
+	if _, err := tx.Exec(ctx, "LOCK TABLE DailyQuests IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return 0, err
+	}
+	written, err := materializeRecurringQuests(ctx, tx, dayIndex)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// RemoveRecurringQuests deletes the recurring quests written for a day, when the day is reverted
+func RemoveRecurringQuests(dayIndex int) error {
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"DailyQuestsInput", "DailyQuestsClaimParams", "DailyQuests"} {
+		_, err := tx.Exec(ctx, "DELETE FROM "+table+" WHERE day_index = $1 AND quest_id >= $2", dayIndex, RecurringQuestIdOffset)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,189 @@
+package quests
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
+)
+
+// Claims of completed quests signed with the backend stark key, for quest contracts checking
+// quests evaluated off-chain. The signed hash is the pedersen hash on elements of
+//
+//	[ 'AFK Quest Claim', chainId, user, isDaily, dayIndex, questId, nonce, expiresAt, h(calldata) ]
+//
+// with h(calldata) the pedersen hash on elements of the claim calldata. The contract checks the
+// signature against the backend stark key, the expiry against the block timestamp, and marks
+// the nonce used.
+
+const (
+	QuestClaimSignerEnv      = "QUEST_CLAIM_SIGNER_PRIVATE_KEY"
+	questClaimPrefix         = "AFK Quest Claim"
+	defaultQuestClaimTtl     = 600
+	defaultQuestClaimLimit   = 10
+	defaultQuestClaimChainId = "SN_MAIN"
+)
+
+type SignedClaim struct {
+	User      string   `json:"user"`
+	IsDaily   bool     `json:"isDaily"`
+	DayIndex  int      `json:"dayIndex"`
+	QuestId   int      `json:"questId"`
+	Calldata  []int    `json:"calldata"`
+	Nonce     string   `json:"nonce"`
+	ExpiresAt int64    `json:"expiresAt"`
+	Hash      string   `json:"hash"`
+	Signature []string `json:"signature"` // r, s
+}
+
+var (
+	claimSignerOnce sync.Once
+	claimSignerKey  *big.Int
+	claimSignerErr  error
+)
+
+// claimSigner loads the signer key once, nil without QUEST_CLAIM_SIGNER_PRIVATE_KEY
+func claimSigner() (*big.Int, error) {
+	claimSignerOnce.Do(func() {
+		value := os.Getenv(QuestClaimSignerEnv)
+		if value == "" {
+			fmt.Println("No", QuestClaimSignerEnv, "set, quest claims are not signed")
+			return
+		}
+		claimSignerKey, claimSignerErr = starknet.ParsePrivateKey(value)
+	})
+	return claimSignerKey, claimSignerErr
+}
+
+// ClaimSignerPublicKey returns the stark key claims are signed with, empty when signing is off
+func ClaimSignerPublicKey() (string, error) {
+	key, err := claimSigner()
+	if err != nil || key == nil {
+		return "", err
+	}
+	return starknet.FeltToHex(starknet.GetPublicKey(key).X), nil
+}
+
+func claimTtl() time.Duration {
+	ttl := core.AFKBackend.BackendConfig.QuestClaims.ClaimTtl
+	if ttl <= 0 {
+		ttl = defaultQuestClaimTtl
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func claimLimit() time.Duration {
+	limit := core.AFKBackend.BackendConfig.QuestClaims.ClaimInterval
+	if limit <= 0 {
+		limit = defaultQuestClaimLimit
+	}
+	return time.Duration(limit) * time.Second
+}
+
+func claimChainId() string {
+	chainId := core.AFKBackend.BackendConfig.Auth.ChainId
+	if chainId == "" {
+		chainId = defaultQuestClaimChainId
+	}
+	return chainId
+}
+
+// ClaimHash is the hash signed for the claim, see above
+func (c *SignedClaim) ClaimHash(nonce *big.Int) (*big.Int, error) {
+	prefix, err := starknet.EncodeShortString(questClaimPrefix)
+	if err != nil {
+		return nil, err
+	}
+	chainId, err := starknet.EncodeShortString(claimChainId())
+	if err != nil {
+		return nil, err
+	}
+	user, err := starknet.ParseFelt("0x" + c.User)
+	if err != nil {
+		return nil, err
+	}
+
+	calldata := make([]*big.Int, len(c.Calldata))
+	for idx, value := range c.Calldata {
+		calldata[idx] = big.NewInt(int64(value))
+	}
+	isDaily := big.NewInt(0)
+	if c.IsDaily {
+		isDaily = big.NewInt(1)
+	}
+
+	return starknet.ComputeHashOnElements([]*big.Int{
+		prefix,
+		chainId,
+		user,
+		isDaily,
+		big.NewInt(int64(c.DayIndex)),
+		big.NewInt(int64(c.QuestId)),
+		nonce,
+		big.NewInt(c.ExpiresAt),
+		starknet.ComputeHashOnElements(calldata),
+	}), nil
+}
+
+// IsClaimable tells if the evaluated quest can be signed: a quest type checked by the backend
+// with something to reach, reached. Unknown types evaluate to 0 / 0.
+func (q *Quest) IsClaimable(progress int, needed int) bool {
+	return QuestChecks[q.Type] != nil && needed > 0 && progress >= needed
+}
+
+// TakeClaimSlot rate limits the signatures of a user to one per claim_interval, false when the
+// user signed a claim too recently
+func TakeClaimSlot(user string) (bool, error) {
+	return core.AFKBackend.Databases.Redis.SetNX(context.Background(), "quest-claim-limit:"+user, 1, claimLimit()).Result()
+}
+
+// SignClaim signs the claim of a completed quest with a fresh nonce, nil when signing is off.
+// The caller checks the quest is claimable.
+func (q *Quest) SignClaim(user string, calldata []int) (*SignedClaim, error) {
+	key, err := claimSigner()
+	if err != nil || key == nil {
+		return nil, err
+	}
+	if calldata == nil {
+		calldata = []int{}
+	}
+
+	claim := &SignedClaim{
+		User:      user,
+		IsDaily:   q.IsDaily,
+		DayIndex:  q.DayIndex,
+		QuestId:   q.QuestId,
+		Calldata:  calldata,
+		ExpiresAt: time.Now().Add(claimTtl()).Unix(),
+	}
+
+	// Pedersen hashes can exceed the 251 bits signed, draw another nonce then
+	for {
+		nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+		if err != nil {
+			return nil, err
+		}
+		hash, err := claim.ClaimHash(nonce)
+		if err != nil {
+			return nil, err
+		}
+		if hash.BitLen() > 251 {
+			continue
+		}
+
+		r, s, err := starknet.Sign(key, hash)
+		if err != nil {
+			return nil, err
+		}
+		claim.Nonce = starknet.FeltToHex(nonce)
+		claim.Hash = starknet.FeltToHex(hash)
+		claim.Signature = []string{starknet.FeltToHex(r), starknet.FeltToHex(s)}
+		return claim, nil
+	}
+}
@@ -0,0 +1,78 @@
+package quests
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
+)
+
+func TestSignClaim(t *testing.T) {
+	backendConfig := config.DefaultBackendConfig
+	core.AFKBackend = &core.Backend{BackendConfig: &backendConfig}
+	t.Setenv(QuestClaimSignerEnv, "0x3c1e9550e66958296d11b60f8e8e7a7ad990d07fa65d5f7652c4a6c87d4e3cc")
+
+	publicKey, err := ClaimSignerPublicKey()
+	if err != nil {
+		t.Fatalf("ClaimSignerPublicKey: %v", err)
+	}
+	if publicKey != "0x77a3b314db07c45076d11f62b6f9e748a39790441823307743cf00d6597ea43" {
+		t.Errorf("public key = %s", publicKey)
+	}
+
+	quest := &Quest{Type: PixelQuestType, IsDaily: true, DayIndex: 3, QuestId: 2}
+	claim, err := quest.SignClaim("0123", []int{7})
+	if err != nil || claim == nil {
+		t.Fatalf("SignClaim: %v, %v", claim, err)
+	}
+
+	nonce, _ := starknet.ParseFelt(claim.Nonce)
+	hash, err := claim.ClaimHash(nonce)
+	if err != nil {
+		t.Fatalf("ClaimHash: %v", err)
+	}
+	if starknet.FeltToHex(hash) != claim.Hash {
+		t.Errorf("hash = %s, want %s", claim.Hash, starknet.FeltToHex(hash))
+	}
+	starkKey, _ := starknet.ParseFelt(publicKey)
+	r, _ := starknet.ParseFelt(claim.Signature[0])
+	s, _ := starknet.ParseFelt(claim.Signature[1])
+	if !starknet.Verify(starkKey, hash, r, s) {
+		t.Errorf("claim signature rejected")
+	}
+
+	// Every field of the claim is signed
+	claim.QuestId++
+	other, err := claim.ClaimHash(nonce)
+	if err != nil {
+		t.Fatalf("ClaimHash: %v", err)
+	}
+	if other.Cmp(hash) == 0 || starknet.Verify(starkKey, other, r, s) {
+		t.Errorf("signature valid for another quest")
+	}
+	if other, _ := claim.ClaimHash(new(big.Int).Add(nonce, big.NewInt(1))); other.Cmp(hash) == 0 {
+		t.Errorf("nonce is not part of the hash")
+	}
+}
+
+func TestIsClaimable(t *testing.T) {
+	cases := []struct {
+		questType int
+		progress  int
+		needed    int
+		want      bool
+	}{
+		{PixelQuestType, 5, 5, true},
+		{PixelQuestType, 4, 5, false},
+		{PixelQuestType, 0, 0, false},
+		{-1, 1, 1, false},
+	}
+	for _, c := range cases {
+		quest := &Quest{Type: c.questType}
+		if got := quest.IsClaimable(c.progress, c.needed); got != c.want {
+			t.Errorf("IsClaimable(type %d, %d / %d) = %v, want %v", c.questType, c.progress, c.needed, got, c.want)
+		}
+	}
+}
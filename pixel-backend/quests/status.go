@@ -1,6 +1,8 @@
 package quests
 
 import (
+	"fmt"
+
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 )
 
@@ -16,6 +18,7 @@ var QuestChecks = map[int]func(*Quest, string) (int, int){
 	FactionQuestType:      CheckFactionStatus,
 	ChainFactionQuestType: CheckChainFactionStatus,
 	UsernameQuestType:     CheckUsernameStatus,
+	StreakQuestType:       CheckStreakStatus,
 }
 
 func (q *Quest) CheckStatus(user string) (progress int, needed int) {
@@ -26,9 +29,20 @@ func (q *Quest) CheckStatus(user string) (progress int, needed int) {
 	return check(q, user)
 }
 
+// CheckAuthorityStatus checks the user is on the quest's allow list, filled by an admin
 func CheckAuthorityStatus(q *Quest, user string) (progress int, needed int) {
-	// TODO: Implement this
-	return 0, 1
+	var count *int
+	var err error
+	if q.IsDaily {
+		count, err = core.PostgresQueryOne[int]("SELECT COUNT(*) FROM DailyQuestsAllowList WHERE day_index = $1 AND quest_id = $2 AND user_address = $3", q.DayIndex, q.QuestId, user)
+	} else {
+		count, err = core.PostgresQueryOne[int]("SELECT COUNT(*) FROM MainQuestsAllowList WHERE quest_id = $1 AND user_address = $2", q.QuestId, user)
+	}
+	if err != nil {
+		return 0, 1
+	}
+
+	return *count, 1
 }
 
 func CheckHodlStatus(q *Quest, user string) (progress int, needed int) {
@@ -136,14 +150,70 @@ func CheckRainbowStatus(q *Quest, user string) (progress int, needed int) {
 	return status.Used, status.Colors
 }
 
+type templateQuestData struct {
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Position int    `json:"position"`
+	Data     []byte `json:"data"`
+}
+
+// CheckTemplateStatus counts the template pixels whose latest placement is the user's, in the
+// template color. Transparent pixels ( 0xFF ) are not part of the template.
 func CheckTemplateStatus(q *Quest, user string) (progress int, needed int) {
-	// TODO: Implement this
-	return 0, 1
+	templateQuestInputs := NewTemplateQuestInputs(q.InputData)
+	template, err := core.PostgresQueryOne[templateQuestData]("SELECT t.width, t.height, t.position, d.data FROM Templates t LEFT JOIN TemplateData d ON d.hash = t.hash WHERE t.key = $1 LIMIT 1", templateQuestInputs.TemplateId)
+	if err != nil {
+		return 0, 1
+	}
+	if template.Data == nil {
+		fmt.Println("Template", templateQuestInputs.TemplateId, "has no TemplateData, add it with /add-template-data or /backfill-template-data")
+		return 0, 1
+	}
+	if len(template.Data) != template.Width*template.Height {
+		return 0, 1
+	}
+
+	positions, colors := template.pixels(int(core.AFKBackend.CanvasConfig.Canvas.Width))
+	if len(positions) == 0 {
+		return 0, 1
+	}
+	needed = templatePixelsNeeded(len(positions), templateQuestInputs.PercentNeeded)
+
+	count, err := core.PostgresQueryOne[int]("SELECT COUNT(*) FROM (SELECT DISTINCT ON (position) position, address, color FROM Pixels WHERE position = ANY($1) ORDER BY position, block_number DESC, key DESC) latest JOIN unnest($1::integer[], $2::integer[]) AS t(position, color) ON latest.position = t.position AND latest.color = t.color WHERE latest.address = $3", positions, colors, user)
+	if err != nil {
+		return 0, needed
+	}
+
+	return *count, needed
 }
 
+// pixels returns the canvas positions of the template pixels and their colors
+func (t *templateQuestData) pixels(canvasWidth int) (positions []int, colors []int) {
+	positions = make([]int, 0, len(t.Data))
+	colors = make([]int, 0, len(t.Data))
+	for idx, colorIdx := range t.Data {
+		if colorIdx == 0xFF {
+			continue
+		}
+		positions = append(positions, t.Position+(idx/t.Width)*canvasWidth+idx%t.Width)
+		colors = append(colors, int(colorIdx))
+	}
+	return positions, colors
+}
+
+// templatePixelsNeeded is the percent of the template pixels, rounded up
+func templatePixelsNeeded(templatePixels int, percentNeeded int) int {
+	return (templatePixels*percentNeeded + 99) / 100
+}
+
+// CheckUnruggableStatus checks the user owns a memecoin launched from the unruggable factory
 func CheckUnruggableStatus(q *Quest, user string) (progress int, needed int) {
-	// TODO: Implement this
-	return 0, 1
+	count, err := core.PostgresQueryOne[int]("SELECT COUNT(*) FROM Memecoins WHERE owner = $1 AND launched = true", user)
+	if err != nil {
+		return 0, 1
+	}
+
+	return *count, 1
 }
 
 func CheckUsernameStatus(q *Quest, user string) (progress int, needed int) {
@@ -0,0 +1,91 @@
+package quests
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewTemplateQuestInputs(t *testing.T) {
+	tests := []struct {
+		inputs        []int
+		templateId    int
+		percentNeeded int
+	}{
+		{inputs: nil, templateId: -1, percentNeeded: 100},
+		{inputs: []int{4}, templateId: 4, percentNeeded: 100},
+		{inputs: []int{4, 75}, templateId: 4, percentNeeded: 75},
+		{inputs: []int{0, 1}, templateId: 0, percentNeeded: 1},
+		// Out of range percents fall back to the whole template
+		{inputs: []int{4, 0}, templateId: 4, percentNeeded: 100},
+		{inputs: []int{4, 101}, templateId: 4, percentNeeded: 100},
+		{inputs: []int{4, -5}, templateId: 4, percentNeeded: 100},
+	}
+	for _, test := range tests {
+		inputs := NewTemplateQuestInputs(test.inputs)
+		if inputs.TemplateId != test.templateId || inputs.PercentNeeded != test.percentNeeded {
+			t.Errorf("%v: template %d at %d%%, want %d at %d%%", test.inputs, inputs.TemplateId, inputs.PercentNeeded, test.templateId, test.percentNeeded)
+		}
+	}
+}
+
+func TestTemplateQuestPixels(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  templateQuestData
+		positions []int
+		colors    []int
+	}{
+		{
+			name:      "2x2 at the origin",
+			template:  templateQuestData{Width: 2, Height: 2, Position: 0, Data: []byte{1, 2, 3, 4}},
+			positions: []int{0, 1, 10, 11},
+			colors:    []int{1, 2, 3, 4},
+		},
+		{
+			name:      "3x2 placed at 5,3",
+			template:  templateQuestData{Width: 3, Height: 2, Position: 35, Data: []byte{0, 1, 2, 3, 4, 5}},
+			positions: []int{35, 36, 37, 45, 46, 47},
+			colors:    []int{0, 1, 2, 3, 4, 5},
+		},
+		{
+			name:      "transparent pixels",
+			template:  templateQuestData{Width: 2, Height: 2, Position: 22, Data: []byte{0xFF, 7, 8, 0xFF}},
+			positions: []int{23, 32},
+			colors:    []int{7, 8},
+		},
+		{
+			name:      "all transparent",
+			template:  templateQuestData{Width: 1, Height: 2, Position: 0, Data: []byte{0xFF, 0xFF}},
+			positions: []int{},
+			colors:    []int{},
+		},
+	}
+	for _, test := range tests {
+		positions, colors := test.template.pixels(10)
+		if !reflect.DeepEqual(positions, test.positions) || !reflect.DeepEqual(colors, test.colors) {
+			t.Errorf("%s: positions %v colors %v, want %v %v", test.name, positions, colors, test.positions, test.colors)
+		}
+	}
+}
+
+func TestTemplatePixelsNeeded(t *testing.T) {
+	tests := []struct {
+		pixels  int
+		percent int
+		needed  int
+	}{
+		{pixels: 100, percent: 100, needed: 100},
+		{pixels: 100, percent: 50, needed: 50},
+		{pixels: 3, percent: 50, needed: 2},
+		{pixels: 3, percent: 34, needed: 2},
+		{pixels: 3, percent: 33, needed: 1},
+		{pixels: 1, percent: 1, needed: 1},
+		{pixels: 7, percent: 100, needed: 7},
+		{pixels: 250, percent: 10, needed: 25},
+	}
+	for _, test := range tests {
+		if needed := templatePixelsNeeded(test.pixels, test.percent); needed != test.needed {
+			t.Errorf("%d%% of %d pixels: %d needed, want %d", test.percent, test.pixels, needed, test.needed)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package quests
+
+import (
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Streaks count the consecutive days a user claimed every daily quest authored for the day,
+// recurring quests are not required. Days without authored quests are skipped, and today only
+// ends the current streak once it is over.
+
+type Streak struct {
+	Current int `json:"current"`
+	Longest int `json:"longest"`
+	LastDay int `json:"lastDay"` // last day index with every daily quest claimed, -1 if none
+}
+
+type streakDayRow struct {
+	DayIndex  int  `json:"dayIndex"`
+	Completed bool `json:"completed"`
+}
+
+func GetUserStreak(user string) (*Streak, error) {
+	today, err := core.PostgresQueryOne[int]("SELECT COALESCE(MAX(day_index), 0) FROM Days")
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := core.PostgresQuery[streakDayRow]("SELECT q.day_index, COUNT(*) = COUNT(u.key) AS completed FROM DailyQuests q LEFT JOIN UserDailyQuests u ON u.day_index = q.day_index AND u.quest_id = q.quest_id AND u.user_address = $1 AND u.completed WHERE q.day_index <= $2 AND q.quest_id < $3 GROUP BY q.day_index ORDER BY q.day_index", user, *today, RecurringQuestIdOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	streak := &Streak{LastDay: -1}
+	run := 0
+	for _, day := range days {
+		if day.Completed {
+			run++
+			if run > streak.Longest {
+				streak.Longest = run
+			}
+			streak.LastDay = day.DayIndex
+		} else if day.DayIndex < *today {
+			// A missed day ends the run, today can still be completed
+			run = 0
+		}
+	}
+	streak.Current = run
+	return streak, nil
+}
+
+// CheckStreakStatus compares the user's longest streak with the days needed
+func CheckStreakStatus(q *Quest, user string) (progress int, needed int) {
+	streakQuestInputs := NewStreakQuestInputs(q.InputData)
+	streak, err := GetUserStreak(user)
+	if err != nil {
+		return 0, streakQuestInputs.DaysNeeded
+	}
+
+	return streak.Longest, streakQuestInputs.DaysNeeded
+}
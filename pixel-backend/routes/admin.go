@@ -0,0 +1,167 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+func InitAdminRoutes() {
+	http.HandleFunc("/get-admin-keys", getAdminKeys)
+	http.HandleFunc("/create-admin-key", createAdminKey)
+	http.HandleFunc("/rotate-admin-key", rotateAdminKey)
+	http.HandleFunc("/revoke-admin-key", revokeAdminKey)
+	http.HandleFunc("/get-admin-audit-log", getAdminAuditLog)
+}
+
+type CreateAdminKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type AdminKeyIdRequest struct {
+	KeyId string `json:"keyId"`
+}
+
+type NewAdminKeyResponse struct {
+	KeyId  string `json:"keyId"`
+	ApiKey string `json:"apiKey"`
+}
+
+func getAdminKeys(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeAdminKeys) {
+		return
+	}
+
+	keys, err := auth.ListAdminKeys()
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get admin keys")
+		return
+	}
+
+	keysJson, err := json.Marshal(keys)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal admin keys")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(keysJson))
+}
+
+func createAdminKey(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeAdminKeys) {
+		return
+	}
+
+	body, err := routeutils.ReadJsonBody[CreateAdminKeyRequest](r)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+	if body.Name == "" || len(body.Scopes) == 0 {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing name or scopes")
+		return
+	}
+	for _, scope := range body.Scopes {
+		if !auth.IsValidAdminScope(scope) {
+			routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid scope "+scope)
+			return
+		}
+	}
+
+	apiKey, adminKey, err := auth.CreateAdminKey(body.Name, body.Scopes)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to create admin key")
+		return
+	}
+
+	response, err := json.Marshal(NewAdminKeyResponse{KeyId: adminKey.KeyId, ApiKey: apiKey})
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal admin key")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(response))
+}
+
+func rotateAdminKey(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeAdminKeys) {
+		return
+	}
+
+	body, err := routeutils.ReadJsonBody[AdminKeyIdRequest](r)
+	if err != nil || body.KeyId == "" {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+
+	apiKey, err := auth.RotateAdminKey(body.KeyId)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Failed to rotate admin key, config keys must be rotated in the config")
+		return
+	}
+
+	response, err := json.Marshal(NewAdminKeyResponse{KeyId: body.KeyId, ApiKey: apiKey})
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal admin key")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(response))
+}
+
+func revokeAdminKey(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeAdminKeys) {
+		return
+	}
+
+	body, err := routeutils.ReadJsonBody[AdminKeyIdRequest](r)
+	if err != nil || body.KeyId == "" {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+
+	err = auth.RevokeAdminKey(body.KeyId)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Failed to revoke admin key, config keys must be removed from the config")
+		return
+	}
+
+	routeutils.WriteResultJson(w, "Admin key revoked")
+}
+
+func getAdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeAdminKeys) {
+		return
+	}
+
+	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
+	if err != nil || pageLength <= 0 {
+		pageLength = 50
+	}
+	if pageLength > 200 {
+		pageLength = 200
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * pageLength
+
+	entries, err := auth.ListAdminAuditLog(r.URL.Query().Get("keyId"), pageLength, offset)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get admin audit log")
+		return
+	}
+
+	entriesJson, err := json.Marshal(entries)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal admin audit log")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(entriesJson))
+}
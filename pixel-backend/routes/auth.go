@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
+)
+
+func InitAuthRoutes() {
+	http.HandleFunc("/get-auth-challenge", getAuthChallenge)
+	http.HandleFunc("/verify-auth-challenge", verifyAuthChallenge)
+	http.HandleFunc("/revoke-auth-session", revokeAuthSession)
+}
+
+type VerifyAuthChallengeRequest struct {
+	Address   string   `json:"address"`
+	Signature []string `json:"signature"`
+}
+
+func getAuthChallenge(w http.ResponseWriter, r *http.Request) {
+	address, err := starknet.NormalizeAddress(r.URL.Query().Get("address"))
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid address parameter")
+		return
+	}
+
+	challenge, err := auth.NewChallenge(address)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to create auth challenge")
+		return
+	}
+
+	typedData, err := json.Marshal(challenge.TypedData())
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal auth challenge")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(typedData))
+}
+
+func verifyAuthChallenge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		routeutils.WriteErrorJson(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	body, err := routeutils.ReadJsonBody[VerifyAuthChallengeRequest](r)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+
+	address, err := starknet.NormalizeAddress(body.Address)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid address")
+		return
+	}
+
+	session, err := auth.VerifyChallenge(address, body.Signature)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusUnauthorized, "Failed to verify auth challenge")
+		return
+	}
+
+	sessionJson, err := json.Marshal(session)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal session")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(sessionJson))
+}
+
+func revokeAuthSession(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AuthMiddleware(w, r) {
+		return
+	}
+
+	err := auth.RevokeSession(routeutils.GetBearerToken(r))
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	routeutils.WriteResultJson(w, "Session revoked")
+}
@@ -2,21 +2,31 @@ package routes
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"net/http"
+	"strconv"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
 
 func InitCanvasRoutes() {
 	http.HandleFunc("/init-canvas", initCanvas)
 	http.HandleFunc("/get-canvas", getCanvas)
+	http.HandleFunc("/rebuild-canvas", rebuildCanvas)
+	http.HandleFunc("/get-canvas-at", getCanvasAt)
+	http.HandleFunc("/get-canvas-region", getCanvasRegion)
 }
 
 func initCanvas(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to initialize canvas
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeCanvasWrite) {
 		return
 	}
 
@@ -58,11 +68,139 @@ func getCanvas(w http.ResponseWriter, r *http.Request) {
 	canvasKey := fmt.Sprintf("canvas-%s", roundNumber)
 
 	ctx := context.Background()
-	val, err := core.AFKBackend.Databases.Redis.Get(ctx, canvasKey).Result()
+	val, seq, err := routeutils.GetCanvasWithSeq(ctx, canvasKey)
 	if err != nil {
 		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get canvas")
 		return
 	}
 
+	// Sequence of the last update in the canvas, to resume the websocket from
+	w.Header().Set("X-Canvas-Sequence", strconv.FormatInt(seq, 10))
 	w.Write([]byte(val))
 }
+
+type RebuildCanvasRequest struct {
+	WorldId *int `json:"worldId"` // main canvas if unset
+	Swap    bool `json:"swap"`
+}
+
+// Rebuilds a canvas from the pixels in postgres, reports the differences with redis and
+// replaces the redis canvas when swap is set
+func rebuildCanvas(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeCanvasWrite) {
+		return
+	}
+
+	request, err := routeutils.ReadJsonBody[RebuildCanvasRequest](r)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	worldId := ""
+	if request.WorldId != nil {
+		worldId = strconv.Itoa(*request.WorldId)
+	}
+	rebuild, err := indexer.RebuildCanvas(worldId, request.Swap)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to rebuild canvas: "+err.Error())
+		return
+	}
+
+	rebuildJson, err := json.Marshal(rebuild)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal canvas rebuild")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(rebuildJson))
+}
+
+func getCanvasAt(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	// Only the current round has its pixels in postgres
+	roundNumber := r.URL.Query().Get("round")
+	if roundNumber != "" && roundNumber != core.AFKBackend.CanvasConfig.Round {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "No pixel history for round "+roundNumber)
+		return
+	}
+
+	writeCanvasAt(w, r, routeutils.MainCanvasHistory())
+}
+
+// writeCanvasAt writes the canvas at the requested time / day as a bitfield, or a png with format=png
+func writeCanvasAt(w http.ResponseWriter, r *http.Request, history *routeutils.CanvasHistory) {
+	at, err := routeutils.HistoryTimeFromRequest(r)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	canvas, err := history.CanvasAt(at)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to rebuild canvas")
+		return
+	}
+
+	w.Header().Set("X-Canvas-Time", at.Format("2006-01-02T15:04:05"))
+	if r.URL.Query().Get("format") != "png" {
+		w.Write(canvas)
+		return
+	}
+
+	palette, err := history.CanvasPalette()
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get colors")
+		return
+	}
+	img := routeutils.CanvasImage(canvas, history.Width, image.Rect(0, 0, int(history.Width), int(history.Height)), palette)
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, img); err != nil {
+		fmt.Println("Failed to encode canvas png", err)
+	}
+}
+
+func getCanvasRegion(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	if r.URL.Query().Get("worldId") != "" {
+		getWorldCanvasRegion(w, r)
+		return
+	}
+
+	canvasConfig := core.AFKBackend.CanvasConfig
+	writeCanvasRegion(w, r, fmt.Sprintf("canvas-%s", canvasConfig.Round), canvasConfig.Canvas.Width, canvasConfig.Canvas.Height)
+}
+
+// writeCanvasRegion writes the x, y, w, h region as a packed bitfield, or the colors row by row
+// with format=json
+func writeCanvasRegion(w http.ResponseWriter, r *http.Request, canvasKey string, canvasWidth uint, canvasHeight uint) {
+	region, err := routeutils.ParseCanvasRegion(r, canvasWidth, canvasHeight)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	colors, err := routeutils.GetCanvasRegion(context.Background(), canvasKey, canvasWidth, region)
+	if errors.Is(err, routeutils.ErrCanvasNotFound) {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Canvas not found")
+		return
+	} else if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get canvas region")
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		colorsJson, err := json.Marshal(colors)
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal canvas region")
+			return
+		}
+		routeutils.WriteDataJson(w, string(colorsJson))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(routeutils.PackCanvasColors(colors, core.AFKBackend.CanvasConfig.ColorsBitWidth))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
@@ -18,7 +19,7 @@ type ColorType = string
 
 func InitColors(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to initialize colors
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeCanvasWrite) {
 		return
 	}
 
@@ -8,7 +8,9 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
 
@@ -17,6 +19,7 @@ func InitContractRoutes() {
 	http.HandleFunc("/set-contract-address", setContractAddress)
 	http.HandleFunc("/get-factory-contract-address", getFactoryContractAddress)
 	http.HandleFunc("/set-factory-contract-address", setFactoryContractAddress)
+	http.HandleFunc("/set-unruggable-factory-address", setUnruggableFactoryAddress)
 	http.HandleFunc("/get-game-data", getGameData)
 }
 
@@ -27,7 +30,7 @@ func getContractAddress(w http.ResponseWriter, r *http.Request) {
 
 func setContractAddress(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to set contract address
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeContractsWrite) {
 		return
 	}
 
@@ -36,6 +39,10 @@ func setContractAddress(w http.ResponseWriter, r *http.Request) {
 		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
+	if err := indexer.SetContractAddress(indexer.ContractArtPeace, string(data)); err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to register contract address")
+		return
+	}
 	os.Setenv("ART_PEACE_CONTRACT_ADDRESS", string(data))
 	routeutils.WriteResultJson(w, "Contract address set")
 }
@@ -47,7 +54,7 @@ func getFactoryContractAddress(w http.ResponseWriter, r *http.Request) {
 
 func setFactoryContractAddress(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to set contract address
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeContractsWrite) {
 		return
 	}
 
@@ -57,11 +64,35 @@ func setFactoryContractAddress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	fmt.Println("Setting factory contract address to: ", string(data))
+	if err := indexer.SetContractAddress(indexer.ContractCanvasFactory, string(data)); err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to register contract address")
+		return
+	}
 	os.Setenv("CANVAS_FACTORY_CONTRACT_ADDRESS", string(data))
 	fmt.Println("Factory contract address set to: ", os.Getenv("CANVAS_FACTORY_CONTRACT_ADDRESS"))
 	routeutils.WriteResultJson(w, "Factory contract address set")
 }
 
+// The memecoins it creates and launches are indexed for the unruggable quests
+func setUnruggableFactoryAddress(w http.ResponseWriter, r *http.Request) {
+	// Only allow admin to set contract address
+	if routeutils.AdminMiddleware(w, r, auth.ScopeContractsWrite) {
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	if err := indexer.SetContractAddress(indexer.ContractUnruggable, string(data)); err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to register contract address")
+		return
+	}
+	os.Setenv("UNRUGGABLE_FACTORY_CONTRACT_ADDRESS", string(data))
+	routeutils.WriteResultJson(w, "Unruggable factory address set")
+}
+
 type GameData struct {
 	Day     int    `json:"day"`
 	EndTime int    `json:"endTime"`
@@ -0,0 +1,208 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+func InitDeadLetterRoutes() {
+	http.HandleFunc("/get-dead-letters", getDeadLetters)
+	http.HandleFunc("/get-dead-letter", getDeadLetter)
+	http.HandleFunc("/replay-dead-letter", replayDeadLetter)
+	http.HandleFunc("/get-quarantined-events", getQuarantinedEvents)
+	http.HandleFunc("/replay-quarantined-event", replayQuarantinedEvent)
+	http.HandleFunc("/get-indexed-contracts", getIndexedContracts)
+}
+
+type DeadLetterKeyRequest struct {
+	Key int64 `json:"key"`
+}
+
+type ReplayDeadLetterResponse struct {
+	Replayed   bool                `json:"replayed"`
+	Error      string              `json:"error,omitempty"`
+	DeadLetter *indexer.DeadLetter `json:"deadLetter"`
+}
+
+func getDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeIndexerWrite) {
+		return
+	}
+
+	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
+	if err != nil || pageLength <= 0 {
+		pageLength = 50
+	}
+	if pageLength > 200 {
+		pageLength = 200
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * pageLength
+
+	deadLetters, err := indexer.ListDeadLetters(r.URL.Query().Get("status"), pageLength, offset)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get dead letters")
+		return
+	}
+
+	deadLettersJson, err := json.Marshal(deadLetters)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal dead letters")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(deadLettersJson))
+}
+
+func getDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeIndexerWrite) {
+		return
+	}
+
+	key, err := strconv.ParseInt(r.URL.Query().Get("key"), 10, 64)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid key")
+		return
+	}
+
+	deadLetter, err := indexer.GetDeadLetter(key)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Dead letter not found")
+		return
+	}
+
+	deadLetterJson, err := json.Marshal(deadLetter)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal dead letter")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(deadLetterJson))
+}
+
+func replayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeIndexerWrite) {
+		return
+	}
+
+	body, err := routeutils.ReadJsonBody[DeadLetterKeyRequest](r)
+	if err != nil || body.Key == 0 {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+
+	deadLetter, err := indexer.ReplayDeadLetter(body.Key)
+	if deadLetter == nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Dead letter not found")
+		return
+	}
+
+	response := ReplayDeadLetterResponse{Replayed: err == nil, DeadLetter: deadLetter}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal dead letter")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(responseJson))
+}
+
+func getQuarantinedEvents(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeIndexerWrite) {
+		return
+	}
+
+	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
+	if err != nil || pageLength <= 0 {
+		pageLength = 50
+	}
+	if pageLength > 200 {
+		pageLength = 200
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * pageLength
+
+	events, err := indexer.ListQuarantinedEvents(pageLength, offset)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get quarantined events")
+		return
+	}
+
+	eventsJson, err := json.Marshal(events)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal quarantined events")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(eventsJson))
+}
+
+type ReplayQuarantinedEventResponse struct {
+	Replayed bool                      `json:"replayed"`
+	Error    string                    `json:"error,omitempty"`
+	Event    *indexer.QuarantinedEvent `json:"event"`
+}
+
+func replayQuarantinedEvent(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeIndexerWrite) {
+		return
+	}
+
+	body, err := routeutils.ReadJsonBody[DeadLetterKeyRequest](r)
+	if err != nil || body.Key == 0 {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+
+	event, err := indexer.ReplayQuarantinedEvent(body.Key)
+	if event == nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Quarantined event not found")
+		return
+	}
+
+	response := ReplayQuarantinedEventResponse{Replayed: err == nil, Event: event}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal quarantined event")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(responseJson))
+}
+
+func getIndexedContracts(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeIndexerWrite) {
+		return
+	}
+
+	contracts, err := indexer.GetContractAddresses()
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get indexed contracts")
+		return
+	}
+
+	contractsJson, err := json.Marshal(contracts)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal indexed contracts")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(contractsJson))
+}
@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"strconv"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
@@ -89,7 +90,7 @@ type FactionMemberData struct {
 
 func initFactions(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to initialize colors
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeFactionsWrite) {
 		return
 	}
 
@@ -114,7 +115,7 @@ func initFactions(w http.ResponseWriter, r *http.Request) {
 
 func uploadFactionIcon(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to initialize colors
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeFactionsWrite) {
 		return
 	}
 
@@ -163,7 +164,10 @@ func uploadFactionIcon(w http.ResponseWriter, r *http.Request) {
 }
 
 func getMyFactions(w http.ResponseWriter, r *http.Request) {
-	address := r.URL.Query().Get("address")
+	if routeutils.AuthMiddleware(w, r) {
+		return
+	}
+	address := routeutils.GetAuthAddress(r)
 	// TODO: Paginate and accumulate the allocations for each faction
 
 	query := `
@@ -220,10 +224,10 @@ func getFactions(w http.ResponseWriter, r *http.Request) {
 }
 
 func getMyChainFactions(w http.ResponseWriter, r *http.Request) {
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		address = "0"
+	if routeutils.AuthMiddleware(w, r) {
+		return
 	}
+	address := routeutils.GetAuthAddress(r)
 
 	query := `
     SELECT f.faction_id, name, 'N/A' as leader, COALESCE((SELECT COUNT(*) FROM chainfactionmembersinfo fm WHERE f.faction_id = fm.faction_id), 0) as members,
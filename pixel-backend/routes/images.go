@@ -0,0 +1,268 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+// Canvas images rendered from the redis bitfields with the Colors / WorldsColors palettes.
+//
+//	/canvas.png, /worlds/{id}.png   whole canvas, from a single GET
+//	/tiles/{world}/{z}/{x}/{y}.png  256x256 tile, world is "main" or a world id. At zoom z each
+//	                                canvas pixel is drawn as 2^z pixels, so a tile covers
+//	                                256 / 2^z canvas pixels per side
+//
+// Rendered images are cached with the canvas sequence they reflect. When the sequence moved, the
+// pixel updates since then are checked and the image is only rendered again if one falls in it.
+
+const (
+	tileSize          = 256
+	maxTileZoom       = 8
+	maxCachedImages   = 2048
+	maxCheckedUpdates = 1000
+)
+
+type cachedImage struct {
+	seq    int64
+	region image.Rectangle
+	png    []byte
+}
+
+var imageCache = make(map[string]*cachedImage)
+var imageCacheLock = &sync.Mutex{}
+
+func InitImageRoutes() {
+	http.HandleFunc("/canvas.png", getCanvasPng)
+	http.HandleFunc("/worlds/{file}", getWorldPng)
+	http.HandleFunc("/tiles/{world}/{z}/{x}/{file}", getTilePng)
+}
+
+func getCanvasPng(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+	writeCanvasPng(w, routeutils.MainCanvasHistory())
+}
+
+func getWorldPng(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	worldId, err := strconv.Atoi(strings.TrimSuffix(r.PathValue("file"), ".png"))
+	if err != nil || !strings.HasSuffix(r.PathValue("file"), ".png") {
+		http.NotFound(w, r)
+		return
+	}
+	canvas, err := routeutils.WorldCanvasHistory(worldId)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "World not found")
+		return
+	}
+	writeCanvasPng(w, canvas)
+}
+
+func getTilePng(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	var canvas *routeutils.CanvasHistory
+	if r.PathValue("world") == "main" {
+		canvas = routeutils.MainCanvasHistory()
+	} else {
+		worldId, err := strconv.Atoi(r.PathValue("world"))
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid world")
+			return
+		}
+		canvas, err = routeutils.WorldCanvasHistory(worldId)
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusNotFound, "World not found")
+			return
+		}
+	}
+
+	z, errZ := strconv.Atoi(r.PathValue("z"))
+	x, errX := strconv.Atoi(r.PathValue("x"))
+	y, errY := strconv.Atoi(strings.TrimSuffix(r.PathValue("file"), ".png"))
+	if errZ != nil || errX != nil || errY != nil || z < 0 || z > maxTileZoom || x < 0 || y < 0 {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid tile")
+		return
+	}
+
+	region := tileRegion(z, x, y, canvas.Width, canvas.Height)
+	if region.Empty() {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Tile outside of the canvas")
+		return
+	}
+
+	cacheKey := fmt.Sprintf("%s/%d/%d/%d", canvas.CanvasKey, z, x, y)
+	pngBytes, err := renderCached(cacheKey, canvas, region, func() (int64, image.Image, error) {
+		ctx := context.Background()
+		// Sequence read before the pixels, the image is at least as recent
+		seq, err := canvasSeq(ctx, canvas.CanvasKey)
+		if err != nil {
+			return 0, nil, err
+		}
+		colors, err := routeutils.GetCanvasRegion(ctx, canvas.CanvasKey, canvas.Width, region)
+		if err != nil {
+			return 0, nil, err
+		}
+		packed := routeutils.PackCanvasColors(colors, core.AFKBackend.CanvasConfig.ColorsBitWidth)
+		palette, err := canvas.CanvasPalette()
+		if err != nil {
+			return 0, nil, err
+		}
+		tile := routeutils.CanvasImage(packed, uint(region.Dx()), image.Rect(0, 0, region.Dx(), region.Dy()), palette)
+		return seq, scaleImage(tile, 1<<z), nil
+	})
+	if errors.Is(err, routeutils.ErrCanvasNotFound) {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Canvas not found")
+		return
+	} else if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to render tile")
+		return
+	}
+	writePng(w, pngBytes)
+}
+
+func writeCanvasPng(w http.ResponseWriter, canvas *routeutils.CanvasHistory) {
+	region := image.Rect(0, 0, int(canvas.Width), int(canvas.Height))
+	pngBytes, err := renderCached(canvas.CanvasKey, canvas, region, func() (int64, image.Image, error) {
+		bitfield, seq, err := routeutils.GetCanvasWithSeq(context.Background(), canvas.CanvasKey)
+		if err != nil {
+			return 0, nil, err
+		}
+		palette, err := canvas.CanvasPalette()
+		if err != nil {
+			return 0, nil, err
+		}
+		return seq, routeutils.CanvasImage([]byte(bitfield), canvas.Width, region, palette), nil
+	})
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to render canvas")
+		return
+	}
+	writePng(w, pngBytes)
+}
+
+func writePng(w http.ResponseWriter, pngBytes []byte) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(pngBytes)
+}
+
+func canvasSeq(ctx context.Context, canvasKey string) (int64, error) {
+	seq, err := core.AFKBackend.Databases.Redis.Get(ctx, routeutils.CanvasSeqKey(canvasKey)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// renderCached returns the cached png of the region if no pixel of it changed, or renders it
+func renderCached(cacheKey string, canvas *routeutils.CanvasHistory, region image.Rectangle, render func() (int64, image.Image, error)) ([]byte, error) {
+	ctx := context.Background()
+	seq, err := canvasSeq(ctx, canvas.CanvasKey)
+	if err != nil {
+		return nil, err
+	}
+
+	imageCacheLock.Lock()
+	cached, ok := imageCache[cacheKey]
+	imageCacheLock.Unlock()
+	if ok && imageUnchanged(ctx, canvas, cached, seq) {
+		return cached.png, nil
+	}
+
+	renderedSeq, img, err := render()
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+
+	imageCacheLock.Lock()
+	if len(imageCache) >= maxCachedImages {
+		// Drop an arbitrary entry
+		for key := range imageCache {
+			delete(imageCache, key)
+			break
+		}
+	}
+	imageCache[cacheKey] = &cachedImage{seq: renderedSeq, region: region, png: buffer.Bytes()}
+	imageCacheLock.Unlock()
+	return buffer.Bytes(), nil
+}
+
+// imageUnchanged checks the pixel updates since the cached image, moving it to seq when none is in it
+func imageUnchanged(ctx context.Context, canvas *routeutils.CanvasHistory, cached *cachedImage, seq int64) bool {
+	imageCacheLock.Lock()
+	cachedSeq := cached.seq
+	imageCacheLock.Unlock()
+	if cachedSeq == seq {
+		return true
+	}
+	if seq < cachedSeq || seq-cachedSeq > maxCheckedUpdates {
+		return false
+	}
+
+	updates, gap, err := routeutils.GetCanvasUpdatesSince(ctx, canvas.CanvasKey, cachedSeq, seq-cachedSeq)
+	if err != nil || gap || !updatesOutside(updates, canvas.Width, cached.region) {
+		return false
+	}
+
+	imageCacheLock.Lock()
+	if cached.seq < seq {
+		cached.seq = seq
+	}
+	imageCacheLock.Unlock()
+	return true
+}
+
+// updatesOutside tells if none of the pixel updates falls in the region, unreadable updates
+// count as inside
+func updatesOutside(updates []map[string]string, canvasWidth uint, region image.Rectangle) bool {
+	for _, update := range updates {
+		position, err := strconv.Atoi(update["position"])
+		if err != nil {
+			return false
+		}
+		point := image.Pt(position%int(canvasWidth), position/int(canvasWidth))
+		if point.In(region) {
+			return false
+		}
+	}
+	return true
+}
+
+// tileRegion returns the canvas pixels drawn in the tile, cut to the canvas
+func tileRegion(z int, x int, y int, canvasWidth uint, canvasHeight uint) image.Rectangle {
+	span := tileSize >> z
+	return image.Rect(x*span, y*span, (x+1)*span, (y+1)*span).Intersect(image.Rect(0, 0, int(canvasWidth), int(canvasHeight)))
+}
+
+// scaleImage draws each pixel as a factor x factor square
+func scaleImage(img *image.RGBA, factor int) *image.RGBA {
+	if factor == 1 {
+		return img
+	}
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*factor, bounds.Dy()*factor))
+	for y := 0; y < bounds.Dy()*factor; y++ {
+		for x := 0; x < bounds.Dx()*factor; x++ {
+			scaled.SetRGBA(x, y, img.RGBAAt(bounds.Min.X+x/factor, bounds.Min.Y+y/factor))
+		}
+	}
+	return scaled
+}
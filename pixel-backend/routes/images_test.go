@@ -0,0 +1,89 @@
+package routes
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestTileRegion(t *testing.T) {
+	tests := []struct {
+		z, x, y int
+		width   uint
+		height  uint
+		region  image.Rectangle
+	}{
+		// A 256 pixels tile at zoom 0
+		{z: 0, x: 0, y: 0, width: 100, height: 100, region: image.Rect(0, 0, 100, 100)},
+		{z: 0, x: 1, y: 0, width: 100, height: 100, region: image.Rectangle{}},
+		{z: 0, x: 1, y: 1, width: 518, height: 396, region: image.Rect(256, 256, 512, 396)},
+		// 2^z pixels per canvas pixel, 256 / 2^z canvas pixels per tile
+		{z: 1, x: 0, y: 0, width: 518, height: 396, region: image.Rect(0, 0, 128, 128)},
+		{z: 2, x: 3, y: 1, width: 518, height: 396, region: image.Rect(192, 64, 256, 128)},
+		{z: 4, x: 32, y: 24, width: 518, height: 396, region: image.Rect(512, 384, 518, 396)},
+		{z: 8, x: 517, y: 395, width: 518, height: 396, region: image.Rect(517, 395, 518, 396)},
+		{z: 8, x: 518, y: 0, width: 518, height: 396, region: image.Rectangle{}},
+	}
+	for _, test := range tests {
+		region := tileRegion(test.z, test.x, test.y, test.width, test.height)
+		if region.Empty() && test.region.Empty() {
+			continue
+		}
+		if region != test.region {
+			t.Errorf("tile %d/%d/%d of %dx%d: region %v, want %v", test.z, test.x, test.y, test.width, test.height, region, test.region)
+		}
+	}
+}
+
+func TestScaleImage(t *testing.T) {
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.SetRGBA(0, 0, red)
+	img.SetRGBA(1, 0, blue)
+
+	for _, factor := range []int{1, 2, 4, 256} {
+		scaled := scaleImage(img, factor)
+		if scaled.Bounds() != image.Rect(0, 0, 2*factor, factor) {
+			t.Errorf("factor %d: bounds %v", factor, scaled.Bounds())
+			continue
+		}
+		for y := 0; y < factor; y++ {
+			for x := 0; x < 2*factor; x++ {
+				want := red
+				if x >= factor {
+					want = blue
+				}
+				if got := scaled.RGBAAt(x, y); got != want {
+					t.Fatalf("factor %d: pixel %d,%d is %v, want %v", factor, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestUpdatesOutside(t *testing.T) {
+	// Tile covering x 64 to 99 and y 0 to 63 of a canvas 100 pixels wide
+	region := image.Rect(64, 0, 100, 64)
+	update := func(position string) map[string]string {
+		return map[string]string{"position": position, "color": "3"}
+	}
+
+	tests := []struct {
+		name    string
+		updates []map[string]string
+		outside bool
+	}{
+		{name: "no updates", outside: true},
+		{name: "left of the tile", updates: []map[string]string{update("0"), update("163")}, outside: true},
+		{name: "below the tile", updates: []map[string]string{update("6464")}, outside: true},
+		{name: "first pixel", updates: []map[string]string{update("64")}},
+		{name: "last pixel", updates: []map[string]string{update("12"), update("6399")}},
+		{name: "unreadable position", updates: []map[string]string{update("12"), {"color": "3"}}},
+	}
+	for _, test := range tests {
+		if outside := updatesOutside(test.updates, 100, region); outside != test.outside {
+			t.Errorf("%s: outside %t, want %t", test.name, outside, test.outside)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+// Events touching both Postgres and Redis describe their writes in an EventApplication.
+// The SQL statements and an outbox row holding the Redis ops are committed in one transaction,
+// the Redis ops are applied after the commit and the outbox row is then dropped. Rows left
+// behind ( Redis down, crash ) are retried by the reconciler.
+//
+// Writers of the Redis canvases hold a Postgres advisory lock from the commit ( or the read of
+// Postgres ) to the Redis write, so the reconciler never sets a pixel from a stale snapshot.
+// It is shared by the consumer and the admin replays of the backend.
+
+type SqlStatement struct {
+	Query string
+	Args  []interface{}
+}
+
+const (
+	RedisOpSetPixel     = "setPixel"
+	RedisOpCreateCanvas = "createCanvas"
+	RedisOpDeleteCanvas = "deleteCanvas"
+)
+
+type RedisOp struct {
+	Kind         string            `json:"kind"`
+	CanvasKey    string            `json:"canvasKey"`
+	BitfieldType string            `json:"bitfieldType,omitempty"`
+	Offset       uint              `json:"offset,omitempty"`
+	Color        int64             `json:"color,omitempty"`
+	Size         uint              `json:"size,omitempty"`
+	Message      map[string]string `json:"message,omitempty"` // sent to websocket clients once applied
+}
+
+type EventApplication struct {
+	Sql   []SqlStatement
+	Redis []RedisOp
+}
+
+func (a *EventApplication) Exec(query string, args ...interface{}) {
+	a.Sql = append(a.Sql, SqlStatement{Query: query, Args: args})
+}
+
+func (a *EventApplication) SetPixel(canvasKey string, position uint, color int64, message map[string]string) {
+	bitWidth := core.AFKBackend.CanvasConfig.ColorsBitWidth
+	a.Redis = append(a.Redis, RedisOp{
+		Kind:         RedisOpSetPixel,
+		CanvasKey:    canvasKey,
+		BitfieldType: fmt.Sprintf("u%d", bitWidth),
+		Offset:       position * bitWidth,
+		Color:        color,
+		Message:      message,
+	})
+}
+
+func (a *EventApplication) CreateCanvas(canvasKey string, width uint, height uint) {
+	totalBitSize := width * height * core.AFKBackend.CanvasConfig.ColorsBitWidth
+	totalByteSize := totalBitSize / 8
+	if totalBitSize%8 != 0 {
+		totalByteSize += 1
+	}
+	a.Redis = append(a.Redis, RedisOp{Kind: RedisOpCreateCanvas, CanvasKey: canvasKey, Size: totalByteSize})
+}
+
+func (a *EventApplication) DeleteCanvas(canvasKey string) {
+	a.Redis = append(a.Redis, RedisOp{Kind: RedisOpDeleteCanvas, CanvasKey: canvasKey})
+}
+
+// Arbitrary key of the advisory lock, "afkcanvs"
+const canvasWritesLockId = 0x61666b63616e7673
+
+// lockCanvasWrites blocks until no other process writes the canvases, the lock is held by a
+// pool connection until the returned func is called
+func lockCanvasWrites(ctx context.Context) (func(), error) {
+	conn, err := core.AFKBackend.Databases.Postgres.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", int64(canvasWritesLockId)); err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return func() {
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", int64(canvasWritesLockId)); err != nil {
+			// Closing the connection drops its session locks
+			logIndexerError("lockCanvasWrites", "Failed to unlock canvas writes", err)
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}, nil
+}
+
+// Apply commits the SQL statements with the outbox row, then applies the Redis ops
+func (a *EventApplication) Apply() error {
+	ctx := context.Background()
+	if len(a.Redis) > 0 {
+		unlock, err := lockCanvasWrites(ctx)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, statement := range a.Sql {
+		if _, err := tx.Exec(ctx, statement.Query, statement.Args...); err != nil {
+			return fmt.Errorf("%s: %w", statement.Query, err)
+		}
+	}
+
+	var outboxKey int64
+	if len(a.Redis) > 0 {
+		ops, err := json.Marshal(a.Redis)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRow(ctx, "INSERT INTO RedisOutbox (ops) VALUES ($1) RETURNING key", ops).Scan(&outboxKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	if len(a.Redis) == 0 {
+		return nil
+	}
+
+	// Events can be applied out of chain order ( dead letter or quarantine replays ), the
+	// pixels are set to the latest one in chain order rather than to the event's
+	for idx := range a.Redis {
+		if a.Redis[idx].Kind != RedisOpSetPixel {
+			continue
+		}
+		if err := refreshPixelOp(&a.Redis[idx]); err != nil {
+			logIndexerError("EventApplication.Apply", "Failed to read the latest pixel, left in outbox", outboxKey, err)
+			return nil
+		}
+	}
+
+	// Postgres is committed, a Redis failure is left to the outbox retry
+	if err := applyRedisOps(ctx, outboxKey, a.Redis); err != nil {
+		logIndexerError("EventApplication.Apply", "Redis ops failed, left in outbox", outboxKey, err)
+	}
+	return nil
+}
+
+// applyRedisOps runs the ops of an outbox row ( 0 for ops outside the outbox ) and notifies clients
+func applyRedisOps(ctx context.Context, outboxKey int64, ops []RedisOp) error {
+	redis := core.AFKBackend.Databases.Redis
+	for _, op := range ops {
+		var err error
+		switch op.Kind {
+		case RedisOpSetPixel:
+			_, err = routeutils.SetCanvasPixel(ctx, op.CanvasKey, op.BitfieldType, op.Offset, op.Color, op.Message)
+		case RedisOpCreateCanvas:
+			// Keep an existing canvas, the op may be retried
+			err = redis.SetNX(ctx, op.CanvasKey, make([]byte, op.Size), 0).Err()
+		case RedisOpDeleteCanvas:
+			err = redis.Del(ctx, op.CanvasKey, routeutils.CanvasSeqKey(op.CanvasKey), routeutils.CanvasUpdatesKey(op.CanvasKey)).Err()
+		default:
+			err = fmt.Errorf("unknown redis op %s", op.Kind)
+		}
+		if err != nil {
+			if outboxKey != 0 {
+				core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE RedisOutbox SET attempts = attempts + 1 WHERE key = $1", outboxKey)
+			}
+			return err
+		}
+	}
+
+	if outboxKey != 0 {
+		_, err := core.AFKBackend.Databases.Postgres.Exec(ctx, "DELETE FROM RedisOutbox WHERE key = $1", outboxKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, op := range ops {
+		if op.Message != nil {
+			routeutils.SendMessageToWSS(op.Message)
+		}
+	}
+	return nil
+}
+
+type redisOutboxRow struct {
+	Key int64  `json:"key"`
+	Ops []byte `json:"ops"`
+}
+
+// RetryRedisOutbox applies the outbox rows older than a few seconds, ie not in flight. Events
+// applied since may have changed the pixels, so their colors are read again from Postgres.
+func RetryRedisOutbox() (int, error) {
+	rows, err := core.PostgresQuery[redisOutboxRow]("SELECT key, ops FROM RedisOutbox WHERE created_at < NOW() - INTERVAL '30 seconds' ORDER BY key ASC")
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, row := range rows {
+		var ops []RedisOp
+		if err := json.Unmarshal(row.Ops, &ops); err != nil {
+			logIndexerError("RetryRedisOutbox", "Invalid outbox ops", row.Key, err)
+			continue
+		}
+		if err := retryOutboxRow(row.Key, ops); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+func retryOutboxRow(key int64, ops []RedisOp) error {
+	ctx := context.Background()
+	unlock, err := lockCanvasWrites(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for idx := range ops {
+		if ops[idx].Kind != RedisOpSetPixel {
+			continue
+		}
+		if err := refreshPixelOp(&ops[idx]); err != nil {
+			return err
+		}
+	}
+	return applyRedisOps(ctx, key, ops)
+}
+
+// pixelOpPosition reads the canvas position back from the bit offset of a setPixel op
+func pixelOpPosition(op RedisOp) (uint, error) {
+	bitWidth, err := strconv.ParseUint(strings.TrimPrefix(op.BitfieldType, "u"), 10, 32)
+	if err != nil || bitWidth == 0 || op.Offset%uint(bitWidth) != 0 {
+		return 0, fmt.Errorf("invalid bitfield type %s for offset %d", op.BitfieldType, op.Offset)
+	}
+	return op.Offset / uint(bitWidth), nil
+}
+
+// refreshPixelOp sets the op color to the latest pixel at its position
+func refreshPixelOp(op *RedisOp) error {
+	position, err := pixelOpPosition(*op)
+	if err != nil {
+		return err
+	}
+
+	color, err := latestPixelColor(op.Message["worldId"], position)
+	if err != nil {
+		return err
+	}
+	op.Color = color
+	if op.Message != nil {
+		op.Message["color"] = strconv.FormatInt(color, 10)
+	}
+	return nil
+}
+
+// Pixels are ordered by their block, then by insertion within the block. Keys alone follow the
+// order events were applied in, which replays break.
+const latestPixelOrder = "block_number DESC, key DESC"
+
+// latestPixelColor reads the latest color at a position of the main canvas, or of the world
+// canvas when worldId is set, 0 when no pixel is left
+func latestPixelColor(worldId string, position uint) (int64, error) {
+	query := "SELECT color FROM Pixels WHERE position = $1 ORDER BY " + latestPixelOrder + " LIMIT 1"
+	args := []interface{}{position}
+	if worldId != "" {
+		id, err := strconv.Atoi(worldId)
+		if err != nil {
+			return 0, fmt.Errorf("invalid world id %s", worldId)
+		}
+		query = "SELECT color FROM WorldsPixels WHERE world_id = $2 AND position = $1 ORDER BY " + latestPixelOrder + " LIMIT 1"
+		args = append(args, id)
+	}
+	current, err := core.PostgresQuery[int64](query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if len(current) == 0 {
+		return 0, nil
+	}
+	return current[0], nil
+}
@@ -0,0 +1,159 @@
+package indexer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// useTestColorsBitWidth runs the test with the canvas color width, the backend is restored after it
+func useTestColorsBitWidth(t *testing.T, bitWidth uint) {
+	saved := core.AFKBackend
+	core.AFKBackend = &core.Backend{CanvasConfig: &config.CanvasConfig{ColorsBitWidth: bitWidth, Round: "test"}}
+	t.Cleanup(func() { core.AFKBackend = saved })
+}
+
+func TestEventApplicationSetPixel(t *testing.T) {
+	tests := []struct {
+		bitWidth     uint
+		position     uint
+		bitfieldType string
+		offset       uint
+	}{
+		{bitWidth: 5, position: 0, bitfieldType: "u5", offset: 0},
+		{bitWidth: 5, position: 3, bitfieldType: "u5", offset: 15},
+		{bitWidth: 5, position: 9999, bitfieldType: "u5", offset: 49995},
+		{bitWidth: 8, position: 7, bitfieldType: "u8", offset: 56},
+		{bitWidth: 3, position: 11, bitfieldType: "u3", offset: 33},
+	}
+	for _, test := range tests {
+		useTestColorsBitWidth(t, test.bitWidth)
+		app := &EventApplication{}
+		app.SetPixel("canvas-test", test.position, 4, map[string]string{"position": "x"})
+
+		if len(app.Redis) != 1 {
+			t.Fatalf("u%d position %d: %d redis ops, want 1", test.bitWidth, test.position, len(app.Redis))
+		}
+		op := app.Redis[0]
+		if op.Kind != RedisOpSetPixel || op.CanvasKey != "canvas-test" || op.Color != 4 {
+			t.Errorf("u%d position %d: op %+v", test.bitWidth, test.position, op)
+		}
+		if op.BitfieldType != test.bitfieldType || op.Offset != test.offset {
+			t.Errorf("u%d position %d: %s at %d, want %s at %d", test.bitWidth, test.position, op.BitfieldType, op.Offset, test.bitfieldType, test.offset)
+		}
+	}
+}
+
+func TestEventApplicationCreateCanvas(t *testing.T) {
+	tests := []struct {
+		bitWidth uint
+		width    uint
+		height   uint
+		size     uint
+	}{
+		{bitWidth: 5, width: 100, height: 100, size: 6250},
+		// 45 bits, rounded up to the byte
+		{bitWidth: 5, width: 3, height: 3, size: 6},
+		{bitWidth: 8, width: 3, height: 3, size: 9},
+		{bitWidth: 3, width: 1, height: 1, size: 1},
+		{bitWidth: 5, width: 0, height: 10, size: 0},
+	}
+	for _, test := range tests {
+		useTestColorsBitWidth(t, test.bitWidth)
+		app := &EventApplication{}
+		app.CreateCanvas("canvas-7", test.width, test.height)
+
+		op := app.Redis[0]
+		if op.Kind != RedisOpCreateCanvas || op.CanvasKey != "canvas-7" {
+			t.Errorf("%dx%d u%d: op %+v", test.width, test.height, test.bitWidth, op)
+		}
+		if op.Size != test.size {
+			t.Errorf("%dx%d u%d: size %d, want %d", test.width, test.height, test.bitWidth, op.Size, test.size)
+		}
+	}
+}
+
+func TestRedisOutboxOps(t *testing.T) {
+	useTestColorsBitWidth(t, 5)
+
+	tests := []struct {
+		name  string
+		apply func(app *EventApplication)
+	}{
+		{name: "no ops", apply: func(app *EventApplication) {}},
+		{name: "pixel", apply: func(app *EventApplication) {
+			app.SetPixel("canvas-test", 12, 3, map[string]string{"position": "12", "color": "3", "messageType": "colorPixel"})
+		}},
+		// Zero values are omitted from the row and read back as zero
+		{name: "pixel at origin without message", apply: func(app *EventApplication) {
+			app.SetPixel("canvas-test", 0, 0, nil)
+		}},
+		{name: "world lifecycle", apply: func(app *EventApplication) {
+			app.CreateCanvas("canvas-3", 16, 16)
+			app.SetPixel("canvas-3", 255, 31, map[string]string{"worldId": "3", "position": "255", "color": "31", "messageType": "colorWorldPixel"})
+			app.DeleteCanvas("canvas-3")
+		}},
+	}
+	for _, test := range tests {
+		app := &EventApplication{}
+		test.apply(app)
+
+		// The ops are stored as the outbox row and read back by the retry
+		row, err := json.Marshal(app.Redis)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %v", test.name, err)
+		}
+		var ops []RedisOp
+		if err := json.Unmarshal(row, &ops); err != nil {
+			t.Fatalf("%s: Unmarshal: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(ops, app.Redis) {
+			t.Errorf("%s: outbox ops %+v, want %+v", test.name, ops, app.Redis)
+		}
+	}
+}
+
+func TestPixelOpPosition(t *testing.T) {
+	tests := []struct {
+		op       RedisOp
+		position uint
+		valid    bool
+	}{
+		{op: RedisOp{BitfieldType: "u5", Offset: 0}, position: 0, valid: true},
+		{op: RedisOp{BitfieldType: "u5", Offset: 15}, position: 3, valid: true},
+		{op: RedisOp{BitfieldType: "u8", Offset: 56}, position: 7, valid: true},
+		{op: RedisOp{BitfieldType: "u5", Offset: 16}},
+		{op: RedisOp{BitfieldType: "u0", Offset: 0}},
+		{op: RedisOp{BitfieldType: "i5", Offset: 15}},
+		{op: RedisOp{BitfieldType: "", Offset: 15}},
+	}
+	for _, test := range tests {
+		position, err := pixelOpPosition(test.op)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s at %d: position %d, want an error", test.op.BitfieldType, test.op.Offset, position)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s at %d: %v", test.op.BitfieldType, test.op.Offset, err)
+		} else if position != test.position {
+			t.Errorf("%s at %d: position %d, want %d", test.op.BitfieldType, test.op.Offset, position, test.position)
+		}
+	}
+
+	// The ops of the application map back to their positions
+	useTestColorsBitWidth(t, 5)
+	app := &EventApplication{}
+	for _, position := range []uint{0, 1, 64, 9999} {
+		app.SetPixel("canvas-test", position, 1, nil)
+	}
+	for idx, position := range []uint{0, 1, 64, 9999} {
+		if got, err := pixelOpPosition(app.Redis[idx]); err != nil || got != position {
+			t.Errorf("op %d: position %d ( %v ), want %d", idx, got, err, position)
+		}
+	}
+}
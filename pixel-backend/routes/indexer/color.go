@@ -2,44 +2,41 @@ package indexer
 
 import (
 	"context"
-	"strconv"
+	"fmt"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 )
 
-func processColorAddedEvent(event IndexerEvent) {
-	colorKeyHex := event.Event.Keys[1]
-	colorHex := event.Event.Data[0]
+type colorAdded struct {
+	ColorKey int64  `cairo:"key"`
+	Color    uint32 `cairo:"data"`
+}
 
-	colorKey, err := strconv.ParseInt(colorKeyHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processColorAddedEvent", "Error converting color key hex to int", colorKeyHex, colorHex)
-		return
+func processColorAddedEvent(event IndexerEvent) error {
+	var added colorAdded
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("processColorAddedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
-
-	color := colorHex[len(colorHex)-6:]
+	color := fmt.Sprintf("%06x", added.Color)
 
 	// Set color in postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Colors (color_key, hex) VALUES ($1, $2)", colorKey, color)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Colors (color_key, hex) VALUES ($1, $2)", added.ColorKey, color)
 	if err != nil {
-		PrintIndexerError("processColorAddedEvent", "Error inserting color into postgres", colorKey, color)
-		return
+		return PrintIndexerError("processColorAddedEvent", "Error inserting color into postgres", added.ColorKey, color, err)
 	}
+	return nil
 }
 
-func revertColorAddedEvent(event IndexerEvent) {
-	colorIdxHex := event.Event.Keys[1]
-
-	colorIdx, err := strconv.ParseInt(colorIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertColorAddedEvent", "Error converting color index hex to int", colorIdxHex)
-		return
+func revertColorAddedEvent(event IndexerEvent) error {
+	var added colorAdded
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("revertColorAddedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Delete color from postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM colors WHERE color_key = $1", colorIdx)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM colors WHERE color_key = $1", added.ColorKey)
 	if err != nil {
-		PrintIndexerError("revertColorAddedEvent", "Error deleting color from postgres", colorIdx)
-		return
+		return PrintIndexerError("revertColorAddedEvent", "Error deleting color from postgres", added.ColorKey, err)
 	}
+	return nil
 }
@@ -0,0 +1,311 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
+)
+
+// Events are routed by ( fromAddress, selector ): each selector belongs to one of our contracts
+// and is only handled when emitted by that contract's address. The addresses are seeded from the
+// environment when the processor starts and changed with the set-*-address routes, which write
+// ContractAddresses so the consumer picks them up. Events from any other source are quarantined,
+// and can be replayed once their address is registered.
+
+const (
+	ContractArtPeace      = "art-peace"
+	ContractCanvasFactory = "canvas-factory"
+	ContractUsernameStore = "username-store"
+	ContractCanvasNFT     = "canvas-nft"
+	ContractUnruggable    = "unruggable-factory"
+)
+
+var contractEnvs = map[string]string{
+	ContractArtPeace:      "ART_PEACE_CONTRACT_ADDRESS",
+	ContractCanvasFactory: "CANVAS_FACTORY_CONTRACT_ADDRESS",
+	ContractUsernameStore: "USERNAME_STORE_CONTRACT_ADDRESS",
+	ContractCanvasNFT:     "CANVAS_NFT_CONTRACT_ADDRESS",
+	ContractUnruggable:    "UNRUGGABLE_FACTORY_CONTRACT_ADDRESS",
+}
+
+var eventContracts = map[string]string{
+	newDayEvent:                      ContractArtPeace,
+	colorAddedEvent:                  ContractArtPeace,
+	pixelPlacedEvent:                 ContractArtPeace,
+	basicPixelPlacedEvent:            ContractArtPeace,
+	factionPixelsPlacedEvent:         ContractArtPeace,
+	chainFactionPixelsPlacedEvent:    ContractArtPeace,
+	extraPixelsPlacedEvent:           ContractArtPeace,
+	dailyQuestClaimedEvent:           ContractArtPeace,
+	mainQuestClaimedEvent:            ContractArtPeace,
+	voteColorEvent:                   ContractArtPeace,
+	votableColorAddedEvent:           ContractArtPeace,
+	factionCreatedEvent:              ContractArtPeace,
+	factionLeaderChangedEvent:        ContractArtPeace,
+	factionJoinedEvent:               ContractArtPeace,
+	factionLeftEvent:                 ContractArtPeace,
+	chainFactionCreatedEvent:         ContractArtPeace,
+	chainFactionJoinedEvent:          ContractArtPeace,
+	factionTemplateAddedEvent:        ContractArtPeace,
+	factionTemplateRemovedEvent:      ContractArtPeace,
+	chainFactionTemplateAddedEvent:   ContractArtPeace,
+	chainFactionTemplateRemovedEvent: ContractArtPeace,
+	hostAwardedPixelsEvent:           ContractArtPeace,
+	nftMintedEvent:                   ContractCanvasNFT,
+	nftLikedEvent:                    ContractCanvasNFT,
+	nftUnlikedEvent:                  ContractCanvasNFT,
+	nftTransferEvent:                 ContractCanvasNFT,
+	usernameClaimedEvent:             ContractUsernameStore,
+	usernameChangedEvent:             ContractUsernameStore,
+	canvasCreatedEvent:               ContractCanvasFactory,
+	canvasHostChangedEvent:           ContractCanvasFactory,
+	canvasPixelsPerTimeChangedEvent:  ContractCanvasFactory,
+	canvasTimerChangedEvent:          ContractCanvasFactory,
+	canvasStartTimeChangedEvent:      ContractCanvasFactory,
+	canvasEndTimeChangedEvent:        ContractCanvasFactory,
+	canvasColorAddedEvent:            ContractCanvasFactory,
+	canvasPixelPlacedEvent:           ContractCanvasFactory,
+	canvasBasicPixelPlacedEvent:      ContractCanvasFactory,
+	canvasExtraPixelsPlacedEvent:     ContractCanvasFactory,
+	canvasHostAwardedUserEvent:       ContractCanvasFactory,
+	canvasFavoritedEvent:             ContractCanvasFactory,
+	canvasUnfavoritedEvent:           ContractCanvasFactory,
+	stencilAddedEvent:                ContractCanvasFactory,
+	stencilRemovedEvent:              ContractCanvasFactory,
+	stencilFavoritedEvent:            ContractCanvasFactory,
+	stencilUnfavoritedEvent:          ContractCanvasFactory,
+	memecoinCreatedEvent:             ContractUnruggable,
+	memecoinLaunchedEvent:            ContractUnruggable,
+}
+
+const contractsRefreshInterval = 30 * time.Second
+
+type ContractAddress struct {
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Cached ContractAddresses, name -> normalized address
+var contractAddresses = make(map[string]string)
+var contractsLoadedAt time.Time
+var contractsLock = &sync.Mutex{}
+
+// SeedContractAddresses registers the addresses set in the environment for the contracts
+// without one, the addresses set by the admin routes are kept across restarts
+func SeedContractAddresses() error {
+	ctx := context.Background()
+	for name, env := range contractEnvs {
+		value := os.Getenv(env)
+		if value == "" {
+			logIndexerError("SeedContractAddresses", "No address for contract, its events will be quarantined", name, env)
+			continue
+		}
+		normalized, err := starknet.NormalizeAddress(value)
+		if err != nil {
+			return err
+		}
+
+		_, err = core.AFKBackend.Databases.Postgres.Exec(ctx, "INSERT INTO ContractAddresses (name, address) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING", name, normalized)
+		if err != nil {
+			return err
+		}
+		registered, err := core.PostgresQueryOne[string]("SELECT address FROM ContractAddresses WHERE name = $1", name)
+		if err != nil {
+			return err
+		}
+		if *registered != normalized {
+			logIndexerError("SeedContractAddresses", "Keeping the registered address over the environment", name, *registered, env, normalized)
+		}
+	}
+
+	// Reload the cache on the next event
+	contractsLock.Lock()
+	contractsLoadedAt = time.Time{}
+	contractsLock.Unlock()
+	return nil
+}
+
+// SetContractAddress registers the address of one of the contracts, replacing the current one.
+// The indexer only handles the events emitted by the registered address, see eventSourceError.
+func SetContractAddress(name string, address string) error {
+	if _, ok := contractEnvs[name]; !ok {
+		return fmt.Errorf("unknown contract %s", name)
+	}
+	normalized, err := starknet.NormalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ContractAddresses (name, address) VALUES ($1, $2) ON CONFLICT (name) DO UPDATE SET address = $2, updated_at = CURRENT_TIMESTAMP", name, normalized)
+	if err != nil {
+		return err
+	}
+
+	contractsLock.Lock()
+	contractAddresses[name] = normalized
+	contractsLock.Unlock()
+	return nil
+}
+
+func GetContractAddresses() ([]ContractAddress, error) {
+	return core.PostgresQuery[ContractAddress]("SELECT name, address, updated_at FROM ContractAddresses ORDER BY name ASC")
+}
+
+// registeredAddress returns the cached address of the contract, reloaded every few seconds
+func registeredAddress(name string) (string, error) {
+	contractsLock.Lock()
+	defer contractsLock.Unlock()
+
+	if time.Since(contractsLoadedAt) > contractsRefreshInterval {
+		contracts, err := core.PostgresQuery[ContractAddress]("SELECT name, address, updated_at FROM ContractAddresses")
+		if err != nil {
+			return "", err
+		}
+		contractAddresses = make(map[string]string, len(contracts))
+		for _, contract := range contracts {
+			contractAddresses[contract.Name] = contract.Address
+		}
+		contractsLoadedAt = time.Now()
+	}
+	return contractAddresses[name], nil
+}
+
+// eventSourceError returns why the event does not come from the contract handling its selector
+func eventSourceError(event IndexerEvent) (string, error) {
+	eventKey := event.Event.Keys[0]
+	name, ok := eventContracts[eventKey]
+	if !ok {
+		// No handler either, left to the dead letters
+		return "", nil
+	}
+
+	expected, err := registeredAddress(name)
+	if err != nil {
+		return "", err
+	}
+	if expected == "" {
+		return fmt.Sprintf("no address registered for %s", name), nil
+	}
+	fromAddress, err := starknet.NormalizeAddress(event.Event.FromAddress)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if fromAddress != expected {
+		return fmt.Sprintf("event %s expected from %s %s", eventKey, name, expected), nil
+	}
+	return "", nil
+}
+
+const (
+	QuarantineStatusQuarantined = "quarantined"
+	QuarantineStatusReplayed    = "replayed"
+)
+
+type QuarantinedEvent struct {
+	Key            int64      `json:"key"`
+	EventKey       string     `json:"eventKey"`
+	FromAddress    string     `json:"fromAddress"`
+	Keys           []string   `json:"keys"`
+	Data           []string   `json:"data"`
+	OrderKey       int        `json:"orderKey"`
+	UniqueKey      string     `json:"uniqueKey"`
+	Finality       string     `json:"finality"`
+	BlockNumber    int64      `json:"blockNumber"`
+	BlockTimestamp *int64     `json:"blockTimestamp"` // unix seconds, nil without the block header
+	Reason         string     `json:"reason"`
+	Seen           int        `json:"seen"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	ReplayedAt     *time.Time `json:"replayedAt"`
+}
+
+// quarantineEventHash identifies the event within its block, the keys and data are
+// hashed since indexing the arrays fails once they outgrow a btree row
+func quarantineEventHash(keys []string, data []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(keys, ",") + "|" + strings.Join(data, ",")))
+	return hex.EncodeToString(hash[:])
+}
+
+// quarantineEvent stores an event from an unknown source instead of handling it. Pending
+// events are only logged, they are quarantined once accepted.
+func quarantineEvent(event IndexerEvent, message IndexerMessage, reason string) error {
+	logIndexerError("quarantineEvent", "Event from an unknown source, quarantined", event.Event.Keys[0], event.Event.FromAddress, reason)
+	if message.Data.Finality == DATA_STATUS_PENDING {
+		return nil
+	}
+
+	keys := event.Event.Keys
+	data := event.Event.Data
+	if data == nil {
+		data = []string{}
+	}
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO IndexerQuarantine (event_key, from_address, keys, data, event_hash, order_key, unique_key, finality, block_number, block_timestamp, reason) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) ON CONFLICT (unique_key, from_address, event_hash) DO UPDATE SET seen = IndexerQuarantine.seen + 1", keys[0], event.Event.FromAddress, keys, data, quarantineEventHash(keys, data), message.Data.Cursor.OrderKey, message.Data.Cursor.UniqueKey, message.Data.Finality, event.Block.Number, event.Block.Timestamp, reason)
+	return err
+}
+
+const quarantineColumns = "key, event_key, from_address, keys, data, order_key, unique_key, finality, block_number, block_timestamp, reason, seen, status, created_at, replayed_at"
+
+// ListQuarantinedEvents returns the quarantined events, newest first
+func ListQuarantinedEvents(limit int, offset int) ([]QuarantinedEvent, error) {
+	return core.PostgresQuery[QuarantinedEvent]("SELECT "+quarantineColumns+" FROM IndexerQuarantine ORDER BY key DESC LIMIT $1 OFFSET $2", limit, offset)
+}
+
+func GetQuarantinedEvent(key int64) (*QuarantinedEvent, error) {
+	return core.PostgresQueryOne[QuarantinedEvent]("SELECT "+quarantineColumns+" FROM IndexerQuarantine WHERE key = $1", key)
+}
+
+// ReplayQuarantinedEvent runs the event through its handler once its address is registered,
+// the event is marked replayed on success or keeps the new reason otherwise
+func ReplayQuarantinedEvent(key int64) (*QuarantinedEvent, error) {
+	quarantined, err := GetQuarantinedEvent(key)
+	if err != nil {
+		return nil, err
+	}
+	if quarantined.Status != QuarantineStatusQuarantined {
+		return quarantined, fmt.Errorf("quarantined event %d already %s", key, quarantined.Status)
+	}
+
+	event := IndexerEvent{}
+	event.Event.FromAddress = quarantined.FromAddress
+	event.Event.Keys = quarantined.Keys
+	event.Event.Data = quarantined.Data
+	event.Block = IndexerEventBlock{Number: quarantined.BlockNumber, Timestamp: quarantined.BlockTimestamp}
+
+	var replayErr error
+	handler, ok := eventHandlers(DeadLetterProcess)[quarantined.EventKey]
+	reason, sourceErr := eventSourceError(event)
+	if !ok {
+		replayErr = fmt.Errorf("no %s handler for event %s", DeadLetterProcess, quarantined.EventKey)
+	} else if sourceErr != nil {
+		replayErr = fmt.Errorf("failed to check the event source: %w", sourceErr)
+	} else if reason != "" {
+		replayErr = fmt.Errorf("unknown event source: %s", reason)
+	} else {
+		replayErr = runEventHandler(handler, event)
+	}
+
+	ctx := context.Background()
+	if replayErr != nil {
+		_, err = core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE IndexerQuarantine SET reason = $2 WHERE key = $1", key, replayErr.Error())
+	} else {
+		_, err = core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE IndexerQuarantine SET status = $2, replayed_at = CURRENT_TIMESTAMP WHERE key = $1", key, QuarantineStatusReplayed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := GetQuarantinedEvent(key)
+	if err != nil {
+		return nil, err
+	}
+	return updated, replayErr
+}
@@ -0,0 +1,26 @@
+package indexer
+
+import (
+	"testing"
+)
+
+func TestQuarantineEventHash(t *testing.T) {
+	keys := []string{"0x1", "0x2"}
+	data := []string{"0x3"}
+
+	hash := quarantineEventHash(keys, data)
+	if len(hash) != 64 {
+		t.Fatalf("hash = %s, want 64 hex chars", hash)
+	}
+	if quarantineEventHash([]string{"0x1", "0x2"}, []string{"0x3"}) != hash {
+		t.Errorf("hash is not deterministic")
+	}
+
+	// Moving a felt between the keys and data is another event
+	if quarantineEventHash([]string{"0x1"}, []string{"0x2", "0x3"}) == hash {
+		t.Errorf("keys and data are not separated in the hash")
+	}
+	if quarantineEventHash(keys, []string{}) == hash {
+		t.Errorf("data is not part of the hash")
+	}
+}
@@ -2,48 +2,50 @@ package indexer
 
 import (
 	"context"
-	"strconv"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
 )
 
-func processNewDayEvent(event IndexerEvent) {
-	dayIdxHex := event.Event.Keys[1]
-	dayStartTimeHex := event.Event.Data[0]
+type newDay struct {
+	DayIndex int64 `cairo:"key"`
+	DayStart int64 `cairo:"data"`
+}
 
-	dayIdx, err := strconv.ParseInt(dayIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processNewDayEvent", "Error converting day index hex to int", dayIdxHex, dayStartTimeHex)
-		return
+func processNewDayEvent(event IndexerEvent) error {
+	var day newDay
+	if err := decodeEvent(event, &day); err != nil {
+		return PrintIndexerError("processNewDayEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	dayStartTime, err := strconv.ParseInt(dayStartTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processNewDayEvent", "Error converting day start time hex to int", dayIdxHex, dayStartTimeHex)
-		return
+	// Write the recurring quests of the day first, they are skipped once written so a failed
+	// day insert can be replayed
+	if _, err := quests.MaterializeRecurringQuests(int(day.DayIndex)); err != nil {
+		return PrintIndexerError("processNewDayEvent", "Error writing recurring quests", day.DayIndex, err)
 	}
 
 	// Set day in postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Days (day_index, day_start) VALUES ($1, to_timestamp($2))", dayIdx, dayStartTime)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Days (day_index, day_start) VALUES ($1, to_timestamp($2))", day.DayIndex, day.DayStart)
 	if err != nil {
-		PrintIndexerError("processNewDayEvent", "Error inserting day into postgres", dayIdx, dayStartTime)
-		return
+		return PrintIndexerError("processNewDayEvent", "Error inserting day into postgres", day.DayIndex, day.DayStart, err)
 	}
+	return nil
 }
 
-func revertNewDayEvent(event IndexerEvent) {
-	dayIdxHex := event.Event.Keys[1]
-
-	dayIdx, err := strconv.ParseInt(dayIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertNewDayEvent", "Error converting day index hex to int", dayIdxHex)
-		return
+func revertNewDayEvent(event IndexerEvent) error {
+	var day newDay
+	if err := decodeEvent(event, &day); err != nil {
+		return PrintIndexerError("revertNewDayEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Delete day from postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Days WHERE day_index = $1", dayIdx)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Days WHERE day_index = $1", day.DayIndex)
 	if err != nil {
-		PrintIndexerError("revertNewDayEvent", "Error deleting day from postgres", dayIdx)
-		return
+		return PrintIndexerError("revertNewDayEvent", "Error deleting day from postgres", day.DayIndex, err)
+	}
+
+	if err := quests.RemoveRecurringQuests(int(day.DayIndex)); err != nil {
+		return PrintIndexerError("revertNewDayEvent", "Error deleting recurring quests", day.DayIndex, err)
 	}
+	return nil
 }
@@ -0,0 +1,102 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+// Tests touching Postgres and Redis run when INDEXER_TEST_DATABASES=1, against scratch databases
+// loaded with postgres/init.sql and set with the PG_* / REDIS_* env vars like the servers.
+// They own the pixels of testAddress and the positions from testFirstPosition.
+
+const (
+	testAddress       = "00000000000000000000000000000000000000000000000000000000000a11ce"
+	testContract      = "0xafa1"
+	testFirstPosition = 64
+)
+
+var testBackendOnce sync.Once
+var testBackendErr error
+
+func requireTestDatabases(t *testing.T) {
+	t.Helper()
+	if os.Getenv("INDEXER_TEST_DATABASES") != "1" {
+		t.Skip("INDEXER_TEST_DATABASES is not set")
+	}
+
+	testBackendOnce.Do(func() {
+		databaseConfig, err := config.LoadDatabaseConfig()
+		if err != nil {
+			testBackendErr = err
+			return
+		}
+		canvasConfig, err := config.LoadCanvasConfig("../../configs/canvas.config.json")
+		if err != nil {
+			testBackendErr = err
+			return
+		}
+		backendConfig := config.DefaultBackendConfig
+		core.AFKBackend = core.NewBackend(core.NewDatabases(databaseConfig), config.DefaultRoundsConfig, canvasConfig, &backendConfig, false)
+		testBackendErr = SetContractAddress(ContractArtPeace, testContract)
+	})
+	if testBackendErr != nil {
+		t.Fatal(testBackendErr)
+	}
+}
+
+func testCanvasKey() string {
+	return fmt.Sprintf("canvas-%s", core.AFKBackend.CanvasConfig.Round)
+}
+
+// resetTestPixels drops the test pixels and clears their positions of the canvas
+func resetTestPixels(t *testing.T, positions int) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := core.AFKBackend.Databases.Postgres.Exec(ctx, "DELETE FROM Pixels WHERE address = $1", testAddress); err != nil {
+		t.Fatal(err)
+	}
+	bitWidth := core.AFKBackend.CanvasConfig.ColorsBitWidth
+	for position := uint(testFirstPosition); position < uint(testFirstPosition+positions); position++ {
+		err := core.AFKBackend.Databases.Redis.BitField(ctx, testCanvasKey(), "SET", fmt.Sprintf("u%d", bitWidth), position*bitWidth, 0).Err()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testPixelEvent(block int64, position int, color int64) IndexerEvent {
+	event := IndexerEvent{}
+	event.Event.FromAddress = testContract
+	event.Event.Keys = []string{pixelPlacedEvent, "0x" + testAddress, fmt.Sprintf("0x%x", position), "0x0"}
+	event.Event.Data = []string{fmt.Sprintf("0x%x", color)}
+	event.Block = IndexerEventBlock{Number: block}
+	return event
+}
+
+// checkTestPixel compares the latest pixel in Postgres and the Redis canvas with the color
+func checkTestPixel(t *testing.T, position int, color int64) {
+	t.Helper()
+	latest, err := latestPixelColor("", uint(position))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != color {
+		t.Errorf("position %d: postgres color %d, want %d", position, latest, color)
+	}
+
+	canvas, err := core.AFKBackend.Databases.Redis.Get(context.Background(), testCanvasKey()).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisColor := routeutils.GetCanvasPixelColor(canvas, core.AFKBackend.CanvasConfig.ColorsBitWidth, uint(position))
+	if redisColor != color {
+		t.Errorf("position %d: redis color %d, want %d", position, redisColor, color)
+	}
+}
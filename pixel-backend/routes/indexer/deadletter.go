@@ -0,0 +1,176 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Events without a handler, or whose handler fails ( returns an error or panics ), are
+// stored in IndexerDeadLetters with the raw event and the cursor of their message. Once the
+// handler is fixed they can be replayed through eventProcessors / eventReverters.
+
+const (
+	DeadLetterProcess = "process"
+	DeadLetterRevert  = "revert"
+
+	DeadLetterStatusFailed   = "failed"
+	DeadLetterStatusReplayed = "replayed"
+)
+
+type DeadLetter struct {
+	Key            int64      `json:"key"`
+	Kind           string     `json:"kind"`
+	Processor      string     `json:"processor"`
+	EventKey       string     `json:"eventKey"`
+	FromAddress    string     `json:"fromAddress"`
+	Keys           []string   `json:"keys"`
+	Data           []string   `json:"data"`
+	OrderKey       int        `json:"orderKey"`
+	UniqueKey      string     `json:"uniqueKey"`
+	Finality       string     `json:"finality"`
+	BlockNumber    int64      `json:"blockNumber"`
+	BlockTimestamp *int64     `json:"blockTimestamp"` // unix seconds, nil without the block header
+	Error          string     `json:"error"`
+	Attempts       int        `json:"attempts"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	ReplayedAt     *time.Time `json:"replayedAt"`
+}
+
+type EventHandler func(IndexerEvent) error
+
+func eventHandlers(kind string) map[string]EventHandler {
+	if kind == DeadLetterRevert {
+		return eventReverters
+	}
+	return eventProcessors
+}
+
+func eventHandlerName(handler EventHandler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// runEventHandler runs the handler and returns its error, or its panic
+func runEventHandler(handler EventHandler, event IndexerEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return handler(event)
+}
+
+// handleEvent processes or reverts an event of the message, dead lettering it on failure.
+// Events from an unknown source are quarantined, and never reverted since they were not applied.
+func handleEvent(kind string, event IndexerEvent, message IndexerMessage) {
+	eventKey := event.Event.Keys[0]
+	reason, err := eventSourceError(event)
+	if err != nil {
+		storeDeadLetter(kind, "", event, message, "failed to check the event source: "+err.Error())
+		return
+	}
+	if reason != "" {
+		if kind == DeadLetterProcess {
+			if err := quarantineEvent(event, message, reason); err != nil {
+				storeDeadLetter(kind, "", event, message, "failed to quarantine the event: "+err.Error())
+			}
+		}
+		return
+	}
+
+	handler, ok := eventHandlers(kind)[eventKey]
+	if !ok {
+		storeDeadLetter(kind, "", event, message, "no "+kind+" handler for event "+eventKey)
+		return
+	}
+
+	if err := runEventHandler(handler, event); err != nil {
+		storeDeadLetter(kind, eventHandlerName(handler), event, message, err.Error())
+	}
+}
+
+func storeDeadLetter(kind string, processor string, event IndexerEvent, message IndexerMessage, errMsg string) {
+	logIndexerError("handleEvent", "Event failed, sent to dead letters", kind, event.Event.Keys[0], errMsg)
+
+	keys := event.Event.Keys
+	if keys == nil {
+		keys = []string{}
+	}
+	data := event.Event.Data
+	if data == nil {
+		data = []string{}
+	}
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO IndexerDeadLetters (kind, processor, event_key, from_address, keys, data, order_key, unique_key, finality, block_number, block_timestamp, error) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)", kind, processor, event.Event.Keys[0], event.Event.FromAddress, keys, data, message.Data.Cursor.OrderKey, message.Data.Cursor.UniqueKey, message.Data.Finality, event.Block.Number, event.Block.Timestamp, errMsg)
+	if err != nil {
+		logIndexerError("storeDeadLetter", "Failed to store dead letter", kind, event, err)
+	}
+}
+
+const deadLetterColumns = "key, kind, processor, event_key, from_address, keys, data, order_key, unique_key, finality, block_number, block_timestamp, error, attempts, status, created_at, replayed_at"
+
+// ListDeadLetters returns the dead letters with the status ( all if empty ), oldest first
+func ListDeadLetters(status string, limit int, offset int) ([]DeadLetter, error) {
+	if status == "" {
+		return core.PostgresQuery[DeadLetter]("SELECT "+deadLetterColumns+" FROM IndexerDeadLetters ORDER BY key ASC LIMIT $1 OFFSET $2", limit, offset)
+	}
+	return core.PostgresQuery[DeadLetter]("SELECT "+deadLetterColumns+" FROM IndexerDeadLetters WHERE status = $1 ORDER BY key ASC LIMIT $2 OFFSET $3", status, limit, offset)
+}
+
+func GetDeadLetter(key int64) (*DeadLetter, error) {
+	return core.PostgresQueryOne[DeadLetter]("SELECT "+deadLetterColumns+" FROM IndexerDeadLetters WHERE key = $1", key)
+}
+
+// ReplayDeadLetter runs the event through its handler again, the dead letter is marked replayed
+// on success or keeps the new error otherwise
+func ReplayDeadLetter(key int64) (*DeadLetter, error) {
+	deadLetter, err := GetDeadLetter(key)
+	if err != nil {
+		return nil, err
+	}
+	if deadLetter.Status != DeadLetterStatusFailed {
+		return deadLetter, fmt.Errorf("dead letter %d already %s", key, deadLetter.Status)
+	}
+
+	event := IndexerEvent{}
+	event.Event.FromAddress = deadLetter.FromAddress
+	event.Event.Keys = deadLetter.Keys
+	event.Event.Data = deadLetter.Data
+	event.Block = IndexerEventBlock{Number: deadLetter.BlockNumber, Timestamp: deadLetter.BlockTimestamp}
+
+	var replayErr error
+	handler, ok := eventHandlers(deadLetter.Kind)[deadLetter.EventKey]
+	reason, sourceErr := eventSourceError(event)
+	if !ok {
+		replayErr = fmt.Errorf("no %s handler for event %s", deadLetter.Kind, deadLetter.EventKey)
+	} else if sourceErr != nil {
+		replayErr = fmt.Errorf("failed to check the event source: %w", sourceErr)
+	} else if reason != "" {
+		replayErr = fmt.Errorf("unknown event source: %s", reason)
+	} else {
+		replayErr = runEventHandler(handler, event)
+	}
+
+	ctx := context.Background()
+	if replayErr != nil {
+		_, err = core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE IndexerDeadLetters SET attempts = attempts + 1, error = $2 WHERE key = $1", key, replayErr.Error())
+	} else {
+		_, err = core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE IndexerDeadLetters SET attempts = attempts + 1, processor = $2, status = $3, replayed_at = CURRENT_TIMESTAMP WHERE key = $1", key, eventHandlerName(handler), DeadLetterStatusReplayed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := GetDeadLetter(key)
+	if err != nil {
+		return nil, err
+	}
+	return updated, replayErr
+}
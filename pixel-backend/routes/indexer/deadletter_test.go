@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"testing"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+func TestRunEventHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler EventHandler
+		err     string
+	}{
+		{name: "success", handler: func(IndexerEvent) error { return nil }},
+		{name: "error", handler: func(IndexerEvent) error { return PrintIndexerError("handler", "no such world", 3) }, err: "handler: no such world -- [3]"},
+		{name: "panic", handler: func(IndexerEvent) error { panic("index out of range") }, err: "panic: index out of range"},
+		{name: "nil event data", handler: func(event IndexerEvent) error {
+			_ = event.Event.Data[3]
+			return nil
+		}, err: "panic: runtime error: index out of range [3] with length 0"},
+	}
+	for _, test := range tests {
+		err := runEventHandler(test.handler, IndexerEvent{})
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestEventHandlers(t *testing.T) {
+	tests := []struct {
+		kind     string
+		eventKey string
+		name     string
+	}{
+		{kind: DeadLetterProcess, eventKey: pixelPlacedEvent, name: "processPixelPlacedEvent"},
+		{kind: DeadLetterRevert, eventKey: pixelPlacedEvent, name: "revertPixelPlacedEvent"},
+		{kind: DeadLetterProcess, eventKey: newDayEvent, name: "processNewDayEvent"},
+		// Unknown kinds are processed
+		{kind: "", eventKey: pixelPlacedEvent, name: "processPixelPlacedEvent"},
+	}
+	for _, test := range tests {
+		handler, ok := eventHandlers(test.kind)[test.eventKey]
+		if !ok {
+			t.Errorf("%s %s: no handler", test.kind, test.eventKey)
+			continue
+		}
+		if name := eventHandlerName(handler); name != test.name {
+			t.Errorf("%s %s: handler %s, want %s", test.kind, test.eventKey, name, test.name)
+		}
+	}
+
+	if _, ok := eventHandlers(DeadLetterRevert)["0x0"]; ok {
+		t.Errorf("handler for unknown event 0x0")
+	}
+}
+
+func TestReplayOldPixelAfterNewer(t *testing.T) {
+	requireTestDatabases(t)
+	resetTestPixels(t, 1)
+	position := testFirstPosition
+
+	if err := processPixelPlacedEvent(testPixelEvent(200, position, 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The event of an older block failed and is replayed after the newer one
+	old := testPixelEvent(100, position, 2)
+	storeDeadLetter(DeadLetterProcess, "", old, IndexerMessage{}, "test failure")
+	key, err := core.PostgresQueryOne[int64]("SELECT MAX(key) FROM IndexerDeadLetters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := ReplayDeadLetter(*key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Status != DeadLetterStatusReplayed {
+		t.Errorf("dead letter status %s, want %s", replayed.Status, DeadLetterStatusReplayed)
+	}
+	checkTestPixel(t, position, 3)
+
+	// Reverting the newer block falls back to the replayed pixel
+	if err := revertPixelPlacedEvent(testPixelEvent(200, position, 3)); err != nil {
+		t.Fatal(err)
+	}
+	checkTestPixel(t, position, 2)
+
+	count, err := core.PostgresQueryOne[int]("SELECT COUNT(*) FROM Pixels WHERE address = $1", testAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *count != 1 {
+		t.Errorf("%d pixels left, want 1", *count)
+	}
+}
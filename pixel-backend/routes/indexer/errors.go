@@ -1,7 +1,18 @@
 package indexer
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
-func PrintIndexerError(funcName string, errMsg string, args ...interface{}) {
+// PrintIndexerError logs an indexer error and returns it, event handlers return it to fail the
+// event and send it to the dead letters ( see deadletter.go )
+func PrintIndexerError(funcName string, errMsg string, args ...interface{}) error {
+	logIndexerError(funcName, errMsg, args...)
+	return errors.New(fmt.Sprint(funcName+": "+errMsg+" -- ", args))
+}
+
+// logIndexerError logs without failing the running event, for code outside the event handlers
+func logIndexerError(funcName string, errMsg string, args ...interface{}) {
 	fmt.Println("Error indexing in "+funcName+": "+errMsg+" -- ", args)
 }
@@ -2,250 +2,194 @@ package indexer
 
 import (
 	"context"
-	"encoding/hex"
-	"strconv"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
 )
 
-func processFactionCreatedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	nameHex := event.Event.Data[0][2:] // Remove 0x prefix
-	leader := event.Event.Data[1][2:]  // Remove 0x prefix
-	joinableHex := event.Event.Data[2]
-	allocationHex := event.Event.Data[3]
+type factionCreated struct {
+	FactionId  int64  `cairo:"key"`
+	Name       string `cairo:"data,shortstring"`
+	Leader     string `cairo:"data,address"`
+	Joinable   bool   `cairo:"data"`
+	Allocation int64  `cairo:"data"`
+}
 
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processFactionCreatedEvent", "Failed to parse factionId", factionIdHex, nameHex, leader, joinableHex, allocationHex)
-		return
-	}
+type chainFactionCreated struct {
+	FactionId int64  `cairo:"key"`
+	Name      string `cairo:"data,shortstring"`
+}
 
-	decodedName, err := hex.DecodeString(nameHex)
-	if err != nil {
-		PrintIndexerError("processFactionCreatedEvent", "Failed to decode name", factionIdHex, nameHex, leader, joinableHex, allocationHex)
-		return
-	}
-	// Trim off 0s at the start
-	trimmedName := []byte{}
-	trimming := true
-	for _, b := range decodedName {
-		if b == 0 && trimming {
-			continue
-		}
-		trimming = false
-		trimmedName = append(trimmedName, b)
-	}
-	name := string(trimmedName)
-
-	joinableInt, err := strconv.ParseInt(joinableHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processFactionCreatedEvent", "Failed to parse joinable", factionIdHex, nameHex, leader, joinableHex, allocationHex)
-		return
-	}
-	joinable := joinableInt != 0
+type factionLeaderChanged struct {
+	FactionId int64  `cairo:"key"`
+	NewLeader string `cairo:"data,address"`
+}
 
-	allocation, err := strconv.ParseInt(allocationHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processFactionCreatedEvent", "Failed to parse allocation", factionIdHex, nameHex, leader, joinableHex, allocationHex)
-		return
+// Faction and chain faction joined / left events
+type factionMember struct {
+	FactionId int64  `cairo:"key"`
+	User      string `cairo:"key,address"`
+}
+
+func processFactionCreatedEvent(event IndexerEvent) error {
+	var created factionCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("processFactionCreatedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Add faction info into postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Factions (faction_id, name, leader, joinable, allocation) VALUES ($1, $2, $3, $4, $5)", factionId, name, leader, joinable, allocation)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Factions (faction_id, name, leader, joinable, allocation) VALUES ($1, $2, $3, $4, $5)", created.FactionId, created.Name, created.Leader, created.Joinable, created.Allocation)
 	if err != nil {
-		PrintIndexerError("processFactionCreatedEvent", "Failed to insert faction into postgres", factionIdHex, nameHex, leader, joinableHex, allocationHex)
-		return
+		return PrintIndexerError("processFactionCreatedEvent", "Failed to insert faction into postgres", created.FactionId, created.Name, created.Leader, created.Joinable, created.Allocation)
 	}
+	return nil
 }
 
-func revertFactionCreatedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertFactionCreatedEvent", "Failed to parse factionId", factionIdHex)
-		return
+func revertFactionCreatedEvent(event IndexerEvent) error {
+	var created factionCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("revertFactionCreatedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Factions WHERE id = $1", factionId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Factions WHERE faction_id = $1", created.FactionId)
 	if err != nil {
-		PrintIndexerError("revertFactionCreatedEvent", "Failed to delete faction from postgres", factionIdHex)
-		return
+		return PrintIndexerError("revertFactionCreatedEvent", "Failed to delete faction from postgres", created.FactionId, err)
 	}
+	return nil
 }
 
-func processFactionLeaderChangedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	newLeader := event.Event.Data[0][2:] // Remove 0x prefix
-
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processFactionLeaderChangedEvent", "Failed to parse factionId", factionIdHex, newLeader)
-		return
+func processFactionLeaderChangedEvent(event IndexerEvent) error {
+	var changed factionLeaderChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("processFactionLeaderChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Factions SET leader = $1 WHERE faction_id = $2", newLeader, factionId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Factions SET leader = $1 WHERE faction_id = $2", changed.NewLeader, changed.FactionId)
 	if err != nil {
-		PrintIndexerError("processFactionLeaderChangedEvent", "Failed to update faction leader in postgres", factionIdHex, newLeader)
-		return
+		return PrintIndexerError("processFactionLeaderChangedEvent", "Failed to update faction leader in postgres", changed.FactionId, changed.NewLeader, err)
 	}
+	return nil
 }
 
-func revertFactionLeaderChangedEvent(event IndexerEvent) {
+func revertFactionLeaderChangedEvent(event IndexerEvent) error {
 	// TODO: Implement
+	return nil
 }
 
-func processFactionJoinedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	userAddress := event.Event.Keys[2][2:] // Remove 0x prefix
-
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processFactionJoinedEvent", "Failed to parse factionId", factionIdHex, userAddress)
-		return
+func processFactionJoinedEvent(event IndexerEvent) error {
+	var member factionMember
+	if err := decodeEvent(event, &member); err != nil {
+		return PrintIndexerError("processFactionJoinedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO FactionMembersInfo (faction_id, user_address, last_placed_time, member_pixels) VALUES ($1, $2, TO_TIMESTAMP($3), $4)", factionId, userAddress, 0, 0)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO FactionMembersInfo (faction_id, user_address, last_placed_time, member_pixels) VALUES ($1, $2, TO_TIMESTAMP($3), $4)", member.FactionId, member.User, 0, 0)
 	if err != nil {
-		PrintIndexerError("processFactionJoinedEvent", "Failed to insert faction member into postgres", factionIdHex, userAddress)
-		return
+		return PrintIndexerError("processFactionJoinedEvent", "Failed to insert faction member into postgres", member.FactionId, member.User, err)
 	}
-}
 
-func revertFactionJoinedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	userAddress := event.Event.Keys[2][2:] // Remove 0x prefix
+	quests.InvalidateUserStats(member.User)
+	return nil
+}
 
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertFactionJoinedEvent", "Failed to parse factionId", factionIdHex, userAddress)
-		return
+func revertFactionJoinedEvent(event IndexerEvent) error {
+	var member factionMember
+	if err := decodeEvent(event, &member); err != nil {
+		return PrintIndexerError("revertFactionJoinedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM FactionMembersInfo WHERE faction_id = $1 AND user_address = $2", factionId, userAddress)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM FactionMembersInfo WHERE faction_id = $1 AND user_address = $2", member.FactionId, member.User)
 	if err != nil {
-		PrintIndexerError("revertFactionJoinedEvent", "Failed to delete faction member from postgres", factionIdHex, userAddress)
-		return
+		return PrintIndexerError("revertFactionJoinedEvent", "Failed to delete faction member from postgres", member.FactionId, member.User, err)
 	}
-}
 
-func processFactionLeftEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	userAddress := event.Event.Keys[2][2:] // Remove 0x prefix
+	quests.InvalidateUserStats(member.User)
+	return nil
+}
 
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processFactionLeftEvent", "Failed to parse factionId", factionIdHex, userAddress)
-		return
+func processFactionLeftEvent(event IndexerEvent) error {
+	var member factionMember
+	if err := decodeEvent(event, &member); err != nil {
+		return PrintIndexerError("processFactionLeftEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM FactionMembersInfo WHERE faction_id = $1 AND user_address = $2", factionId, userAddress)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM FactionMembersInfo WHERE faction_id = $1 AND user_address = $2", member.FactionId, member.User)
 	if err != nil {
-		PrintIndexerError("processFactionLeftEvent", "Failed to delete faction member from postgres", factionIdHex, userAddress)
-		return
+		return PrintIndexerError("processFactionLeftEvent", "Failed to delete faction member from postgres", member.FactionId, member.User, err)
 	}
-}
 
-func revertFactionLeftEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	userAddress := event.Event.Keys[2][2:] // Remove 0x prefix
+	quests.InvalidateUserStats(member.User)
+	return nil
+}
 
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertFactionLeftEvent", "Failed to parse factionId", factionIdHex, userAddress)
-		return
+func revertFactionLeftEvent(event IndexerEvent) error {
+	var member factionMember
+	if err := decodeEvent(event, &member); err != nil {
+		return PrintIndexerError("revertFactionLeftEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
 	// TODO: Stash the last_placed_time and member_pixels in the event data
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO FactionMembersInfo (faction_id, user_address, last_placed_time, member_pixels) VALUES ($1, $2, TO_TIMESTAMP($3), $4)", factionId, userAddress, 0, 0)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO FactionMembersInfo (faction_id, user_address, last_placed_time, member_pixels) VALUES ($1, $2, TO_TIMESTAMP($3), $4)", member.FactionId, member.User, 0, 0)
 	if err != nil {
-		PrintIndexerError("revertFactionLeftEvent", "Failed to insert faction member into postgres", factionIdHex, userAddress)
-		return
+		return PrintIndexerError("revertFactionLeftEvent", "Failed to insert faction member into postgres", member.FactionId, member.User, err)
 	}
-}
-
-func processChainFactionCreatedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	nameHex := event.Event.Data[0][2:] // Remove 0x prefix
 
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processChainFactionCreatedEvent", "Failed to parse factionId", factionIdHex, nameHex)
-		return
-	}
+	quests.InvalidateUserStats(member.User)
+	return nil
+}
 
-	decodedName, err := hex.DecodeString(nameHex)
-	if err != nil {
-		PrintIndexerError("processChainFactionCreatedEvent", "Failed to decode name", factionIdHex, nameHex)
-		return
-	}
-	// Trim off 0s at the start
-	trimmedName := []byte{}
-	trimming := true
-	for _, b := range decodedName {
-		if b == 0 && trimming {
-			continue
-		}
-		trimming = false
-		trimmedName = append(trimmedName, b)
+func processChainFactionCreatedEvent(event IndexerEvent) error {
+	var created chainFactionCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("processChainFactionCreatedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
-	name := string(trimmedName)
 
 	// Add faction info into postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ChainFactions (faction_id, name) VALUES ($1, $2)", factionId, name)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ChainFactions (faction_id, name) VALUES ($1, $2)", created.FactionId, created.Name)
 	if err != nil {
-		PrintIndexerError("processChainFactionCreatedEvent", "Failed to insert faction into postgres", factionIdHex, nameHex)
-		return
+		return PrintIndexerError("processChainFactionCreatedEvent", "Failed to insert faction into postgres", created.FactionId, created.Name)
 	}
+	return nil
 }
 
-func revertChainFactionCreatedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertChainFactionCreatedEvent", "Failed to parse factionId", factionIdHex)
-		return
+func revertChainFactionCreatedEvent(event IndexerEvent) error {
+	var created chainFactionCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("revertChainFactionCreatedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM ChainFactions WHERE faction_id = $1", factionId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM ChainFactions WHERE faction_id = $1", created.FactionId)
 	if err != nil {
-		PrintIndexerError("revertChainFactionCreatedEvent", "Failed to delete faction from postgres", factionIdHex)
-		return
+		return PrintIndexerError("revertChainFactionCreatedEvent", "Failed to delete faction from postgres", created.FactionId, err)
 	}
+	return nil
 }
 
-func processChainFactionJoinedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	userAddress := event.Event.Keys[2][2:] // Remove 0x prefix
-
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processChainFactionJoinedEvent", "Failed to parse factionId", factionIdHex, userAddress)
-		return
+func processChainFactionJoinedEvent(event IndexerEvent) error {
+	var member factionMember
+	if err := decodeEvent(event, &member); err != nil {
+		return PrintIndexerError("processChainFactionJoinedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ChainFactionMembersInfo (faction_id, user_address, last_placed_time, member_pixels) VALUES ($1, $2, TO_TIMESTAMP($3), $4)", factionId, userAddress, 0, 0)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ChainFactionMembersInfo (faction_id, user_address, last_placed_time, member_pixels) VALUES ($1, $2, TO_TIMESTAMP($3), $4)", member.FactionId, member.User, 0, 0)
 	if err != nil {
-		PrintIndexerError("processChainFactionJoinedEvent", "Failed to insert faction member into postgres", factionIdHex, userAddress)
-		return
+		return PrintIndexerError("processChainFactionJoinedEvent", "Failed to insert faction member into postgres", member.FactionId, member.User, err)
 	}
-}
 
-func revertChainFactionJoinedEvent(event IndexerEvent) {
-	factionIdHex := event.Event.Keys[1]
-	userAddress := event.Event.Keys[2][2:] // Remove 0x prefix
+	quests.InvalidateUserStats(member.User)
+	return nil
+}
 
-	factionId, err := strconv.ParseInt(factionIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertChainFactionJoinedEvent", "Failed to parse factionId", factionIdHex, userAddress)
-		return
+func revertChainFactionJoinedEvent(event IndexerEvent) error {
+	var member factionMember
+	if err := decodeEvent(event, &member); err != nil {
+		return PrintIndexerError("revertChainFactionJoinedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM ChainFactionMembersInfo WHERE faction_id = $1 AND user_address = $2", factionId, userAddress)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM ChainFactionMembersInfo WHERE faction_id = $1 AND user_address = $2", member.FactionId, member.User)
 	if err != nil {
-		PrintIndexerError("revertChainFactionJoinedEvent", "Failed to delete faction member from postgres", factionIdHex, userAddress)
-		return
+		return PrintIndexerError("revertChainFactionJoinedEvent", "Failed to delete faction member from postgres", member.FactionId, member.User, err)
 	}
+
+	quests.InvalidateUserStats(member.User)
+	return nil
 }
@@ -0,0 +1,96 @@
+package indexer
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
+)
+
+// Memecoins created and launched from the unruggable factory, the registry checked by the
+// unruggable quests
+
+type memecoinCreated struct {
+	Owner           string   `cairo:"data,address"`
+	Name            string   `cairo:"data,shortstring"`
+	Symbol          string   `cairo:"data,shortstring"`
+	InitialSupply   *big.Int `cairo:"data,u256"`
+	MemecoinAddress string   `cairo:"data,address"`
+}
+
+type memecoinLaunched struct {
+	MemecoinAddress string `cairo:"data,address"`
+	QuoteToken      string `cairo:"data,address"`
+	ExchangeName    string `cairo:"data,shortstring"`
+}
+
+func processMemecoinCreatedEvent(event IndexerEvent) error {
+	var created memecoinCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("processMemecoinCreatedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
+	}
+
+	// A launch indexed first left the owner empty
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Memecoins (address, owner, name, symbol, initial_supply) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (address) DO UPDATE SET owner = $2, name = $3, symbol = $4, initial_supply = $5", created.MemecoinAddress, created.Owner, created.Name, created.Symbol, created.InitialSupply.String())
+	if err != nil {
+		return PrintIndexerError("processMemecoinCreatedEvent", "Error inserting memecoin into postgres", created.MemecoinAddress, created.Owner, err)
+	}
+
+	quests.InvalidateUserStats(created.Owner)
+	return nil
+}
+
+func revertMemecoinCreatedEvent(event IndexerEvent) error {
+	var created memecoinCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("revertMemecoinCreatedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
+	}
+
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Memecoins WHERE address = $1", created.MemecoinAddress)
+	if err != nil {
+		return PrintIndexerError("revertMemecoinCreatedEvent", "Error deleting memecoin from postgres", created.MemecoinAddress, err)
+	}
+
+	invalidateMemecoinOwner(created.MemecoinAddress)
+	return nil
+}
+
+func processMemecoinLaunchedEvent(event IndexerEvent) error {
+	var launched memecoinLaunched
+	if err := decodeEvent(event, &launched); err != nil {
+		return PrintIndexerError("processMemecoinLaunchedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
+	}
+
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Memecoins (address, launched, quote_token, exchange_name, launched_at) VALUES ($1, true, $2, $3, CURRENT_TIMESTAMP) ON CONFLICT (address) DO UPDATE SET launched = true, quote_token = $2, exchange_name = $3, launched_at = CURRENT_TIMESTAMP", launched.MemecoinAddress, launched.QuoteToken, launched.ExchangeName)
+	if err != nil {
+		return PrintIndexerError("processMemecoinLaunchedEvent", "Error marking memecoin launched in postgres", launched.MemecoinAddress, err)
+	}
+
+	invalidateMemecoinOwner(launched.MemecoinAddress)
+	return nil
+}
+
+func revertMemecoinLaunchedEvent(event IndexerEvent) error {
+	var launched memecoinLaunched
+	if err := decodeEvent(event, &launched); err != nil {
+		return PrintIndexerError("revertMemecoinLaunchedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
+	}
+
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Memecoins SET launched = false, quote_token = NULL, exchange_name = NULL, launched_at = NULL WHERE address = $1", launched.MemecoinAddress)
+	if err != nil {
+		return PrintIndexerError("revertMemecoinLaunchedEvent", "Error reverting memecoin launch in postgres", launched.MemecoinAddress, err)
+	}
+
+	invalidateMemecoinOwner(launched.MemecoinAddress)
+	return nil
+}
+
+// invalidateMemecoinOwner drops the quest stats of the memecoin owner, not part of the launch events
+func invalidateMemecoinOwner(memecoinAddress string) {
+	owner, err := core.PostgresQueryOne[string]("SELECT owner FROM Memecoins WHERE address = $1 AND owner IS NOT NULL", memecoinAddress)
+	if err != nil {
+		return
+	}
+	quests.InvalidateUserStats(*owner)
+}
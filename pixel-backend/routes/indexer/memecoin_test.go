@@ -0,0 +1,113 @@
+package indexer
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
+)
+
+func TestDecodeMemecoinCreated(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []string
+		created memecoinCreated
+		valid   bool
+	}{
+		{
+			// owner, name, symbol, initial supply ( low, high ), memecoin
+			name: "created",
+			data: []string{"0xA11CE", "0x446f6765", "0x444f4745", "0x3635c9adc5dea00000", "0x0", "0x3e3e"},
+			created: memecoinCreated{
+				Owner:           strings.Repeat("0", 59) + "a11ce",
+				Name:            "Doge",
+				Symbol:          "DOGE",
+				InitialSupply:   new(big.Int).Mul(big.NewInt(1000), big.NewInt(1e18)),
+				MemecoinAddress: strings.Repeat("0", 60) + "3e3e",
+			},
+			valid: true,
+		},
+		{
+			name: "supply over 128 bits",
+			data: []string{"0x1", "0x41", "0x41", "0x0", "0x1", "0x2"},
+			created: memecoinCreated{
+				Owner:           strings.Repeat("0", 63) + "1",
+				Name:            "A",
+				Symbol:          "A",
+				InitialSupply:   new(big.Int).Lsh(big.NewInt(1), 128),
+				MemecoinAddress: strings.Repeat("0", 63) + "2",
+			},
+			valid: true,
+		},
+		{name: "missing memecoin", data: []string{"0xA11CE", "0x446f6765", "0x444f4745", "0x1", "0x0"}},
+		{name: "no data", data: []string{}},
+	}
+	for _, test := range tests {
+		event := IndexerEvent{}
+		event.Event.Keys = []string{memecoinCreatedEvent}
+		event.Event.Data = test.data
+
+		var created memecoinCreated
+		err := decodeEvent(event, &created)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: decoded %+v, want an error", test.name, created)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if created.Owner != test.created.Owner || created.Name != test.created.Name || created.Symbol != test.created.Symbol || created.MemecoinAddress != test.created.MemecoinAddress {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, created, test.created)
+		}
+		if created.InitialSupply.Cmp(test.created.InitialSupply) != 0 {
+			t.Errorf("%s: initial supply %s, want %s", test.name, created.InitialSupply, test.created.InitialSupply)
+		}
+	}
+}
+
+func TestDecodeMemecoinLaunched(t *testing.T) {
+	event := IndexerEvent{}
+	event.Event.Keys = []string{memecoinLaunchedEvent}
+	event.Event.Data = []string{"0x3e3e", "0x49d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7", "0x456b7562"}
+
+	var launched memecoinLaunched
+	if err := decodeEvent(event, &launched); err != nil {
+		t.Fatal(err)
+	}
+	want := memecoinLaunched{
+		MemecoinAddress: strings.Repeat("0", 60) + "3e3e",
+		QuoteToken:      "049d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7",
+		ExchangeName:    "Ekub",
+	}
+	if launched != want {
+		t.Errorf("decoded %+v, want %+v", launched, want)
+	}
+
+	event.Event.Data = event.Event.Data[:2]
+	if err := decodeEvent(event, &launched); err == nil {
+		t.Errorf("decoded a launch without exchange")
+	}
+}
+
+func TestMemecoinOwnerMatchesUsers(t *testing.T) {
+	// The unruggable check looks the owner up with the address of the user routes
+	event := IndexerEvent{}
+	event.Event.Keys = []string{memecoinCreatedEvent}
+	event.Event.Data = []string{"0x0A11CE", "0x41", "0x41", "0x1", "0x0", "0x2"}
+
+	var created memecoinCreated
+	if err := decodeEvent(event, &created); err != nil {
+		t.Fatal(err)
+	}
+	user, err := starknet.NormalizeAddress("0xa11ce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Owner != user {
+		t.Errorf("owner %s, user %s", created.Owner, user)
+	}
+}
@@ -0,0 +1,63 @@
+package indexer
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Latency of each stage of the message processor, exposed on /debug/vars and /indexer-status
+
+type StageLatency struct {
+	Count   int64   `json:"count"`
+	TotalMs float64 `json:"totalMs"`
+	MaxMs   float64 `json:"maxMs"`
+	LastMs  float64 `json:"lastMs"`
+}
+
+const (
+	stageQueueWait  = "queue_wait"
+	stageFinalized  = "process_finalized"
+	stageAccepted   = "process_accepted"
+	stagePending    = "process_pending"
+	stageCheckpoint = "checkpoint"
+)
+
+var stageLatencies = make(map[string]*StageLatency)
+var stageLatenciesLock = &sync.Mutex{}
+
+func init() {
+	expvar.Publish("indexer_stage_latency", expvar.Func(func() interface{} {
+		return GetStageLatencies()
+	}))
+}
+
+func observeStage(stage string, start time.Time) {
+	elapsedMs := float64(time.Since(start).Microseconds()) / 1000
+
+	stageLatenciesLock.Lock()
+	defer stageLatenciesLock.Unlock()
+
+	latency, ok := stageLatencies[stage]
+	if !ok {
+		latency = &StageLatency{}
+		stageLatencies[stage] = latency
+	}
+	latency.Count++
+	latency.TotalMs += elapsedMs
+	latency.LastMs = elapsedMs
+	if elapsedMs > latency.MaxMs {
+		latency.MaxMs = elapsedMs
+	}
+}
+
+func GetStageLatencies() map[string]StageLatency {
+	stageLatenciesLock.Lock()
+	defer stageLatenciesLock.Unlock()
+
+	snapshot := make(map[string]StageLatency, len(stageLatencies))
+	for stage, latency := range stageLatencies {
+		snapshot[stage] = *latency
+	}
+	return snapshot
+}
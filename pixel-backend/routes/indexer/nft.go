@@ -2,7 +2,6 @@ package indexer
 
 import (
 	"context"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -12,114 +11,77 @@ import (
 	"strconv"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
 
-func processNFTMintedEvent(event IndexerEvent) {
-	tokenIdLowHex := event.Event.Keys[1][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[2][2:] // Remove 0x prefix
-	positionHex := event.Event.Data[0]
-	widthHex := event.Event.Data[1]
-	heightHex := event.Event.Data[2]
-	nameHex := event.Event.Data[3][2:] // Remove 0x prefix
-	imageHashHex := event.Event.Data[4]
-	blockNumberHex := event.Event.Data[5]
-	dayIndexHex := event.Event.Data[6]
-	minter := event.Event.Data[7][2:] // Remove 0x prefix
-
-	// combine high and low token ids
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error combining high and low tokenId hex", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
-	tokenId := tokenIdU256.Uint64()
-
-	position, err := strconv.ParseInt(positionHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error converting position hex to int", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
-
-	width, err := strconv.ParseInt(widthHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error converting width hex to int", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
-
-	height, err := strconv.ParseInt(heightHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error converting height hex to int", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
-
-	decodedName, err := hex.DecodeString(nameHex)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error decoding name hex", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
-	trimmedName := []byte{}
-	trimming := true
-	for _, b := range decodedName {
-		if b == 0 && trimming {
-			continue
-		}
-		trimming = false
-		trimmedName = append(trimmedName, b)
-	}
-	name := string(trimmedName)
-
-	blockNumber, err := strconv.ParseInt(blockNumberHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error converting block number hex to int", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
-
-	dayIndex, err := strconv.ParseInt(dayIndexHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error converting day index hex to int", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
+type nftMinted struct {
+	TokenId     uint64 `cairo:"key,u256"`
+	Position    int64  `cairo:"data"`
+	Width       int64  `cairo:"data"`
+	Height      int64  `cairo:"data"`
+	Name        string `cairo:"data,shortstring"`
+	ImageHash   string `cairo:"data"`
+	BlockNumber int64  `cairo:"data"`
+	DayIndex    int64  `cairo:"data"`
+	Minter      string `cairo:"data,address"`
+}
 
-	// Set NFT in postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO NFTs (token_id, position, width, height, name, image_hash, block_number, day_index, minter, owner) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", tokenId, position, width, height, name, imageHashHex, blockNumber, dayIndex, minter, minter)
-	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error inserting NFT into postgres", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
-	}
+type nftLike struct {
+	TokenId uint64 `cairo:"key,u256"`
+	User    string `cairo:"key,address"`
+}
 
-	// Load image from redis
+func processNFTMintedEvent(event IndexerEvent) error {
+	var minted nftMinted
+	if err := decodeEvent(event, &minted); err != nil {
+		return PrintIndexerError("processNFTMintedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
+	}
+	tokenId := minted.TokenId
+	position := minted.Position
+	width := minted.Width
+	height := minted.Height
+	name := minted.Name
+	minter := minted.Minter
+
+	// The image and metadata files are written before the NFT row, an event failing before
+	// the insert can be replayed
+	// Load the NFT region from redis
 	ctx := context.Background()
 	roundNumber := core.AFKBackend.CanvasConfig.Round
 	canvasKey := fmt.Sprintf("canvas-%s", roundNumber)
-	canvas, err := core.AFKBackend.Databases.Redis.Get(ctx, canvasKey).Result()
+	canvasWidth := int64(core.AFKBackend.CanvasConfig.Canvas.Width)
+	canvasHeight := core.AFKBackend.CanvasConfig.Canvas.Height
+	startX := position % canvasWidth
+	startY := position / canvasWidth
+	// Not canonicalized, a negative size is an empty region
+	region := image.Rectangle{Min: image.Pt(int(startX), int(startY)), Max: image.Pt(int(startX+width), int(startY+height))}
+	if position < 0 || routeutils.CheckCanvasRegion(region, uint(canvasWidth), canvasHeight) != nil {
+		return PrintIndexerError("processNFTMintedEvent", "NFT region outside of the canvas", tokenId, position, width, height, canvasWidth, canvasHeight)
+	}
+	colors, err := routeutils.GetCanvasRegion(ctx, canvasKey, uint(canvasWidth), region)
 	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error getting canvas from redis", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
+		return PrintIndexerError("processNFTMintedEvent", "Error getting canvas region from redis", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter, err)
 	}
 
 	colorPaletteHex, err := core.PostgresQuery[string]("SELECT hex FROM colors ORDER BY color_key")
 	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error getting color palette from postgres", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
+		return PrintIndexerError("processNFTMintedEvent", "Error getting color palette from postgres", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 	}
 
 	colorPalette := make([]color.RGBA, len(colorPaletteHex))
 	for idx, colorHex := range colorPaletteHex {
 		r, err := strconv.ParseInt(colorHex[0:2], 16, 64)
 		if err != nil {
-			PrintIndexerError("processNFTMintedEvent", "Error converting red hex to int when creating palette", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-			return
+			return PrintIndexerError("processNFTMintedEvent", "Error converting red hex to int when creating palette", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 		}
 		g, err := strconv.ParseInt(colorHex[2:4], 16, 64)
 		if err != nil {
-			PrintIndexerError("processNFTMintedEvent", "Error converting green hex to int when creating palette", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-			return
+			return PrintIndexerError("processNFTMintedEvent", "Error converting green hex to int when creating palette", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 		}
 		b, err := strconv.ParseInt(colorHex[4:6], 16, 64)
 		if err != nil {
-			PrintIndexerError("processNFTMintedEvent", "Error converting blue hex to int when creating palette", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-			return
+			return PrintIndexerError("processNFTMintedEvent", "Error converting blue hex to int when creating palette", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 		}
 		colorPalette[idx] = color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
 	}
@@ -132,41 +94,24 @@ func processNFTMintedEvent(event IndexerEvent) {
 	// Create a new image with scaled dimensions
 	generatedImage := image.NewRGBA(image.Rect(0, 0, int(scaledWidth), int(scaledHeight)))
 
-	bitWidth := int64(core.AFKBackend.CanvasConfig.ColorsBitWidth)
-	startX := int64(position % int64(core.AFKBackend.CanvasConfig.Canvas.Width))
-	startY := int64(position / int64(core.AFKBackend.CanvasConfig.Canvas.Width))
-	oneByteBitOffset := int64(8 - bitWidth)
-	twoByteBitOffset := int64(16 - bitWidth)
-
-	for y := startY; y < startY+height; y++ {
-		for x := startX; x < startX+width; x++ {
-			pos := y*int64(core.AFKBackend.CanvasConfig.Canvas.Width) + x
-			bitPos := pos * bitWidth
-			bytePos := bitPos / 8
-			bitOffset := bitPos % 8
-
-			// Calculate the scaled position
-			for dy := 0; dy < scaleFactor; dy++ {
-				for dx := 0; dx < scaleFactor; dx++ {
-					scaledX := int(x-startX)*scaleFactor + dx
-					scaledY := int(y-startY)*scaleFactor + dy
-
-					if bitOffset <= oneByteBitOffset {
-						colorIdx := (canvas[bytePos] >> (oneByteBitOffset - bitOffset)) & 0b11111
-						generatedImage.Set(scaledX, scaledY, colorPalette[colorIdx])
-					} else {
-						colorIdx := (((uint16(canvas[bytePos]) << 8) | uint16(canvas[bytePos+1])) >> (twoByteBitOffset - bitOffset)) & 0b11111
-						generatedImage.Set(scaledX, scaledY, colorPalette[colorIdx])
-					}
-				}
+	for idx, colorIdx := range colors {
+		if colorIdx < 0 || colorIdx >= int64(len(colorPalette)) {
+			return PrintIndexerError("processNFTMintedEvent", "Color outside of the palette", tokenId, position, idx, colorIdx)
+		}
+		x := idx % int(width)
+		y := idx / int(width)
+
+		// Calculate the scaled position
+		for dy := 0; dy < scaleFactor; dy++ {
+			for dx := 0; dx < scaleFactor; dx++ {
+				generatedImage.Set(x*scaleFactor+dx, y*scaleFactor+dy, colorPalette[colorIdx])
 			}
 		}
 	}
 
 	// TODO: Check if file exists
 	if roundNumber == "" {
-		PrintIndexerError("processNFTMintedEvent", "Error getting round number from environment", tokenIdLowHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
+		return PrintIndexerError("processNFTMintedEvent", "Error getting round number from environment", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 	}
 	roundDir := fmt.Sprintf("round-%s", roundNumber)
 
@@ -182,8 +127,7 @@ func processNFTMintedEvent(event IndexerEvent) {
 		if _, err := os.Stat(dir); os.IsNotExist(err) {
 			err = os.MkdirAll(dir, os.ModePerm)
 			if err != nil {
-				PrintIndexerError("processNFTMintedEvent", fmt.Sprintf("Error creating %s directory", dir), tokenIdLowHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-				return
+				return PrintIndexerError("processNFTMintedEvent", fmt.Sprintf("Error creating %s directory", dir), tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 			}
 		}
 	}
@@ -192,15 +136,13 @@ func processNFTMintedEvent(event IndexerEvent) {
 	filename := fmt.Sprintf("nfts/%s/images/nft-%d.png", roundDir, tokenId)
 	file, err := os.Create(filename)
 	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error creating file", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
+		return PrintIndexerError("processNFTMintedEvent", "Error creating file", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 	}
 	defer file.Close()
 
 	err = png.Encode(file, generatedImage)
 	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error encoding image", tokenIdLowHex, tokenIdHighHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
+		return PrintIndexerError("processNFTMintedEvent", "Error encoding image", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 	}
 
 	// Create a NFT JSON metadata file
@@ -225,7 +167,7 @@ func processNFTMintedEvent(event IndexerEvent) {
 			},
 			{
 				"trait_type": "Day Index",
-				"value":      fmt.Sprintf("%d", dayIndex),
+				"value":      fmt.Sprintf("%d", minted.DayIndex),
 			},
 			{
 				"trait_type": "Minter",
@@ -240,17 +182,23 @@ func processNFTMintedEvent(event IndexerEvent) {
 
 	metadataFile, err := json.MarshalIndent(metadata, "", "  ")
 	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error generating NFT metadata", tokenIdLowHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
+		return PrintIndexerError("processNFTMintedEvent", "Error generating NFT metadata", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 	}
 
 	metadataFilename := fmt.Sprintf("nfts/%s/metadata/nft-%d.json", roundDir, tokenId)
 	err = os.WriteFile(metadataFilename, metadataFile, 0644)
 	if err != nil {
-		PrintIndexerError("processNFTMintedEvent", "Error writing NFT metadata file", tokenIdLowHex, positionHex, widthHex, heightHex, nameHex, imageHashHex, blockNumberHex, minter)
-		return
+		return PrintIndexerError("processNFTMintedEvent", "Error writing NFT metadata file", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter)
 	}
 
+	// Set NFT in postgres
+	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO NFTs (token_id, position, width, height, name, image_hash, block_number, day_index, minter, owner) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minted.DayIndex, minter, minter)
+	if err != nil {
+		return PrintIndexerError("processNFTMintedEvent", "Error inserting NFT into postgres", tokenId, position, width, height, name, minted.ImageHash, minted.BlockNumber, minter, err)
+	}
+
+	quests.RecordNFTMinted(minter, int(minted.DayIndex))
+
 	message := map[string]string{
 		"token_id":    strconv.FormatUint(tokenId, 10),
 		"minter":      minter,
@@ -259,105 +207,79 @@ func processNFTMintedEvent(event IndexerEvent) {
 	routeutils.SendMessageToWSS(message)
 
 	// TODO: Response?
+	return nil
 }
 
-func revertNFTMintedEvent(event IndexerEvent) {
-	tokenIdLowHex := event.Event.Keys[1][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[2][2:] // Remove 0x prefix
-
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("revertNFTMintedEvent", "Error converting tokenId hex to int", tokenIdLowHex)
-		return
+func revertNFTMintedEvent(event IndexerEvent) error {
+	var minted nftMinted
+	if err := decodeEvent(event, &minted); err != nil {
+		return PrintIndexerError("revertNFTMintedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
-	tokenId := tokenIdU256.Uint64()
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM NFTs WHERE token_id = $1", tokenId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM NFTs WHERE token_id = $1", minted.TokenId)
 	if err != nil {
-		PrintIndexerError("reverseNFTMintedEvent", "Error deleting NFT from postgres", tokenIdLowHex)
-		return
+		return PrintIndexerError("reverseNFTMintedEvent", "Error deleting NFT from postgres", minted.TokenId)
 	}
 
+	quests.InvalidateUserStats(minted.Minter)
+
 	// TODO: Mark image as unused?
+	return nil
 }
 
-func processNFTLikedEvent(event IndexerEvent) {
-	tokenIdLowHex := event.Event.Keys[1][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[2][2:] // Remove 0x prefix
-	liker := event.Event.Keys[3][2:]          // Remove 0x prefix
-
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("processNFTLikedEvent", "Error converting tokenId hex to int", tokenIdLowHex, liker)
-		return
+func processNFTLikedEvent(event IndexerEvent) error {
+	var like nftLike
+	if err := decodeEvent(event, &like); err != nil {
+		return PrintIndexerError("processNFTLikedEvent", "Error decoding event", event.Event.Keys, err)
 	}
-	tokenId := tokenIdU256.Uint64()
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO NFTLikes (nftKey, liker) VALUES ($1, $2) ON CONFLICT DO NOTHING", tokenId, liker)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO NFTLikes (nftKey, liker) VALUES ($1, $2) ON CONFLICT DO NOTHING", like.TokenId, like.User)
 	if err != nil {
-		PrintIndexerError("processNFTLikedEvent", "Error inserting NFT like into postgres", tokenIdLowHex, liker)
-		return
+		return PrintIndexerError("processNFTLikedEvent", "Error inserting NFT like into postgres", like.TokenId, like.User)
 	}
 
 	// TODO: WebSocket message?
+	return nil
 }
 
-func revertNFTLikedEvent(event IndexerEvent) {
-	tokenIdLowHex := event.Event.Keys[1][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[2][2:] // Remove 0x prefix
-	liker := event.Event.Keys[3][2:]          // Remove 0x prefix
-
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("revertNFTLikedEvent", "Error converting tokenId hex to int", tokenIdLowHex, liker)
-		return
+func revertNFTLikedEvent(event IndexerEvent) error {
+	var like nftLike
+	if err := decodeEvent(event, &like); err != nil {
+		return PrintIndexerError("revertNFTLikedEvent", "Error decoding event", event.Event.Keys, err)
 	}
-	tokenId := tokenIdU256.Uint64()
 
 	// TODO: Check if like exists before event
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM NFTLikes WHERE nftKey = $1 AND liker = $2", tokenId, liker)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM NFTLikes WHERE nftKey = $1 AND liker = $2", like.TokenId, like.User)
 	if err != nil {
-		PrintIndexerError("revertNFTLikedEvent", "Error deleting NFT like from postgres", tokenIdLowHex, liker)
-		return
+		return PrintIndexerError("revertNFTLikedEvent", "Error deleting NFT like from postgres", like.TokenId, like.User)
 	}
+	return nil
 }
 
-func processNFTUnlikedEvent(event IndexerEvent) {
-	tokenIdLowHex := event.Event.Keys[1][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[2][2:] // Remove 0x prefix
-	unliker := event.Event.Keys[3][2:]        // Remove 0x prefix
-
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("processNFTUnlikedEvent", "Error converting tokenId hex to int", tokenIdLowHex, unliker)
-		return
+func processNFTUnlikedEvent(event IndexerEvent) error {
+	var unlike nftLike
+	if err := decodeEvent(event, &unlike); err != nil {
+		return PrintIndexerError("processNFTUnlikedEvent", "Error decoding event", event.Event.Keys, err)
 	}
-	tokenId := tokenIdU256.Uint64()
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM NFTLikes WHERE nftKey = $1 AND liker = $2", tokenId, unliker)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM NFTLikes WHERE nftKey = $1 AND liker = $2", unlike.TokenId, unlike.User)
 	if err != nil {
-		PrintIndexerError("processNFTUnlikedEvent", "Error deleting NFT like from postgres", tokenIdLowHex, unliker)
-		return
+		return PrintIndexerError("processNFTUnlikedEvent", "Error deleting NFT like from postgres", unlike.TokenId, unlike.User)
 	}
 
 	// TODO: WebSocket message?
+	return nil
 }
 
-func revertNFTUnlikedEvent(event IndexerEvent) {
-	tokenIdLowHex := event.Event.Keys[1][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[2][2:] // Remove 0x prefix
-	unliker := event.Event.Keys[3][2:]        // Remove 0x prefix
-
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("revertNFTUnlikedEvent", "Error converting tokenId hex to int", tokenIdLowHex, unliker)
-		return
+func revertNFTUnlikedEvent(event IndexerEvent) error {
+	var unlike nftLike
+	if err := decodeEvent(event, &unlike); err != nil {
+		return PrintIndexerError("revertNFTUnlikedEvent", "Error decoding event", event.Event.Keys, err)
 	}
-	tokenId := tokenIdU256.Uint64()
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO NFTLikes (nftKey, liker) VALUES ($1, $2) ON CONFLICT DO NOTHING", tokenId, unliker)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO NFTLikes (nftKey, liker) VALUES ($1, $2) ON CONFLICT DO NOTHING", unlike.TokenId, unlike.User)
 	if err != nil {
-		PrintIndexerError("revertNFTUnlikedEvent", "Error inserting NFT like into postgres", tokenIdLowHex, unliker)
-		return
+		return PrintIndexerError("revertNFTUnlikedEvent", "Error inserting NFT like into postgres", unlike.TokenId, unlike.User)
 	}
+	return nil
 }
@@ -6,44 +6,36 @@ import (
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 )
 
-func processNFTTransferEvent(event IndexerEvent) {
-	to := event.Event.Keys[2][2:]             // Remove 0x prefix
-	tokenIdLowHex := event.Event.Keys[3][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[4][2:] // Remove 0x prefix
+type nftTransfer struct {
+	From    string `cairo:"key,address"`
+	To      string `cairo:"key,address"`
+	TokenId uint64 `cairo:"key,u256"`
+}
 
-	// combine high and low token ids
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("processNFTTransferEvent", "Error combining high and low tokenId hex", to, tokenIdLowHex, tokenIdHighHex)
-		return
+func processNFTTransferEvent(event IndexerEvent) error {
+	var transfer nftTransfer
+	if err := decodeEvent(event, &transfer); err != nil {
+		return PrintIndexerError("processNFTTransferEvent", "Error decoding event", event.Event.Keys, err)
 	}
-	tokenId := tokenIdU256.Uint64()
 
 	// Set owner
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE NFTs SET owner = $1 WHERE token_id = $2", to, tokenId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE NFTs SET owner = $1 WHERE token_id = $2", transfer.To, transfer.TokenId)
 	if err != nil {
-		PrintIndexerError("processNFTTransferEvent", "Error updating owner in postgres", to, tokenIdLowHex, tokenIdHighHex)
-		return
+		return PrintIndexerError("processNFTTransferEvent", "Error updating owner in postgres", transfer.To, transfer.TokenId)
 	}
+	return nil
 }
 
-func revertNFTTransferEvent(event IndexerEvent) {
-	from := event.Event.Keys[1][2:]           // Remove 0x prefix
-	tokenIdLowHex := event.Event.Keys[3][2:]  // Remove 0x prefix
-	tokenIdHighHex := event.Event.Keys[4][2:] // Remove 0x prefix
-
-	// combine high and low token ids
-	tokenIdU256, err := combineLowHigh(tokenIdLowHex, tokenIdHighHex)
-	if err != nil {
-		PrintIndexerError("revertNFTTransferEvent", "Error combining high and low tokenId hex", from, tokenIdLowHex, tokenIdHighHex)
-		return
+func revertNFTTransferEvent(event IndexerEvent) error {
+	var transfer nftTransfer
+	if err := decodeEvent(event, &transfer); err != nil {
+		return PrintIndexerError("revertNFTTransferEvent", "Error decoding event", event.Event.Keys, err)
 	}
-	tokenId := tokenIdU256.Uint64()
 
 	// Set owner
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE NFTs SET owner = $1 WHERE token_id = $2", from, tokenId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE NFTs SET owner = $1 WHERE token_id = $2", transfer.From, transfer.TokenId)
 	if err != nil {
-		PrintIndexerError("revertNFTTransferEvent", "Error updating owner in postgres", from, tokenIdLowHex, tokenIdHighHex)
-		return
+		return PrintIndexerError("revertNFTTransferEvent", "Error updating owner in postgres", transfer.From, transfer.TokenId)
 	}
+	return nil
 }
@@ -6,276 +6,226 @@ import (
 	"strconv"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
-	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
 )
 
-func processPixelPlacedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:] // Remove 0x prefix
-	posHex := event.Event.Keys[2]
-	dayIdxHex := event.Event.Keys[3]
-	colorHex := event.Event.Data[0]
+type pixelPlaced struct {
+	Address  string `cairo:"key,address"`
+	Position int64  `cairo:"key"`
+	DayIndex int64  `cairo:"key"`
+	Color    int64  `cairo:"data"`
+}
 
-	// Convert hex to int
-	position, err := strconv.ParseInt(posHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processPixelPlacedEvent", "Error converting position hex to int", address, posHex, dayIdxHex, colorHex)
-		return
+type basicPixelPlaced struct {
+	Address   string `cairo:"key,address"`
+	Timestamp int64  `cairo:"data"`
+}
+
+// Faction and chain faction member placements
+type memberPixelsPlaced struct {
+	Address      string `cairo:"key,address"`
+	Timestamp    int64  `cairo:"data"`
+	MemberPixels int64  `cairo:"data"`
+}
+
+// Events of a user with an amount of extra pixels, extra pixels placed / host awarded pixels
+type userPixelsAmount struct {
+	Address string `cairo:"key,address"`
+	Amount  int64  `cairo:"data"`
+}
+
+func processPixelPlacedEvent(event IndexerEvent) error {
+	var placed pixelPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("processPixelPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
+	address := placed.Address
+	position := placed.Position
+	dayIdx := placed.DayIndex
+	color := placed.Color
 
 	//validate position
 	maxPosition := int64(core.AFKBackend.CanvasConfig.Canvas.Width) * int64(core.AFKBackend.CanvasConfig.Canvas.Height)
 
 	// Perform comparison with maxPosition
 	if position < 0 || position >= maxPosition {
-		PrintIndexerError("processPixelPlacedEvent", "Position value exceeds canvas dimensions", address, posHex, dayIdxHex, colorHex)
-		return
-	}
-
-	dayIdx, err := strconv.ParseInt(dayIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processPixelPlacedEvent", "Error converting day index hex to int", address, posHex, dayIdxHex, colorHex)
-		return
-	}
-	color, err := strconv.ParseInt(colorHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processPixelPlacedEvent", "Error converting color hex to int", address, posHex, dayIdxHex, colorHex)
-		return
+		return PrintIndexerError("processPixelPlacedEvent", "Position value exceeds canvas dimensions", address, position, dayIdx, color)
 	}
 
 	fmt.Println("Processing pixel placed event", address, position, dayIdx, color)
-	// Set pixel in redis
-	bitfieldType := "u" + strconv.Itoa(int(core.AFKBackend.CanvasConfig.ColorsBitWidth))
-	pos := uint(position) * core.AFKBackend.CanvasConfig.ColorsBitWidth
-
-	fmt.Println("Setting pixel in redis", bitfieldType, pos, color)
-	ctx := context.Background()
 	roundNumber := core.AFKBackend.CanvasConfig.Round
 	canvasKey := fmt.Sprintf("canvas-%s", roundNumber)
-	err = core.AFKBackend.Databases.Redis.BitField(ctx, canvasKey, "SET", bitfieldType, pos, color).Err()
-	if err != nil {
-		PrintIndexerError("processPixelPlacedEvent", "Error setting pixel in redis", address, posHex, dayIdxHex, colorHex)
-		return
-	}
-
-	fmt.Println("Setting pixel in postgres")
-	// Set pixel in postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Pixels (address, position, day, color) VALUES ($1, $2, $3, $4)", address, position, dayIdx, color)
-	if err != nil {
-		// TODO: Reverse redis operation?
-		PrintIndexerError("processPixelPlacedEvent", "Error inserting pixel into postgres", address, posHex, dayIdxHex, colorHex)
-		return
-	}
-
-	fmt.Println("Sending message to all connected clients")
-	// Send message to all connected clients
 	var message = map[string]string{
 		"position":    strconv.FormatInt(position, 10),
 		"color":       strconv.FormatInt(color, 10),
 		"messageType": "colorPixel",
 	}
-	routeutils.SendMessageToWSS(message)
-}
-
-func revertPixelPlacedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:] // Remove 0x prefix
-	posHex := event.Event.Keys[2]
 
-	// Convert hex to int
-	position, err := strconv.ParseInt(posHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertPixelPlacedEvent", "Error converting position hex to int", address, posHex)
-		return
+	// Set pixel in postgres, then in redis and notify clients
+	app := EventApplication{}
+	app.Exec("INSERT INTO Pixels (address, position, day, color, block_number, block_time) VALUES ($1, $2, $3, $4, $5, TO_TIMESTAMP($6))", address, position, dayIdx, color, event.Block.Number, event.Block.Timestamp)
+	app.SetPixel(canvasKey, uint(position), color, message)
+	if err := app.Apply(); err != nil {
+		return PrintIndexerError("processPixelPlacedEvent", "Error inserting pixel into postgres", address, position, dayIdx, color, err)
 	}
 
-	// Delete pixel from postgres ( last one )
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Pixels WHERE address = $1 AND position = $2 ORDER BY time DESC limit 1", address, position)
-	if err != nil {
-		PrintIndexerError("revertPixelPlacedEvent", "Error deleting pixel from postgres", address, posHex)
-		return
-	}
+	quests.RecordPixelPlaced(address, int(dayIdx), int(color))
+	return nil
+}
 
-	// Retrieve the old color
-	oldColor, err := core.PostgresQueryOne[int]("SELECT color FROM Pixels WHERE address = $1 AND position = $2 ORDER BY time DESC LIMIT 1", address, position)
-	if err != nil {
-		PrintIndexerError("revertPixelPlacedEvent", "Error retrieving old color from postgres", address, posHex)
-		return
+func revertPixelPlacedEvent(event IndexerEvent) error {
+	var placed pixelPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("revertPixelPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
-	// Reset pixel in redis
-	bitfieldType := "u" + strconv.Itoa(int(core.AFKBackend.CanvasConfig.ColorsBitWidth))
-	pos := uint(position) * core.AFKBackend.CanvasConfig.ColorsBitWidth
+	address := placed.Address
+	position := placed.Position
 
-	ctx := context.Background()
 	roundNumber := core.AFKBackend.CanvasConfig.Round
 	canvasKey := fmt.Sprintf("canvas-%s", roundNumber)
-	err = core.AFKBackend.Databases.Redis.BitField(ctx, canvasKey, "SET", bitfieldType, pos, oldColor).Err()
-	if err != nil {
-		PrintIndexerError("revertPixelPlacedEvent", "Error resetting pixel in redis", address, posHex)
-		return
-	}
-
-	// Send message to all connected clients
 	var message = map[string]string{
 		"position":    strconv.FormatInt(position, 10),
-		"color":       strconv.Itoa(*oldColor),
 		"messageType": "colorPixel",
 	}
-	routeutils.SendMessageToWSS(message)
-}
 
-func processBasicPixelPlacedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:] // Remove 0x prefix
-	timestampHex := event.Event.Data[0]
+	// Delete the pixel of the event's block from postgres ( last one ), the canvas falls back to
+	// the latest remaining pixel at the position, read by Apply once committed
+	app := EventApplication{}
+	app.Exec("DELETE FROM Pixels WHERE key = (SELECT key FROM Pixels WHERE address = $1 AND position = $2 AND block_number = $3 ORDER BY key DESC LIMIT 1)", address, position, event.Block.Number)
+	app.SetPixel(canvasKey, uint(position), 0, message)
+	if err := app.Apply(); err != nil {
+		return PrintIndexerError("revertPixelPlacedEvent", "Error deleting pixel from postgres", address, position, err)
+	}
 
-	timestamp, err := strconv.ParseInt(timestampHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processBasicPixelPlacedEvent", "Error converting timestamp hex to int", address, timestampHex)
-		return
+	quests.InvalidateUserStats(address)
+	return nil
+}
+
+func processBasicPixelPlacedEvent(event IndexerEvent) error {
+	var placed basicPixelPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("processBasicPixelPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO LastPlacedTime (address, time) VALUES ($1, TO_TIMESTAMP($2)) ON CONFLICT (address) DO UPDATE SET time = TO_TIMESTAMP($2)", address, timestamp)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO LastPlacedTime (address, time) VALUES ($1, TO_TIMESTAMP($2)) ON CONFLICT (address) DO UPDATE SET time = TO_TIMESTAMP($2)", placed.Address, placed.Timestamp)
 	if err != nil {
-		PrintIndexerError("processBasicPixelPlacedEvent", "Error inserting last placed time into postgres", address, timestampHex)
-		return
+		return PrintIndexerError("processBasicPixelPlacedEvent", "Error inserting last placed time into postgres", placed.Address, placed.Timestamp, err)
 	}
+	return nil
 }
 
-func revertBasicPixelPlacedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:] // Remove 0x prefix
+func revertBasicPixelPlacedEvent(event IndexerEvent) error {
+	var placed basicPixelPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("revertBasicPixelPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
+	}
 
 	// Reset last placed time to time of last pixel placed
-	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE LastPlacedTime SET time = (SELECT time FROM Pixels WHERE address = $1 ORDER BY time DESC LIMIT 1) WHERE address = $1", address)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE LastPlacedTime SET time = (SELECT time FROM Pixels WHERE address = $1 ORDER BY "+latestPixelOrder+" LIMIT 1) WHERE address = $1", placed.Address)
 	if err != nil {
-		PrintIndexerError("revertBasicPixelPlacedEvent", "Error resetting last placed time in postgres", address)
-		return
+		return PrintIndexerError("revertBasicPixelPlacedEvent", "Error resetting last placed time in postgres", placed.Address, err)
 	}
 
 	// TODO: check ordering of this and revertPixelPlacedEvent
+	return nil
 }
 
-func processFactionPixelsPlacedEvent(event IndexerEvent) {
+func processFactionPixelsPlacedEvent(event IndexerEvent) error {
 	// TODO: Faction id
-	userAddress := event.Event.Keys[1][2:] // Remove 0x prefix
-	timestampHex := event.Event.Data[0]
-	memberPixelsHex := event.Event.Data[1]
-
-	timestamp, err := strconv.ParseInt(timestampHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processMemberPixelsPlacedEvent", "Error converting timestamp hex to int", userAddress, timestampHex, memberPixelsHex)
-		return
-	}
-
-	memberPixels, err := strconv.ParseInt(memberPixelsHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processMemberPixelsPlacedEvent", "Error converting member pixels hex to int", userAddress, timestampHex, memberPixelsHex)
-		return
+	var placed memberPixelsPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("processMemberPixelsPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE FactionMembersInfo SET last_placed_time = TO_TIMESTAMP($1), member_pixels = $2 WHERE user_address = $3", timestamp, memberPixels, userAddress)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE FactionMembersInfo SET last_placed_time = TO_TIMESTAMP($1), member_pixels = $2 WHERE user_address = $3", placed.Timestamp, placed.MemberPixels, placed.Address)
 	if err != nil {
-		PrintIndexerError("processMemberPixelsPlacedEvent", "Error updating faction member info in postgres", userAddress, timestampHex, memberPixelsHex)
-		return
+		return PrintIndexerError("processMemberPixelsPlacedEvent", "Error updating faction member info in postgres", placed.Address, placed.Timestamp, placed.MemberPixels, err)
 	}
+	return nil
 }
 
-func revertFactionPixelsPlacedEvent(event IndexerEvent) {
+func revertFactionPixelsPlacedEvent(event IndexerEvent) error {
 	// TODO
+	return nil
 }
 
-func processChainFactionPixelsPlacedEvent(event IndexerEvent) {
+func processChainFactionPixelsPlacedEvent(event IndexerEvent) error {
 	// TODO: Faction id
-	userAddress := event.Event.Keys[1][2:] // Remove 0x prefix
-	timestampHex := event.Event.Data[0]
-	memberPixelsHex := event.Event.Data[1]
-
-	timestamp, err := strconv.ParseInt(timestampHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processChainFactionMemberPixelsPlacedEvent", "Error converting timestamp hex to int", userAddress, timestampHex, memberPixelsHex)
-		return
+	var placed memberPixelsPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("processChainFactionMemberPixelsPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	memberPixels, err := strconv.ParseInt(memberPixelsHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ChainFactionMembersInfo SET last_placed_time = TO_TIMESTAMP($1), member_pixels = $2 WHERE user_address = $3", placed.Timestamp, placed.MemberPixels, placed.Address)
 	if err != nil {
-		PrintIndexerError("processChainFactionMemberPixelsPlacedEvent", "Error converting member pixels hex to int", userAddress, timestampHex, memberPixelsHex)
-		return
-	}
-
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ChainFactionMembersInfo SET last_placed_time = TO_TIMESTAMP($1), member_pixels = $2 WHERE user_address = $3", timestamp, memberPixels, userAddress)
-	if err != nil {
-		PrintIndexerError("processChainFactionMemberPixelsPlacedEvent", "Error updating chain faction member info in postgres", userAddress, timestampHex, memberPixelsHex)
-		return
+		return PrintIndexerError("processChainFactionMemberPixelsPlacedEvent", "Error updating chain faction member info in postgres", placed.Address, placed.Timestamp, placed.MemberPixels, err)
 	}
+	return nil
 }
 
-func revertChainFactionPixelsPlacedEvent(event IndexerEvent) {
+func revertChainFactionPixelsPlacedEvent(event IndexerEvent) error {
 	// TODO
+	return nil
 }
 
-func processExtraPixelsPlacedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:] // Remove 0x prefix
-	extraPixelsHex := event.Event.Data[0]
-
-	extraPixels, err := strconv.ParseInt(extraPixelsHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processExtraPixelsPlacedEvent", "Error converting extra pixels hex to int", address, extraPixelsHex)
-		return
+func processExtraPixelsPlacedEvent(event IndexerEvent) error {
+	var extra userPixelsAmount
+	if err := decodeEvent(event, &extra); err != nil {
+		return PrintIndexerError("processExtraPixelsPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ExtraPixels SET available = available - $1, used = used + $1 WHERE address = $2", extraPixels, address)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ExtraPixels SET available = available - $1, used = used + $1 WHERE address = $2", extra.Amount, extra.Address)
 	if err != nil {
-		PrintIndexerError("processExtraPixelsPlacedEvent", "Error updating extra pixels in postgres", address, extraPixelsHex)
-		return
+		return PrintIndexerError("processExtraPixelsPlacedEvent", "Error updating extra pixels in postgres", extra.Address, extra.Amount, err)
 	}
-}
 
-func revertExtraPixelsPlacedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:] // Remove 0x prefix
-	extraPixelsHex := event.Event.Data[0]
+	quests.InvalidateUserStats(extra.Address)
+	return nil
+}
 
-	extraPixels, err := strconv.ParseInt(extraPixelsHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertExtraPixelsPlacedEvent", "Error converting extra pixels hex to int", address, extraPixelsHex)
-		return
+func revertExtraPixelsPlacedEvent(event IndexerEvent) error {
+	var extra userPixelsAmount
+	if err := decodeEvent(event, &extra); err != nil {
+		return PrintIndexerError("revertExtraPixelsPlacedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ExtraPixels SET available = available + $1, used = used - $1 WHERE address = $2", extraPixels, address)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ExtraPixels SET available = available + $1, used = used - $1 WHERE address = $2", extra.Amount, extra.Address)
 	if err != nil {
-		PrintIndexerError("revertExtraPixelsPlacedEvent", "Error updating extra pixels in postgres", address, extraPixelsHex)
-		return
+		return PrintIndexerError("revertExtraPixelsPlacedEvent", "Error updating extra pixels in postgres", extra.Address, extra.Amount, err)
 	}
-}
 
-func processHostAwardedPixelsEvent(event IndexerEvent) {
-	user := event.Event.Keys[1][2:] // Remove 0x prefix
-	awardHex := event.Event.Data[0]
+	quests.InvalidateUserStats(extra.Address)
+	return nil
+}
 
-	award, err := strconv.ParseInt(awardHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processHostAwardedPixelsEvent", "Error converting award hex to int", user, awardHex)
-		return
+func processHostAwardedPixelsEvent(event IndexerEvent) error {
+	var award userPixelsAmount
+	if err := decodeEvent(event, &award); err != nil {
+		return PrintIndexerError("processHostAwardedPixelsEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ExtraPixels (address, available, used) VALUES ($1, $2, 0) ON CONFLICT (address) DO UPDATE SET available = ExtraPixels.available + $2", user, award)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ExtraPixels (address, available, used) VALUES ($1, $2, 0) ON CONFLICT (address) DO UPDATE SET available = ExtraPixels.available + $2", award.Address, award.Amount)
 	if err != nil {
-		PrintIndexerError("processHostAwardedPixelsEvent", "Error updating extra pixels in postgres", user, awardHex)
-		return
+		return PrintIndexerError("processHostAwardedPixelsEvent", "Error updating extra pixels in postgres", award.Address, award.Amount, err)
 	}
-}
 
-func revertHostAwardedPixelsEvent(event IndexerEvent) {
-	user := event.Event.Keys[1][2:] // Remove 0x prefix
-	awardHex := event.Event.Data[0]
+	quests.InvalidateUserStats(award.Address)
+	return nil
+}
 
-	award, err := strconv.ParseInt(awardHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertHostAwardedPixelsEvent", "Error converting award hex to int", user, awardHex)
-		return
+func revertHostAwardedPixelsEvent(event IndexerEvent) error {
+	var award userPixelsAmount
+	if err := decodeEvent(event, &award); err != nil {
+		return PrintIndexerError("revertHostAwardedPixelsEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ExtraPixels SET available = ExtraPixels.available - $1 WHERE address = $2", award, user)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE ExtraPixels SET available = ExtraPixels.available - $1 WHERE address = $2", award.Amount, award.Address)
 	if err != nil {
-		PrintIndexerError("revertHostAwardedPixelsEvent", "Error updating extra pixels in postgres", user, awardHex)
-		return
+		return PrintIndexerError("revertHostAwardedPixelsEvent", "Error updating extra pixels in postgres", award.Address, award.Amount, err)
 	}
+
+	quests.InvalidateUserStats(award.Address)
+	return nil
 }
 
 
@@ -0,0 +1,197 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Incoming indexer messages are written to IndexerMessages before they are queued and removed
+// once processed, together with the cursor checkpoint, so a restart resumes where it stopped.
+// Pending messages are kept as the latest one and the processed ones whose block is not accepted
+// yet ( for reverts, see reorg.go ).
+
+type QueuedIndexerMessage struct {
+	Key        int64
+	Message    IndexerMessage
+	ReceivedAt time.Time
+}
+
+type storedIndexerMessage struct {
+	Key        int64     `json:"key"`
+	Finality   string    `json:"finality"`
+	Processed  bool      `json:"processed"`
+	Message    []byte    `json:"message"`
+	ReceivedAt time.Time `json:"receivedAt"`
+}
+
+// Last checkpointed cursor
+var lastCursor IndexerCursor
+var lastCursorLock = &sync.Mutex{}
+
+func GetLastCursor() IndexerCursor {
+	lastCursorLock.Lock()
+	defer lastCursorLock.Unlock()
+	return lastCursor
+}
+
+func setLastCursor(cursor IndexerCursor) {
+	lastCursorLock.Lock()
+	lastCursor = cursor
+	lastCursorLock.Unlock()
+}
+
+func persistIndexerMessage(message *IndexerMessage, streamCursor *IndexerCursor) (int64, error) {
+	messageJson, err := json.Marshal(message)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if message.Data.Finality == DATA_STATUS_PENDING {
+		// Only the latest pending message is processed
+		_, err = tx.Exec(ctx, "DELETE FROM IndexerMessages WHERE finality = $1 AND processed = false", DATA_STATUS_PENDING)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var key int64
+	err = tx.QueryRow(ctx, "INSERT INTO IndexerMessages (finality, order_key, unique_key, message) VALUES ($1, $2, $3, $4) RETURNING key", message.Data.Finality, message.Data.Cursor.OrderKey, message.Data.Cursor.UniqueKey, messageJson).Scan(&key)
+	if err != nil {
+		return 0, err
+	}
+
+	// The stream resumes after the last persisted message
+	if streamCursor != nil {
+		err = checkpointStreamCursor(ctx, tx, *streamCursor)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return key, tx.Commit(ctx)
+}
+
+// completeIndexerMessage drops a processed message, the pending messages it consumed, and
+// checkpoints the cursor in one transaction
+func completeIndexerMessage(key int64, cursor *IndexerCursor, consumedPending ...int64) error {
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM IndexerMessages WHERE key = $1 OR key = ANY($2)", key, consumedPending)
+	if err != nil {
+		return err
+	}
+
+	if cursor != nil {
+		err = checkpointCursor(ctx, tx, *cursor)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+	if cursor != nil {
+		setLastCursor(*cursor)
+	}
+	return nil
+}
+
+func checkpointCursor(ctx context.Context, tx pgx.Tx, cursor IndexerCursor) error {
+	_, err := tx.Exec(ctx, "INSERT INTO IndexerCursor (id, order_key, unique_key) VALUES (1, $1, $2) ON CONFLICT (id) DO UPDATE SET order_key = $1, unique_key = $2, updated_at = CURRENT_TIMESTAMP", cursor.OrderKey, cursor.UniqueKey)
+	return err
+}
+
+func checkpointStreamCursor(ctx context.Context, tx pgx.Tx, cursor IndexerCursor) error {
+	_, err := tx.Exec(ctx, "INSERT INTO IndexerStreamCursor (id, order_key, unique_key) VALUES (1, $1, $2) ON CONFLICT (id) DO UPDATE SET order_key = $1, unique_key = $2, updated_at = CURRENT_TIMESTAMP", cursor.OrderKey, cursor.UniqueKey)
+	return err
+}
+
+// completePendingMessage keeps the processed pending message in place of the ones it consumed
+func completePendingMessage(key int64, consumedPending ...int64) error {
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM IndexerMessages WHERE key = ANY($1)", consumedPending)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "UPDATE IndexerMessages SET processed = true WHERE key = $1", key)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// LoadIndexerState restores the queues and the cursor persisted by a previous run
+func LoadIndexerState() error {
+	cursors, err := core.PostgresQuery[IndexerCursor]("SELECT order_key, unique_key FROM IndexerCursor WHERE id = 1")
+	if err != nil {
+		return err
+	}
+	if len(cursors) > 0 {
+		setLastCursor(cursors[0])
+		LastFinalizedCursor = cursors[0].OrderKey
+	}
+
+	stored, err := core.PostgresQuery[storedIndexerMessage]("SELECT key, finality, processed, message, received_at FROM IndexerMessages ORDER BY key ASC")
+	if err != nil {
+		return err
+	}
+
+	FinalizedMessageLock.Lock()
+	AcceptedMessageLock.Lock()
+	PendingMessageLock.Lock()
+	defer FinalizedMessageLock.Unlock()
+	defer AcceptedMessageLock.Unlock()
+	defer PendingMessageLock.Unlock()
+
+	for _, storedMessage := range stored {
+		message := IndexerMessage{}
+		if err := json.Unmarshal(storedMessage.Message, &message); err != nil {
+			return fmt.Errorf("failed to decode indexer message %d: %w", storedMessage.Key, err)
+		}
+		queued := QueuedIndexerMessage{Key: storedMessage.Key, Message: message, ReceivedAt: storedMessage.ReceivedAt}
+
+		switch storedMessage.Finality {
+		case DATA_STATUS_FINALIZED:
+			FinalizedMessageQueue = enqueueOrdered(FinalizedMessageQueue, queued)
+		case DATA_STATUS_ACCEPTED:
+			AcceptedMessageQueue = enqueueOrdered(AcceptedMessageQueue, queued)
+		case DATA_STATUS_PENDING:
+			if storedMessage.Processed {
+				pushProcessedPending(queued)
+			} else {
+				LatestPendingMessage = &queued
+			}
+		}
+	}
+
+	fmt.Println("Loaded indexer state: cursor", GetLastCursor().OrderKey, "finalized", len(FinalizedMessageQueue), "accepted", len(AcceptedMessageQueue))
+	return nil
+}
@@ -0,0 +1,206 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+// A canvas can be rebuilt from the latest pixel per position in Postgres and compared with the
+// live Redis key. When swapped in, the rebuilt bitfield replaces the key with a RENAME, the
+// update stream is reset so clients reload the canvas, and the pixels placed while rebuilding
+// are set again to their latest color.
+
+const (
+	maxRebuildDiffs = 1000
+	// Pixels rows are stamped with their transaction start, rows committed around the snapshot
+	// can be older than it
+	rebuildReplayMargin = time.Minute
+	// Times a replayed pixel is set again when its color changes meanwhile
+	maxReplayAttempts = 5
+)
+
+type CanvasDiff struct {
+	Position uint  `json:"position"`
+	Redis    int64 `json:"redis"`
+	Postgres int64 `json:"postgres"`
+}
+
+type CanvasRebuild struct {
+	CanvasKey    string       `json:"canvasKey"`
+	Size         uint         `json:"size"`
+	RedisMissing bool         `json:"redisMissing"`
+	DiffCount    int          `json:"diffCount"`
+	Diffs        []CanvasDiff `json:"diffs"` // the first maxRebuildDiffs
+	Swapped      bool         `json:"swapped"`
+	Replayed     int          `json:"replayed"`
+}
+
+// Postgres clock, which stamps the pixels
+type rebuildSnapshot struct {
+	TakenAt time.Time `json:"takenAt"`
+}
+
+// KEYS: rebuilt canvas, canvas, seq, updates
+var swapCanvasScript = redis.NewScript(`
+redis.call('RENAME', KEYS[1], KEYS[2])
+redis.call('DEL', KEYS[4])
+return redis.call('INCR', KEYS[3])
+`)
+
+// RebuildCanvas rebuilds the main canvas, or the world canvas when worldId is set, and swaps it
+// in if asked
+func RebuildCanvas(worldId string, swap bool) (*CanvasRebuild, error) {
+	ctx := context.Background()
+	canvasConfig := core.AFKBackend.CanvasConfig
+	bitWidth := canvasConfig.ColorsBitWidth
+
+	canvasKey := fmt.Sprintf("canvas-%s", canvasConfig.Round)
+	size := canvasConfig.Canvas.Width * canvasConfig.Canvas.Height
+	table := "Pixels"
+	filter := ""
+	if worldId != "" {
+		id, err := strconv.Atoi(worldId)
+		if err != nil {
+			return nil, fmt.Errorf("invalid world id %s", worldId)
+		}
+		world, err := core.PostgresQueryOne[worldSize]("SELECT world_id, width, height FROM Worlds WHERE world_id = $1", id)
+		if err != nil {
+			return nil, fmt.Errorf("world %d not found: %w", id, err)
+		}
+		worldId = strconv.Itoa(world.WorldId)
+		canvasKey = "canvas-" + worldId
+		size = world.Width * world.Height
+		table = "WorldsPixels"
+		filter = "world_id = " + worldId + " AND "
+	}
+
+	snapshot, err := core.PostgresQueryOne[rebuildSnapshot]("SELECT CURRENT_TIMESTAMP::timestamp AS taken_at")
+	if err != nil {
+		return nil, err
+	}
+	latest, err := core.PostgresQuery[positionColor]("SELECT DISTINCT ON (position) position, color FROM " + table + " WHERE " + filter + "TRUE ORDER BY position, " + latestPixelOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	rebuilt := rebuildBitfield(canvasKey, latest, size, bitWidth)
+
+	result := &CanvasRebuild{CanvasKey: canvasKey, Size: size, Diffs: []CanvasDiff{}}
+	live, err := core.AFKBackend.Databases.Redis.Get(ctx, canvasKey).Bytes()
+	if err == redis.Nil {
+		result.RedisMissing = true
+	} else if err != nil {
+		return nil, err
+	}
+	result.diff(live, rebuilt, bitWidth)
+
+	if !swap {
+		return result, nil
+	}
+
+	rebuiltKey := canvasKey + "-rebuild"
+	if err := core.AFKBackend.Databases.Redis.Set(ctx, rebuiltKey, rebuilt, 0).Err(); err != nil {
+		return result, err
+	}
+	keys := []string{rebuiltKey, canvasKey, routeutils.CanvasSeqKey(canvasKey), routeutils.CanvasUpdatesKey(canvasKey)}
+	if err := swapCanvasScript.Run(ctx, core.AFKBackend.Databases.Redis, keys).Err(); err != nil {
+		return result, err
+	}
+	result.Swapped = true
+	fmt.Println("Swapped rebuilt canvas", canvasKey, "with", result.DiffCount, "differences")
+
+	// Pixels placed since the snapshot went to the old key
+	recent, err := core.PostgresQuery[int64]("SELECT DISTINCT position FROM "+table+" WHERE "+filter+"time >= $1", snapshot.TakenAt.Add(-rebuildReplayMargin))
+	if err != nil {
+		return result, err
+	}
+	for _, position := range recent {
+		if position < 0 || uint(position) >= size {
+			continue
+		}
+		if err := replayLatestPixel(ctx, canvasKey, worldId, uint(position)); err != nil {
+			return result, err
+		}
+		result.Replayed++
+	}
+	return result, nil
+}
+
+// rebuildBitfield packs the latest pixels into a canvas bitfield, pixels outside of it are skipped
+func rebuildBitfield(canvasKey string, latest []positionColor, size uint, bitWidth uint) []byte {
+	totalBitSize := size * bitWidth
+	totalByteSize := totalBitSize / 8
+	if totalBitSize%8 != 0 {
+		totalByteSize += 1
+	}
+	rebuilt := make([]byte, totalByteSize)
+	for _, pixel := range latest {
+		if pixel.Position < 0 || uint(pixel.Position) >= size {
+			logIndexerError("RebuildCanvas", "Pixel outside of the canvas, skipped", canvasKey, pixel.Position)
+			continue
+		}
+		routeutils.SetCanvasPixelColor(rebuilt, bitWidth, uint(pixel.Position), pixel.Color)
+	}
+	return rebuilt
+}
+
+// diff counts the pixels of the live canvas differing from the rebuilt one, a missing canvas
+// reads as all 0
+func (r *CanvasRebuild) diff(live []byte, rebuilt []byte, bitWidth uint) {
+	for position := uint(0); position < r.Size; position++ {
+		expected := routeutils.GetCanvasPixelColor(rebuilt, bitWidth, position)
+		current := int64(0)
+		if !r.RedisMissing {
+			current = routeutils.GetCanvasPixelColor(live, bitWidth, position)
+		}
+		if current == expected {
+			continue
+		}
+		r.DiffCount++
+		if len(r.Diffs) < maxRebuildDiffs {
+			r.Diffs = append(r.Diffs, CanvasDiff{Position: position, Redis: current, Postgres: expected})
+		}
+	}
+}
+
+// replayLatestPixel sets the pixel to its latest color in Postgres. The color is read again
+// after the write, an event applied meanwhile may have been overwritten and is set again.
+func replayLatestPixel(ctx context.Context, canvasKey string, worldId string, position uint) error {
+	bitWidth := core.AFKBackend.CanvasConfig.ColorsBitWidth
+	color, err := latestPixelColor(worldId, position)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < maxReplayAttempts; attempt++ {
+		message := map[string]string{
+			"position":    strconv.FormatUint(uint64(position), 10),
+			"color":       strconv.FormatInt(color, 10),
+			"messageType": "colorPixel",
+		}
+		if worldId != "" {
+			message["worldId"] = worldId
+			message["messageType"] = "colorWorldPixel"
+		}
+		op := RedisOp{Kind: RedisOpSetPixel, CanvasKey: canvasKey, BitfieldType: fmt.Sprintf("u%d", bitWidth), Offset: position * bitWidth, Color: color, Message: message}
+		if err := applyRedisOps(ctx, 0, []RedisOp{op}); err != nil {
+			return err
+		}
+
+		current, err := latestPixelColor(worldId, position)
+		if err != nil {
+			return err
+		}
+		if current == color {
+			return nil
+		}
+		color = current
+	}
+	return fmt.Errorf("pixel %d of %s kept changing while replayed", position, canvasKey)
+}
@@ -0,0 +1,89 @@
+package indexer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRebuildBitfield(t *testing.T) {
+	tests := []struct {
+		name     string
+		latest   []positionColor
+		size     uint
+		bitWidth uint
+		rebuilt  []byte
+	}{
+		{name: "empty", size: 4, bitWidth: 5, rebuilt: []byte{0x00, 0x00, 0x00}},
+		// 00001 00010 00011 11111
+		{name: "u5", latest: []positionColor{{0, 1}, {1, 2}, {2, 3}, {3, 31}}, size: 4, bitWidth: 5, rebuilt: []byte{0x08, 0x87, 0xF0}},
+		{name: "unordered", latest: []positionColor{{3, 31}, {0, 1}, {2, 3}, {1, 2}}, size: 4, bitWidth: 5, rebuilt: []byte{0x08, 0x87, 0xF0}},
+		{name: "outside", latest: []positionColor{{-1, 7}, {1, 2}, {4, 31}, {100, 1}}, size: 4, bitWidth: 5, rebuilt: []byte{0x00, 0x80, 0x00}},
+		{name: "u8", latest: []positionColor{{1, 42}, {2, 255}}, size: 3, bitWidth: 8, rebuilt: []byte{0x00, 0x2A, 0xFF}},
+	}
+	for _, test := range tests {
+		rebuilt := rebuildBitfield("canvas-test", test.latest, test.size, test.bitWidth)
+		if !reflect.DeepEqual(rebuilt, test.rebuilt) {
+			t.Errorf("%s: rebuilt %x, want %x", test.name, rebuilt, test.rebuilt)
+		}
+	}
+}
+
+func TestCanvasRebuildDiff(t *testing.T) {
+	// u5 colors 1, 2, 3, 31
+	rebuilt := []byte{0x08, 0x87, 0xF0}
+
+	tests := []struct {
+		name      string
+		live      []byte
+		missing   bool
+		diffCount int
+		diffs     []CanvasDiff
+	}{
+		{name: "same", live: []byte{0x08, 0x87, 0xF0}, diffs: []CanvasDiff{}},
+		// 00001 00000 00011 11110
+		{name: "two pixels", live: []byte{0x08, 0x07, 0xE0}, diffCount: 2, diffs: []CanvasDiff{
+			{Position: 1, Redis: 0, Postgres: 2},
+			{Position: 3, Redis: 30, Postgres: 31},
+		}},
+		// The pixels past the end of a short canvas read as 0
+		{name: "short", live: []byte{0x08}, diffCount: 3, diffs: []CanvasDiff{
+			{Position: 1, Redis: 0, Postgres: 2},
+			{Position: 2, Redis: 0, Postgres: 3},
+			{Position: 3, Redis: 0, Postgres: 31},
+		}},
+		{name: "missing", missing: true, diffCount: 4, diffs: []CanvasDiff{
+			{Position: 0, Redis: 0, Postgres: 1},
+			{Position: 1, Redis: 0, Postgres: 2},
+			{Position: 2, Redis: 0, Postgres: 3},
+			{Position: 3, Redis: 0, Postgres: 31},
+		}},
+	}
+	for _, test := range tests {
+		result := &CanvasRebuild{CanvasKey: "canvas-test", Size: 4, RedisMissing: test.missing, Diffs: []CanvasDiff{}}
+		result.diff(test.live, rebuilt, 5)
+		if result.DiffCount != test.diffCount {
+			t.Errorf("%s: %d differences, want %d", test.name, result.DiffCount, test.diffCount)
+		}
+		if !reflect.DeepEqual(result.Diffs, test.diffs) {
+			t.Errorf("%s: diffs %+v, want %+v", test.name, result.Diffs, test.diffs)
+		}
+	}
+}
+
+func TestCanvasRebuildDiffLimit(t *testing.T) {
+	size := uint(maxRebuildDiffs + 10)
+	latest := make([]positionColor, size)
+	for position := range latest {
+		latest[position] = positionColor{Position: int64(position), Color: 1}
+	}
+	rebuilt := rebuildBitfield("canvas-test", latest, size, 5)
+
+	result := &CanvasRebuild{CanvasKey: "canvas-test", Size: size, RedisMissing: true, Diffs: []CanvasDiff{}}
+	result.diff(nil, rebuilt, 5)
+	if result.DiffCount != int(size) {
+		t.Errorf("%d differences, want %d", result.DiffCount, size)
+	}
+	if len(result.Diffs) != maxRebuildDiffs {
+		t.Errorf("%d diffs reported, want %d", len(result.Diffs), maxRebuildDiffs)
+	}
+}
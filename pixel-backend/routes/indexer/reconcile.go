@@ -0,0 +1,158 @@
+package indexer
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+// The reconciler retries the Redis outbox and periodically compares the canvases in Redis
+// with the latest pixels in Postgres, which is the source of truth, repairing any divergence.
+// It also stores the canvas keyframes used to rebuild past canvases.
+
+const (
+	outboxRetryInterval = 30 * time.Second
+	reconcileInterval   = 15 * time.Minute
+	keyframeInterval    = 10 * time.Minute
+)
+
+var reconciledPixels = expvar.NewInt("reconcile_repaired_pixels")
+
+type positionColor struct {
+	Position int64 `json:"position"`
+	Color    int64 `json:"color"`
+}
+
+type worldSize struct {
+	WorldId int  `json:"world_id"`
+	Width   uint `json:"width"`
+	Height  uint `json:"height"`
+}
+
+func StartReconciler(ctx context.Context) {
+	go func() {
+		outboxTicker := time.NewTicker(outboxRetryInterval)
+		reconcileTicker := time.NewTicker(reconcileInterval)
+		keyframeTicker := time.NewTicker(keyframeInterval)
+		defer outboxTicker.Stop()
+		defer reconcileTicker.Stop()
+		defer keyframeTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-outboxTicker.C:
+				applied, err := RetryRedisOutbox()
+				if err != nil {
+					logIndexerError("StartReconciler", "Failed to retry redis outbox", err)
+				} else if applied > 0 {
+					fmt.Println("Applied", applied, "redis outbox entries")
+				}
+			case <-reconcileTicker.C:
+				repaired, err := ReconcileCanvases()
+				if err != nil {
+					logIndexerError("StartReconciler", "Failed to reconcile canvases", err)
+				} else if repaired > 0 {
+					fmt.Println("Reconciler repaired", repaired, "pixels")
+				}
+			case <-keyframeTicker.C:
+				created, err := routeutils.CreateCanvasKeyframes()
+				if err != nil {
+					logIndexerError("StartReconciler", "Failed to create canvas keyframes", err)
+				} else if created > 0 {
+					fmt.Println("Created", created, "canvas keyframes")
+				}
+			}
+		}
+	}()
+}
+
+// ReconcileCanvases checks the main canvas and every world canvas, returns the repaired pixel count
+func ReconcileCanvases() (int, error) {
+	canvasConfig := core.AFKBackend.CanvasConfig
+	mainKey := fmt.Sprintf("canvas-%s", canvasConfig.Round)
+	repaired, err := reconcileCanvas(mainKey, canvasConfig.Canvas.Width*canvasConfig.Canvas.Height, "", "Pixels", "")
+	if err != nil {
+		return repaired, err
+	}
+
+	worlds, err := core.PostgresQuery[worldSize]("SELECT world_id, width, height FROM Worlds")
+	if err != nil {
+		return repaired, err
+	}
+	for _, world := range worlds {
+		worldId := strconv.Itoa(world.WorldId)
+		worldRepaired, err := reconcileCanvas("canvas-"+worldId, world.Width*world.Height, worldId, "WorldsPixels", "world_id = "+worldId+" AND ")
+		repaired += worldRepaired
+		if err != nil {
+			return repaired, err
+		}
+	}
+	return repaired, nil
+}
+
+func reconcileCanvas(canvasKey string, size uint, worldId string, table string, filter string) (int, error) {
+	ctx := context.Background()
+	bitWidth := core.AFKBackend.CanvasConfig.ColorsBitWidth
+
+	// Events are applied to Postgres and Redis under the lock, holding it the snapshots agree
+	// except for real divergences
+	unlock, err := lockCanvasWrites(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	canvas, err := core.AFKBackend.Databases.Redis.Get(ctx, canvasKey).Bytes()
+	if err == redis.Nil {
+		logIndexerError("reconcileCanvas", "Canvas missing from redis", canvasKey)
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	latest, err := core.PostgresQuery[positionColor]("SELECT DISTINCT ON (position) position, color FROM " + table + " WHERE " + filter + "TRUE ORDER BY position, " + latestPixelOrder)
+	if err != nil {
+		return 0, err
+	}
+
+	expected := make(map[int64]int64, len(latest))
+	for _, pixel := range latest {
+		expected[pixel.Position] = pixel.Color
+	}
+
+	repaired := 0
+	for position := uint(0); position < size; position++ {
+		color := expected[int64(position)]
+		if routeutils.GetCanvasPixelColor(canvas, bitWidth, position) == color {
+			continue
+		}
+
+		message := map[string]string{
+			"position":    strconv.Itoa(int(position)),
+			"color":       strconv.FormatInt(color, 10),
+			"messageType": "colorPixel",
+		}
+		if worldId != "" {
+			message["worldId"] = worldId
+			message["messageType"] = "colorWorldPixel"
+		}
+
+		logIndexerError("reconcileCanvas", "Pixel diverged from postgres, repairing", canvasKey, position, color)
+		op := RedisOp{Kind: RedisOpSetPixel, CanvasKey: canvasKey, BitfieldType: fmt.Sprintf("u%d", bitWidth), Offset: position * bitWidth, Color: color, Message: message}
+		if err := applyRedisOps(ctx, 0, []RedisOp{op}); err != nil {
+			return repaired, err
+		}
+		repaired++
+		reconciledPixels.Add(1)
+	}
+	return repaired, nil
+}
@@ -0,0 +1,376 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
+)
+
+// Pending messages are processed optimistically. The processed ones are kept, one per block, until
+// a message of the same block with a higher finality arrives : the events they do not share are
+// then reverted ( latest first ) and the new ones processed. Pending blocks left behind by an
+// accepted / finalized message of a later block were reorged out and are reverted entirely.
+//
+// The DNA stream also invalidates the data after a cursor. Queued messages after it are dropped,
+// and the processed pending ones reverted ( latest first ) by the message processor before the
+// messages of the new chain. Accepted messages are not kept once processed : the pixels of their
+// invalidated blocks are deleted, the canvases rebuilt and the cursor moved back before the
+// new chain is processed.
+
+// Processed pending messages ordered by block, only used by the message processor
+var ProcessedPendingMessages []QueuedIndexerMessage
+var processedPendingLock = &sync.Mutex{}
+
+// Lowest cursor invalidated by the stream and not reverted yet
+var invalidatedCursor *IndexerCursor
+var invalidatedLock = &sync.Mutex{}
+
+// Wait before retrying a failed revert of the applied blocks, nothing else is processed meanwhile
+const invalidationRetryDelay = 10 * time.Second
+
+type messageEvent struct {
+	Batch int
+	Event IndexerEvent
+}
+
+// messageEvents flattens the events of every batch of the message, in order
+func messageEvents(message IndexerMessage) []messageEvent {
+	var events []messageEvent
+	for batchIdx, batch := range message.Data.Batch {
+		block := batchBlock(message, batch)
+		for _, event := range batch.Events {
+			if len(event.Event.Keys) == 0 {
+				continue
+			}
+			event.Block = block
+			events = append(events, messageEvent{Batch: batchIdx, Event: event})
+		}
+	}
+	return events
+}
+
+// verifyEventHandlers checks every processed event can be reverted
+func verifyEventHandlers() error {
+	for eventKey := range eventProcessors {
+		if _, ok := eventReverters[eventKey]; !ok {
+			return fmt.Errorf("event %s has a processor but no reverter", eventKey)
+		}
+		if _, ok := eventRequiresOrdering[eventKey]; !ok {
+			return fmt.Errorf("event %s has a processor but no ordering", eventKey)
+		}
+		if _, ok := eventContracts[eventKey]; !ok {
+			return fmt.Errorf("event %s has a processor but no contract", eventKey)
+		}
+	}
+	return nil
+}
+
+func pushProcessedPending(queued QueuedIndexerMessage) {
+	processedPendingLock.Lock()
+	defer processedPendingLock.Unlock()
+
+	ProcessedPendingMessages = append(ProcessedPendingMessages, queued)
+	sort.SliceStable(ProcessedPendingMessages, func(i, j int) bool {
+		return ProcessedPendingMessages[i].Message.Data.Cursor.OrderKey < ProcessedPendingMessages[j].Message.Data.Cursor.OrderKey
+	})
+}
+
+// takeProcessedPending removes the processed pending messages of the block, and of the
+// earlier blocks when orphans is set
+func takeProcessedPending(orderKey int, orphans bool) (*QueuedIndexerMessage, []QueuedIndexerMessage) {
+	processedPendingLock.Lock()
+	defer processedPendingLock.Unlock()
+
+	var sameBlock *QueuedIndexerMessage
+	var orphaned []QueuedIndexerMessage
+	kept := ProcessedPendingMessages[:0]
+	for _, queued := range ProcessedPendingMessages {
+		queuedOrderKey := queued.Message.Data.Cursor.OrderKey
+		switch {
+		case queuedOrderKey == orderKey:
+			match := queued
+			sameBlock = &match
+		case orphans && queuedOrderKey < orderKey:
+			orphaned = append(orphaned, queued)
+		default:
+			kept = append(kept, queued)
+		}
+	}
+	ProcessedPendingMessages = kept
+	return sameBlock, orphaned
+}
+
+// ProcessMessage processes the events of the message against the pending messages already
+// processed, returns the keys of the pending messages it consumed
+func ProcessMessage(message IndexerMessage) []int64 {
+	var consumed []int64
+	isPending := message.Data.Finality == DATA_STATUS_PENDING
+	sameBlock, orphaned := takeProcessedPending(message.Data.Cursor.OrderKey, !isPending)
+
+	// Latest blocks first
+	for idx := len(orphaned) - 1; idx >= 0; idx-- {
+		fmt.Println("Reverting orphaned pending block:", orphaned[idx].Message.Data.Cursor.OrderKey)
+		revertMessageEvents(orphaned[idx].Message)
+		consumed = append(consumed, orphaned[idx].Key)
+	}
+
+	if sameBlock != nil {
+		processMessageEventsWithReverter(sameBlock.Message, message)
+		consumed = append(consumed, sameBlock.Key)
+	} else {
+		ProcessMessageEvents(message)
+	}
+	return consumed
+}
+
+// processMessageEventsWithReverter moves the state from oldMessage to newMessage, both of the
+// same block : the events after their common prefix are reverted then processed
+func processMessageEventsWithReverter(oldMessage IndexerMessage, newMessage IndexerMessage) {
+	oldEvents := messageEvents(oldMessage)
+	newEvents := messageEvents(newMessage)
+
+	// A block built on another parent shares nothing
+	common := 0
+	if oldMessage.Data.Cursor.UniqueKey == newMessage.Data.Cursor.UniqueKey {
+		for common < len(oldEvents) && common < len(newEvents) {
+			oldEvent, newEvent := oldEvents[common], newEvents[common]
+			if oldEvent.Batch != newEvent.Batch || !EventComparator(oldEvent.Event, newEvent.Event) {
+				break
+			}
+			common++
+		}
+	}
+	if common < len(oldEvents) {
+		fmt.Println("Reorg in block", newMessage.Data.Cursor.OrderKey, "reverting", len(oldEvents)-common, "events")
+	}
+
+	// Revert from the end, events which do not require ordering are only reverted if they are
+	// not in the new events
+	var unorderedEvents []IndexerEvent
+	for idx := len(oldEvents) - 1; idx >= common; idx-- {
+		event := oldEvents[idx].Event
+		if eventRequiresOrdering[event.Event.Keys[0]] {
+			handleEvent(DeadLetterRevert, event, oldMessage)
+		} else {
+			unorderedEvents = append(unorderedEvents, event)
+		}
+	}
+
+	for idx := common; idx < len(newEvents); idx++ {
+		event := newEvents[idx].Event
+
+		wasProcessed := false
+		for unorderedIdx, unorderedEvent := range unorderedEvents {
+			if EventComparator(unorderedEvent, event) {
+				unorderedEvents = append(unorderedEvents[:unorderedIdx], unorderedEvents[unorderedIdx+1:]...)
+				wasProcessed = true
+				break
+			}
+		}
+		if wasProcessed {
+			continue
+		}
+
+		handleEvent(DeadLetterProcess, event, newMessage)
+	}
+
+	// Revert remaining unordered events
+	for _, unorderedEvent := range unorderedEvents {
+		handleEvent(DeadLetterRevert, unorderedEvent, oldMessage)
+	}
+}
+
+// revertMessageEvents reverts every event of the message, latest first
+func revertMessageEvents(message IndexerMessage) {
+	events := messageEvents(message)
+	for idx := len(events) - 1; idx >= 0; idx-- {
+		handleEvent(DeadLetterRevert, events[idx].Event, message)
+	}
+}
+
+// invalidateIndexerMessages drops the messages starting at or after the cursor ( their blocks
+// follow it ) and checkpoints the stream cursor, the processed ones are reverted by the processor
+func invalidateIndexerMessages(cursor IndexerCursor) error {
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, "DELETE FROM IndexerMessages WHERE order_key >= $1 AND processed = false", cursor.OrderKey)
+	if err != nil {
+		return err
+	}
+	err = checkpointStreamCursor(ctx, tx, cursor)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit(ctx)
+	if err != nil {
+		return err
+	}
+
+	invalidated := func(queue []QueuedIndexerMessage) []QueuedIndexerMessage {
+		kept := queue[:0]
+		for _, queued := range queue {
+			if queued.Message.Data.Cursor.OrderKey < cursor.OrderKey {
+				kept = append(kept, queued)
+			}
+		}
+		return kept
+	}
+	FinalizedMessageLock.Lock()
+	FinalizedMessageQueue = invalidated(FinalizedMessageQueue)
+	FinalizedMessageLock.Unlock()
+	AcceptedMessageLock.Lock()
+	AcceptedMessageQueue = invalidated(AcceptedMessageQueue)
+	AcceptedMessageLock.Unlock()
+	PendingMessageLock.Lock()
+	if LatestPendingMessage != nil && LatestPendingMessage.Message.Data.Cursor.OrderKey >= cursor.OrderKey {
+		LatestPendingMessage = nil
+	}
+	PendingMessageLock.Unlock()
+
+	invalidateCursor(cursor)
+	notifyMessageProcessor()
+	return nil
+}
+
+// invalidateCursor queues the cursor for TryProcessInvalidation, keeping the lowest one
+func invalidateCursor(cursor IndexerCursor) {
+	invalidatedLock.Lock()
+	defer invalidatedLock.Unlock()
+	if invalidatedCursor == nil || cursor.OrderKey < invalidatedCursor.OrderKey {
+		invalidatedCursor = &cursor
+	}
+}
+
+// takeProcessedPendingAfter removes the processed pending messages starting at or after the order key
+func takeProcessedPendingAfter(orderKey int) []QueuedIndexerMessage {
+	processedPendingLock.Lock()
+	defer processedPendingLock.Unlock()
+
+	var invalidated []QueuedIndexerMessage
+	kept := ProcessedPendingMessages[:0]
+	for _, queued := range ProcessedPendingMessages {
+		if queued.Message.Data.Cursor.OrderKey >= orderKey {
+			invalidated = append(invalidated, queued)
+		} else {
+			kept = append(kept, queued)
+		}
+	}
+	ProcessedPendingMessages = kept
+	return invalidated
+}
+
+// TryProcessInvalidation reverts the processed pending messages invalidated by the stream, and
+// the applied accepted blocks after the cursor
+func TryProcessInvalidation() bool {
+	invalidatedLock.Lock()
+	cursor := invalidatedCursor
+	invalidatedCursor = nil
+	invalidatedLock.Unlock()
+
+	if cursor == nil {
+		return false
+	}
+
+	invalidated := takeProcessedPendingAfter(cursor.OrderKey)
+	keys := make([]int64, 0, len(invalidated))
+	for idx := len(invalidated) - 1; idx >= 0; idx-- {
+		fmt.Println("Reverting invalidated pending block:", invalidated[idx].Message.Data.Cursor.OrderKey)
+		revertMessageEvents(invalidated[idx].Message)
+		keys = append(keys, invalidated[idx].Key)
+	}
+	if _, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM IndexerMessages WHERE key = ANY($1)", keys); err != nil {
+		logIndexerError("TryProcessInvalidation", "error removing invalidated messages", keys, err)
+	}
+
+	if lastCursor := GetLastCursor(); lastCursor.OrderKey >= cursor.OrderKey {
+		if err := revertAppliedBlocks(*cursor); err != nil {
+			// The new chain can not be applied over the invalidated blocks, retry before anything else
+			logIndexerError("TryProcessInvalidation", "Failed to revert the applied blocks, retrying", cursor.OrderKey, lastCursor.OrderKey, err)
+			invalidateCursor(*cursor)
+			time.Sleep(invalidationRetryDelay)
+		}
+	}
+	return true
+}
+
+// revertAppliedBlocks deletes the pixels of the blocks after the cursor and moves the checkpoint
+// back before them, in one transaction, then rebuilds the canvases from the pixels left. Messages
+// starting at the cursor carry the blocks after it. Only the pixels are reverted, the other rows
+// written by these blocks are left as they are.
+func revertAppliedBlocks(cursor IndexerCursor) error {
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "DELETE FROM Pixels WHERE block_number > $1 RETURNING address", cursor.OrderKey)
+	if err != nil {
+		return err
+	}
+	addresses, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	rows, err = tx.Query(ctx, "DELETE FROM WorldsPixels WHERE block_number > $1 RETURNING world_id", cursor.OrderKey)
+	if err != nil {
+		return err
+	}
+	worldIds, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+
+	// The last valid message started before the cursor, the next one starts at it
+	checkpoint := IndexerCursor{OrderKey: cursor.OrderKey - 1}
+	if err := checkpointCursor(ctx, tx, checkpoint); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	setLastCursor(checkpoint)
+	LastFinalizedCursor = checkpoint.OrderKey
+	logIndexerError("revertAppliedBlocks", "Reverted the pixels of the applied blocks after the cursor, other state is not reverted", cursor.OrderKey, len(addresses), len(worldIds))
+
+	for _, address := range distinct(addresses) {
+		quests.InvalidateUserStats(address)
+	}
+
+	// Postgres is reverted, a failed rebuild is left to the reconciler
+	if _, err := RebuildCanvas("", true); err != nil {
+		logIndexerError("revertAppliedBlocks", "Failed to rebuild the canvas", err)
+	}
+	for _, worldId := range distinct(worldIds) {
+		if _, err := RebuildCanvas(strconv.Itoa(worldId), true); err != nil {
+			logIndexerError("revertAppliedBlocks", "Failed to rebuild the world canvas", worldId, err)
+		}
+	}
+	return nil
+}
+
+func distinct[T comparable](values []T) []T {
+	seen := make(map[T]bool, len(values))
+	unique := []T{}
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
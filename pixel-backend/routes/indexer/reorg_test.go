@@ -0,0 +1,93 @@
+package indexer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func queuedAt(key int64, orderKey int) QueuedIndexerMessage {
+	queued := QueuedIndexerMessage{Key: key}
+	queued.Message.Data.Cursor.OrderKey = orderKey
+	return queued
+}
+
+func TestTakeProcessedPendingAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		orderKey int
+		taken    []int64
+		kept     []int64
+	}{
+		{name: "after every block", orderKey: 40, taken: nil, kept: []int64{1, 2, 3}},
+		{name: "from a block", orderKey: 20, taken: []int64{2, 3}, kept: []int64{1}},
+		{name: "between blocks", orderKey: 25, taken: []int64{3}, kept: []int64{1, 2}},
+		{name: "before every block", orderKey: 5, taken: []int64{1, 2, 3}, kept: []int64{}},
+	}
+	for _, test := range tests {
+		ProcessedPendingMessages = nil
+		pushProcessedPending(queuedAt(3, 30))
+		pushProcessedPending(queuedAt(1, 10))
+		pushProcessedPending(queuedAt(2, 20))
+
+		var taken []int64
+		for _, queued := range takeProcessedPendingAfter(test.orderKey) {
+			taken = append(taken, queued.Key)
+		}
+		kept := []int64{}
+		for _, queued := range ProcessedPendingMessages {
+			kept = append(kept, queued.Key)
+		}
+		if !reflect.DeepEqual(taken, test.taken) {
+			t.Errorf("%s: taken %v, want %v", test.name, taken, test.taken)
+		}
+		if !reflect.DeepEqual(kept, test.kept) {
+			t.Errorf("%s: kept %v, want %v", test.name, kept, test.kept)
+		}
+	}
+	ProcessedPendingMessages = nil
+}
+
+func TestInvalidateCursorKeepsLowest(t *testing.T) {
+	invalidatedCursor = nil
+	invalidateCursor(IndexerCursor{OrderKey: 30, UniqueKey: "0x30"})
+	invalidateCursor(IndexerCursor{OrderKey: 20, UniqueKey: "0x20"})
+	invalidateCursor(IndexerCursor{OrderKey: 25, UniqueKey: "0x25"})
+	if invalidatedCursor == nil || invalidatedCursor.OrderKey != 20 || invalidatedCursor.UniqueKey != "0x20" {
+		t.Errorf("invalidated cursor %v, want 20", invalidatedCursor)
+	}
+	invalidatedCursor = nil
+}
+
+func TestInvalidationRevertsAppliedBlocks(t *testing.T) {
+	requireTestDatabases(t)
+	resetTestPixels(t, 2)
+	position := testFirstPosition
+
+	// Blocks 240 and 260 were accepted and applied, the stream then invalidates the data after 250
+	for _, event := range []IndexerEvent{
+		testPixelEvent(240, position, 1),
+		testPixelEvent(260, position, 2),
+		testPixelEvent(260, position+1, 4),
+	} {
+		if err := processPixelPlacedEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setLastCursor(IndexerCursor{OrderKey: 259})
+
+	if err := invalidateIndexerMessages(IndexerCursor{OrderKey: 250, UniqueKey: "0xfa"}); err != nil {
+		t.Fatal(err)
+	}
+	if !TryProcessInvalidation() {
+		t.Fatal("invalidation not processed")
+	}
+
+	checkTestPixel(t, position, 1)
+	checkTestPixel(t, position+1, 0)
+	if cursor := GetLastCursor(); cursor.OrderKey != 249 {
+		t.Errorf("cursor %d, want 249", cursor.OrderKey)
+	}
+	if invalidatedCursor != nil {
+		t.Errorf("invalidation left queued at %d", invalidatedCursor.OrderKey)
+	}
+}
@@ -2,16 +2,20 @@ package indexer
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"sync"
+	"time"
 
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
 
 func InitIndexerRoutes() {
 	http.HandleFunc("/consume-indexer-msg", consumeIndexerMsg)
+	http.HandleFunc("/indexer-status", getIndexerStatus)
 	// http.HandleFunc("/enable-turboda", enableTurboda)
 	// http.HandleFunc("/disable-turboda", disableTurboda)
 }
@@ -27,33 +31,60 @@ type IndexerEvent struct {
 		Keys        []string `json:"keys"`
 		Data        []string `json:"data"`
 	} `json:"event"`
+	// Set from the batch when the event is handled
+	Block IndexerEventBlock `json:"-"`
+}
+
+// Block the event was emitted in, Timestamp is in unix seconds and nil without the block header
+type IndexerEventBlock struct {
+	Number    int64
+	Timestamp *int64
+}
+
+// Sent by the indexer when its filter includes the header, the block number as a string or a number
+type IndexerBlockHeader struct {
+	BlockNumber json.Number `json:"blockNumber"`
+	Timestamp   time.Time   `json:"timestamp"`
+}
+
+type IndexerBatch struct {
+	Status string              `json:"status"`
+	Header *IndexerBlockHeader `json:"header,omitempty"`
+	Events []IndexerEvent      `json:"events"`
+}
+
+// batchBlock returns the block of the batch, the message end cursor stands for the block number
+// when the indexer sent no header
+func batchBlock(message IndexerMessage, batch IndexerBatch) IndexerEventBlock {
+	if batch.Header == nil {
+		return IndexerEventBlock{Number: int64(message.Data.EndCursor.OrderKey)}
+	}
+	number, err := batch.Header.BlockNumber.Int64()
+	if err != nil {
+		logIndexerError("batchBlock", "Invalid block number, using the message end cursor", batch.Header.BlockNumber, err)
+		number = int64(message.Data.EndCursor.OrderKey)
+	}
+	timestamp := batch.Header.Timestamp.Unix()
+	return IndexerEventBlock{Number: number, Timestamp: &timestamp}
 }
 
 type IndexerMessage struct {
 	Data struct {
-		Cursor    IndexerCursor `json:"cursor"`
-		EndCursor IndexerCursor `json:"end_cursor"`
-		Finality  string        `json:"finality"`
-		Batch     []struct {
-			Status string         `json:"status"`
-			Events []IndexerEvent `json:"events"`
-		} `json:"batch"`
+		Cursor    IndexerCursor  `json:"cursor"`
+		EndCursor IndexerCursor  `json:"end_cursor"`
+		Finality  string         `json:"finality"`
+		Batch     []IndexerBatch `json:"batch"`
 	} `json:"data"`
 }
 
-// TODO: When will there be multiple events in a batch?
-//       Try interacting with multiple contracts in a single block
-
-// TODO: Pointers?
-// TODO: Load on init
-var LatestPendingMessage *IndexerMessage
-var LastProcessedPendingMessage *IndexerMessage
+// Persisted in IndexerMessages / IndexerCursor, see LoadIndexerState
+var LatestPendingMessage *QueuedIndexerMessage
 var PendingMessageLock = &sync.Mutex{}
 var LastAcceptedEndKey int
-var AcceptedMessageQueue []IndexerMessage
+var AcceptedMessageQueue []QueuedIndexerMessage
 var AcceptedMessageLock = &sync.Mutex{}
 var LastFinalizedCursor int
-var FinalizedMessageQueue []IndexerMessage
+var FinalizedMessageQueue []QueuedIndexerMessage
 var FinalizedMessageLock = &sync.Mutex{}
 
 const (
@@ -102,9 +133,11 @@ const (
 	stencilRemovedEvent              = "0x023c933ed3ee3f94b5b82f8e2e570c8354e6f5036c3a079092ceeed15979e7fa"
 	stencilFavoritedEvent            = "0x007cb4ae927fb597834e194e2c950a2d813461c72f372f78d0610ea246f53017"
 	stencilUnfavoritedEvent          = "0x00a5477c7df6522316b652e56317e69e52429ab43a6772fb6f6c2a574f7e196f"
+	memecoinCreatedEvent             = "0x01be539d3a1327d450ab9b7a754f7708ea94f67182f2506217cafff2d694f8e1"
+	memecoinLaunchedEvent            = "0x0257c7875ae0eb2f487e258997d033dde6441d55296281bc727bc1e64b833cfd"
 )
 
-var eventProcessors = map[string](func(IndexerEvent)){
+var eventProcessors = map[string]EventHandler{
 	newDayEvent:                      processNewDayEvent,
 	colorAddedEvent:                  processColorAddedEvent,
 	pixelPlacedEvent:                 processPixelPlacedEvent,
@@ -150,9 +183,11 @@ var eventProcessors = map[string](func(IndexerEvent)){
 	stencilRemovedEvent:              processStencilRemovedEvent,
 	stencilFavoritedEvent:            processStencilFavoritedEvent,
 	stencilUnfavoritedEvent:          processStencilUnfavoritedEvent,
+	memecoinCreatedEvent:             processMemecoinCreatedEvent,
+	memecoinLaunchedEvent:            processMemecoinLaunchedEvent,
 }
 
-var eventReverters = map[string](func(IndexerEvent)){
+var eventReverters = map[string]EventHandler{
 	newDayEvent:                      revertNewDayEvent,
 	colorAddedEvent:                  revertColorAddedEvent,
 	pixelPlacedEvent:                 revertPixelPlacedEvent,
@@ -198,6 +233,8 @@ var eventReverters = map[string](func(IndexerEvent)){
 	stencilRemovedEvent:              revertStencilRemovedEvent,
 	stencilFavoritedEvent:            revertStencilFavoritedEvent,
 	stencilUnfavoritedEvent:          revertStencilUnfavoritedEvent,
+	memecoinCreatedEvent:             revertMemecoinCreatedEvent,
+	memecoinLaunchedEvent:            revertMemecoinLaunchedEvent,
 }
 
 // TODO: Rethink this ( & look at values before multicanvas PR )
@@ -247,6 +284,8 @@ var eventRequiresOrdering = map[string]bool{
 	stencilRemovedEvent:              true,
 	stencilFavoritedEvent:            true,
 	stencilUnfavoritedEvent:          true,
+	memecoinCreatedEvent:             true,
+	memecoinLaunchedEvent:            true,
 }
 
 const (
@@ -261,7 +300,7 @@ func consumeIndexerMsg(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("r", r.Body)
 	fmt.Println("body", string(body))
 	if err != nil {
-		PrintIndexerError("consumeIndexerMsg", "error reading request body", err)
+		logIndexerError("consumeIndexerMsg", "error reading request body", err)
 		// return
 	}
 
@@ -279,7 +318,7 @@ func consumeIndexerMsg(w http.ResponseWriter, r *http.Request) {
 	message, err := routeutils.ReadJsonBody[IndexerMessage](r)
 	fmt.Println("message", message)
 	if err != nil {
-		PrintIndexerError("consumeIndexerMsg", "error reading indexer message", err)
+		logIndexerError("consumeIndexerMsg", "error reading indexer message", err)
 		return
 	}
 
@@ -288,25 +327,47 @@ func consumeIndexerMsg(w http.ResponseWriter, r *http.Request) {
 		// return
 	}
 
+	switch message.Data.Finality {
+	case DATA_STATUS_FINALIZED, DATA_STATUS_ACCEPTED, DATA_STATUS_PENDING:
+	default:
+		logIndexerError("consumeIndexerMsg", "unknown finality", message.Data.Finality)
+		return
+	}
+
+	// Persist before acknowledging so the message survives a restart
+	if err := queueIndexerMessage(message, nil); err != nil {
+		logIndexerError("consumeIndexerMsg", "error persisting indexer message", err)
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to persist indexer message")
+		return
+	}
+}
+
+// queueIndexerMessage persists a message, with the stream cursor when read from the DNA stream,
+// and hands it to the message processor
+func queueIndexerMessage(message *IndexerMessage, streamCursor *IndexerCursor) error {
+	key, err := persistIndexerMessage(message, streamCursor)
+	if err != nil {
+		return err
+	}
+	queued := QueuedIndexerMessage{Key: key, Message: *message, ReceivedAt: time.Now()}
+
 	switch message.Data.Finality {
 	case DATA_STATUS_FINALIZED:
 		// TODO: Track diffs with accepted messages? / check if accepted message processed
 		FinalizedMessageLock.Lock()
-		FinalizedMessageQueue = append(FinalizedMessageQueue, *message)
+		FinalizedMessageQueue = enqueueOrdered(FinalizedMessageQueue, queued)
 		FinalizedMessageLock.Unlock()
-		return
 	case DATA_STATUS_ACCEPTED:
 		AcceptedMessageLock.Lock()
-		// TODO: Ensure ordering w/ EndCursor?
-		AcceptedMessageQueue = append(AcceptedMessageQueue, *message)
+		AcceptedMessageQueue = enqueueOrdered(AcceptedMessageQueue, queued)
 		AcceptedMessageLock.Unlock()
-		return
 	case DATA_STATUS_PENDING:
 		PendingMessageLock.Lock()
-		LatestPendingMessage = message
+		LatestPendingMessage = &queued
 		PendingMessageLock.Unlock()
-		return
 	}
+	notifyMessageProcessor()
+	return nil
 }
 
 func ProcessMessageEvents(message IndexerMessage) {
@@ -319,18 +380,14 @@ func ProcessMessageEvents(message IndexerMessage) {
 			fmt.Println("No events in batch")
 			continue
 		}
+		block := batchBlock(message, batch)
 		for _, event := range batch.Events {
 			if len(event.Event.Keys) == 0 {
 				fmt.Println("[WARN] Event with empty Keys array, skipping event:", event)
 				continue
 			}
-			eventKey := event.Event.Keys[0]
-			eventProcessor, ok := eventProcessors[eventKey]
-			if !ok {
-				PrintIndexerError("consumeIndexerMsg", "error processing event", eventKey)
-				return
-			}
-			eventProcessor(event)
+			event.Block = block
+			handleEvent(DeadLetterProcess, event, message)
 		}
 	}
 }
@@ -364,104 +421,27 @@ func EventComparator(event1 IndexerEvent, event2 IndexerEvent) bool {
 	return true
 }
 
-func processMessageEventsWithReverter(oldMessage IndexerMessage, newMessage IndexerMessage) {
-	var idx int
-	var latestEventIndex int
-	var unorderedEvents []IndexerEvent
-	for idx = 0; idx < len(oldMessage.Data.Batch[0].Events); idx++ {
-		oldEvent := oldMessage.Data.Batch[0].Events[idx]
-		newEvent := newMessage.Data.Batch[0].Events[idx]
-		// Check if events are the same
-		if EventComparator(oldEvent, newEvent) {
-			latestEventIndex = idx
-			continue
-		}
-
-		// Non-matching events, revert remaining old events based on ordering
-		// TODO: Print note here and see how often this happens
-		// Revert events from end of old events to current event
-		latestEventIndex = idx
-		for idx = len(oldMessage.Data.Batch[0].Events) - 1; idx >= latestEventIndex; idx-- {
-			eventKey := oldMessage.Data.Batch[0].Events[idx].Event.Keys[0]
-			if eventRequiresOrdering[eventKey] {
-				// Revert event
-				eventReverter, ok := eventReverters[eventKey]
-				if !ok {
-					PrintIndexerError("consumeIndexerMsg", "error reverting event", eventKey)
-					return
-				}
-				eventReverter(oldMessage.Data.Batch[0].Events[idx])
-			} else {
-				unorderedEvents = append(unorderedEvents, oldMessage.Data.Batch[0].Events[idx])
-			}
-		}
-		break
-	}
-
-	// Process new events
-	for idx = latestEventIndex + 1; idx < len(newMessage.Data.Batch[0].Events); idx++ {
-		eventKey := newMessage.Data.Batch[0].Events[idx].Event.Keys[0]
-
-		// Check if event is in unordered events
-		var wasProcessed bool
-		for idx, unorderedEvent := range unorderedEvents {
-			if EventComparator(unorderedEvent, newMessage.Data.Batch[0].Events[idx]) {
-				// Remove event from unordered events
-				unorderedEvents = append(unorderedEvents[:idx], unorderedEvents[idx+1:]...)
-				wasProcessed = true
-				break
-			}
-		}
-		if wasProcessed {
-			continue
-		}
-
-		eventProcessor, ok := eventProcessors[eventKey]
-		if !ok {
-			PrintIndexerError("consumeIndexerMsg", "error processing event", eventKey)
-			return
-		}
-		eventProcessor(newMessage.Data.Batch[0].Events[idx])
-	}
-
-	// Revert remaining unordered events
-	for _, unorderedEvent := range unorderedEvents {
-		eventKey := unorderedEvent.Event.Keys[0]
-		eventReverter, ok := eventReverters[eventKey]
-		if !ok {
-			PrintIndexerError("consumeIndexerMsg", "error reverting event", eventKey)
-			return
-		}
-		eventReverter(unorderedEvent)
-	}
-}
-
-func ProcessMessage(message IndexerMessage) {
-	// Check if there are pending messages for this start key
-	// TODO: OrderKey or UniqueKey or both?
-	if LastProcessedPendingMessage != nil && LastProcessedPendingMessage.Data.Cursor.OrderKey == message.Data.Cursor.OrderKey {
-		processMessageEventsWithReverter(*LastProcessedPendingMessage, message)
-	} else {
-		ProcessMessageEvents(message)
-	}
-}
-
 func TryProcessFinalizedMessages() bool {
 	FinalizedMessageLock.Lock()
-	var message IndexerMessage
+	var queued QueuedIndexerMessage
 	if len(FinalizedMessageQueue) > 0 {
-		message = FinalizedMessageQueue[0]
+		queued = FinalizedMessageQueue[0]
 		FinalizedMessageQueue = FinalizedMessageQueue[1:]
 		FinalizedMessageLock.Unlock()
 	} else {
 		FinalizedMessageLock.Unlock()
 		return false
 	}
+	message := queued.Message
 
 	if message.Data.Cursor.OrderKey <= LastFinalizedCursor {
 		// Skip message
+		if err := completeIndexerMessage(queued.Key, nil); err != nil {
+			logIndexerError("TryProcessFinalizedMessages", "error removing skipped message", queued.Key, err)
+		}
 		return true
 	}
+	observeStage(stageQueueWait, queued.ReceivedAt)
 
 	// Submit to Avail Turbo DA on Finalized messages
 	/*
@@ -473,58 +453,124 @@ func TryProcessFinalizedMessages() bool {
 		}()
 	*/
 
-	ProcessMessage(message)
+	start := time.Now()
+	consumedPending := ProcessMessage(message)
+	observeStage(stageFinalized, start)
 
 	fmt.Println("Processed finalized message:", message.Data.Cursor.OrderKey)
 	LastFinalizedCursor = message.Data.Cursor.OrderKey
+	checkpointStart := time.Now()
+	if err := completeIndexerMessage(queued.Key, &message.Data.Cursor, consumedPending...); err != nil {
+		logIndexerError("TryProcessFinalizedMessages", "error checkpointing cursor", message.Data.Cursor, err)
+	}
+	observeStage(stageCheckpoint, checkpointStart)
 	return true
 }
 
 func TryProcessAcceptedMessages() bool {
 	AcceptedMessageLock.Lock()
-	var message IndexerMessage
+	var queued QueuedIndexerMessage
 	if len(AcceptedMessageQueue) > 0 {
-		message = AcceptedMessageQueue[0]
+		queued = AcceptedMessageQueue[0]
 		AcceptedMessageQueue = AcceptedMessageQueue[1:]
 		AcceptedMessageLock.Unlock()
 	} else {
 		AcceptedMessageLock.Unlock()
 		return false
 	}
+	message := queued.Message
 
-	go func() {
-		// if err := submitToAvailTurboDA(message); err != nil {
-		// 	fmt.Printf("Failed to submit to Avail Turbo DA: %v\n", err)
-		// 	// Continue processing even if submission fails
-		// }
-	}()
+	if message.Data.Cursor.OrderKey < GetLastCursor().OrderKey {
+		// Older than the checkpoint, already applied
+		if err := completeIndexerMessage(queued.Key, nil); err != nil {
+			logIndexerError("TryProcessAcceptedMessages", "error removing skipped message", queued.Key, err)
+		}
+		return true
+	}
+	observeStage(stageQueueWait, queued.ReceivedAt)
 
-	ProcessMessage(message)
+	start := time.Now()
+	consumedPending := ProcessMessage(message)
+	observeStage(stageAccepted, start)
 
 	fmt.Println("Processed accepted message:", message.Data.Cursor.OrderKey)
 	LastFinalizedCursor = message.Data.Cursor.OrderKey
+	checkpointStart := time.Now()
+	if err := completeIndexerMessage(queued.Key, &message.Data.Cursor, consumedPending...); err != nil {
+		logIndexerError("TryProcessAcceptedMessages", "error checkpointing cursor", message.Data.Cursor, err)
+	}
+	observeStage(stageCheckpoint, checkpointStart)
 	return true
 }
 
 func TryProcessPendingMessage() bool {
 	PendingMessageLock.Lock()
-	defer PendingMessageLock.Unlock()
+	pending := LatestPendingMessage
+	LatestPendingMessage = nil
+	PendingMessageLock.Unlock()
 
-	if LatestPendingMessage == nil {
+	if pending == nil {
 		return false
 	}
-
-	ProcessMessage(*LatestPendingMessage)
-	fmt.Println("Processed pending message:", LatestPendingMessage.Data.Cursor.OrderKey)
-	LastProcessedPendingMessage = LatestPendingMessage
-	LatestPendingMessage = nil
+	observeStage(stageQueueWait, pending.ReceivedAt)
+
+	start := time.Now()
+	consumedPending := ProcessMessage(pending.Message)
+	observeStage(stagePending, start)
+	fmt.Println("Processed pending message:", pending.Message.Data.Cursor.OrderKey)
+	if err := completePendingMessage(pending.Key, consumedPending...); err != nil {
+		logIndexerError("TryProcessPendingMessage", "error marking pending message processed", pending.Key, err)
+	}
+	pushProcessedPending(*pending)
 	return true
 }
 
-func StartMessageProcessor() {
-	// Goroutine to process pending/accepted messages
+// Wakes the processor up, buffered so a signal sent while it is busy is not lost
+var messageSignal = make(chan struct{}, 1)
+
+func notifyMessageProcessor() {
+	select {
+	case messageSignal <- struct{}{}:
+	default:
+	}
+}
+
+// enqueueOrdered keeps a queue sorted by block, messages of the same block stay in arrival order
+func enqueueOrdered(queue []QueuedIndexerMessage, queued QueuedIndexerMessage) []QueuedIndexerMessage {
+	idx := len(queue)
+	for idx > 0 && queue[idx-1].Message.Data.Cursor.OrderKey > queued.Message.Data.Cursor.OrderKey {
+		idx--
+	}
+	queue = append(queue, QueuedIndexerMessage{})
+	copy(queue[idx+1:], queue[idx:])
+	queue[idx] = queued
+	return queue
+}
+
+// StartMessageProcessor processes the queues until ctx is done, the returned channel is closed
+// once the message in progress is finished
+func StartMessageProcessor(ctx context.Context) <-chan struct{} {
+	if err := verifyEventHandlers(); err != nil {
+		panic(err)
+	}
+	if err := LoadIndexerState(); err != nil {
+		panic(fmt.Sprintf("Failed to load indexer state: %v", err))
+	}
+
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		for {
+			if ctx.Err() != nil {
+				fmt.Println("Message processor stopped")
+				return
+			}
+
+			// Revert the data invalidated by the stream before the new chain
+			if TryProcessInvalidation() {
+				continue
+			}
+
 			// Check Finalized messages ( for initial load )
 			if TryProcessFinalizedMessages() {
 				continue
@@ -539,11 +585,54 @@ func StartMessageProcessor() {
 				continue
 			}
 
-			// No messages to process, sleep for 1 second
-			// time.Sleep( * time.Second)
+			// Wait for consumeIndexerMsg to queue something
+			select {
+			case <-ctx.Done():
+			case <-messageSignal:
+			}
 		}
 	}()
+	return done
+}
+
+type IndexerStatus struct {
+	FinalizedQueueLength   int                     `json:"finalizedQueueLength"`
+	AcceptedQueueLength    int                     `json:"acceptedQueueLength"`
+	HasPendingMessage      bool                    `json:"hasPendingMessage"`
+	ProcessedPendingBlocks int                     `json:"processedPendingBlocks"`
+	LastPendingOrderKey    *int                    `json:"lastPendingOrderKey"`
+	Cursor                 IndexerCursor           `json:"cursor"`
+	StageLatency           map[string]StageLatency `json:"stageLatency"`
 }
 
-// TODO: User might miss some messages between loading canvas and connecting to websocket?
-// TODO: Check thread safety of these things
+func getIndexerStatus(w http.ResponseWriter, r *http.Request) {
+	status := IndexerStatus{Cursor: GetLastCursor(), StageLatency: GetStageLatencies()}
+
+	FinalizedMessageLock.Lock()
+	status.FinalizedQueueLength = len(FinalizedMessageQueue)
+	FinalizedMessageLock.Unlock()
+
+	AcceptedMessageLock.Lock()
+	status.AcceptedQueueLength = len(AcceptedMessageQueue)
+	AcceptedMessageLock.Unlock()
+
+	PendingMessageLock.Lock()
+	status.HasPendingMessage = LatestPendingMessage != nil
+	PendingMessageLock.Unlock()
+
+	processedPendingLock.Lock()
+	status.ProcessedPendingBlocks = len(ProcessedPendingMessages)
+	if len(ProcessedPendingMessages) > 0 {
+		orderKey := ProcessedPendingMessages[len(ProcessedPendingMessages)-1].Message.Data.Cursor.OrderKey
+		status.LastPendingOrderKey = &orderKey
+	}
+	processedPendingLock.Unlock()
+
+	statusJson, err := json.Marshal(status)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal indexer status")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(statusJson))
+}
@@ -0,0 +1,43 @@
+package indexer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestBatchBlock(t *testing.T) {
+	payload := `{"data": {"cursor": {"orderKey": 99}, "end_cursor": {"orderKey": 102}, "finality": "DATA_STATUS_ACCEPTED", "batch": [
+		{"status": "BLOCK_STATUS_ACCEPTED_ON_L2", "header": {"blockNumber": "100", "timestamp": "2024-06-10T06:13:20Z"}, "events": []},
+		{"status": "BLOCK_STATUS_ACCEPTED_ON_L2", "header": {"blockNumber": 101, "timestamp": "2024-06-10T06:13:26Z"}, "events": []},
+		{"status": "BLOCK_STATUS_ACCEPTED_ON_L2", "events": []}
+	]}}`
+	var message IndexerMessage
+	if err := json.Unmarshal([]byte(payload), &message); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	tests := []struct {
+		number    int64
+		timestamp int64
+	}{
+		{number: 100, timestamp: 1718000000},
+		{number: 101, timestamp: 1718000006},
+		// No header, the message end cursor
+		{number: 102, timestamp: -1},
+	}
+	for idx, test := range tests {
+		block := batchBlock(message, message.Data.Batch[idx])
+		if block.Number != test.number {
+			t.Errorf("batch %d: Number = %d, want %d", idx, block.Number, test.number)
+		}
+		if test.timestamp < 0 {
+			if block.Timestamp != nil {
+				t.Errorf("batch %d: Timestamp = %d, want nil", idx, *block.Timestamp)
+			}
+			continue
+		}
+		if block.Timestamp == nil || *block.Timestamp != test.timestamp {
+			t.Errorf("batch %d: Timestamp = %v, want %d", idx, block.Timestamp, test.timestamp)
+		}
+	}
+}
@@ -2,240 +2,137 @@ package indexer
 
 import (
 	"context"
-	"strconv"
+	"strings"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 )
 
-func processStencilAddedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-	hashHex := event.Event.Data[0][2:] // Remove the 0x prefix
-	widthHex := event.Event.Data[1]
-	heightHex := event.Event.Data[2]
-	positionHex := event.Event.Data[3]
-	ipfsHashHex := event.Event.Data[4]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilAddedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
-	}
-
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilAddedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
-	}
-
-	stencilWidth, err := strconv.ParseInt(widthHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilAddedEvent", "Failed to parse widthHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
-	}
-
-	stencilHeight, err := strconv.ParseInt(heightHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilAddedEvent", "Failed to parse heightHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
-	}
-
-	stencilPosition, err := strconv.ParseInt(positionHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilAddedEvent", "Failed to parse positionHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
-	}
-
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Stencils (stencil_id, world_id, hash, width, height, position, ipfs_hash) VALUES ($1, $2, $3, $4, $5, $6, $7)", stencilId, canvasId, hashHex, stencilWidth, stencilHeight, stencilPosition, ipfsHashHex)
-	if err != nil {
-		PrintIndexerError("processStencilAddedEvent", "Failed to insert into Stencils", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
-	}
+type stencilMetadata struct {
+	Hash     string `cairo:"data"`
+	Width    int64  `cairo:"data"`
+	Height   int64  `cairo:"data"`
+	Position int64  `cairo:"data"`
+	IpfsHash string `cairo:"data,bytearray"`
 }
 
-func revertStencilAddedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilAddedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, err)
-		return
-	}
-
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilAddedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, err)
-		return
-	}
-
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Stencils WHERE stencil_id = $1 AND world_id = $2", stencilId, canvasId)
-	if err != nil {
-		PrintIndexerError("revertStencilAddedEvent", "Failed to delete from Stencils", canvasIdHex, stencilIdHex, err)
-		return
-	}
+// Stencil added and removed events
+type stencilChanged struct {
+	CanvasId  int64           `cairo:"key"`
+	StencilId int64           `cairo:"key"`
+	Stencil   stencilMetadata `cairo:"data"`
 }
 
-func processStencilRemovedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilRemovedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, err)
-		return
-	}
+// Stencil favorited and unfavorited events
+type stencilFavorite struct {
+	CanvasId  int64  `cairo:"key"`
+	StencilId int64  `cairo:"key"`
+	User      string `cairo:"key,address"`
+}
 
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilRemovedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, err)
-		return
+func processStencilAddedEvent(event IndexerEvent) error {
+	var added stencilChanged
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("processStencilAddedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
+	stencil := added.Stencil
+	hash := strings.TrimPrefix(stencil.Hash, "0x")
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Stencils WHERE stencil_id = $1 AND world_id = $2", stencilId, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Stencils (stencil_id, world_id, hash, width, height, position, ipfs_hash) VALUES ($1, $2, $3, $4, $5, $6, $7)", added.StencilId, added.CanvasId, hash, stencil.Width, stencil.Height, stencil.Position, stencil.IpfsHash)
 	if err != nil {
-		PrintIndexerError("processStencilRemovedEvent", "Failed to delete from Stencils", canvasIdHex, stencilIdHex, err)
-		return
+		return PrintIndexerError("processStencilAddedEvent", "Failed to insert into Stencils", added.CanvasId, added.StencilId, hash, stencil.Width, stencil.Height, stencil.Position, err)
 	}
+	return nil
 }
 
-func revertStencilRemovedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-	hashHex := event.Event.Data[0]
-	widthHex := event.Event.Data[1]
-	heightHex := event.Event.Data[2]
-	positionHex := event.Event.Data[3]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilRemovedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
+func revertStencilAddedEvent(event IndexerEvent) error {
+	var added stencilChanged
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("revertStencilAddedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Stencils WHERE stencil_id = $1 AND world_id = $2", added.StencilId, added.CanvasId)
 	if err != nil {
-		PrintIndexerError("revertStencilRemovedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
+		return PrintIndexerError("revertStencilAddedEvent", "Failed to delete from Stencils", added.CanvasId, added.StencilId, err)
 	}
+	return nil
+}
 
-	stencilWidth, err := strconv.ParseInt(widthHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilRemovedEvent", "Failed to parse widthHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
+func processStencilRemovedEvent(event IndexerEvent) error {
+	var removed stencilChanged
+	if err := decodeEvent(event, &removed); err != nil {
+		return PrintIndexerError("processStencilRemovedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	stencilHeight, err := strconv.ParseInt(heightHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Stencils WHERE stencil_id = $1 AND world_id = $2", removed.StencilId, removed.CanvasId)
 	if err != nil {
-		PrintIndexerError("revertStencilRemovedEvent", "Failed to parse heightHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
+		return PrintIndexerError("processStencilRemovedEvent", "Failed to delete from Stencils", removed.CanvasId, removed.StencilId, err)
 	}
+	return nil
+}
 
-	stencilPosition, err := strconv.ParseInt(positionHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilRemovedEvent", "Failed to parse positionHex", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
+func revertStencilRemovedEvent(event IndexerEvent) error {
+	var removed stencilChanged
+	if err := decodeEvent(event, &removed); err != nil {
+		return PrintIndexerError("revertStencilRemovedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
+	stencil := removed.Stencil
+	hash := strings.TrimPrefix(stencil.Hash, "0x")
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Stencils (stencil_id, world_id, hash, width, height, position) VALUES ($1, $2, $3, $4, $5, $6)", stencilId, canvasId, hashHex, stencilWidth, stencilHeight, stencilPosition)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Stencils (stencil_id, world_id, hash, width, height, position, ipfs_hash) VALUES ($1, $2, $3, $4, $5, $6, $7)", removed.StencilId, removed.CanvasId, hash, stencil.Width, stencil.Height, stencil.Position, stencil.IpfsHash)
 	if err != nil {
-		PrintIndexerError("revertStencilRemovedEvent", "Failed to insert into Stencils", canvasIdHex, stencilIdHex, hashHex, widthHex, heightHex, positionHex, err)
-		return
+		return PrintIndexerError("revertStencilRemovedEvent", "Failed to insert into Stencils", removed.CanvasId, removed.StencilId, hash, stencil.Width, stencil.Height, stencil.Position, err)
 	}
+	return nil
 }
 
-func processStencilFavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-	userAddressHex := event.Event.Keys[3][2:] // Remove the 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilFavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+func processStencilFavoritedEvent(event IndexerEvent) error {
+	var favorite stencilFavorite
+	if err := decodeEvent(event, &favorite); err != nil {
+		return PrintIndexerError("processStencilFavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO StencilFavorites (stencil_id, world_id, user_address) VALUES ($1, $2, $3)", favorite.StencilId, favorite.CanvasId, favorite.User)
 	if err != nil {
-		PrintIndexerError("processStencilFavoritedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
-	}
-
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO StencilFavorites (stencil_id, world_id, user_address) VALUES ($1, $2, $3)", stencilId, canvasId, userAddressHex)
-	if err != nil {
-		PrintIndexerError("processStencilFavoritedEvent", "Failed to insert into StencilFavorites", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+		return PrintIndexerError("processStencilFavoritedEvent", "Failed to insert into StencilFavorites", favorite.CanvasId, favorite.StencilId, favorite.User, err)
 	}
+	return nil
 }
 
-func revertStencilFavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-	userAddressHex := event.Event.Keys[3][2:] // Remove the 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilFavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
-	}
-
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilFavoritedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+func revertStencilFavoritedEvent(event IndexerEvent) error {
+	var favorite stencilFavorite
+	if err := decodeEvent(event, &favorite); err != nil {
+		return PrintIndexerError("revertStencilFavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM StencilFavorites WHERE stencil_id = $1 AND world_id = $2 AND user_address = $3", stencilId, canvasId, userAddressHex)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM StencilFavorites WHERE stencil_id = $1 AND world_id = $2 AND user_address = $3", favorite.StencilId, favorite.CanvasId, favorite.User)
 	if err != nil {
-		PrintIndexerError("revertStencilFavoritedEvent", "Failed to delete from StencilFavorites", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+		return PrintIndexerError("revertStencilFavoritedEvent", "Failed to delete from StencilFavorites", favorite.CanvasId, favorite.StencilId, favorite.User, err)
 	}
+	return nil
 }
 
-func processStencilUnfavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-	userAddressHex := event.Event.Keys[3][2:] // Remove the 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilUnfavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
-	}
-
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processStencilUnfavoritedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+func processStencilUnfavoritedEvent(event IndexerEvent) error {
+	var unfavorite stencilFavorite
+	if err := decodeEvent(event, &unfavorite); err != nil {
+		return PrintIndexerError("processStencilUnfavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM StencilFavorites WHERE stencil_id = $1 AND world_id = $2 AND user_address = $3", stencilId, canvasId, userAddressHex)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM StencilFavorites WHERE stencil_id = $1 AND world_id = $2 AND user_address = $3", unfavorite.StencilId, unfavorite.CanvasId, unfavorite.User)
 	if err != nil {
-		PrintIndexerError("processStencilUnfavoritedEvent", "Failed to delete from StencilFavorites", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+		return PrintIndexerError("processStencilUnfavoritedEvent", "Failed to delete from StencilFavorites", unfavorite.CanvasId, unfavorite.StencilId, unfavorite.User, err)
 	}
+	return nil
 }
 
-func revertStencilUnfavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	stencilIdHex := event.Event.Keys[2]
-	userAddressHex := event.Event.Keys[3][2:] // Remove the 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilUnfavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
-	}
-
-	stencilId, err := strconv.ParseInt(stencilIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertStencilUnfavoritedEvent", "Failed to parse stencilIdHex", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+func revertStencilUnfavoritedEvent(event IndexerEvent) error {
+	var unfavorite stencilFavorite
+	if err := decodeEvent(event, &unfavorite); err != nil {
+		return PrintIndexerError("revertStencilUnfavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO StencilFavorites (stencil_id, world_id, user_address) VALUES ($1, $2, $3)", stencilId, canvasId, userAddressHex)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO StencilFavorites (stencil_id, world_id, user_address) VALUES ($1, $2, $3)", unfavorite.StencilId, unfavorite.CanvasId, unfavorite.User)
 	if err != nil {
-		PrintIndexerError("revertStencilUnfavoritedEvent", "Failed to insert into StencilFavorites", canvasIdHex, stencilIdHex, userAddressHex, err)
-		return
+		return PrintIndexerError("revertStencilUnfavoritedEvent", "Failed to insert into StencilFavorites", unfavorite.CanvasId, unfavorite.StencilId, unfavorite.User, err)
 	}
+	return nil
 }
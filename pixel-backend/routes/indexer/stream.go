@@ -0,0 +1,247 @@
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/apibara"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Stream source of the consumer: reads the Apibara DNA stream directly instead of receiving the
+// webhook. Each data message becomes an IndexerMessage ( one batch per block ) queued like the
+// webhook ones, the stream end cursor being stored with it so a restart resumes after it.
+
+const (
+	streamMinBackoff = time.Second
+	streamMaxBackoff = 30 * time.Second
+)
+
+var streamFinalities = map[string]apibara.DataFinality{
+	"pending":   apibara.FinalityPending,
+	"accepted":  apibara.FinalityAccepted,
+	"finalized": apibara.FinalityFinalized,
+}
+
+var dataStatuses = map[apibara.DataFinality]string{
+	apibara.FinalityPending:   DATA_STATUS_PENDING,
+	apibara.FinalityAccepted:  DATA_STATUS_ACCEPTED,
+	apibara.FinalityFinalized: DATA_STATUS_FINALIZED,
+}
+
+var blockStatuses = map[int32]string{
+	1: "BLOCK_STATUS_PENDING",
+	2: "BLOCK_STATUS_ACCEPTED_ON_L2",
+	3: "BLOCK_STATUS_ACCEPTED_ON_L1",
+	4: "BLOCK_STATUS_REJECTED",
+}
+
+// StartStreamConsumer reads the stream until the context is cancelled, reconnecting on errors.
+// The returned channel is closed once it stopped.
+func StartStreamConsumer(ctx context.Context, streamConfig config.StreamConfig) (<-chan struct{}, error) {
+	request, err := newStreamRequest(streamConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := apibara.NewClient(streamConfig.Url, os.Getenv("APIBARA_AUTH_TOKEN"))
+	if err != nil {
+		return nil, err
+	}
+
+	cursors, err := core.PostgresQuery[IndexerCursor]("SELECT order_key, unique_key FROM IndexerStreamCursor WHERE id = 1")
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	if len(cursors) > 0 {
+		request.StartingCursor, err = streamCursor(cursors[0])
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+		fmt.Println("Resuming indexer stream from block", cursors[0].OrderKey)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer client.Close()
+
+		backoff := streamMinBackoff
+		for {
+			received, err := consumeStream(ctx, client, request)
+			if ctx.Err() != nil {
+				return
+			}
+			if received {
+				backoff = streamMinBackoff
+			}
+			logIndexerError("StartStreamConsumer", "Stream interrupted, reconnecting in", backoff, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > streamMaxBackoff {
+				backoff = streamMaxBackoff
+			}
+		}
+	}()
+	return done, nil
+}
+
+// consumeStream runs one connection, moving request.StartingCursor along the queued messages
+func consumeStream(ctx context.Context, client *apibara.Client, request *apibara.StreamDataRequest) (bool, error) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.StreamData(streamCtx, request)
+	if err != nil {
+		return false, err
+	}
+
+	received := false
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		switch {
+		case response.Invalidate != nil:
+			// Data after the cursor was reorged out, the stream sends the new chain next
+			cursor := indexerCursor(response.Invalidate.Cursor)
+			logIndexerError("consumeStream", "Stream invalidated data after block", cursor.OrderKey)
+			if err := invalidateIndexerMessages(cursor); err != nil {
+				return received, fmt.Errorf("failed to invalidate stream messages: %w", err)
+			}
+			request.StartingCursor = response.Invalidate.Cursor
+		case response.Data != nil:
+			message, err := streamMessage(response.Data)
+			if err != nil {
+				return received, err
+			}
+			endCursor := indexerCursor(response.Data.EndCursor)
+			if err := queueIndexerMessage(message, &endCursor); err != nil {
+				return received, fmt.Errorf("failed to queue stream message: %w", err)
+			}
+			request.StartingCursor = response.Data.EndCursor
+		}
+	}
+}
+
+func newStreamRequest(streamConfig config.StreamConfig) (*apibara.StreamDataRequest, error) {
+	finality, ok := streamFinalities[streamConfig.Finality]
+	if !ok {
+		return nil, fmt.Errorf("unknown stream finality %s", streamConfig.Finality)
+	}
+	if len(streamConfig.Contracts) == 0 {
+		return nil, fmt.Errorf("no contracts to stream")
+	}
+
+	// Pixels are stamped with the block of their event
+	filter := &apibara.Filter{Header: &apibara.HeaderFilter{Weak: true}}
+	for _, contract := range streamConfig.Contracts {
+		address, err := apibara.FieldElementFromHex(contract.Address)
+		if err != nil {
+			return nil, err
+		}
+		selectors := contract.Selectors
+		if len(selectors) == 0 {
+			for selector := range eventProcessors {
+				selectors = append(selectors, selector)
+			}
+		}
+		for _, selector := range selectors {
+			key, err := apibara.FieldElementFromHex(selector)
+			if err != nil {
+				return nil, err
+			}
+			filter.Events = append(filter.Events, apibara.EventFilter{FromAddress: &address, Keys: []apibara.FieldElement{key}})
+		}
+	}
+	encodedFilter, err := filter.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	request := &apibara.StreamDataRequest{
+		BatchSize: streamConfig.BatchSize,
+		Finality:  finality,
+		Filter:    encodedFilter,
+	}
+	if streamConfig.StartingBlock > 0 {
+		// The stream starts after the cursor
+		request.StartingCursor = &apibara.Cursor{OrderKey: streamConfig.StartingBlock - 1}
+	}
+	return request, nil
+}
+
+// streamMessage converts stream data to the webhook message format
+func streamMessage(data *apibara.Data) (*IndexerMessage, error) {
+	message := &IndexerMessage{}
+	finality, ok := dataStatuses[data.Finality]
+	if !ok {
+		return nil, fmt.Errorf("unknown stream data finality %d", data.Finality)
+	}
+	message.Data.Finality = finality
+	message.Data.Cursor = indexerCursor(data.Cursor)
+	message.Data.EndCursor = indexerCursor(data.EndCursor)
+
+	for _, encodedBlock := range data.Data {
+		block := apibara.Block{}
+		if err := block.Unmarshal(encodedBlock); err != nil {
+			return nil, fmt.Errorf("failed to decode stream block: %w", err)
+		}
+
+		batch := IndexerBatch{Status: blockStatuses[block.Status], Events: []IndexerEvent{}}
+		if block.Header != nil {
+			batch.Header = &IndexerBlockHeader{BlockNumber: json.Number(strconv.FormatUint(block.Header.BlockNumber, 10)), Timestamp: block.Header.Timestamp}
+		}
+		for _, streamEvent := range block.Events {
+			event := IndexerEvent{}
+			event.Event.FromAddress = streamEvent.FromAddress.Hex()
+			event.Event.Keys = make([]string, len(streamEvent.Keys))
+			for i, key := range streamEvent.Keys {
+				event.Event.Keys[i] = key.Hex()
+			}
+			event.Event.Data = make([]string, len(streamEvent.Data))
+			for i, value := range streamEvent.Data {
+				event.Event.Data[i] = value.Hex()
+			}
+			batch.Events = append(batch.Events, event)
+		}
+		message.Data.Batch = append(message.Data.Batch, batch)
+	}
+	return message, nil
+}
+
+func indexerCursor(cursor *apibara.Cursor) IndexerCursor {
+	if cursor == nil {
+		return IndexerCursor{}
+	}
+	uniqueKey := ""
+	if len(cursor.UniqueKey) > 0 {
+		uniqueKey = "0x" + hex.EncodeToString(cursor.UniqueKey)
+	}
+	return IndexerCursor{OrderKey: int(cursor.OrderKey), UniqueKey: uniqueKey}
+}
+
+func streamCursor(cursor IndexerCursor) (*apibara.Cursor, error) {
+	uniqueKey, err := hex.DecodeString(strings.TrimPrefix(cursor.UniqueKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid stream cursor %s: %w", cursor.UniqueKey, err)
+	}
+	return &apibara.Cursor{OrderKey: uint64(cursor.OrderKey), UniqueKey: uniqueKey}, nil
+}
@@ -2,82 +2,69 @@ package indexer
 
 import (
 	"context"
-	"encoding/hex"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
 )
 
-func processUsernameClaimedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:]     // Remove 0x prefix
-	usernameHex := event.Event.Data[0][2:] // Remove 0x prefix
+type usernameClaimed struct {
+	Address  string `cairo:"key,address"`
+	Username string `cairo:"data,shortstring"`
+}
 
-	// Parse username hex as bytes encoded in utf-8
-	decodedUsername, err := hex.DecodeString(usernameHex)
-	if err != nil {
-		PrintIndexerError("processUsernameClaimedEvent", "Error decoding username hex", address, usernameHex)
-		return
-	}
-	// Trim off 0s at the start
-	trimmedUsername := []byte{}
-	trimming := true
-	for _, b := range decodedUsername {
-		if b == 0 && trimming {
-			continue
-		}
-		trimming = false
-		trimmedUsername = append(trimmedUsername, b)
+type usernameChanged struct {
+	Address     string `cairo:"key,address"`
+	OldUsername string `cairo:"data,shortstring"`
+	Username    string `cairo:"data,shortstring"`
+}
+
+func processUsernameClaimedEvent(event IndexerEvent) error {
+	var claimed usernameClaimed
+	if err := decodeEvent(event, &claimed); err != nil {
+		return PrintIndexerError("processUsernameClaimedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
-	username := string(trimmedUsername)
 
 	// Set username in postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Users (address, name) VALUES ($1, $2)", address, username)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Users (address, name) VALUES ($1, $2)", claimed.Address, claimed.Username)
 	if err != nil {
-		PrintIndexerError("processUsernameClaimedEvent", "Error inserting username into postgres", address, username)
-		return
+		return PrintIndexerError("processUsernameClaimedEvent", "Error inserting username into postgres", claimed.Address, claimed.Username)
 	}
+
+	quests.InvalidateUserStats(claimed.Address)
+	return nil
 }
 
-func revertUsernameClaimedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:] // Remove 0x prefix
+func revertUsernameClaimedEvent(event IndexerEvent) error {
+	var claimed usernameClaimed
+	if err := decodeEvent(event, &claimed); err != nil {
+		return PrintIndexerError("revertUsernameClaimedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
+	}
 
 	// Remove username from postgres
-	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Users WHERE address = $1", address)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Users WHERE address = $1", claimed.Address)
 	if err != nil {
-		PrintIndexerError("revertUsernameClaimedEvent", "Error deleting username from postgres", address, "")
-		return
+		return PrintIndexerError("revertUsernameClaimedEvent", "Error deleting username from postgres", claimed.Address, "")
 	}
-}
 
-func processUsernameChangedEvent(event IndexerEvent) {
-	address := event.Event.Keys[1][2:]     // Remove 0x prefix
-	usernameHex := event.Event.Data[1][2:] // Remove 0x prefix
+	quests.InvalidateUserStats(claimed.Address)
+	return nil
+}
 
-	// Parse username hex as bytes encoded in utf-8
-	decodedUsername, err := hex.DecodeString(usernameHex)
-	if err != nil {
-		PrintIndexerError("processUsernameChangedEvent", "Error decoding username hex", address, usernameHex)
-		return
-	}
-	// Trim off 0s at the start
-	trimmedUsername := []byte{}
-	trimming := true
-	for _, b := range decodedUsername {
-		if b == 0 && trimming {
-			continue
-		}
-		trimming = false
-		trimmedUsername = append(trimmedUsername, b)
+func processUsernameChangedEvent(event IndexerEvent) error {
+	var changed usernameChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("processUsernameChangedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
-	username := string(trimmedUsername)
 
 	// Set username in postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Users SET name = $1 WHERE address = $2", username, address)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Users SET name = $1 WHERE address = $2", changed.Username, changed.Address)
 	if err != nil {
-		PrintIndexerError("processUsernameChangedEvent", "Error updating username in postgres", address, username)
-		return
+		return PrintIndexerError("processUsernameChangedEvent", "Error updating username in postgres", changed.Address, changed.Username)
 	}
+	return nil
 }
 
-func revertUsernameChangedEvent(event IndexerEvent) {
+func revertUsernameChangedEvent(event IndexerEvent) error {
 	// TODO: Revert username in postgres
+	return nil
 }
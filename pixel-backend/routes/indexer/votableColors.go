@@ -2,58 +2,42 @@ package indexer
 
 import (
 	"context"
-	"strconv"
+	"fmt"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 )
 
-func processVotableColorAddedEvent(event IndexerEvent) {
-	dayIdxHex := event.Event.Keys[1]
-	colorKeyHex := event.Event.Keys[2]
-	colorHex := event.Event.Data[0]
-
-	dayIdx, err := strconv.ParseInt(dayIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processVotableColorAddedEvent", "Error converting day index hex to int", dayIdxHex, colorHex)
-		return
-	}
+type votableColorAdded struct {
+	DayIndex int64  `cairo:"key"`
+	ColorKey int64  `cairo:"key"`
+	Color    uint32 `cairo:"data"`
+}
 
-	colorKey, err := strconv.ParseInt(colorKeyHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processVotableColorAddedEvent", "Error converting color key hex to int", dayIdxHex, colorHex)
-		return
+func processVotableColorAddedEvent(event IndexerEvent) error {
+	var added votableColorAdded
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("processVotableColorAddedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
-
-	color := colorHex[len(colorHex)-6:]
+	color := fmt.Sprintf("%06x", added.Color)
 
 	// Set votable color in postgres ( or update if already exists )
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO VotableColors (day_index, color_key, hex) VALUES ($1, $2, $3)", dayIdx, colorKey, color)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO VotableColors (day_index, color_key, hex) VALUES ($1, $2, $3)", added.DayIndex, added.ColorKey, color)
 	if err != nil {
-		PrintIndexerError("processVotableColorAddedEvent", "Error inserting color vote into postgres", dayIdxHex, colorHex)
-		return
+		return PrintIndexerError("processVotableColorAddedEvent", "Error inserting color vote into postgres", added.DayIndex, added.ColorKey, color, err)
 	}
+	return nil
 }
 
-func revertVotableColorAddedEvent(event IndexerEvent) {
-	dayIdxHex := event.Event.Keys[1]
-	colorKeyHex := event.Event.Keys[2]
-
-	dayIdx, err := strconv.ParseInt(dayIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertVotableColorAddedEvent", "Error converting day index hex to int", dayIdxHex, colorKeyHex)
-		return
-	}
-
-	colorKey, err := strconv.ParseInt(colorKeyHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertVotableColorAddedEvent", "Error converting color key hex to int", dayIdxHex, colorKeyHex)
-		return
+func revertVotableColorAddedEvent(event IndexerEvent) error {
+	var added votableColorAdded
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("revertVotableColorAddedEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Remove vote from postgres
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM VotableColors WHERE day_index = $1 AND color_key = $2", dayIdx, colorKey)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM VotableColors WHERE day_index = $1 AND color_key = $2", added.DayIndex, added.ColorKey)
 	if err != nil {
-		PrintIndexerError("revertVotableColorAddedEvent", "Error deleting votable color from postgres", dayIdxHex, colorKeyHex)
-		return
+		return PrintIndexerError("revertVotableColorAddedEvent", "Error deleting votable color from postgres", added.DayIndex, added.ColorKey, err)
 	}
+	return nil
 }
@@ -2,51 +2,46 @@ package indexer
 
 import (
 	"context"
-	"strconv"
 
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
 )
 
-func processVoteColorEvent(event IndexerEvent) {
-	voter := event.Event.Keys[1][2:] // Remove 0x prefix
-	dayIdxHex := event.Event.Keys[2]
-	colorHex := event.Event.Keys[3]
-
-	dayIdx, err := strconv.ParseInt(dayIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processVoteColorEvent", "Error converting day index hex to int", voter, dayIdxHex, colorHex)
-		return
-	}
+type colorVote struct {
+	Voter    string `cairo:"key,address"`
+	DayIndex int64  `cairo:"key"`
+	ColorKey int64  `cairo:"key"`
+}
 
-	color, err := strconv.ParseInt(colorHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processVoteColorEvent", "Error converting color hex to int", voter, dayIdxHex, colorHex)
-		return
+func processVoteColorEvent(event IndexerEvent) error {
+	var vote colorVote
+	if err := decodeEvent(event, &vote); err != nil {
+		return PrintIndexerError("processVoteColorEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Set vote in postgres ( or update if already exists )
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ColorVotes (user_address, day_index, color_key) VALUES ($1, $2, $3) ON CONFLICT (user_address, day_index) DO UPDATE SET color_key = $3", voter, dayIdx, color)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO ColorVotes (user_address, day_index, color_key) VALUES ($1, $2, $3) ON CONFLICT (user_address, day_index) DO UPDATE SET color_key = $3", vote.Voter, vote.DayIndex, vote.ColorKey)
 	if err != nil {
-		PrintIndexerError("processVoteColorEvent", "Error inserting color vote into postgres", voter, dayIdxHex, colorHex)
-		return
+		return PrintIndexerError("processVoteColorEvent", "Error inserting color vote into postgres", vote.Voter, vote.DayIndex, vote.ColorKey, err)
 	}
-}
 
-func revertVoteColorEvent(event IndexerEvent) {
-	voter := event.Event.Keys[1][2:] // Remove 0x prefix
-	dayIdxHex := event.Event.Keys[2]
+	quests.RecordColorVote(vote.Voter, int(vote.DayIndex))
+	return nil
+}
 
-	dayIdx, err := strconv.ParseInt(dayIdxHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertVoteColorEvent", "Error converting day index hex to int", voter, dayIdxHex)
-		return
+func revertVoteColorEvent(event IndexerEvent) error {
+	var vote colorVote
+	if err := decodeEvent(event, &vote); err != nil {
+		return PrintIndexerError("revertVoteColorEvent", "Error decoding event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Remove vote from postgres
 	// TODO: Revert to old vote if it existed before the vote being reverted
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM ColorVotes WHERE user_address = $1 AND day_index = $2", voter, dayIdx)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM ColorVotes WHERE user_address = $1 AND day_index = $2", vote.Voter, vote.DayIndex)
 	if err != nil {
-		PrintIndexerError("revertVoteColorEvent", "Error deleting color vote from postgres", voter, dayIdxHex)
-		return
+		return PrintIndexerError("revertVoteColorEvent", "Error deleting color vote from postgres", vote.Voter, vote.DayIndex, err)
 	}
+
+	quests.InvalidateUserStats(vote.Voter)
+	return nil
 }
@@ -2,7 +2,6 @@ package indexer
 
 import (
 	"context"
-	"encoding/hex"
 	"image"
 	"image/color"
 	"image/png"
@@ -14,126 +13,75 @@ import (
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
 
-func processCanvasCreatedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	host := event.Event.Data[0][2:]          // Remove 0x prefix
-	nameHex := event.Event.Data[1][2:]       // Remove 0x prefix
-	uniqueNameHex := event.Event.Data[2][2:] // Remove 0x prefix
-	widthHex := event.Event.Data[3]
-	heightHex := event.Event.Data[4]
-	pixelsPerTimeHex := event.Event.Data[5]
-	timeBetweenPixelsHex := event.Event.Data[6]
-	colorPaletteLenHex := event.Event.Data[7]
-
-	colorPaletteLen, err := strconv.ParseInt(colorPaletteLenHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse colorPaletteLenHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
-	// Skip colors since they are processed in another event
-
-	startTimeHex := event.Event.Data[8+colorPaletteLen]
-	endTimeHex := event.Event.Data[9+colorPaletteLen]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse canvasIdHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
-
-	decodedName, err := hex.DecodeString(nameHex)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to decode nameHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
-	trimmedName := []byte{}
-	trimming := true
-	for _, b := range decodedName {
-		if b == 0 && trimming {
-			continue
-		}
-		trimming = false
-		trimmedName = append(trimmedName, b)
-	}
-	name := string(trimmedName)
-
-	decodedUniqueName, err := hex.DecodeString(uniqueNameHex)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to decode uniqueNameHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
-	trimmedUniqueName := []byte{}
-	trimming = true
-	for _, b := range decodedUniqueName {
-		if b == 0 && trimming {
-			continue
-		}
-		trimming = false
-		trimmedUniqueName = append(trimmedUniqueName, b)
-	}
-	uniqueName := string(trimmedUniqueName)
-
-	width, err := strconv.ParseInt(widthHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse widthHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
+type canvasCreated struct {
+	CanvasId          int64    `cairo:"key"`
+	Host              string   `cairo:"data,address"`
+	Name              string   `cairo:"data,shortstring"`
+	UniqueName        string   `cairo:"data,shortstring"`
+	Width             int64    `cairo:"data"`
+	Height            int64    `cairo:"data"`
+	PixelsPerTime     int64    `cairo:"data"`
+	TimeBetweenPixels int64    `cairo:"data"`
+	ColorPalette      []uint32 `cairo:"data"` // Processed in canvasColorAddedEvent
+	StartTime         int64    `cairo:"data"`
+	EndTime           int64    `cairo:"data"`
+}
 
-	height, err := strconv.ParseInt(heightHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse heightHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
+type canvasHostChanged struct {
+	CanvasId int64  `cairo:"key"`
+	OldHost  string `cairo:"data,address"`
+	NewHost  string `cairo:"data,address"`
+}
 
-	pixelsPerTime, err := strconv.ParseInt(pixelsPerTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse pixelsPerTimeHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
+// Canvas pixels per time, time between pixels, start and end time changes
+type canvasSettingChanged struct {
+	CanvasId int64 `cairo:"key"`
+	Old      int64 `cairo:"data"`
+	New      int64 `cairo:"data"`
+}
 
-	timeBetweenPixels, err := strconv.ParseInt(timeBetweenPixelsHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse timeBetweenPixelsHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
+type canvasColorAdded struct {
+	CanvasId int64  `cairo:"key"`
+	ColorKey int64  `cairo:"key"`
+	Color    uint32 `cairo:"data"`
+}
 
-	startTime, err := strconv.ParseInt(startTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse startTimeHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
+type canvasPixelPlaced struct {
+	CanvasId int64  `cairo:"key"`
+	PlacedBy string `cairo:"key,address"`
+	Position int64  `cairo:"key"`
+	Color    int64  `cairo:"data"`
+}
 
-	endTime, err := strconv.ParseInt(endTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse endTimeHex", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
+type canvasBasicPixelPlaced struct {
+	CanvasId  int64  `cairo:"key"`
+	PlacedBy  string `cairo:"key,address"`
+	Timestamp int64  `cairo:"data"`
+}
 
-	// Insert into Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO Worlds (world_id, host, name, unique_name, width, height, pixels_per_time, time_between_pixels, start_time, end_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TO_TIMESTAMP($9), TO_TIMESTAMP($10))", canvasId, host, name, uniqueName, width, height, pixelsPerTime, timeBetweenPixels, startTime, endTime)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to insert into Worlds", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-		return
-	}
+// Canvas extra pixels placed and host awarded user events
+type canvasUserPixels struct {
+	CanvasId int64  `cairo:"key"`
+	User     string `cairo:"key,address"`
+	Amount   int64  `cairo:"data"`
+}
 
-	canvasRedisKey := "canvas-" + strconv.Itoa(int(canvasId))
-	if core.AFKBackend.Databases.Redis.Exists(context.Background(), canvasRedisKey).Val() == 0 {
-		totalBitSize := uint(width*height) * core.AFKBackend.CanvasConfig.ColorsBitWidth
-		totalByteSize := (totalBitSize / 8)
-		if totalBitSize%8 != 0 {
-			totalByteSize += 1
-		}
+// Canvas favorited and unfavorited events
+type canvasFavorite struct {
+	CanvasId int64  `cairo:"key"`
+	User     string `cairo:"key,address"`
+}
 
-		canvas := make([]byte, totalByteSize)
-		err := core.AFKBackend.Databases.Redis.Set(context.Background(), canvasRedisKey, canvas, 0).Err()
-		if err != nil {
-			PrintIndexerError("processCanvasCreatedEvent", "Failed to set canvas in redis", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
-			return
-		}
-	} else {
-		PrintIndexerError("processCanvasCreatedEvent", "Canvas already exists in redis", canvasIdHex, host, nameHex, uniqueNameHex, widthHex, heightHex, pixelsPerTimeHex, timeBetweenPixelsHex, colorPaletteLenHex, err)
+func processCanvasCreatedEvent(event IndexerEvent) error {
+	var created canvasCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("processCanvasCreatedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
+	canvasId := created.CanvasId
+	width := created.Width
+	height := created.Height
 
+	// The world image is written first, an event failing after it can be replayed
 	// Create base directories if they don't exist
 	dirs := []string{
 		"worlds",
@@ -144,31 +92,18 @@ func processCanvasCreatedEvent(event IndexerEvent) {
 		if _, err := os.Stat(dir); os.IsNotExist(err) {
 			err = os.MkdirAll(dir, os.ModePerm)
 			if err != nil {
-				PrintIndexerError("processCanvasCreatedEvent", "Failed to create directory", dir, err)
-				return
+				return PrintIndexerError("processCanvasCreatedEvent", "Failed to create directory", dir, err)
 			}
 		}
 	}
 
 	generatedWorldImage := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
-	baseColorHex := event.Event.Data[7]
-	baseColor := baseColorHex[len(baseColorHex)-6:] // Remove prefix
-	baseColorR, err := strconv.ParseInt(baseColor[0:2], 16, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse baseColorR", baseColor, err)
-		return
-	}
-	baseColorG, err := strconv.ParseInt(baseColor[2:4], 16, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse baseColorG", baseColor, err)
-		return
+	// The world starts filled with the first color of the palette
+	var baseColor uint32
+	if len(created.ColorPalette) > 0 {
+		baseColor = created.ColorPalette[0]
 	}
-	baseColorB, err := strconv.ParseInt(baseColor[4:6], 16, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to parse baseColorB", baseColor, err)
-		return
-	}
-	color := color.RGBA{R: uint8(baseColorR), G: uint8(baseColorG), B: uint8(baseColorB), A: 255}
+	color := color.RGBA{R: uint8(baseColor >> 16), G: uint8(baseColor >> 8), B: uint8(baseColor), A: 255}
 	for y := 0; y < int(height); y++ {
 		for x := 0; x < int(width); x++ {
 			generatedWorldImage.Set(x, y, color)
@@ -179,15 +114,22 @@ func processCanvasCreatedEvent(event IndexerEvent) {
 	filename := "worlds/images/world-" + strconv.Itoa(int(canvasId)) + ".png"
 	file, err := os.Create(filename)
 	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to create file", filename, err)
-		return
+		return PrintIndexerError("processCanvasCreatedEvent", "Failed to create file", filename, err)
 	}
 	defer file.Close()
 
 	err = png.Encode(file, generatedWorldImage)
 	if err != nil {
-		PrintIndexerError("processCanvasCreatedEvent", "Failed to encode image", filename, err)
-		return
+		return PrintIndexerError("processCanvasCreatedEvent", "Failed to encode image", filename, err)
+	}
+
+	// Insert into Worlds and create the canvas in redis
+	canvasRedisKey := "canvas-" + strconv.Itoa(int(canvasId))
+	app := EventApplication{}
+	app.Exec("INSERT INTO Worlds (world_id, host, name, unique_name, width, height, pixels_per_time, time_between_pixels, start_time, end_time) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TO_TIMESTAMP($9), TO_TIMESTAMP($10))", canvasId, created.Host, created.Name, created.UniqueName, width, height, created.PixelsPerTime, created.TimeBetweenPixels, created.StartTime, created.EndTime)
+	app.CreateCanvas(canvasRedisKey, uint(width), uint(height))
+	if err := app.Apply(); err != nil {
+		return PrintIndexerError("processCanvasCreatedEvent", "Failed to insert into Worlds", canvasId, created.Host, created.Name, created.UniqueName, width, height, err)
 	}
 
 	// After world creation
@@ -196,378 +138,230 @@ func processCanvasCreatedEvent(event IndexerEvent) {
 		"worldId":     strconv.Itoa(int(canvasId)),
 	}
 	routeutils.SendMessageToWSS(message)
+	return nil
 }
 
-func revertCanvasCreatedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasCreatedEvent", "Failed to parse canvasIdHex", canvasIdHex, err)
-		return
+func revertCanvasCreatedEvent(event IndexerEvent) error {
+	var created canvasCreated
+	if err := decodeEvent(event, &created); err != nil {
+		return PrintIndexerError("revertCanvasCreatedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
+	canvasId := created.CanvasId
 
-	// Delete from Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM Worlds WHERE world_id = $1", canvasId)
-	if err != nil {
-		PrintIndexerError("revertCanvasCreatedEvent", "Failed to delete from Worlds", canvasIdHex, err)
-		return
-	}
-
-	canvasRedisKey := "canvas-" + strconv.Itoa(int(canvasId))
-	err = core.AFKBackend.Databases.Redis.Del(context.Background(), canvasRedisKey).Err()
-	if err != nil {
-		PrintIndexerError("revertCanvasCreatedEvent", "Failed to delete canvas from redis", canvasIdHex, err)
-		return
+	// Delete from Worlds and the canvas from redis
+	app := EventApplication{}
+	app.Exec("DELETE FROM Worlds WHERE world_id = $1", canvasId)
+	app.DeleteCanvas("canvas-" + strconv.Itoa(int(canvasId)))
+	if err := app.Apply(); err != nil {
+		return PrintIndexerError("revertCanvasCreatedEvent", "Failed to delete from Worlds", canvasId, err)
 	}
+	return nil
 }
 
-func processCanvasHostChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldHost := event.Event.Data[0][2:] // Remove 0x prefix
-	newHost := event.Event.Data[1][2:] // Remove 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasHostChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldHost, newHost, err)
-		return
+func processCanvasHostChangedEvent(event IndexerEvent) error {
+	var changed canvasHostChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("processCanvasHostChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET host = $1 WHERE world_id = $2", newHost, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET host = $1 WHERE world_id = $2", changed.NewHost, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("processCanvasHostChangedEvent", "Failed to update Worlds", canvasIdHex, oldHost, newHost, err)
-		return
+		return PrintIndexerError("processCanvasHostChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.OldHost, changed.NewHost, err)
 	}
+	return nil
 }
 
-func revertCanvasHostChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldHost := event.Event.Data[0][2:] // Remove 0x prefix
-	newHost := event.Event.Data[1][2:] // Remove 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasHostChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldHost, newHost, err)
-		return
+func revertCanvasHostChangedEvent(event IndexerEvent) error {
+	var changed canvasHostChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("revertCanvasHostChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET host = $1 WHERE world_id = $2", oldHost, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET host = $1 WHERE world_id = $2", changed.OldHost, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("revertCanvasHostChangedEvent", "Failed to update Worlds", canvasIdHex, oldHost, newHost, err)
-		return
+		return PrintIndexerError("revertCanvasHostChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.OldHost, changed.NewHost, err)
 	}
+	return nil
 }
 
-func processCanvasPixelsPerTimeChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldPixelsPerTimeHex := event.Event.Data[0]
-	newPixelsPerTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasPixelsPerTimeChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldPixelsPerTimeHex, newPixelsPerTimeHex, err)
-		return
-	}
-
-	newPixelsPerTime, err := strconv.ParseInt(newPixelsPerTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasPixelsPerTimeChangedEvent", "Failed to parse newPixelsPerTimeHex", canvasIdHex, oldPixelsPerTimeHex, newPixelsPerTimeHex, err)
-		return
+func processCanvasPixelsPerTimeChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("processCanvasPixelsPerTimeChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET pixels_per_time = $1 WHERE world_id = $2", newPixelsPerTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET pixels_per_time = $1 WHERE world_id = $2", changed.New, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("processCanvasPixelsPerTimeChangedEvent", "Failed to update Worlds", canvasIdHex, oldPixelsPerTimeHex, newPixelsPerTimeHex, err)
-		return
+		return PrintIndexerError("processCanvasPixelsPerTimeChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func revertCanvasPixelsPerTimeChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldPixelsPerTimeHex := event.Event.Data[0]
-	newPixelsPerTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasPixelsPerTimeChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldPixelsPerTimeHex, newPixelsPerTimeHex, err)
-		return
-	}
-
-	oldPixelsPerTime, err := strconv.ParseInt(oldPixelsPerTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasPixelsPerTimeChangedEvent", "Failed to parse oldPixelsPerTimeHex", canvasIdHex, oldPixelsPerTimeHex, newPixelsPerTimeHex, err)
-		return
+func revertCanvasPixelsPerTimeChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("revertCanvasPixelsPerTimeChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET pixels_per_time = $1 WHERE world_id = $2", oldPixelsPerTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET pixels_per_time = $1 WHERE world_id = $2", changed.Old, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("revertCanvasPixelsPerTimeChangedEvent", "Failed to update Worlds", canvasIdHex, oldPixelsPerTimeHex, newPixelsPerTimeHex, err)
-		return
+		return PrintIndexerError("revertCanvasPixelsPerTimeChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func processCanvasTimerChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldTimeHex := event.Event.Data[0]
-	newTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasTimeBetweenPixelsChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldTimeHex, newTimeHex, err)
-		return
-	}
-
-	newTime, err := strconv.ParseInt(newTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasTimeBetweenPixelsChangedEvent", "Failed to parse newTimeHex", canvasIdHex, oldTimeHex, newTimeHex, err)
-		return
+func processCanvasTimerChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("processCanvasTimeBetweenPixelsChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET time_between_pixels = $1 WHERE world_id = $2", newTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET time_between_pixels = $1 WHERE world_id = $2", changed.New, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("processCanvasTimeBetweenPixelsChangedEvent", "Failed to update Worlds", canvasIdHex, oldTimeHex, newTimeHex, err)
-		return
+		return PrintIndexerError("processCanvasTimeBetweenPixelsChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func revertCanvasTimerChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldTimeHex := event.Event.Data[0]
-	newTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasTimeBetweenPixelsChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldTimeHex, newTimeHex, err)
-		return
-	}
-
-	oldTime, err := strconv.ParseInt(oldTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasTimeBetweenPixelsChangedEvent", "Failed to parse oldTimeHex", canvasIdHex, oldTimeHex, newTimeHex, err)
-		return
+func revertCanvasTimerChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("revertCanvasTimeBetweenPixelsChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET time_between_pixels = $1 WHERE world_id = $2", oldTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET time_between_pixels = $1 WHERE world_id = $2", changed.Old, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("revertCanvasTimeBetweenPixelsChangedEvent", "Failed to update Worlds", canvasIdHex, oldTimeHex, newTimeHex, err)
-		return
+		return PrintIndexerError("revertCanvasTimeBetweenPixelsChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func processCanvasStartTimeChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldStartTimeHex := event.Event.Data[0]
-	newStartTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasStartTimeChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldStartTimeHex, newStartTimeHex, err)
-		return
-	}
-
-	newStartTime, err := strconv.ParseInt(newStartTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasStartTimeChangedEvent", "Failed to parse newStartTimeHex", canvasIdHex, oldStartTimeHex, newStartTimeHex, err)
-		return
+func processCanvasStartTimeChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("processCanvasStartTimeChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET start_time = TO_TIMESTAMP($1) WHERE world_id = $2", newStartTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET start_time = TO_TIMESTAMP($1) WHERE world_id = $2", changed.New, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("processCanvasStartTimeChangedEvent", "Failed to update Worlds", canvasIdHex, oldStartTimeHex, newStartTimeHex, err)
-		return
+		return PrintIndexerError("processCanvasStartTimeChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func revertCanvasStartTimeChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldStartTimeHex := event.Event.Data[0]
-	newStartTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasStartTimeChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldStartTimeHex, newStartTimeHex, err)
-		return
-	}
-
-	oldStartTime, err := strconv.ParseInt(oldStartTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasStartTimeChangedEvent", "Failed to parse oldStartTimeHex", canvasIdHex, oldStartTimeHex, newStartTimeHex, err)
-		return
+func revertCanvasStartTimeChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("revertCanvasStartTimeChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET start_time = TO_TIMESTAMP($1) WHERE world_id = $2", oldStartTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET start_time = TO_TIMESTAMP($1) WHERE world_id = $2", changed.Old, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("revertCanvasStartTimeChangedEvent", "Failed to update Worlds", canvasIdHex, oldStartTimeHex, newStartTimeHex, err)
-		return
+		return PrintIndexerError("revertCanvasStartTimeChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func processCanvasEndTimeChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldEndTimeHex := event.Event.Data[0]
-	newEndTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasEndTimeChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldEndTimeHex, newEndTimeHex, err)
-		return
-	}
-
-	newEndTime, err := strconv.ParseInt(newEndTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasEndTimeChangedEvent", "Failed to parse newEndTimeHex", canvasIdHex, oldEndTimeHex, newEndTimeHex, err)
-		return
+func processCanvasEndTimeChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("processCanvasEndTimeChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET end_time = TO_TIMESTAMP($1) WHERE world_id = $2", newEndTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET end_time = TO_TIMESTAMP($1) WHERE world_id = $2", changed.New, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("processCanvasEndTimeChangedEvent", "Failed to update Worlds", canvasIdHex, oldEndTimeHex, newEndTimeHex, err)
-		return
+		return PrintIndexerError("processCanvasEndTimeChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func revertCanvasEndTimeChangedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	oldEndTimeHex := event.Event.Data[0]
-	newEndTimeHex := event.Event.Data[1]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasEndTimeChangedEvent", "Failed to parse canvasIdHex", canvasIdHex, oldEndTimeHex, newEndTimeHex, err)
-		return
-	}
-
-	oldEndTime, err := strconv.ParseInt(oldEndTimeHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasEndTimeChangedEvent", "Failed to parse oldEndTimeHex", canvasIdHex, oldEndTimeHex, newEndTimeHex, err)
-		return
+func revertCanvasEndTimeChangedEvent(event IndexerEvent) error {
+	var changed canvasSettingChanged
+	if err := decodeEvent(event, &changed); err != nil {
+		return PrintIndexerError("revertCanvasEndTimeChangedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Update Worlds
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET end_time = TO_TIMESTAMP($1) WHERE world_id = $2", oldEndTime, canvasId)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Worlds SET end_time = TO_TIMESTAMP($1) WHERE world_id = $2", changed.Old, changed.CanvasId)
 	if err != nil {
-		PrintIndexerError("revertCanvasEndTimeChangedEvent", "Failed to update Worlds", canvasIdHex, oldEndTimeHex, newEndTimeHex, err)
-		return
+		return PrintIndexerError("revertCanvasEndTimeChangedEvent", "Failed to update Worlds", changed.CanvasId, changed.Old, changed.New, err)
 	}
+	return nil
 }
 
-func processCanvasColorAddedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	colorKeyHex := event.Event.Keys[2]
-	colorHex := event.Event.Data[0]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasColorAddedEvent", "Failed to parse canvasIdHex", canvasIdHex, colorKeyHex, colorHex, err)
-		return
-	}
-
-	colorKey, err := strconv.ParseInt(colorKeyHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasColorAddedEvent", "Failed to parse colorKeyHex", canvasIdHex, colorKeyHex, colorHex, err)
-		return
+func processCanvasColorAddedEvent(event IndexerEvent) error {
+	var added canvasColorAdded
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("processCanvasColorAddedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
-
-	color := colorHex[len(colorHex)-6:] // Remove prefix
+	color := fmt.Sprintf("%06x", added.Color)
 
 	// Insert into WorldsColors
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldsColors (world_id, color_key, hex) VALUES ($1, $2, $3)", canvasId, colorKey, color)
-}
-
-func revertCanvasColorAddedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	colorKeyHex := event.Event.Keys[2]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldsColors (world_id, color_key, hex) VALUES ($1, $2, $3)", added.CanvasId, added.ColorKey, color)
 	if err != nil {
-		PrintIndexerError("revertCanvasColorAddedEvent", "Failed to parse canvasIdHex", canvasIdHex, colorKeyHex, err)
-		return
+		return PrintIndexerError("processCanvasColorAddedEvent", "Failed to insert into WorldsColors", added.CanvasId, added.ColorKey, color, err)
 	}
+	return nil
+}
 
-	colorKey, err := strconv.ParseInt(colorKeyHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasColorAddedEvent", "Failed to parse colorKeyHex", canvasIdHex, colorKeyHex, err)
-		return
+func revertCanvasColorAddedEvent(event IndexerEvent) error {
+	var added canvasColorAdded
+	if err := decodeEvent(event, &added); err != nil {
+		return PrintIndexerError("revertCanvasColorAddedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
 	// Delete from WorldsColors
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM WorldsColors WHERE world_id = $1 AND color_key = $2", canvasId, colorKey)
-}
-
-func processCanvasPixelPlacedEvent(event IndexerEvent) {
-	fmt.Println("processCanvasPixelPlacedEvent", event)		
-	canvasIdHex := event.Event.Keys[1]
-	placedBy := event.Event.Keys[2][2:] // Remove 0x prefix
-	posHex := event.Event.Keys[3]
-	colorHex := event.Event.Data[0]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM WorldsColors WHERE world_id = $1 AND color_key = $2", added.CanvasId, added.ColorKey)
 	if err != nil {
-		PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse canvasIdHex", canvasIdHex, placedBy, posHex, colorHex, err)
-		return
+		return PrintIndexerError("revertCanvasColorAddedEvent", "Failed to delete from WorldsColors", added.CanvasId, added.ColorKey, err)
 	}
+	return nil
+}
 
-	pos, err := strconv.ParseInt(posHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse posHex", canvasIdHex, placedBy, posHex, colorHex, err)
-		return
+func processCanvasPixelPlacedEvent(event IndexerEvent) error {
+	var placed canvasPixelPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
+	canvasId := placed.CanvasId
+	placedBy := placed.PlacedBy
+	pos := placed.Position
+	colorVal := placed.Color
 
-	colorVal, err := strconv.ParseInt(colorHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse colorHex", canvasIdHex, placedBy, posHex, colorHex, err)
-		return
+	var message = map[string]string{
+		"worldId":     strconv.Itoa(int(canvasId)),
+		"position":    strconv.Itoa(int(pos)),
+		"color":       strconv.Itoa(int(colorVal)),
+		"messageType": "colorWorldPixel",
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldsPixels (world_id, address, position, color) VALUES ($1, $2, $3, $4)", canvasId, placedBy, pos, colorVal)
-	if err != nil {
-		PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to insert into WorldsPixels", canvasIdHex, placedBy, posHex, colorHex, err)
-		return
+	// TODO: Dont resend on revert & reindex
+	app := EventApplication{}
+	app.Exec("INSERT INTO WorldsPixels (world_id, address, position, color, block_number, block_time) VALUES ($1, $2, $3, $4, $5, TO_TIMESTAMP($6))", canvasId, placedBy, pos, colorVal, event.Block.Number, event.Block.Timestamp)
+	app.SetPixel("canvas-"+strconv.Itoa(int(canvasId)), uint(pos), colorVal, message)
+	if err := app.Apply(); err != nil {
+		return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to insert into WorldsPixels", canvasId, placedBy, pos, colorVal, err)
 	}
 
-	go func() {
-		bitfieldType := "u" + strconv.Itoa(int(core.AFKBackend.CanvasConfig.ColorsBitWidth))
-		position := uint(pos) * core.AFKBackend.CanvasConfig.ColorsBitWidth
-
-		ctx := context.Background()
-		canvasRedisKey := "canvas-" + strconv.Itoa(int(canvasId))
-		err = core.AFKBackend.Databases.Redis.BitField(ctx, canvasRedisKey, "SET", bitfieldType, position, colorVal).Err()
-		if err != nil {
-			PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to set bitfield", canvasIdHex, placedBy, posHex, colorHex, err)
-			return
-		}
-
-		// TODO: Dont resend on revert & reindex
-		var message = map[string]string{
-			"worldId":     strconv.Itoa(int(canvasId)),
-			"position":    strconv.Itoa(int(pos)),
-			"color":       strconv.Itoa(int(colorVal)),
-			"messageType": "colorWorldPixel",
-		}
-		routeutils.SendMessageToWSS(message)
-	}()
-
 	// Check # of total pixels placed on this world
 	/*
 		totalPixelsPlaced, err := core.PostgresQueryOne[int]("SELECT COUNT(*) FROM WorldsPixels WHERE world_id = $1", canvasId)
 		if err != nil {
-			PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query totalPixelsPlaced", canvasIdHex, placedBy, posHex, colorHex, err)
-			return
+			return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query totalPixelsPlaced", canvasIdHex, placedBy, posHex, colorHex, err)
 		}
 
-		lastPixelPlacedTime, err := core.PostgresQueryOne[*time.Time]("SELECT time FROM WorldsPixels WHERE world_id = $1 ORDER BY time DESC LIMIT 1", canvasId)
+		lastPixelPlacedTime, err := core.PostgresQueryOne[*time.Time]("SELECT time FROM WorldsPixels WHERE world_id = $1 ORDER BY key DESC LIMIT 1", canvasId)
 		if err != nil {
-			PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query lastPixelPlacedTime", canvasIdHex, placedBy, posHex, colorHex, err)
-			return
+			return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query lastPixelPlacedTime", canvasIdHex, placedBy, posHex, colorHex, err)
 		}
 		timeSinceLastPixelPlaced := time.Now().Unix() - (*lastPixelPlacedTime).Unix()
 		threeHours := int64(3 * 60 * 60)
@@ -577,45 +371,38 @@ func processCanvasPixelPlacedEvent(event IndexerEvent) {
 		if uint(*totalPixelsPlaced)%2000 == 0 || timeSinceLastPixelPlaced > threeHours {
 			worldWidth, err := core.PostgresQueryOne[int]("SELECT width FROM Worlds WHERE world_id = $1", canvasId)
 			if err != nil {
-				PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query worldWidth", canvasIdHex, placedBy, posHex, colorHex, err)
-				return
+				return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query worldWidth", canvasIdHex, placedBy, posHex, colorHex, err)
 			}
 			worldHeight, err := core.PostgresQueryOne[int]("SELECT height FROM Worlds WHERE world_id = $1", canvasId)
 			if err != nil {
-				PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query worldHeight", canvasIdHex, placedBy, posHex, colorHex, err)
-				return
+				return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query worldHeight", canvasIdHex, placedBy, posHex, colorHex, err)
 			}
 
 			ctx := context.Background()
 			canvasRedisKey := "canvas-" + strconv.Itoa(int(canvasId))
 			canvas, err := core.AFKBackend.Databases.Redis.Get(ctx, canvasRedisKey).Result()
 			if err != nil {
-				PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to get canvas", canvasIdHex, placedBy, posHex, colorHex, err)
-				return
+				return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to get canvas", canvasIdHex, placedBy, posHex, colorHex, err)
 			}
 
 			colorPaletteHex, err := core.PostgresQuery[string]("SELECT hex FROM WorldsColors WHERE world_id = $1 ORDER BY color_key", strconv.Itoa(int(canvasId)))
 			if err != nil {
-				PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query colorPaletteHex", canvasIdHex, placedBy, posHex, colorHex, err)
-				return
+				return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to query colorPaletteHex", canvasIdHex, placedBy, posHex, colorHex, err)
 			}
 
 			colorPalette := make([]color.RGBA, len(colorPaletteHex))
 			for idx, colorHex := range colorPaletteHex {
 				r, err := strconv.ParseInt(colorHex[0:2], 16, 64)
 				if err != nil {
-					PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse colorHex", colorHex, err)
-					return
+					return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse colorHex", colorHex, err)
 				}
 				g, err := strconv.ParseInt(colorHex[2:4], 16, 64)
 				if err != nil {
-					PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse colorHex", colorHex, err)
-					return
+					return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse colorHex", colorHex, err)
 				}
 				b, err := strconv.ParseInt(colorHex[4:6], 16, 64)
 				if err != nil {
-					PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse colorHex", colorHex, err)
-					return
+					return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to parse colorHex", colorHex, err)
 				}
 				colorPalette[idx] = color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
 			}
@@ -647,254 +434,162 @@ func processCanvasPixelPlacedEvent(event IndexerEvent) {
 			filename := "worlds/images/world-" + strconv.Itoa(int(canvasId)) + ".png"
 			file, err := os.Create(filename)
 			if err != nil {
-				PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to create file", filename, err)
-				return
+				return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to create file", filename, err)
 			}
 			defer file.Close()
 
 			err = png.Encode(file, generatedWorldImage)
 			if err != nil {
-				PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to encode image", filename, err)
-				return
+				return PrintIndexerError("processCanvasPixelPlacedEvent", "Failed to encode image", filename, err)
 			}
 		}
 	*/
-
+	return nil
 }
 
-func revertCanvasPixelPlacedEvent(event IndexerEvent) {
-	worldIdHex := event.Event.Keys[1]
-	placedBy := event.Event.Keys[2][2:] // Remove 0x prefix
-	posHex := event.Event.Keys[3]
-
-	worldId, err := strconv.ParseInt(worldIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertPixelPlacedEvent", "Failed to parse worldIdHex", worldIdHex, placedBy, posHex, err)
-		return
+func revertCanvasPixelPlacedEvent(event IndexerEvent) error {
+	var placed canvasPixelPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("revertPixelPlacedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
+	worldId := placed.CanvasId
+	placedBy := placed.PlacedBy
+	pos := placed.Position
 
-	pos, err := strconv.ParseInt(posHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertPixelPlacedEvent", "Failed to parse posHex", worldIdHex, placedBy, posHex, err)
-		return
+	var message = map[string]string{
+		"worldId":     strconv.Itoa(int(worldId)),
+		"position":    strconv.Itoa(int(pos)),
+		"messageType": "colorWorldPixel",
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM WorldsPixels WHERE world_id = $1 AND address = $2 AND position = $3 ORDER BY time DESC limit 1", worldId, placedBy, pos)
-	if err != nil {
-		PrintIndexerError("revertPixelPlacedEvent", "Failed to delete from WorldsPixels", worldIdHex, placedBy, posHex, err)
-		return
+	// The canvas falls back to the latest remaining pixel, read by Apply once committed
+	app := EventApplication{}
+	app.Exec("DELETE FROM WorldsPixels WHERE key = (SELECT key FROM WorldsPixels WHERE world_id = $1 AND address = $2 AND position = $3 AND block_number = $4 ORDER BY key DESC LIMIT 1)", worldId, placedBy, pos, event.Block.Number)
+	app.SetPixel("canvas-"+strconv.Itoa(int(worldId)), uint(pos), 0, message)
+	if err := app.Apply(); err != nil {
+		return PrintIndexerError("revertPixelPlacedEvent", "Failed to delete from WorldsPixels", worldId, placedBy, pos, err)
 	}
-
-	/*
-		  TODO
-			oldColor, err := core.PostgresQueryOne[int]("SELECT color FROM WorldsPixels WHERE world_id = $1 AND address = $2 AND position = $3 ORDER BY time DESC LIMIT 1", worldId, placedBy, pos)
-			if err != nil {
-				PrintIndexerError("revertPixelPlacedEvent", "Failed to query old color", worldIdHex, placedBy, posHex, err)
-				return
-			}
-
-			bitfieldType := "u" + strconv.Itoa(int(core.AFKBackend.CanvasConfig.ColorsBitWidth))
-			position := uint(pos) * core.AFKBackend.CanvasConfig.ColorsBitWidth
-
-			ctx := context.Background()
-			err = core.AFKBackend.Databases.Redis.BitField(ctx, "canvas-"+strconv.Itoa(int(worldId)), "SET", bitfieldType, position, oldColor).Err()
-			if err != nil {
-				PrintIndexerError("revertPixelPlacedEvent", "Failed to set bitfield", worldIdHex, placedBy, posHex, err)
-				return
-			}
-	*/
-
-	// TODO: Send websocket message?
+	return nil
 }
 
-func processCanvasBasicPixelPlacedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	placedBy := event.Event.Keys[2][2:] // Remove 0x prefix
-	timestampHex := event.Event.Data[0]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasBasicPixelPlacedEvent", "Failed to parse canvasIdHex", canvasIdHex, placedBy, timestampHex, err)
-		return
-	}
-
-	timestamp, err := strconv.ParseInt(timestampHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasBasicPixelPlacedEvent", "Failed to parse timestampHex", canvasIdHex, placedBy, timestampHex, err)
-		return
+func processCanvasBasicPixelPlacedEvent(event IndexerEvent) error {
+	var placed canvasBasicPixelPlaced
+	if err := decodeEvent(event, &placed); err != nil {
+		return PrintIndexerError("processCanvasBasicPixelPlacedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldsLastPlacedTime (world_id, address, time) VALUES ($1, $2, TO_TIMESTAMP($3)) ON CONFLICT (world_id, address) DO UPDATE SET time = TO_TIMESTAMP($3)", canvasId, placedBy, timestamp)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldsLastPlacedTime (world_id, address, time) VALUES ($1, $2, TO_TIMESTAMP($3)) ON CONFLICT (world_id, address) DO UPDATE SET time = TO_TIMESTAMP($3)", placed.CanvasId, placed.PlacedBy, placed.Timestamp)
 	if err != nil {
-		PrintIndexerError("processCanvasBasicPixelPlacedEvent", "Failed to insert into WorldsLastPlacedTime", canvasIdHex, placedBy, timestampHex, err)
-		return
+		return PrintIndexerError("processCanvasBasicPixelPlacedEvent", "Failed to insert into WorldsLastPlacedTime", placed.CanvasId, placed.PlacedBy, placed.Timestamp, err)
 	}
+	return nil
 }
 
-func revertCanvasBasicPixelPlacedEvent(event IndexerEvent) {
+func revertCanvasBasicPixelPlacedEvent(event IndexerEvent) error {
 	// TODO: See pixel.go impl?
+	return nil
 }
 
-func processCanvasExtraPixelsPlacedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	placedBy := event.Event.Keys[2][2:] // Remove 0x prefix
-	extraPixelsHex := event.Event.Data[0]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasExtraPixelsPlacedEvent", "Failed to parse canvasIdHex", canvasIdHex, placedBy, extraPixelsHex, err)
-		return
+func processCanvasExtraPixelsPlacedEvent(event IndexerEvent) error {
+	var extra canvasUserPixels
+	if err := decodeEvent(event, &extra); err != nil {
+		return PrintIndexerError("processCanvasExtraPixelsPlacedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	extraPixels, err := strconv.ParseInt(extraPixelsHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE WorldsExtraPixels SET available = available - $1, used = used + $1 WHERE world_id = $2 AND address = $3", extra.Amount, extra.CanvasId, extra.User)
 	if err != nil {
-		PrintIndexerError("processCanvasExtraPixelsPlacedEvent", "Failed to parse extraPixelsHex", canvasIdHex, placedBy, extraPixelsHex, err)
-		return
-	}
-
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE WorldsExtraPixels SET available = available - $1, used = used + $1 WHERE world_id = $2 AND address = $3", extraPixels, canvasId, placedBy)
-	if err != nil {
-		PrintIndexerError("processCanvasExtraPixelsPlacedEvent", "Failed to insert into WorldsExtraPixels", canvasIdHex, placedBy, extraPixelsHex, err)
-		return
+		return PrintIndexerError("processCanvasExtraPixelsPlacedEvent", "Failed to insert into WorldsExtraPixels", extra.CanvasId, extra.User, extra.Amount, err)
 	}
+	return nil
 }
 
-func revertCanvasExtraPixelsPlacedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	placedBy := event.Event.Keys[2][2:] // Remove 0x prefix
-	extraPixelsHex := event.Event.Data[0]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasExtraPixelsPlacedEvent", "Failed to parse canvasIdHex", canvasIdHex, placedBy, extraPixelsHex, err)
-		return
-	}
-
-	extraPixels, err := strconv.ParseInt(extraPixelsHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasExtraPixelsPlacedEvent", "Failed to parse extraPixelsHex", canvasIdHex, placedBy, extraPixelsHex, err)
-		return
+func revertCanvasExtraPixelsPlacedEvent(event IndexerEvent) error {
+	var extra canvasUserPixels
+	if err := decodeEvent(event, &extra); err != nil {
+		return PrintIndexerError("revertCanvasExtraPixelsPlacedEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE WorldsExtraPixels SET available = available + $1, used = used - $1 WHERE world_id = $2 AND address = $3", extraPixels, canvasId, placedBy)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE WorldsExtraPixels SET available = available + $1, used = used - $1 WHERE world_id = $2 AND address = $3", extra.Amount, extra.CanvasId, extra.User)
 	if err != nil {
-		PrintIndexerError("revertCanvasExtraPixelsPlacedEvent", "Failed to insert into WorldsExtraPixels", canvasIdHex, placedBy, extraPixelsHex, err)
-		return
+		return PrintIndexerError("revertCanvasExtraPixelsPlacedEvent", "Failed to insert into WorldsExtraPixels", extra.CanvasId, extra.User, extra.Amount, err)
 	}
+	return nil
 }
 
-func processCanvasHostAwardedUserEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	user := event.Event.Keys[2][2:] // Remove 0x prefix
-	amountHex := event.Event.Data[0]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasHostAwardedUserEvent", "Failed to parse canvasIdHex", canvasIdHex, user, amountHex, err)
-		return
+func processCanvasHostAwardedUserEvent(event IndexerEvent) error {
+	var award canvasUserPixels
+	if err := decodeEvent(event, &award); err != nil {
+		return PrintIndexerError("processCanvasHostAwardedUserEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	amount, err := strconv.ParseInt(amountHex, 0, 64)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldsExtraPixels (world_id, address, available, used) VALUES ($1, $2, $3, 0) ON CONFLICT (world_id, address) DO UPDATE SET available = WorldsExtraPixels.available + $3", award.CanvasId, award.User, award.Amount)
 	if err != nil {
-		PrintIndexerError("processCanvasHostAwardedUserEvent", "Failed to parse amountHex", canvasIdHex, user, amountHex, err)
-		return
-	}
-
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldsExtraPixels (world_id, address, available, used) VALUES ($1, $2, $3, 0) ON CONFLICT (world_id, address) DO UPDATE SET available = WorldsExtraPixels.available + $3", canvasId, user, amount)
-	if err != nil {
-		PrintIndexerError("processCanvasHostAwardedUserEvent", "Failed to insert into WorldFavorites", canvasIdHex, user, amountHex, err)
-		return
+		return PrintIndexerError("processCanvasHostAwardedUserEvent", "Failed to insert into WorldFavorites", award.CanvasId, award.User, award.Amount, err)
 	}
+	return nil
 }
 
-func revertCanvasHostAwardedUserEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	user := event.Event.Keys[2][2:] // Remove 0x prefix
-	amountHex := event.Event.Data[0]
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasHostAwardedUserEvent", "Failed to parse canvasIdHex", canvasIdHex, user, amountHex, err)
-		return
-	}
-
-	amount, err := strconv.ParseInt(amountHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasHostAwardedUserEvent", "Failed to parse amountHex", canvasIdHex, user, amountHex, err)
-		return
+func revertCanvasHostAwardedUserEvent(event IndexerEvent) error {
+	var award canvasUserPixels
+	if err := decodeEvent(event, &award); err != nil {
+		return PrintIndexerError("revertCanvasHostAwardedUserEvent", "Failed to decode event", event.Event.Keys, event.Event.Data, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE WorldsExtraPixels SET available = available - $1 WHERE world_id = $2 AND address = $3", amount, canvasId, user)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE WorldsExtraPixels SET available = available - $1 WHERE world_id = $2 AND address = $3", award.Amount, award.CanvasId, award.User)
 	if err != nil {
-		PrintIndexerError("revertCanvasHostAwardedUserEvent", "Failed to insert into WorldFavorites", canvasIdHex, user, amountHex, err)
-		return
+		return PrintIndexerError("revertCanvasHostAwardedUserEvent", "Failed to insert into WorldFavorites", award.CanvasId, award.User, award.Amount, err)
 	}
+	return nil
 }
 
-func processCanvasFavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	user := event.Event.Keys[2][2:] // Remove 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasFavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, user, err)
-		return
+func processCanvasFavoritedEvent(event IndexerEvent) error {
+	var favorite canvasFavorite
+	if err := decodeEvent(event, &favorite); err != nil {
+		return PrintIndexerError("processCanvasFavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldFavorites (world_id, user_address) VALUES ($1, $2)", canvasId, user)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldFavorites (world_id, user_address) VALUES ($1, $2)", favorite.CanvasId, favorite.User)
 	if err != nil {
-		PrintIndexerError("processCanvasFavoritedEvent", "Failed to insert into WorldFavorites", canvasIdHex, user, err)
-		return
+		return PrintIndexerError("processCanvasFavoritedEvent", "Failed to insert into WorldFavorites", favorite.CanvasId, favorite.User, err)
 	}
+	return nil
 }
 
-func revertCanvasFavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	user := event.Event.Keys[2][2:] // Remove 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasFavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, user, err)
-		return
+func revertCanvasFavoritedEvent(event IndexerEvent) error {
+	var favorite canvasFavorite
+	if err := decodeEvent(event, &favorite); err != nil {
+		return PrintIndexerError("revertCanvasFavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM WorldFavorites WHERE world_id = $1 AND user_address = $2", canvasId, user)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM WorldFavorites WHERE world_id = $1 AND user_address = $2", favorite.CanvasId, favorite.User)
 	if err != nil {
-		PrintIndexerError("revertCanvasFavoritedEvent", "Failed to delete from WorldFavorites", canvasIdHex, user, err)
-		return
+		return PrintIndexerError("revertCanvasFavoritedEvent", "Failed to delete from WorldFavorites", favorite.CanvasId, favorite.User, err)
 	}
+	return nil
 }
 
-func processCanvasUnfavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	user := event.Event.Keys[2][2:] // Remove 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("processCanvasUnfavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, user, err)
-		return
+func processCanvasUnfavoritedEvent(event IndexerEvent) error {
+	var favorite canvasFavorite
+	if err := decodeEvent(event, &favorite); err != nil {
+		return PrintIndexerError("processCanvasUnfavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM WorldFavorites WHERE world_id = $1 AND user_address = $2", canvasId, user)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM WorldFavorites WHERE world_id = $1 AND user_address = $2", favorite.CanvasId, favorite.User)
 	if err != nil {
-		PrintIndexerError("processCanvasUnfavoritedEvent", "Failed to delete from WorldFavorites", canvasIdHex, user, err)
-		return
+		return PrintIndexerError("processCanvasUnfavoritedEvent", "Failed to delete from WorldFavorites", favorite.CanvasId, favorite.User, err)
 	}
+	return nil
 }
 
-func revertCanvasUnfavoritedEvent(event IndexerEvent) {
-	canvasIdHex := event.Event.Keys[1]
-	user := event.Event.Keys[2][2:] // Remove 0x prefix
-
-	canvasId, err := strconv.ParseInt(canvasIdHex, 0, 64)
-	if err != nil {
-		PrintIndexerError("revertCanvasUnfavoritedEvent", "Failed to parse canvasIdHex", canvasIdHex, user, err)
-		return
+func revertCanvasUnfavoritedEvent(event IndexerEvent) error {
+	var favorite canvasFavorite
+	if err := decodeEvent(event, &favorite); err != nil {
+		return PrintIndexerError("revertCanvasUnfavoritedEvent", "Failed to decode event", event.Event.Keys, err)
 	}
 
-	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldFavorites (world_id, user_address) VALUES ($1, $2)", canvasId, user)
+	_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO WorldFavorites (world_id, user_address) VALUES ($1, $2)", favorite.CanvasId, favorite.User)
 	if err != nil {
-		PrintIndexerError("revertCanvasUnfavoritedEvent", "Failed to insert into WorldFavorites", canvasIdHex, user, err)
-		return
+		return PrintIndexerError("revertCanvasUnfavoritedEvent", "Failed to insert into WorldFavorites", favorite.CanvasId, favorite.User, err)
 	}
+	return nil
 }
@@ -9,7 +9,9 @@ import (
 	"os/exec"
 	"strconv"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
 
@@ -47,7 +49,7 @@ func getCanvasNFTAddress(w http.ResponseWriter, r *http.Request) {
 
 func setCanvasNFTAddress(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to set contract address
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeContractsWrite) {
 		return
 	}
 
@@ -56,6 +58,10 @@ func setCanvasNFTAddress(w http.ResponseWriter, r *http.Request) {
 		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to read reques  t body")
 		return
 	}
+	if err := indexer.SetContractAddress(indexer.ContractCanvasNFT, string(data)); err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to register contract address")
+		return
+	}
 	os.Setenv("CANVAS_NFT_CONTRACT_ADDRESS", string(data))
 	routeutils.WriteResultJson(w, "Contract address set")
 }
@@ -81,7 +87,10 @@ type NFTLikesRequest struct {
 }
 
 func getMyNFTs(w http.ResponseWriter, r *http.Request) {
-	address := r.URL.Query().Get("address")
+	if routeutils.AuthMiddleware(w, r) {
+		return
+	}
+	address := routeutils.GetAuthAddress(r)
 	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
 	if err != nil || pageLength <= 0 {
 		pageLength = 25
@@ -551,11 +560,10 @@ func getHotNFTs(w http.ResponseWriter, r *http.Request) {
 }
 
 func getLikedNFTs(w http.ResponseWriter, r *http.Request) {
-	address := r.URL.Query().Get("address")
-	if address == "" || address == "0" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Valid address required for liked NFTs")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	address := routeutils.GetAuthAddress(r)
 
 	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
 	if err != nil || pageLength <= 0 {
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"strconv"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
@@ -68,7 +69,7 @@ func getPixelInfo(w http.ResponseWriter, r *http.Request) {
 	queryRes, err := core.PostgresQueryOne[PixelInfo](`
     SELECT p.address, COALESCE(u.name, '') as name FROM Pixels p
     LEFT JOIN Users u ON p.address = u.address WHERE p.position = $1
-    ORDER BY p.time DESC LIMIT 1`, position)
+    ORDER BY p.block_number DESC, p.key DESC LIMIT 1`, position)
 	if err != nil {
 		routeutils.WriteDataJson(w, "\"0x0000000000000000000000000000000000000000000000000000000000000000\"")
 		return
@@ -180,7 +181,7 @@ func placeExtraPixelsDevnet(w http.ResponseWriter, r *http.Request) {
 
 func placePixelRedis(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to place pixels on redis
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeCanvasWrite) {
 		return
 	}
 
@@ -220,7 +221,13 @@ func placePixelRedis(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	roundNumber := core.AFKBackend.CanvasConfig.Round
 	canvasKey := fmt.Sprintf("canvas-%s", roundNumber)
-	err = core.AFKBackend.Databases.Redis.BitField(ctx, canvasKey, "SET", bitfieldType, pos, color).Err()
+	// Recorded in the canvas updates, so cached images and resuming clients see it
+	message := map[string]string{
+		"position":    strconv.Itoa(int(position)),
+		"color":       strconv.Itoa(int(color)),
+		"messageType": "colorPixel",
+	}
+	_, err = routeutils.SetCanvasPixel(ctx, canvasKey, bitfieldType, pos, int64(color), message)
 	if err != nil {
 		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Error setting pixel on redis")
 		return
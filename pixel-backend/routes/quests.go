@@ -10,9 +10,11 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/quests"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/starknet"
 )
 
 type DailyUserQuest struct {
@@ -51,13 +53,6 @@ type MainQuest struct {
 	ClaimParams []ClaimParams `json:"claimParams"`
 }
 
-type QuestContractConfig struct {
-	Type        string             `json:"type"`
-	InitParams  []string           `json:"initParams"`
-	StoreParams []int              `json:"storeParams"`
-	ClaimParams []ClaimParamConfig `json:"claimParams"`
-}
-
 type ClaimParams struct {
 	QuestId   int    `json:"questId"`
 	ClaimType string `json:"claimType"`
@@ -66,45 +61,11 @@ type ClaimParams struct {
 	Input     bool   `json:"input"`
 }
 
-type ClaimParamConfig struct {
-	Type    string `json:"type"`
-	Name    string `json:"name"`
-	Example string `json:"example"`
-	Input   bool   `json:"input"`
-}
-
-type QuestConfig struct {
-	Name           string              `json:"name"`
-	Description    string              `json:"description"`
-	Reward         int                 `json:"reward"`
-	ContractConfig QuestContractConfig `json:"questContract"`
-}
-
-type DailyQuestConfig struct {
-	Day    int           `json:"day"`
-	Quests []QuestConfig `json:"quests"`
-}
-
-type QuestsConfig struct {
-	DailyQuests struct {
-		DailyQuestsCount int                `json:"dailyQuestsCount"`
-		Quests           []DailyQuestConfig `json:"dailyQuests"`
-	} `json:"daily"`
-	MainQuests struct {
-		Quests []QuestConfig `json:"mainQuests"`
-	} `json:"main"`
-}
-
 type QuestStatus struct {
 	Progress int `json:"progress"`
 	Needed   int `json:"needed"`
 }
 
-type QuestTypes struct {
-	QuestId   int    `json:"questId"`
-	QuestType string `json:"questType"`
-}
-
 type QuestProgress struct {
 	QuestId  int   `json:"questId"`
 	Progress int   `json:"progress"`
@@ -126,6 +87,11 @@ func InitQuestsRoutes() {
 	http.HandleFunc("/get-daily-quest-progress", GetDailyQuestProgress)
 	http.HandleFunc("/get-today-quest-progress", GetTodayQuestProgress)
 	http.HandleFunc("/get-main-quest-progress", GetMainQuestProgress)
+	http.HandleFunc("/add-quest-allow-list", AddQuestAllowList)
+	http.HandleFunc("/remove-quest-allow-list", RemoveQuestAllowList)
+	http.HandleFunc("/get-quest-claim-signer", GetQuestClaimSigner)
+	http.HandleFunc("/sign-quest-claim", SignQuestClaim)
+	http.HandleFunc("/get-user-streak", GetUserStreak)
 	if !core.AFKBackend.BackendConfig.Production {
 		http.HandleFunc("/claim-today-quest-devnet", ClaimTodayQuestDevnet)
 		http.HandleFunc("/claim-main-quest-devnet", ClaimMainQuestDevnet)
@@ -133,104 +99,130 @@ func InitQuestsRoutes() {
 	}
 }
 
+// Validates the quests config and writes the new or changed quests in one transaction,
+// ?dryRun=true only returns the planned changes
 func InitQuests(w http.ResponseWriter, r *http.Request) {
-	// Only allow admin to initialize colors
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeQuestsWrite) {
 		return
 	}
 
-	// TODO: check if quests already exist
-	questJson, err := routeutils.ReadJsonBody[QuestsConfig](r)
+	questsConfig, err := routeutils.ReadJsonBody[quests.QuestsConfig](r)
 	if err != nil {
 		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to parse request body")
 		return
 	}
 
-	// Setup daily quests tables
-	for _, dailyQuestConfig := range questJson.DailyQuests.Quests {
-		for idx, questConfig := range dailyQuestConfig.Quests {
-			_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO DailyQuests (name, description, reward, day_index, quest_id, quest_type) VALUES ($1, $2, $3, $4, $5, $6)", questConfig.Name, questConfig.Description, questConfig.Reward, dailyQuestConfig.Day-1, idx, questConfig.ContractConfig.Type)
-			if err != nil {
-				fmt.Println("Error inserting daily quest, ", idx, err)
-				routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to insert daily quest")
-				return
-			}
-
-			paramIdx := 0
-			for _, storeParam := range questConfig.ContractConfig.StoreParams {
-				paramStr := questConfig.ContractConfig.InitParams[storeParam]
-				// TODO: More generic
-				if paramStr == "$DAY_IDX" {
-					paramStr = strconv.Itoa(dailyQuestConfig.Day - 1)
-				}
-
-				param, err := strconv.Atoi(paramStr)
-				if err != nil {
-					routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid store param")
-					return
-				}
-
-				_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO DailyQuestsInput (day_index, quest_id, input_key, input_value) VALUES ($1, $2, $3, $4)", dailyQuestConfig.Day-1, idx, paramIdx, param)
-				if err != nil {
-					routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to insert daily quest input")
-					return
-				}
-
-				paramIdx++
-			}
-
-			claimParamIdx := 0
-			for _, claimParam := range questConfig.ContractConfig.ClaimParams {
-				_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO DailyQuestsClaimParams (day_index, quest_id, claim_key, claim_type, name, example, input) VALUES ($1, $2, $3, $4, $5, $6, $7)", dailyQuestConfig.Day-1, idx, claimParamIdx, claimParam.Type, claimParam.Name, claimParam.Example, claimParam.Input)
-				if err != nil {
-					routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to insert daily quest claim param")
-					return
-				}
-
-				claimParamIdx++
-			}
+	plan, err := quests.InitQuests(questsConfig, r.URL.Query().Get("dryRun") == "true")
+	if err != nil {
+		if configErrs, ok := err.(quests.QuestConfigErrors); ok {
+			routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid quests config: "+configErrs.Error())
+			return
 		}
+		fmt.Println("Error initializing quests", err)
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to initialize quests")
+		return
 	}
 
-	// Setup main quests tables
-	for idx, questConfig := range questJson.MainQuests.Quests {
-		_, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO MainQuests (name, description, reward, quest_type) VALUES ($1, $2, $3, $4)", questConfig.Name, questConfig.Description, questConfig.Reward, questConfig.ContractConfig.Type)
+	planJson, err := json.Marshal(plan)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal quests plan")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(planJson))
+}
+
+type QuestAllowListRequest struct {
+	QuestId   int      `json:"questId"`
+	DayIndex  *int     `json:"dayIndex"` // main quest if unset
+	Addresses []string `json:"addresses"`
+}
+
+// readQuestAllowList reads the request and formats the addresses like the users table
+func readQuestAllowList(w http.ResponseWriter, r *http.Request) (*QuestAllowListRequest, bool) {
+	request, err := routeutils.ReadJsonBody[QuestAllowListRequest](r)
+	if err != nil || len(request.Addresses) == 0 {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid request body")
+		return nil, false
+	}
+
+	for idx, address := range request.Addresses {
+		normalized, err := starknet.NormalizeAddress(address)
 		if err != nil {
-			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to insert main quest")
-			return
+			routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid address "+address)
+			return nil, false
 		}
+		request.Addresses[idx] = normalized
+	}
+	return request, true
+}
 
-		paramIdx := 0
-		for _, storeParam := range questConfig.ContractConfig.StoreParams {
-			paramStr := questConfig.ContractConfig.InitParams[storeParam]
-			param, err := strconv.Atoi(paramStr)
-			if err != nil {
-				routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid store param")
-				return
-			}
-
-			_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO MainQuestsInput (quest_id, input_key, input_value) VALUES ($1, $2, $3)", idx, paramIdx, param)
-			if err != nil {
-				routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to insert main quest input")
-				return
-			}
-
-			paramIdx++
-		}
+// Allows addresses to complete an authority quest
+func AddQuestAllowList(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeQuestsWrite) {
+		return
+	}
+
+	request, ok := readQuestAllowList(w, r)
+	if !ok {
+		return
+	}
 
-		claimParamIdx := 0
-		for _, claimParam := range questConfig.ContractConfig.ClaimParams {
-			_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO MainQuestsClaimParams (quest_id, claim_key, claim_type, name, example, input) VALUES ($1, $2, $3, $4, $5, $6)", idx, claimParamIdx, claimParam.Type, claimParam.Name, claimParam.Example, claimParam.Input)
-			if err != nil {
-				routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to insert main quest claim param")
-				return
-			}
+	ctx := context.Background()
+	tx, err := core.AFKBackend.Databases.Postgres.Begin(ctx)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to update allow list")
+		return
+	}
+	defer tx.Rollback(ctx)
 
-			claimParamIdx++
+	for _, address := range request.Addresses {
+		if request.DayIndex != nil {
+			_, err = tx.Exec(ctx, "INSERT INTO DailyQuestsAllowList (day_index, quest_id, user_address) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING", *request.DayIndex, request.QuestId, address)
+		} else {
+			_, err = tx.Exec(ctx, "INSERT INTO MainQuestsAllowList (quest_id, user_address) VALUES ($1, $2) ON CONFLICT DO NOTHING", request.QuestId, address)
 		}
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to update allow list")
+			return
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to update allow list")
+		return
+	}
+	for _, address := range request.Addresses {
+		quests.InvalidateUserStats(address)
+	}
+
+	routeutils.WriteResultJson(w, fmt.Sprintf("Allowed %d addresses", len(request.Addresses)))
+}
+
+func RemoveQuestAllowList(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeQuestsWrite) {
+		return
+	}
+
+	request, ok := readQuestAllowList(w, r)
+	if !ok {
+		return
 	}
 
-	routeutils.WriteResultJson(w, "Initialized quests successfully")
+	var err error
+	if request.DayIndex != nil {
+		_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM DailyQuestsAllowList WHERE day_index = $1 AND quest_id = $2 AND user_address = ANY($3)", *request.DayIndex, request.QuestId, request.Addresses)
+	} else {
+		_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "DELETE FROM MainQuestsAllowList WHERE quest_id = $1 AND user_address = ANY($2)", request.QuestId, request.Addresses)
+	}
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to update allow list")
+		return
+	}
+	for _, address := range request.Addresses {
+		quests.InvalidateUserStats(address)
+	}
+
+	routeutils.WriteResultJson(w, fmt.Sprintf("Removed %d addresses", len(request.Addresses)))
 }
 
 func GetDailyQuests(w http.ResponseWriter, r *http.Request) {
@@ -292,11 +284,10 @@ func GetMainQuests(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetMainUserQuests(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
 	quests, err := core.PostgresQuery[MainUserQuest]("SELECT m.name, m.description, m.reward, m.key - 1 as quest_id, COALESCE(u.completed, false) as completed FROM MainQuests m LEFT JOIN UserMainQuests u ON u.quest_id = m.key - 1 AND u.user_address = $1", userAddress)
 	if err != nil {
@@ -327,12 +318,31 @@ func GetMainUserQuests(w http.ResponseWriter, r *http.Request) {
 	routeutils.WriteDataJson(w, string(jsonQuests))
 }
 
+// questsProgress evaluates the quests from the user's cached stats, with the claim calldata of
+// the completed ones
+func questsProgress(userAddress string, questItems []*quests.Quest) []QuestProgress {
+	statuses := quests.EvaluateQuests(userAddress, questItems)
+	result := make([]QuestProgress, 0, len(questItems))
+	for idx, questItem := range questItems {
+		var calldata []int
+		if statuses[idx].Progress >= statuses[idx].Needed {
+			calldata = questItem.GetQuestClaimData(userAddress)
+		}
+		result = append(result, QuestProgress{
+			QuestId:  questItem.QuestId,
+			Progress: statuses[idx].Progress,
+			Needed:   statuses[idx].Needed,
+			Calldata: calldata,
+		})
+	}
+	return result
+}
+
 func GetDailyQuestProgress(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
 	dayIndexStr := r.URL.Query().Get("dayIndex")
 	if dayIndexStr == "" {
@@ -346,34 +356,13 @@ func GetDailyQuestProgress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	questTypes, err := core.PostgresQuery[QuestTypes](
-		"SELECT quest_id, quest_type FROM DailyQuests WHERE day_index = $1",
-		dayIndex,
-	)
+	dailyQuests, err := quests.LoadDailyQuests(dayIndex)
 	if err != nil {
 		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get daily quest status")
 		return
 	}
 
-	var result []QuestProgress
-	for _, quest := range questTypes {
-		questItem := quests.NewDailyQuestWithType(quest.QuestId, quest.QuestType, dayIndex)
-		if questItem == nil {
-			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get daily quest")
-			return
-		}
-		progress, needed := questItem.CheckStatus(userAddress)
-		var calldata []int
-		if progress >= needed {
-			calldata = questItem.GetQuestClaimData(userAddress)
-		}
-		result = append(result, QuestProgress{
-			QuestId:  quest.QuestId,
-			Progress: progress,
-			Needed:   needed,
-			Calldata: calldata,
-		})
-	}
+	result := questsProgress(userAddress, dailyQuests)
 
 	jsonResult, err := json.Marshal(result)
 	if err != nil {
@@ -385,39 +374,18 @@ func GetDailyQuestProgress(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetTodayQuestProgress(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
-	questTypes, err := core.PostgresQuery[QuestTypes](
-		"SELECT quest_id, quest_type FROM DailyQuests WHERE day_index = (SELECT MAX(day_index) FROM Days)",
-	)
+	todayQuests, err := quests.LoadTodayQuests()
 	if err != nil {
 		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get daily quest status")
 		return
 	}
 
-	var result []QuestProgress
-	for _, quest := range questTypes {
-		questItem := quests.NewTodayQuestWithType(quest.QuestId, quest.QuestType)
-		if questItem == nil {
-			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get daily quest")
-			return
-		}
-		progress, needed := questItem.CheckStatus(userAddress)
-		var calldata []int
-		if progress >= needed {
-			calldata = questItem.GetQuestClaimData(userAddress)
-		}
-		result = append(result, QuestProgress{
-			QuestId:  quest.QuestId,
-			Progress: progress,
-			Needed:   needed,
-			Calldata: calldata,
-		})
-	}
+	result := questsProgress(userAddress, todayQuests)
 
 	jsonResult, err := json.Marshal(result)
 	if err != nil {
@@ -429,37 +397,18 @@ func GetTodayQuestProgress(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetMainQuestProgress(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
-	questTypes, err := core.PostgresQuery[QuestTypes]("SELECT key - 1 as quest_id, quest_type FROM MainQuests")
+	mainQuests, err := quests.LoadMainQuests()
 	if err != nil {
 		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get quest status")
 		return
 	}
 
-	var result []QuestProgress
-	for _, quest := range questTypes {
-		questItem := quests.NewMainQuestWithType(quest.QuestId, quest.QuestType)
-		if questItem == nil {
-			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get main quest")
-			return
-		}
-		progress, needed := questItem.CheckStatus(userAddress)
-		var calldata []int
-		if progress >= needed {
-			calldata = questItem.GetQuestClaimData(userAddress)
-		}
-		result = append(result, QuestProgress{
-			QuestId:  quest.QuestId,
-			Progress: progress,
-			Needed:   needed,
-			Calldata: calldata,
-		})
-	}
+	result := questsProgress(userAddress, mainQuests)
 
 	jsonResult, err := json.Marshal(result)
 	if err != nil {
@@ -506,11 +455,10 @@ func getTodaysQuests(w http.ResponseWriter, r *http.Request) {
 }
 
 func getTodaysUserQuests(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
 	quests, err := core.PostgresQuery[DailyUserQuest]("SELECT d.name, d.description, d.reward, d.day_index, d.quest_id, COALESCE(u.completed, false) as completed FROM DailyQuests d LEFT JOIN UserDailyQuests u ON d.quest_id = u.quest_id AND d.day_index = u.day_index AND u.user_address = $1 WHERE d.day_index = (SELECT MAX(day_index) FROM Days)", userAddress)
 	if err != nil {
@@ -541,11 +489,10 @@ func getTodaysUserQuests(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetCompletedMainQuests(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
 	quests, err := core.PostgresQueryJson[MainQuest]("SELECT key - 1 as quest_id, name, description, reward FROM MainQuests WHERE quest_id = (SELECT quest_id FROM UserMainQuests WHERE user_address = $1 AND completed = TRUE)", userAddress)
 	if err != nil {
@@ -557,11 +504,10 @@ func GetCompletedMainQuests(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetCompletedDailyQuests(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
 	quests, err := core.PostgresQueryJson[DailyQuest]("SELECT name, description, reward, day_index, quest_id FROM DailyQuests WHERE quest_id = (SELECT quest_id FROM UserDailyQuests WHERE user_address = $1 AND completed = TRUE)", userAddress)
 	if err != nil {
@@ -572,6 +518,129 @@ func GetCompletedDailyQuests(w http.ResponseWriter, r *http.Request) {
 	routeutils.WriteDataJson(w, string(quests))
 }
 
+// Stark key the quest claims are signed with, for the quest contracts
+func GetQuestClaimSigner(w http.ResponseWriter, r *http.Request) {
+	publicKey, err := quests.ClaimSignerPublicKey()
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Invalid quest claim signer key")
+		return
+	}
+	if publicKey == "" {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Quest claims are not signed")
+		return
+	}
+
+	routeutils.WriteResultJson(w, publicKey)
+}
+
+// Current and longest streaks of consecutive days with every daily quest claimed
+func GetUserStreak(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+		return
+	}
+	normalized, err := starknet.NormalizeAddress(address)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid address")
+		return
+	}
+
+	streak, err := quests.GetUserStreak(normalized)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get user streak")
+		return
+	}
+
+	streakJson, err := json.Marshal(streak)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal user streak")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(streakJson))
+}
+
+// SignQuestClaim signs the claim of a completed quest for the contract, a daily quest with
+// dayIndex or a main quest without. Body like {"questId": "2", "dayIndex": "5"}
+func SignQuestClaim(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AuthMiddleware(w, r) {
+		return
+	}
+	userAddress := routeutils.GetAuthAddress(r)
+
+	jsonBody, err := routeutils.ReadJsonBody[map[string]string](r)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid JSON request body")
+		return
+	}
+	questId, err := strconv.Atoi((*jsonBody)["questId"])
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid quest id")
+		return
+	}
+
+	var questItems []*quests.Quest
+	if dayIndexStr, ok := (*jsonBody)["dayIndex"]; ok {
+		dayIndex, err := strconv.Atoi(dayIndexStr)
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid dayIndex")
+			return
+		}
+		questItems, err = quests.LoadDailyQuests(dayIndex)
+	} else {
+		questItems, err = quests.LoadMainQuests()
+	}
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get quests")
+		return
+	}
+	var questItem *quests.Quest
+	for _, item := range questItems {
+		if item.QuestId == questId {
+			questItem = item
+		}
+	}
+	if questItem == nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Quest not found")
+		return
+	}
+
+	status := quests.EvaluateQuests(userAddress, []*quests.Quest{questItem})[0]
+	if !questItem.IsClaimable(status.Progress, status.Needed) {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Quest not completed")
+		return
+	}
+
+	allowed, err := quests.TakeClaimSlot(userAddress)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to sign quest claim")
+		return
+	}
+	if !allowed {
+		routeutils.WriteErrorJson(w, http.StatusTooManyRequests, "Quest claim signed too recently")
+		return
+	}
+
+	claim, err := questItem.SignClaim(userAddress, questItem.GetQuestClaimData(userAddress))
+	if err != nil {
+		fmt.Println("Error signing quest claim", userAddress, questItem.QuestId, err)
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to sign quest claim")
+		return
+	}
+	if claim == nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Quest claims are not signed")
+		return
+	}
+
+	claimJson, err := json.Marshal(claim)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal response")
+		return
+	}
+	routeutils.WriteDataJson(w, string(claimJson))
+}
+
 func GetTodayStartTime(w http.ResponseWriter, r *http.Request) {
 	todayStartTime, err := core.PostgresQueryOne[*time.Time]("SELECT day_start FROM days WHERE day_index = (SELECT MAX(day_index) FROM days)")
 	if err != nil {
@@ -682,11 +751,10 @@ func IncreaseDayDevnet(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetUserQuestStatus(w http.ResponseWriter, r *http.Request) {
-	userAddress := r.URL.Query().Get("address")
-	if userAddress == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	userAddress := routeutils.GetAuthAddress(r)
 
 	questType := r.URL.Query().Get("type")
 	if questType == "" {
@@ -725,8 +793,8 @@ func GetUserQuestStatus(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		progress, needed := quest.CheckStatus(userAddress)
-		questStatus := QuestStatus{Progress: progress, Needed: needed}
+		status := quests.EvaluateQuests(userAddress, []*quests.Quest{quest})[0]
+		questStatus := QuestStatus{Progress: status.Progress, Needed: status.Needed}
 		questStatusBytes, err := json.Marshal(questStatus)
 		if err != nil {
 			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal quest status")
@@ -742,8 +810,8 @@ func GetUserQuestStatus(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		progress, needed := quest.CheckStatus(userAddress)
-		questStatus := QuestStatus{Progress: progress, Needed: needed}
+		status := quests.EvaluateQuests(userAddress, []*quests.Quest{quest})[0]
+		questStatus := QuestStatus{Progress: status.Progress, Needed: status.Needed}
 		questStatusBytes, err := json.Marshal(questStatus)
 		if err != nil {
 			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal quest status")
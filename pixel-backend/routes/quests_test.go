@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadQuestAllowList(t *testing.T) {
+	alice := strings.Repeat("0", 59) + "a11ce"
+	bob := "049d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7"
+
+	tests := []struct {
+		name      string
+		body      string
+		status    int
+		questId   int
+		dayIndex  int // -1 for a main quest
+		addresses []string
+	}{
+		{
+			name:      "main quest",
+			body:      `{"questId": 3, "addresses": ["0xA11CE", "0x49d36570d4e46f48e99674bd3fcc84644ddd6b96f7c741b1562b82f9e004dc7"]}`,
+			status:    http.StatusOK,
+			questId:   3,
+			dayIndex:  -1,
+			addresses: []string{alice, bob},
+		},
+		{
+			name:      "daily quest",
+			body:      `{"questId": 0, "dayIndex": 2, "addresses": ["0x000a11ce"]}`,
+			status:    http.StatusOK,
+			questId:   0,
+			dayIndex:  2,
+			addresses: []string{alice},
+		},
+		{name: "no addresses", body: `{"questId": 3, "addresses": []}`, status: http.StatusBadRequest},
+		{name: "invalid address", body: `{"questId": 3, "addresses": ["0xA11CE", "alice"]}`, status: http.StatusBadRequest},
+		{name: "invalid json", body: `{"questId": 3, "addresses": ["0xA11CE"]`, status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/add-quest-allow-list", strings.NewReader(test.body))
+		request, ok := readQuestAllowList(w, r)
+		if test.status != http.StatusOK {
+			if ok || w.Code != test.status {
+				t.Errorf("%s: ok %t status %d, want %d", test.name, ok, w.Code, test.status)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: rejected with %d %s", test.name, w.Code, w.Body.String())
+			continue
+		}
+
+		dayIndex := -1
+		if request.DayIndex != nil {
+			dayIndex = *request.DayIndex
+		}
+		if request.QuestId != test.questId || dayIndex != test.dayIndex {
+			t.Errorf("%s: quest %d day %d, want %d day %d", test.name, request.QuestId, dayIndex, test.questId, test.dayIndex)
+		}
+		if !reflect.DeepEqual(request.Addresses, test.addresses) {
+			t.Errorf("%s: addresses %v, want %v", test.name, request.Addresses, test.addresses)
+		}
+	}
+}
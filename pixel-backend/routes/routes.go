@@ -19,7 +19,13 @@ func InitBaseRoutes() {
 
 func InitRoutes() {
 	InitBaseRoutes()
+	InitAuthRoutes()
+	InitAdminRoutes()
+	InitDeadLetterRoutes()
 	InitCanvasRoutes()
+	InitImageRoutes()
+	InitTimelapseRoutes()
+	InitTimelapsesStaticRoutes()
 	InitPixelRoutes()
 	InitFactionRoutes()
 	InitTemplateRoutes()
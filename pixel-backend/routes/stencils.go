@@ -436,6 +436,9 @@ func getTopStencils(w http.ResponseWriter, r *http.Request) {
 }
 
 func getFavoriteStencils(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AuthMiddleware(w, r) {
+		return
+	}
 	worldId := r.URL.Query().Get("worldId")
 	checkWorldId := true
 	if worldId == "" {
@@ -447,10 +450,7 @@ func getFavoriteStencils(w http.ResponseWriter, r *http.Request) {
 		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid worldId")
 		return
 	}
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		address = "0"
-	}
+	address := routeutils.GetAuthAddress(r)
 	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
 	if err != nil || pageLength <= 0 {
 		pageLength = 25
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
@@ -27,6 +29,7 @@ func InitTemplateRoutes() {
 	http.HandleFunc("/build-template-img", buildTemplateImg)
 	http.HandleFunc("/add-template-img", addTemplateImg)
 	http.HandleFunc("/add-template-data", addTemplateData)
+	http.HandleFunc("/backfill-template-data", backfillTemplateData)
 	http.HandleFunc("/get-template-pixel-data", getTemplatePixelData)
 	if !core.AFKBackend.BackendConfig.Production {
 		// http.HandleFunc("/add-template-devnet", addTemplateDevnet)
@@ -458,9 +461,82 @@ func addTemplateData(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Color indexes kept for the template quests
+	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO TemplateData (hash, data) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING", hash, imageBytes)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to store template data")
+		return
+	}
+
 	routeutils.WriteResultJson(w, hash)
 }
 
+type missingTemplateData struct {
+	Hash   string `json:"hash"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type BackfillTemplateDataResponse struct {
+	Backfilled []string          `json:"backfilled"`
+	Skipped    map[string]string `json:"skipped"` // hash -> reason
+}
+
+// backfillTemplateData stores the color indexes of the templates added without TemplateData,
+// read back from their stored png
+func backfillTemplateData(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeQuestsWrite) {
+		return
+	}
+
+	missing, err := core.PostgresQuery[missingTemplateData]("SELECT DISTINCT t.hash, t.width, t.height FROM Templates t LEFT JOIN TemplateData d ON d.hash = t.hash WHERE d.hash IS NULL")
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get templates without data")
+		return
+	}
+
+	response := BackfillTemplateDataResponse{Backfilled: []string{}, Skipped: map[string]string{}}
+	for _, template := range missing {
+		fileBytes, err := os.ReadFile(fmt.Sprintf("templates/template-%s.png", template.Hash))
+		if err != nil {
+			response.Skipped[template.Hash] = "no stored image"
+			continue
+		}
+		imageData, err := imageToPixelData(fileBytes, 1)
+		if err != nil {
+			response.Skipped[template.Hash] = "failed to convert image: " + err.Error()
+			continue
+		}
+		if len(imageData) != template.Width*template.Height {
+			response.Skipped[template.Hash] = "image size does not match the template"
+			continue
+		}
+		imageBytes := make([]byte, len(imageData))
+		for idx, val := range imageData {
+			imageBytes[idx] = byte(val)
+		}
+		// The palette may have changed since the image was stored
+		if hashTemplateImage(imageBytes) != template.Hash {
+			response.Skipped[template.Hash] = "image does not match the template hash"
+			continue
+		}
+
+		_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO TemplateData (hash, data) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING", template.Hash, imageBytes)
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to store template data")
+			return
+		}
+		response.Backfilled = append(response.Backfilled, template.Hash)
+	}
+
+	responseJson, err := json.Marshal(response)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal response")
+		return
+	}
+	routeutils.WriteDataJson(w, string(responseJson))
+}
+
 func getTemplatePixelData(w http.ResponseWriter, r *http.Request) {
 	// Get template hash from query params
 	hash := r.URL.Query().Get("hash")
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/video"
+)
+
+func InitTimelapseRoutes() {
+	http.HandleFunc("/generate-timelapse", generateTimelapse)
+	http.HandleFunc("/get-timelapse", getTimelapse)
+	http.HandleFunc("/get-timelapses", getTimelapses)
+}
+
+func InitTimelapsesStaticRoutes() {
+	http.Handle("/timelapses/", http.StripPrefix("/timelapses/", http.FileServer(http.Dir("./"+video.TimelapsesDir))))
+}
+
+type GenerateTimelapseRequest struct {
+	WorldId *int `json:"worldId"` // main canvas if unset
+	video.TimelapseOptions
+}
+
+// Records a timelapse and renders it in the background, poll /get-timelapse for its file
+func generateTimelapse(w http.ResponseWriter, r *http.Request) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeCanvasWrite) {
+		return
+	}
+
+	request, err := routeutils.ReadJsonBody[GenerateTimelapseRequest](r)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	history := routeutils.MainCanvasHistory()
+	if request.WorldId != nil {
+		history, err = routeutils.WorldCanvasHistory(*request.WorldId)
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusNotFound, "World not found")
+			return
+		}
+	}
+
+	timelapse, err := video.CreateTimelapse(history, request.TimelapseOptions)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to create timelapse: "+err.Error())
+		return
+	}
+
+	go func() {
+		if err := video.RunTimelapse(timelapse, history); err != nil {
+			fmt.Println("Failed to render timelapse", timelapse.Id, err)
+		}
+	}()
+
+	timelapseJson, err := json.Marshal(timelapse)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal timelapse")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(timelapseJson))
+}
+
+func getTimelapse(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	timelapse, err := video.GetTimelapse(id)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "Timelapse not found")
+		return
+	}
+
+	timelapseJson, err := json.Marshal(timelapse)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal timelapse")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(timelapseJson))
+}
+
+func getTimelapses(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	canvasKey := ""
+	if worldId := r.URL.Query().Get("worldId"); worldId != "" {
+		id, err := strconv.Atoi(worldId)
+		if err != nil {
+			routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid worldId")
+			return
+		}
+		canvasKey = "canvas-" + strconv.Itoa(id)
+	} else if r.URL.Query().Get("main") == "true" {
+		canvasKey = routeutils.MainCanvasHistory().CanvasKey
+	}
+
+	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
+	if err != nil || pageLength <= 0 {
+		pageLength = 50
+	}
+	if pageLength > 200 {
+		pageLength = 200
+	}
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * pageLength
+
+	timelapses, err := video.ListTimelapses(canvasKey, pageLength, offset)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get timelapses")
+		return
+	}
+
+	timelapsesJson, err := json.Marshal(timelapses)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to marshal timelapses")
+		return
+	}
+
+	routeutils.WriteDataJson(w, string(timelapsesJson))
+}
@@ -8,7 +8,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/indexer"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
 
@@ -37,7 +39,7 @@ func getUsernameStoreAddress(w http.ResponseWriter, r *http.Request) {
 
 func setUsernameStoreAddress(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to set contract address
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeContractsWrite) {
 		return
 	}
 
@@ -46,6 +48,10 @@ func setUsernameStoreAddress(w http.ResponseWriter, r *http.Request) {
 		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to read request body")
 		return
 	}
+	if err := indexer.SetContractAddress(indexer.ContractUsernameStore, string(data)); err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Failed to register contract address")
+		return
+	}
 	os.Setenv("USERNAME_STORE_CONTRACT_ADDRESS", string(data))
 	routeutils.WriteResultJson(w, "Contract address set")
 }
@@ -229,11 +235,10 @@ func changeUsernameDevnet(w http.ResponseWriter, r *http.Request) {
 }
 
 func getUserColorVote(w http.ResponseWriter, r *http.Request) {
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	address := routeutils.GetAuthAddress(r)
 
 	vote, err := core.PostgresQueryOne[int]("SELECT COALESCE((SELECT color_key FROM ColorVotes WHERE user_address = $1 AND day_index = (SELECT MAX(day_index) FROM days)), 0)", address)
 
@@ -268,11 +273,10 @@ type UserRewardsData struct {
 }
 
 func getUserRewards(w http.ResponseWriter, r *http.Request) {
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Missing address parameter")
+	if routeutils.AuthMiddleware(w, r) {
 		return
 	}
+	address := routeutils.GetAuthAddress(r)
 
 	rewards, err := core.PostgresQueryJson[UserRewardsData]("SELECT address, amount, type FROM AwardWinners WHERE address = $1", address)
 	if err != nil {
@@ -0,0 +1,175 @@
+package routeutils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Every canvas update bumps a per-canvas sequence number ( <canvasKey>-seq ) and is appended
+// to a bounded stream ( <canvasKey>-updates ) with the entry id <seq>-0, so clients that loaded
+// the canvas at some sequence can replay what they missed.
+
+const canvasUpdatesMaxLen = 10000
+
+func CanvasSeqKey(canvasKey string) string {
+	return fmt.Sprintf("%s-seq", canvasKey)
+}
+
+func CanvasUpdatesKey(canvasKey string) string {
+	return fmt.Sprintf("%s-updates", canvasKey)
+}
+
+// The sequence is validated against the stream last id before any write, so a lost seq key
+// can not leave the pixel set without its update entry
+// KEYS: canvas, seq, updates  ARGV: bitfield type, offset, color, max stream length, message fields...
+var setCanvasPixelScript = redis.NewScript(`
+local seq = tonumber(redis.call('GET', KEYS[2]) or '0') + 1
+if redis.call('EXISTS', KEYS[3]) == 1 then
+  local info = redis.call('XINFO', 'STREAM', KEYS[3])
+  for i = 1, #info, 2 do
+    if info[i] == 'last-generated-id' then
+      local last = tonumber(string.match(info[i + 1], '^(%d+)-'))
+      if last >= seq then
+        seq = last + 1
+      end
+    end
+  end
+end
+redis.call('BITFIELD', KEYS[1], 'SET', ARGV[1], ARGV[2], ARGV[3])
+redis.call('SET', KEYS[2], seq)
+redis.call('XADD', KEYS[3], 'MAXLEN', '~', ARGV[4], seq .. '-0', 'seq', seq, unpack(ARGV, 5))
+return seq
+`)
+
+// SetCanvasPixel sets a pixel and records the update atomically, the message is stamped
+// with the new sequence number
+func SetCanvasPixel(ctx context.Context, canvasKey string, bitfieldType string, offset uint, color int64, message map[string]string) (int64, error) {
+	args := []interface{}{bitfieldType, offset, color, canvasUpdatesMaxLen}
+	for field, value := range message {
+		if field == "seq" {
+			continue
+		}
+		args = append(args, field, value)
+	}
+
+	keys := []string{canvasKey, CanvasSeqKey(canvasKey), CanvasUpdatesKey(canvasKey)}
+	seq, err := setCanvasPixelScript.Run(ctx, core.AFKBackend.Databases.Redis, keys, args...).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	message["seq"] = strconv.FormatInt(seq, 10)
+	return seq, nil
+}
+
+// GetCanvasWithSeq returns the canvas along with the sequence number it reflects
+func GetCanvasWithSeq(ctx context.Context, canvasKey string) (string, int64, error) {
+	var canvasCmd *redis.StringCmd
+	var seqCmd *redis.StringCmd
+	_, err := core.AFKBackend.Databases.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		canvasCmd = pipe.Get(ctx, canvasKey)
+		seqCmd = pipe.Get(ctx, CanvasSeqKey(canvasKey))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return "", 0, err
+	}
+
+	canvas, err := canvasCmd.Result()
+	if err != nil {
+		return "", 0, err
+	}
+	seq, err := seqCmd.Int64()
+	if err == redis.Nil {
+		// No updates yet
+		return canvas, 0, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+	return canvas, seq, nil
+}
+
+// GetCanvasUpdatesSince returns up to count updates after seq, gap is set when the stream
+// was trimmed past seq and the client has to reload the canvas
+func GetCanvasUpdatesSince(ctx context.Context, canvasKey string, seq int64, count int64) ([]map[string]string, bool, error) {
+	updatesKey := CanvasUpdatesKey(canvasKey)
+
+	currentSeq, err := core.AFKBackend.Databases.Redis.Get(ctx, CanvasSeqKey(canvasKey)).Int64()
+	if err != nil && err != redis.Nil {
+		return nil, false, err
+	}
+	if seq > currentSeq {
+		// Client is ahead of the canvas, it was reset
+		return nil, true, nil
+	}
+	if seq == currentSeq {
+		return []map[string]string{}, false, nil
+	}
+
+	oldest, err := core.AFKBackend.Databases.Redis.XRangeN(ctx, updatesKey, "-", "+", 1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(oldest) == 0 {
+		return nil, true, nil
+	}
+	oldestSeq, err := strconv.ParseInt(fmt.Sprint(oldest[0].Values["seq"]), 10, 64)
+	if err != nil {
+		return nil, false, err
+	}
+	if oldestSeq > seq+1 {
+		return nil, true, nil
+	}
+
+	entries, err := core.AFKBackend.Databases.Redis.XRangeN(ctx, updatesKey, strconv.FormatInt(seq+1, 10)+"-0", "+", count).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	updates := make([]map[string]string, 0, len(entries))
+	for _, entry := range entries {
+		update := make(map[string]string, len(entry.Values))
+		for field, value := range entry.Values {
+			update[field] = fmt.Sprint(value)
+		}
+		updates = append(updates, update)
+	}
+	return updates, false, nil
+}
+
+// GetCanvasPixelColor reads a pixel from a canvas, stored as big-endian bitfields like BITFIELD does
+func GetCanvasPixelColor(canvas []byte, bitWidth uint, position uint) int64 {
+	var color int64
+	offset := position * bitWidth
+	for bit := uint(0); bit < bitWidth; bit++ {
+		byteIdx := (offset + bit) / 8
+		if byteIdx >= uint(len(canvas)) {
+			return 0
+		}
+		bitIdx := 7 - (offset+bit)%8
+		color = color<<1 | int64(canvas[byteIdx]>>bitIdx&1)
+	}
+	return color
+}
+
+// SetCanvasPixelColor writes a pixel to a canvas with the layout of GetCanvasPixelColor
+func SetCanvasPixelColor(canvas []byte, bitWidth uint, position uint, color int64) {
+	offset := position * bitWidth
+	for bit := uint(0); bit < bitWidth; bit++ {
+		byteIdx := (offset + bit) / 8
+		if byteIdx >= uint(len(canvas)) {
+			return
+		}
+		bitIdx := 7 - (offset+bit)%8
+		if color>>(bitWidth-1-bit)&1 == 1 {
+			canvas[byteIdx] |= 1 << bitIdx
+		} else {
+			canvas[byteIdx] &^= 1 << bitIdx
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package routeutils
+
+import "testing"
+
+func TestGetCanvasPixelColor(t *testing.T) {
+	// u5 colors 1, 2, 3, 31 : 00001 00010 00011 11111 ( 0000 padding )
+	canvas := []byte{0x08, 0x87, 0xF0}
+	// u8 colors are the bytes
+	canvas8 := []byte{0x00, 0x2A, 0xFF}
+
+	tests := []struct {
+		canvas   []byte
+		bitWidth uint
+		position uint
+		color    int64
+	}{
+		{canvas: canvas, bitWidth: 5, position: 0, color: 1},
+		{canvas: canvas, bitWidth: 5, position: 1, color: 2},
+		{canvas: canvas, bitWidth: 5, position: 2, color: 3},
+		{canvas: canvas, bitWidth: 5, position: 3, color: 31},
+		// Past the end of the bitfield
+		{canvas: canvas, bitWidth: 5, position: 4, color: 0},
+		{canvas: canvas, bitWidth: 5, position: 100, color: 0},
+		{canvas: canvas8, bitWidth: 8, position: 0, color: 0},
+		{canvas: canvas8, bitWidth: 8, position: 1, color: 42},
+		{canvas: canvas8, bitWidth: 8, position: 2, color: 255},
+		{canvas: nil, bitWidth: 5, position: 0, color: 0},
+	}
+	for _, test := range tests {
+		color := GetCanvasPixelColor(test.canvas, test.bitWidth, test.position)
+		if color != test.color {
+			t.Errorf("u%d position %d of %x: color %d, want %d", test.bitWidth, test.position, test.canvas, color, test.color)
+		}
+	}
+}
+
+func TestSetCanvasPixelColor(t *testing.T) {
+	tests := []struct {
+		bitWidth uint
+		position uint
+		color    int64
+		canvas   []byte
+	}{
+		{bitWidth: 5, position: 0, color: 1, canvas: []byte{0x08, 0x00, 0x00}},
+		{bitWidth: 5, position: 1, color: 2, canvas: []byte{0x00, 0x80, 0x00}},
+		{bitWidth: 5, position: 3, color: 31, canvas: []byte{0x00, 0x01, 0xF0}},
+		// Past the end of the bitfield, nothing is written
+		{bitWidth: 5, position: 5, color: 31, canvas: []byte{0x00, 0x00, 0x00}},
+		{bitWidth: 8, position: 2, color: 42, canvas: []byte{0x00, 0x00, 0x2A}},
+	}
+	for _, test := range tests {
+		canvas := make([]byte, 3)
+		SetCanvasPixelColor(canvas, test.bitWidth, test.position, test.color)
+		if string(canvas) != string(test.canvas) {
+			t.Errorf("u%d position %d color %d: canvas %x, want %x", test.bitWidth, test.position, test.color, canvas, test.canvas)
+		}
+	}
+
+	// Writing over a pixel clears its previous bits and keeps the neighbours
+	canvas := []byte{0x08, 0x87, 0xF0}
+	SetCanvasPixelColor(canvas, 5, 1, 0)
+	SetCanvasPixelColor(canvas, 5, 2, 30)
+	for position, color := range []int64{1, 0, 30, 31} {
+		if got := GetCanvasPixelColor(canvas, 5, uint(position)); got != color {
+			t.Errorf("position %d: color %d, want %d", position, got, color)
+		}
+	}
+}
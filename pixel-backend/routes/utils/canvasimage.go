@@ -0,0 +1,53 @@
+package routeutils
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+type paletteColor struct {
+	ColorKey int64  `json:"colorKey"`
+	Hex      string `json:"hex"`
+}
+
+// CanvasPalette returns the colors of the canvas by color key
+func (h *CanvasHistory) CanvasPalette() (map[int64]color.RGBA, error) {
+	var colors []paletteColor
+	var err error
+	if h.IsWorld {
+		colors, err = core.PostgresQuery[paletteColor]("SELECT color_key, hex FROM WorldsColors WHERE world_id = $1", h.WorldId)
+	} else {
+		colors, err = core.PostgresQuery[paletteColor]("SELECT color_key, hex FROM Colors")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	palette := make(map[int64]color.RGBA, len(colors))
+	for _, c := range colors {
+		// Hex like "rrggbb"
+		value, err := strconv.ParseUint(strings.TrimPrefix(c.Hex, "#"), 16, 32)
+		if err != nil {
+			continue
+		}
+		palette[c.ColorKey] = color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}
+	}
+	return palette, nil
+}
+
+// CanvasImage renders the region of a canvas bitfield, colors missing from the palette are transparent
+func CanvasImage(canvas []byte, canvasWidth uint, region image.Rectangle, palette map[int64]color.RGBA) *image.RGBA {
+	bitWidth := core.AFKBackend.CanvasConfig.ColorsBitWidth
+	img := image.NewRGBA(image.Rect(0, 0, region.Dx(), region.Dy()))
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			colorKey := GetCanvasPixelColor(canvas, bitWidth, uint(y)*canvasWidth+uint(x))
+			img.SetRGBA(x-region.Min.X, y-region.Min.Y, palette[colorKey])
+		}
+	}
+	return img
+}
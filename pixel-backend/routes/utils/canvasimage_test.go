@@ -0,0 +1,71 @@
+package routeutils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+func TestCanvasImage(t *testing.T) {
+	saved := core.AFKBackend
+	core.AFKBackend = &core.Backend{CanvasConfig: &config.CanvasConfig{ColorsBitWidth: 5}}
+	defer func() { core.AFKBackend = saved }()
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	palette := map[int64]color.RGBA{0: white, 1: red, 2: blue}
+
+	// 4x3 canvas, color 9 is missing from the palette
+	const canvasWidth, canvasHeight = 4, 3
+	colors := []int64{
+		0, 1, 2, 0,
+		1, 1, 9, 2,
+		2, 0, 0, 1,
+	}
+	canvas := make([]byte, (canvasWidth*canvasHeight*5+7)/8)
+	for position, c := range colors {
+		SetCanvasPixelColor(canvas, 5, uint(position), c)
+	}
+
+	regions := []image.Rectangle{
+		image.Rect(0, 0, canvasWidth, canvasHeight),
+		image.Rect(1, 1, 3, 3),
+		image.Rect(3, 0, 4, 3),
+		image.Rect(2, 1, 3, 2),
+	}
+	for _, region := range regions {
+		img := CanvasImage(canvas, canvasWidth, region, palette)
+		if img.Bounds() != image.Rect(0, 0, region.Dx(), region.Dy()) {
+			t.Errorf("region %v: bounds %v", region, img.Bounds())
+			continue
+		}
+
+		// The served png decodes to the same pixels
+		var buffer bytes.Buffer
+		if err := png.Encode(&buffer, img); err != nil {
+			t.Fatalf("region %v: Encode: %v", region, err)
+		}
+		decoded, err := png.Decode(&buffer)
+		if err != nil {
+			t.Fatalf("region %v: Decode: %v", region, err)
+		}
+
+		for y := region.Min.Y; y < region.Max.Y; y++ {
+			for x := region.Min.X; x < region.Max.X; x++ {
+				want := palette[colors[y*canvasWidth+x]]
+				if got := img.RGBAAt(x-region.Min.X, y-region.Min.Y); got != want {
+					t.Errorf("region %v: pixel %d,%d is %v, want %v", region, x, y, got, want)
+				}
+				if got := color.RGBAModel.Convert(decoded.At(x-region.Min.X, y-region.Min.Y)); got != want {
+					t.Errorf("region %v: png pixel %d,%d is %v, want %v", region, x, y, got, want)
+				}
+			}
+		}
+	}
+}
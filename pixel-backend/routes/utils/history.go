@@ -0,0 +1,239 @@
+package routeutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Canvases at a point in time are rebuilt from the Pixels / WorldsPixels history, in chain time:
+// a pixel is placed at the timestamp of its block, or at its insertion when the indexer sent no
+// block header. Keyframes of each canvas are stored in CanvasKeyframes so only the pixels of the
+// later blocks are replayed. A keyframe stops at the checkpointed indexer cursor, every block up
+// to it is processed so no pixel of its blocks can be committed afterwards.
+
+const keyframeMinPixels = 2000
+
+type CanvasHistory struct {
+	CanvasKey string
+	IsWorld   bool
+	WorldId   int
+	Width     uint
+	Height    uint
+}
+
+type historyPixel struct {
+	Position int64     `json:"position"`
+	Color    int64     `json:"color"`
+	PlacedAt time.Time `json:"placedAt"`
+}
+
+type canvasKeyframe struct {
+	BlockNumber int64     `json:"blockNumber"`
+	TakenAt     time.Time `json:"takenAt"`
+	Canvas      []byte    `json:"canvas"`
+}
+
+type historyTime struct {
+	At time.Time `json:"at"`
+}
+
+func MainCanvasHistory() *CanvasHistory {
+	canvasConfig := core.AFKBackend.CanvasConfig
+	return &CanvasHistory{
+		CanvasKey: fmt.Sprintf("canvas-%s", canvasConfig.Round),
+		Width:     canvasConfig.Canvas.Width,
+		Height:    canvasConfig.Canvas.Height,
+	}
+}
+
+func WorldCanvasHistory(worldId int) (*CanvasHistory, error) {
+	type worldSize struct {
+		Width  uint `json:"width"`
+		Height uint `json:"height"`
+	}
+	world, err := core.PostgresQueryOne[worldSize]("SELECT width, height FROM Worlds WHERE world_id = $1", worldId)
+	if err != nil {
+		return nil, err
+	}
+	return &CanvasHistory{
+		CanvasKey: "canvas-" + strconv.Itoa(worldId),
+		IsWorld:   true,
+		WorldId:   worldId,
+		Width:     world.Width,
+		Height:    world.Height,
+	}, nil
+}
+
+// HistoryTimeFromUnix converts unix seconds to the postgres clock
+func HistoryTimeFromUnix(seconds int64) (time.Time, error) {
+	at, err := core.PostgresQueryOne[historyTime]("SELECT to_timestamp($1)::timestamp AS at", seconds)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return at.At, nil
+}
+
+// HistoryTimeAtDayEnd returns the start of the next day, or now for the current day
+func HistoryTimeAtDayEnd(day int) (time.Time, error) {
+	at, err := core.PostgresQuery[historyTime]("SELECT day_start AS at FROM Days WHERE day_index = $1 ORDER BY day_start ASC LIMIT 1", day+1)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(at) > 0 {
+		return at[0].At, nil
+	}
+	now, err := core.PostgresQueryOne[historyTime]("SELECT CURRENT_TIMESTAMP::timestamp AS at")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.At, nil
+}
+
+// pixelsQuery selects the pixels of the blocks after $1 matching the condition, in chain order
+func (h *CanvasHistory) pixelsQuery(condition string) string {
+	columns := "SELECT position, color, COALESCE(block_time, time) AS placed_at"
+	order := " ORDER BY block_number ASC, key ASC"
+	if h.IsWorld {
+		return columns + " FROM WorldsPixels WHERE world_id = " + strconv.Itoa(h.WorldId) + " AND block_number > $1 AND " + condition + order
+	}
+	return columns + " FROM Pixels WHERE block_number > $1 AND " + condition + order
+}
+
+func (h *CanvasHistory) byteSize() uint {
+	totalBitSize := h.Width * h.Height * core.AFKBackend.CanvasConfig.ColorsBitWidth
+	totalByteSize := totalBitSize / 8
+	if totalBitSize%8 != 0 {
+		totalByteSize += 1
+	}
+	return totalByteSize
+}
+
+// replay applies the pixels of the blocks after the keyframe matching the condition on $2, and
+// returns the canvas with the time of its latest pixel
+func (h *CanvasHistory) replay(keyframe *canvasKeyframe, condition string, arg interface{}) ([]byte, time.Time, int, error) {
+	canvas := make([]byte, h.byteSize())
+	fromBlock := int64(-1)
+	placedAt := time.Time{}
+	if keyframe != nil {
+		copy(canvas, keyframe.Canvas)
+		fromBlock = keyframe.BlockNumber
+		placedAt = keyframe.TakenAt
+	}
+
+	pixels, err := core.PostgresQuery[historyPixel](h.pixelsQuery(condition), fromBlock, arg)
+	if err != nil {
+		return nil, placedAt, 0, err
+	}
+	bitWidth := core.AFKBackend.CanvasConfig.ColorsBitWidth
+	size := int64(h.Width * h.Height)
+	for _, pixel := range pixels {
+		if pixel.PlacedAt.After(placedAt) {
+			placedAt = pixel.PlacedAt
+		}
+		if pixel.Position < 0 || pixel.Position >= size {
+			continue
+		}
+		SetCanvasPixelColor(canvas, bitWidth, uint(pixel.Position), pixel.Color)
+	}
+	return canvas, placedAt, len(pixels), nil
+}
+
+// latestKeyframe returns the keyframe with the highest block matching the condition on $2, nil if none
+func (h *CanvasHistory) latestKeyframe(condition string, arg interface{}) (*canvasKeyframe, error) {
+	keyframes, err := core.PostgresQuery[canvasKeyframe]("SELECT block_number, taken_at, canvas FROM CanvasKeyframes WHERE canvas_key = $1 AND "+condition+" ORDER BY block_number DESC LIMIT 1", h.CanvasKey, arg)
+	if err != nil || len(keyframes) == 0 {
+		return nil, err
+	}
+	return &keyframes[0], nil
+}
+
+// CanvasAt returns the canvas bitfield as it was at the time
+func (h *CanvasHistory) CanvasAt(at time.Time) ([]byte, error) {
+	keyframe, err := h.latestKeyframe("taken_at <= $2", at)
+	if err != nil {
+		return nil, err
+	}
+	canvas, _, _, err := h.replay(keyframe, "COALESCE(block_time, time) <= $2", at)
+	return canvas, err
+}
+
+// CreateKeyframe stores a keyframe up to the checkpointed indexer cursor when enough pixels
+// were placed since the last one
+func (h *CanvasHistory) CreateKeyframe() (bool, error) {
+	cursors, err := core.PostgresQuery[int64]("SELECT order_key FROM IndexerCursor WHERE id = 1")
+	if err != nil || len(cursors) == 0 {
+		return false, err
+	}
+	cutoff := cursors[0]
+
+	keyframe, err := h.latestKeyframe("block_number <= $2", cutoff)
+	if err != nil {
+		return false, err
+	}
+	canvas, takenAt, replayed, err := h.replay(keyframe, "block_number <= $2", cutoff)
+	if err != nil {
+		return false, err
+	}
+	if replayed < keyframeMinPixels {
+		return false, nil
+	}
+
+	_, err = core.AFKBackend.Databases.Postgres.Exec(context.Background(), "INSERT INTO CanvasKeyframes (canvas_key, block_number, taken_at, canvas) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING", h.CanvasKey, cutoff, takenAt, canvas)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CreateCanvasKeyframes runs CreateKeyframe on the main canvas and every world canvas
+func CreateCanvasKeyframes() (int, error) {
+	histories := []*CanvasHistory{MainCanvasHistory()}
+	worldIds, err := core.PostgresQuery[int]("SELECT world_id FROM Worlds ORDER BY world_id ASC")
+	if err != nil {
+		return 0, err
+	}
+	for _, worldId := range worldIds {
+		history, err := WorldCanvasHistory(worldId)
+		if err != nil {
+			return 0, err
+		}
+		histories = append(histories, history)
+	}
+
+	created := 0
+	for _, history := range histories {
+		ok, err := history.CreateKeyframe()
+		if err != nil {
+			return created, fmt.Errorf("keyframe for %s: %w", history.CanvasKey, err)
+		}
+		if ok {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// HistoryTimeFromRequest reads the time query param ( unix seconds ) or the day param ( end of
+// the day index )
+func HistoryTimeFromRequest(r *http.Request) (time.Time, error) {
+	if timeParam := r.URL.Query().Get("time"); timeParam != "" {
+		seconds, err := strconv.ParseInt(timeParam, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %s", timeParam)
+		}
+		return HistoryTimeFromUnix(seconds)
+	}
+	if dayParam := r.URL.Query().Get("day"); dayParam != "" {
+		day, err := strconv.Atoi(dayParam)
+		if err != nil || day < 0 {
+			return time.Time{}, fmt.Errorf("invalid day %s", dayParam)
+		}
+		return HistoryTimeAtDayEnd(day)
+	}
+	return time.Time{}, fmt.Errorf("missing time or day")
+}
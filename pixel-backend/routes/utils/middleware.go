@@ -1,14 +1,24 @@
 package routeutils
 
 import (
+	"bytes"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 )
 
 // Middleware functions for routes
 // Return true if middleware stops the request
 
+// Admin payloads are kept in the audit log, bound them
+const maxAdminBodySize = 10 << 20 // 10 MB
+
 func NonProductionMiddleware(w http.ResponseWriter, r *http.Request) bool {
 	if core.AFKBackend.BackendConfig.Production {
 		WriteErrorJson(w, http.StatusNotImplemented, "Route is disabled in production")
@@ -18,17 +28,94 @@ func NonProductionMiddleware(w http.ResponseWriter, r *http.Request) bool {
 	return false
 }
 
+func GetBearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// AuthMiddleware validates the session token and injects the caller's address into the request context
 func AuthMiddleware(w http.ResponseWriter, r *http.Request) bool {
-	// TODO: Implement authentication
+	token := GetBearerToken(r)
+	if token == "" {
+		WriteErrorJson(w, http.StatusUnauthorized, "Missing authorization token")
+		return true
+	}
+
+	address, err := auth.GetSessionAddress(token)
+	if err != nil {
+		WriteErrorJson(w, http.StatusUnauthorized, "Invalid or expired session")
+		return true
+	}
+
+	*r = *r.WithContext(auth.WithAddress(r.Context(), address))
 	return false
 }
 
-func AdminMiddleware(w http.ResponseWriter, r *http.Request) bool {
-	// TODO: Implement admin authentication
-	if core.AFKBackend.AdminMode {
-		return false
-	} else {
+// GetAuthAddress returns the caller's address, only valid after AuthMiddleware
+func GetAuthAddress(r *http.Request) string {
+	address, _ := auth.AddressFromContext(r.Context())
+	return address
+}
+
+// Rejected admin calls by reason, exposed on /debug/vars
+var AdminRejectedCalls = expvar.NewMap("admin_rejected_calls")
+
+// AdminMiddleware requires admin mode and an X-Admin-Key with the given scope.
+// Authorized calls are written to the admin audit log with their payload, rejected
+// calls are only counted and logged without it so unauthenticated clients cannot fill the log
+func AdminMiddleware(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if !core.AFKBackend.AdminMode {
 		WriteErrorJson(w, http.StatusUnauthorized, "Admin is required")
 		return true
 	}
+
+	call := auth.AdminCall{
+		Scope:  scope,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+	}
+
+	adminKey, err := auth.AuthenticateAdminKey(r.Header.Get("X-Admin-Key"))
+	if err != nil {
+		AdminRejectedCalls.Add("invalid_key", 1)
+		fmt.Println("Rejected admin call with an invalid key:", r.Method, r.URL.Path)
+		WriteErrorJson(w, http.StatusUnauthorized, "Invalid admin key")
+		return true
+	}
+
+	call.KeyId = adminKey.KeyId
+	if !adminKey.HasScope(scope) {
+		AdminRejectedCalls.Add("missing_scope", 1)
+		fmt.Println("Rejected admin call of key", adminKey.KeyId, "missing scope", scope+":", r.Method, r.URL.Path)
+		WriteErrorJson(w, http.StatusForbidden, "Admin key is missing scope "+scope)
+		return true
+	}
+
+	// Read the payload for the audit log and restore it for the handler
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxAdminBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			WriteErrorJson(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return true
+		}
+		WriteErrorJson(w, http.StatusBadRequest, "Failed to read request body")
+		return true
+	}
+	r.Body = io.NopCloser(bytes.NewReader(payload))
+
+	call.Payload = payload
+	call.Authorized = true
+	if err := auth.RecordAdminCall(call); err != nil {
+		fmt.Println("Failed to record admin call:", err)
+		WriteErrorJson(w, http.StatusInternalServerError, "Failed to record admin call")
+		return true
+	}
+
+	return false
 }
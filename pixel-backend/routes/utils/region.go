@@ -0,0 +1,123 @@
+package routeutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Rectangular reads of a canvas bitfield: each row of the region is a contiguous bit range,
+// read with one GETRANGE per row in a single transaction.
+
+const MaxCanvasRegionArea = 256 * 256
+
+var ErrCanvasNotFound = errors.New("canvas not found")
+
+// ParseCanvasRegion reads the x, y, w and h query params and checks the region fits the canvas
+func ParseCanvasRegion(r *http.Request, canvasWidth uint, canvasHeight uint) (image.Rectangle, error) {
+	params := make(map[string]int, 4)
+	for _, name := range []string{"x", "y", "w", "h"} {
+		value, err := strconv.Atoi(r.URL.Query().Get(name))
+		if err != nil || value < 0 {
+			return image.Rectangle{}, fmt.Errorf("invalid %s", name)
+		}
+		params[name] = value
+	}
+
+	region := image.Rect(params["x"], params["y"], params["x"]+params["w"], params["y"]+params["h"])
+	if err := CheckCanvasRegion(region, canvasWidth, canvasHeight); err != nil {
+		return region, err
+	}
+	if region.Dx()*region.Dy() > MaxCanvasRegionArea {
+		return region, fmt.Errorf("region larger than %d pixels", MaxCanvasRegionArea)
+	}
+	return region, nil
+}
+
+// CheckCanvasRegion checks the region is not empty and fits the canvas
+func CheckCanvasRegion(region image.Rectangle, canvasWidth uint, canvasHeight uint) error {
+	if region.Empty() {
+		return fmt.Errorf("empty region")
+	}
+	if !region.In(image.Rect(0, 0, int(canvasWidth), int(canvasHeight))) {
+		return fmt.Errorf("region outside of the canvas")
+	}
+	return nil
+}
+
+// GetCanvasRegion returns the colors of the region row by row, or ErrCanvasNotFound when the
+// canvas key is missing ( GETRANGE reads it as empty )
+func GetCanvasRegion(ctx context.Context, canvasKey string, canvasWidth uint, region image.Rectangle) ([]int64, error) {
+	bitWidth := core.AFKBackend.CanvasConfig.ColorsBitWidth
+	rowBits := uint(region.Dx()) * bitWidth
+
+	var exists *redis.IntCmd
+	rows := make([]*redis.StringCmd, region.Dy())
+	_, err := core.AFKBackend.Databases.Redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		exists = pipe.Exists(ctx, canvasKey)
+		for row := range rows {
+			startBit := (uint(region.Min.Y+row)*canvasWidth + uint(region.Min.X)) * bitWidth
+			endBit := startBit + rowBits - 1
+			rows[row] = pipe.GetRange(ctx, canvasKey, int64(startBit/8), int64(endBit/8))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if exists.Val() == 0 {
+		return nil, ErrCanvasNotFound
+	}
+
+	colors := make([]int64, 0, region.Dx()*region.Dy())
+	for row, cmd := range rows {
+		bytes, err := cmd.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		startBit := (uint(region.Min.Y+row)*canvasWidth + uint(region.Min.X)) * bitWidth
+		colors = appendRowColors(colors, bytes, startBit, region.Dx(), bitWidth)
+	}
+	return colors, nil
+}
+
+// appendRowColors reads the colors of a row from the bytes of its GETRANGE, starting at the
+// byte holding startBit
+func appendRowColors(colors []int64, bytes []byte, startBit uint, width int, bitWidth uint) []int64 {
+	// The row starts inside its first byte
+	firstPixelBit := startBit % 8
+	for x := 0; x < width; x++ {
+		colors = append(colors, readBits(bytes, firstPixelBit+uint(x)*bitWidth, bitWidth))
+	}
+	return colors
+}
+
+// readBits reads a big-endian value of width bits at the bit offset, missing bytes read as 0
+func readBits(bytes []byte, offset uint, width uint) int64 {
+	var value int64
+	for bit := uint(0); bit < width; bit++ {
+		byteIdx := (offset + bit) / 8
+		value <<= 1
+		if byteIdx < uint(len(bytes)) {
+			value |= int64(bytes[byteIdx] >> (7 - (offset+bit)%8) & 1)
+		}
+	}
+	return value
+}
+
+// PackCanvasColors packs colors into a bitfield, laid out like the canvas
+func PackCanvasColors(colors []int64, bitWidth uint) []byte {
+	totalBitSize := uint(len(colors)) * bitWidth
+	packed := make([]byte, (totalBitSize+7)/8)
+	for idx, color := range colors {
+		SetCanvasPixelColor(packed, bitWidth, uint(idx), color)
+	}
+	return packed
+}
@@ -0,0 +1,116 @@
+package routeutils
+
+import (
+	"image"
+	"net/http/httptest"
+	"testing"
+)
+
+// canvasRegionColors reads the region from a canvas bitfield like GetCanvasRegion does from
+// the GETRANGE of each row
+func canvasRegionColors(canvas []byte, canvasWidth uint, region image.Rectangle, bitWidth uint) []int64 {
+	var colors []int64
+	for row := 0; row < region.Dy(); row++ {
+		startBit := (uint(region.Min.Y+row)*canvasWidth + uint(region.Min.X)) * bitWidth
+		endBit := startBit + uint(region.Dx())*bitWidth - 1
+		end := min(endBit/8+1, uint(len(canvas)))
+		colors = appendRowColors(colors, canvas[startBit/8:end], startBit, region.Dx(), bitWidth)
+	}
+	return colors
+}
+
+func TestCanvasRegionNotByteAligned(t *testing.T) {
+	const canvasWidth, canvasHeight = 13, 7
+	// 5 and 3 bit colors never line up with the bytes, 8 always does
+	for _, bitWidth := range []uint{3, 5, 8} {
+		canvas := make([]byte, (canvasWidth*canvasHeight*bitWidth+7)/8)
+		mask := int64(1)<<bitWidth - 1
+		for position := uint(0); position < canvasWidth*canvasHeight; position++ {
+			SetCanvasPixelColor(canvas, bitWidth, position, int64(position*7+3)&mask)
+		}
+
+		regions := []image.Rectangle{
+			image.Rect(0, 0, canvasWidth, canvasHeight),
+			image.Rect(1, 1, 4, 3),
+			image.Rect(12, 0, 13, 7), // last column
+			image.Rect(5, 6, 13, 7),  // last pixels of the canvas
+			image.Rect(3, 2, 4, 3),
+		}
+		for _, region := range regions {
+			colors := canvasRegionColors(canvas, canvasWidth, region, bitWidth)
+			if len(colors) != region.Dx()*region.Dy() {
+				t.Fatalf("u%d %v: %d colors, want %d", bitWidth, region, len(colors), region.Dx()*region.Dy())
+			}
+			for idx, color := range colors {
+				x := region.Min.X + idx%region.Dx()
+				y := region.Min.Y + idx/region.Dx()
+				want := GetCanvasPixelColor(canvas, bitWidth, uint(y*canvasWidth+x))
+				if color != want {
+					t.Errorf("u%d %v: pixel (%d, %d) = %d, want %d", bitWidth, region, x, y, color, want)
+				}
+			}
+
+			// The packed region reads back like a canvas of the region width
+			packed := PackCanvasColors(colors, bitWidth)
+			for idx, color := range colors {
+				if got := GetCanvasPixelColor(packed, bitWidth, uint(idx)); got != color {
+					t.Errorf("u%d %v: packed pixel %d = %d, want %d", bitWidth, region, idx, got, color)
+				}
+			}
+		}
+	}
+}
+
+func TestReadBitsPastTheEnd(t *testing.T) {
+	// GETRANGE returns less than asked past the end of the key
+	if value := readBits([]byte{0xff}, 6, 5); value != 0b11000 {
+		t.Errorf("readBits = %05b, want 11000", value)
+	}
+	if value := readBits(nil, 0, 5); value != 0 {
+		t.Errorf("readBits of no bytes = %d, want 0", value)
+	}
+}
+
+func TestParseCanvasRegion(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    image.Rectangle
+		wantErr bool
+	}{
+		{query: "x=0&y=0&w=10&h=10", want: image.Rect(0, 0, 10, 10)},
+		{query: "x=90&y=40&w=10&h=10", want: image.Rect(90, 40, 100, 50)},
+		{query: "x=91&y=0&w=10&h=1", wantErr: true},
+		{query: "x=0&y=41&w=1&h=10", wantErr: true},
+		{query: "x=0&y=0&w=0&h=10", wantErr: true},
+		{query: "x=-1&y=0&w=1&h=1", wantErr: true},
+		{query: "x=0&y=0&w=1", wantErr: true},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest("GET", "/get-canvas-region?"+test.query, nil)
+		region, err := ParseCanvasRegion(request, 100, 50)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.query, region)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+		} else if region != test.want {
+			t.Errorf("%s: region = %v, want %v", test.query, region, test.want)
+		}
+	}
+
+	big := httptest.NewRequest("GET", "/get-canvas-region?x=0&y=0&w=512&h=512", nil)
+	if _, err := ParseCanvasRegion(big, 1024, 1024); err == nil {
+		t.Errorf("expected an error for a region over MaxCanvasRegionArea")
+	}
+}
+
+func TestCheckCanvasRegionNegativeSize(t *testing.T) {
+	region := image.Rectangle{Min: image.Pt(10, 10), Max: image.Pt(5, 12)}
+	if err := CheckCanvasRegion(region, 100, 100); err == nil {
+		t.Errorf("expected an error for a negative width")
+	}
+}
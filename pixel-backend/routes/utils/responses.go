@@ -1,13 +1,14 @@
 package routeutils
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
-	"github.com/gorilla/websocket"
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/config"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 )
 
@@ -15,10 +16,10 @@ import (
 func SetupAccessHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-CSRF-Token, X-Requested-With")
+	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, Authorization, X-CSRF-Token, X-Requested-With, X-Admin-Key")
 	w.Header().Set("Access-Control-Max-Age", "3600")
 	w.Header().Set("Referrer-Policy", "no-referrer-when-downgrade")
-	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, X-Canvas-Sequence")
 }
 
 // SetupHeaders sets up response headers
@@ -63,53 +64,93 @@ func SendWebSocketMessage(message map[string]string) {
 		fmt.Println("Failed to marshal websocket message")
 		return
 	}
-	core.AFKBackend.WSConnectionsLock.Lock()
-	for idx, conn := range core.AFKBackend.WSConnections {
-		if err := conn.WriteMessage(websocket.TextMessage, messageBytes); err != nil {
-			fmt.Println(err)
-			// Remove problematic connection
-			conn.Close()
-			if idx < len(core.AFKBackend.WSConnections) {
-				core.AFKBackend.WSConnections = append(core.AFKBackend.WSConnections[:idx], core.AFKBackend.WSConnections[idx+1:]...)
-			} else {
-				core.AFKBackend.WSConnections = core.AFKBackend.WSConnections[:idx]
-			}
+	for _, client := range core.AFKBackend.WSHub.Clients() {
+		if client.Wants(message["worldId"], message["messageType"]) {
+			client.Send(messageBytes)
 		}
 	}
-	core.AFKBackend.WSConnectionsLock.Unlock()
 }
 
+// SendWebSocketMessages sends each client the messages matching its subscriptions
 func SendWebSocketMessages(messages []map[string]string) {
-	messageBytes, err := json.Marshal(messages)
+	for _, client := range core.AFKBackend.WSHub.Clients() {
+		clientMessages := make([]map[string]string, 0, len(messages))
+		for _, message := range messages {
+			if client.Wants(message["worldId"], message["messageType"]) {
+				clientMessages = append(clientMessages, message)
+			}
+		}
+		if len(clientMessages) == 0 {
+			continue
+		}
+
+		messageBytes, err := json.Marshal(clientMessages)
+		if err != nil {
+			fmt.Println("Failed to marshal websocket message")
+			return
+		}
+		client.Send(messageBytes)
+	}
+}
+
+// Redis pub/sub channel every web-sockets replica subscribes to
+const WsMessagesChannel = "ws-messages"
+
+// SendMessageToWSS forwards a message from the consumer to the web-sockets servers
+func SendMessageToWSS(message map[string]string) {
+	if core.AFKBackend.BackendConfig.WebSocket.Transport == config.WsTransportRedis {
+		publishMessageToWSS(message)
+		return
+	}
+	postMessageToWSS(message)
+}
+
+func publishMessageToWSS(message map[string]string) {
+	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		fmt.Println("Failed to marshal websocket message")
 		return
 	}
-	core.AFKBackend.WSConnectionsLock.Lock()
-	for idx, conn := range core.AFKBackend.WSConnections {
-		if err := conn.WriteMessage(websocket.TextMessage, messageBytes); err != nil {
-			fmt.Println(err)
-			// Remove problematic connection
-			conn.Close()
-			if idx < len(core.AFKBackend.WSConnections) {
-				core.AFKBackend.WSConnections = append(core.AFKBackend.WSConnections[:idx], core.AFKBackend.WSConnections[idx+1:]...)
-			} else {
-				core.AFKBackend.WSConnections = core.AFKBackend.WSConnections[:idx]
-			}
-		}
+	envelope, err := SignWsMessage(messageBytes)
+	if err != nil {
+		fmt.Println("Failed to sign websocket message", err)
+		return
+	}
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		fmt.Println("Failed to marshal websocket message envelope")
+		return
+	}
+	err = core.AFKBackend.Databases.Redis.Publish(context.Background(), WsMessagesChannel, envelopeBytes).Err()
+	if err != nil {
+		fmt.Println("Failed to publish message to websocket servers", err)
 	}
-	core.AFKBackend.WSConnectionsLock.Unlock()
 }
 
-func SendMessageToWSS(message map[string]string) {
+func postMessageToWSS(message map[string]string) {
 	websocketHost := core.AFKBackend.BackendConfig.WsHost + ":" + strconv.Itoa(core.AFKBackend.BackendConfig.WsPort) + "/ws-msg"
 	messageBytes, err := json.Marshal(message)
 	if err != nil {
 		fmt.Println("Failed to marshal websocket message")
 		return
 	}
-	_, err = http.Post("http://"+websocketHost, "application/json", strings.NewReader(string(messageBytes)))
+	envelope, err := SignWsMessage(messageBytes)
+	if err != nil {
+		fmt.Println("Failed to sign websocket message", err)
+		return
+	}
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		fmt.Println("Failed to marshal websocket message envelope")
+		return
+	}
+	resp, err := http.Post("http://"+websocketHost, "application/json", strings.NewReader(string(envelopeBytes)))
 	if err != nil {
 		fmt.Println("Failed to send message to websocket server", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Println("Websocket server rejected message with status", resp.StatusCode)
 	}
 }
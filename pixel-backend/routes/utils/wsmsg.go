@@ -0,0 +1,182 @@
+package routeutils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+)
+
+// Messages from the consumer to the web-sockets process ( /ws-msg or the Redis channel ) are wrapped
+// in an envelope signed with HMAC-SHA256, using the WS_MSG_SECRET env var shared by both processes.
+// The timestamp bounds how long an envelope is valid and the nonce can only be used once.
+
+const (
+	wsMsgMaxAge = 30 * time.Second
+)
+
+// Rejected websocket message envelopes by reason, exposed on /debug/vars
+var WsMsgRejected = expvar.NewMap("ws_msg_rejected")
+
+type WsMsgEnvelope struct {
+	Timestamp int64           `json:"timestamp"`
+	Nonce     string          `json:"nonce"`
+	Message   json.RawMessage `json:"message"`
+	Signature string          `json:"signature"`
+}
+
+func wsMsgSecret() ([]byte, error) {
+	secret := os.Getenv("WS_MSG_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("WS_MSG_SECRET is not set")
+	}
+	return []byte(secret), nil
+}
+
+func wsMsgNonceKey(nonce string) string {
+	return fmt.Sprintf("ws-msg-nonce-%s", nonce)
+}
+
+func wsMsgSignature(secret []byte, timestamp int64, nonce string, message []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// SignWsMessage wraps a message in a signed envelope for /ws-msg or the Redis channel
+func SignWsMessage(message []byte) (*WsMsgEnvelope, error) {
+	secret, err := wsMsgSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return nil, err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := time.Now().Unix()
+
+	return &WsMsgEnvelope{
+		Timestamp: timestamp,
+		Nonce:     nonce,
+		Message:   message,
+		Signature: hex.EncodeToString(wsMsgSignature(secret, timestamp, nonce, message)),
+	}, nil
+}
+
+func rejectWsMsg(reason string, err error) error {
+	WsMsgRejected.Add(reason, 1)
+	return err
+}
+
+// verifyWsMsgEnvelope checks the signature and age of an envelope and decodes its message,
+// the nonce is left to the caller
+func verifyWsMsgEnvelope(secret []byte, body []byte, now time.Time) (*WsMsgEnvelope, map[string]string, error) {
+	envelope := WsMsgEnvelope{}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, nil, rejectWsMsg("malformed", fmt.Errorf("invalid envelope: %w", err))
+	}
+	if envelope.Signature == "" || envelope.Nonce == "" || len(envelope.Message) == 0 {
+		return nil, nil, rejectWsMsg("unsigned", fmt.Errorf("unsigned message"))
+	}
+
+	signature, err := hex.DecodeString(envelope.Signature)
+	if err != nil || !hmac.Equal(signature, wsMsgSignature(secret, envelope.Timestamp, envelope.Nonce, envelope.Message)) {
+		return nil, nil, rejectWsMsg("bad_signature", fmt.Errorf("invalid signature"))
+	}
+
+	age := now.Sub(time.Unix(envelope.Timestamp, 0))
+	if age > wsMsgMaxAge || age < -wsMsgMaxAge {
+		return nil, nil, rejectWsMsg("stale", fmt.Errorf("stale message"))
+	}
+
+	message := make(map[string]string)
+	if err := json.Unmarshal(envelope.Message, &message); err != nil {
+		return nil, nil, rejectWsMsg("malformed", fmt.Errorf("invalid message: %w", err))
+	}
+	return &envelope, message, nil
+}
+
+// OpenWsMsgEnvelope verifies a signed envelope posted to /ws-msg and returns the message it carries
+func OpenWsMsgEnvelope(body []byte) (map[string]string, error) {
+	secret, err := wsMsgSecret()
+	if err != nil {
+		return nil, rejectWsMsg("no_secret", err)
+	}
+
+	envelope, message, err := verifyWsMsgEnvelope(secret, body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the nonce for the whole window the timestamp is accepted in
+	fresh, err := core.AFKBackend.Databases.Redis.SetNX(context.Background(), wsMsgNonceKey(envelope.Nonce), envelope.Timestamp, 2*wsMsgMaxAge).Result()
+	if err != nil {
+		return nil, rejectWsMsg("nonce_store", err)
+	}
+	if !fresh {
+		return nil, rejectWsMsg("replayed", fmt.Errorf("replayed message"))
+	}
+
+	return message, nil
+}
+
+// Published envelopes reach every web-sockets replica, so their nonces are kept per process
+// instead of in Redis, where only the first replica could claim them
+type wsMsgNonces struct {
+	seen map[string]time.Time
+	lock sync.Mutex
+}
+
+var publishedWsMsgNonces = &wsMsgNonces{seen: make(map[string]time.Time)}
+
+// claim returns false when the nonce was already seen in the accepted window
+func (n *wsMsgNonces) claim(nonce string, now time.Time) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for seenNonce, seenAt := range n.seen {
+		if now.Sub(seenAt) > 2*wsMsgMaxAge {
+			delete(n.seen, seenNonce)
+		}
+	}
+	if _, ok := n.seen[nonce]; ok {
+		return false
+	}
+	n.seen[nonce] = now
+	return true
+}
+
+// OpenPublishedWsMsg verifies a signed envelope received on the Redis channel
+func OpenPublishedWsMsg(payload []byte) (map[string]string, error) {
+	secret, err := wsMsgSecret()
+	if err != nil {
+		return nil, rejectWsMsg("no_secret", err)
+	}
+
+	now := time.Now()
+	envelope, message, err := verifyWsMsgEnvelope(secret, payload, now)
+	if err != nil {
+		return nil, err
+	}
+	if !publishedWsMsgNonces.claim(envelope.Nonce, now) {
+		return nil, rejectWsMsg("replayed", fmt.Errorf("replayed message"))
+	}
+
+	return message, nil
+}
@@ -0,0 +1,87 @@
+package routeutils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func signedEnvelope(t *testing.T, message string) []byte {
+	t.Helper()
+	envelope, err := SignWsMessage([]byte(message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelopeBytes, err := json.Marshal(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return envelopeBytes
+}
+
+func TestVerifyWsMsgEnvelope(t *testing.T) {
+	t.Setenv("WS_MSG_SECRET", "test-secret")
+	secret := []byte("test-secret")
+	now := time.Now()
+
+	body := signedEnvelope(t, `{"messageType":"colorPixel","position":"12","color":"3"}`)
+	_, message, err := verifyWsMsgEnvelope(secret, body, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message["position"] != "12" || message["color"] != "3" {
+		t.Errorf("message = %v", message)
+	}
+
+	tampered := WsMsgEnvelope{}
+	if err := json.Unmarshal(body, &tampered); err != nil {
+		t.Fatal(err)
+	}
+	tampered.Message = json.RawMessage(`{"messageType":"colorPixel","position":"12","color":"4"}`)
+	tamperedBytes, _ := json.Marshal(tampered)
+
+	unsigned := tampered
+	unsigned.Signature = ""
+	unsignedBytes, _ := json.Marshal(unsigned)
+
+	tests := []struct {
+		name   string
+		secret []byte
+		body   []byte
+		now    time.Time
+	}{
+		{"raw message", secret, []byte(`{"messageType":"colorPixel"}`), now},
+		{"unsigned", secret, unsignedBytes, now},
+		{"tampered message", secret, tamperedBytes, now},
+		{"other secret", []byte("other-secret"), body, now},
+		{"stale", secret, body, now.Add(2 * wsMsgMaxAge)},
+		{"from the future", secret, body, now.Add(-2 * wsMsgMaxAge)},
+	}
+	for _, test := range tests {
+		if _, _, err := verifyWsMsgEnvelope(test.secret, test.body, test.now); err == nil {
+			t.Errorf("%s: envelope accepted", test.name)
+		}
+	}
+}
+
+func TestWsMsgNoncesClaim(t *testing.T) {
+	nonces := &wsMsgNonces{seen: make(map[string]time.Time)}
+	now := time.Now()
+
+	if !nonces.claim("a", now) {
+		t.Fatal("first claim rejected")
+	}
+	if nonces.claim("a", now.Add(time.Second)) {
+		t.Error("replayed nonce accepted")
+	}
+	if !nonces.claim("b", now) {
+		t.Error("other nonce rejected")
+	}
+	// Past the accepted window the nonce is forgotten, the timestamp check rejects the envelope
+	if !nonces.claim("a", now.Add(3*wsMsgMaxAge)) {
+		t.Error("expired nonce kept")
+	}
+	if len(nonces.seen) != 1 {
+		t.Errorf("%d nonces kept, want 1", len(nonces.seen))
+	}
+}
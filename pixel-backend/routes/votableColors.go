@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"strconv"
 
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/auth"
 	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
 	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
 )
@@ -27,7 +28,7 @@ func InitVotableColorsRoutes() {
 
 func InitVotableColors(w http.ResponseWriter, r *http.Request) {
 	// Only allow admin to initialize votable colors
-	if routeutils.AdminMiddleware(w, r) {
+	if routeutils.AdminMiddleware(w, r, auth.ScopeCanvasWrite) {
 		return
 	}
 
@@ -1,8 +1,13 @@
 package routes
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -12,21 +17,39 @@ import (
 )
 
 var WsMsgPool []map[string]string
+var wsMsgPoolLock sync.Mutex
+
+func addWsMsg(msg map[string]string) {
+	wsMsgPoolLock.Lock()
+	WsMsgPool = append(WsMsgPool, msg)
+	wsMsgPoolLock.Unlock()
+}
 
 func InitWebsocketRoutes() {
 	http.HandleFunc("/ws", wsEndpoint)
 	http.HandleFunc("/ws-msg", wsMsgEndpoint)
 }
 
+// A signed envelope of a batch of pixels stays far below it
+const maxWsMsgBodySize = 1 << 20 // 1 MB
+
 func wsMsgEndpoint(w http.ResponseWriter, r *http.Request) {
-	// TODO: Only allow consumer to send messages
-	msg, err := routeutils.ReadJsonBody[map[string]string](r)
+	// Bounded, the body is read before the signature can be checked
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWsMsgBodySize))
 	if err != nil {
 		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	WsMsgPool = append(WsMsgPool, *msg)
+	// Only the consumer holds the shared secret to sign messages
+	msg, err := routeutils.OpenWsMsgEnvelope(body)
+	if err != nil {
+		fmt.Println("Rejected WS message:", err)
+		routeutils.WriteErrorJson(w, http.StatusUnauthorized, "Invalid WS message envelope")
+		return
+	}
+
+	addWsMsg(msg)
 	routeutils.WriteResultJson(w, "WS message added to queue")
 }
 
@@ -34,24 +57,167 @@ func StartWebsocketServer() {
 	// Send all messages in the pool every 5 seconds
 	timer := 5
 	for {
+		wsMsgPoolLock.Lock()
 		msgPoolCopy := make([]map[string]string, len(WsMsgPool))
 		copy(msgPoolCopy, WsMsgPool)
 		WsMsgPool = WsMsgPool[:0]
-		routeutils.SendWebSocketMessages(msgPoolCopy)
+		wsMsgPoolLock.Unlock()
+		if len(msgPoolCopy) > 0 {
+			routeutils.SendWebSocketMessages(msgPoolCopy)
+		}
 		time.Sleep(time.Duration(timer) * time.Second)
 	}
 }
 
-func wsReader(conn *websocket.Conn) {
+const (
+	wsSubscribeMinBackoff = time.Second
+	wsSubscribeMaxBackoff = 30 * time.Second
+)
+
+// StartWebsocketSubscriber queues the messages the consumer publishes on Redis,
+// every replica receives every message. The first subscription is retried until Redis is up,
+// the channel reconnects on its own after that.
+func StartWebsocketSubscriber() {
+	ctx := context.Background()
+
+	backoff := wsSubscribeMinBackoff
+	for {
+		pubsub := core.AFKBackend.Databases.Redis.Subscribe(ctx, routeutils.WsMessagesChannel)
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			fmt.Println("Failed to subscribe to websocket messages, retrying in", backoff, err)
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > wsSubscribeMaxBackoff {
+				backoff = wsSubscribeMaxBackoff
+			}
+			continue
+		}
+		fmt.Println("Subscribed to websocket messages on", routeutils.WsMessagesChannel)
+
+		for redisMsg := range pubsub.Channel() {
+			msg, err := routeutils.OpenPublishedWsMsg([]byte(redisMsg.Payload))
+			if err != nil {
+				fmt.Println("Rejected websocket message from Redis:", err)
+				continue
+			}
+			addWsMsg(msg)
+		}
+		pubsub.Close()
+		backoff = wsSubscribeMinBackoff
+	}
+}
+
+// Client requests, eg {"op":"subscribe","worldId":12,"types":["colorWorldPixel"]}
+// worldId is omitted for the main canvas or "*" for every world, no types means all types.
+// {"op":"resume","worldId":12,"seq":1234} replays the updates after the X-Canvas-Sequence
+// returned with the canvas, live messages may overlap the replay so clients skip seqs they have.
+type WsClientRequest struct {
+	Op      string          `json:"op"`
+	WorldId json.RawMessage `json:"worldId"`
+	Types   []string        `json:"types"`
+	Seq     *int64          `json:"seq"`
+}
+
+const wsResumeBatchSize = 500
+
+func wsRequestScope(worldId json.RawMessage) (string, error) {
+	if len(worldId) == 0 || string(worldId) == "null" {
+		return core.WSScopeMain, nil
+	}
+	if string(worldId) == `"*"` {
+		return core.WSScopeAllWorlds, nil
+	}
+
+	var id int
+	if err := json.Unmarshal(worldId, &id); err != nil || id < 0 {
+		return "", fmt.Errorf("invalid worldId")
+	}
+	return strconv.Itoa(id), nil
+}
+
+func wsReply(client *core.WSClient, reply map[string]interface{}) {
+	replyBytes, err := json.Marshal(reply)
+	if err != nil {
+		fmt.Println("Failed to marshal websocket reply")
+		return
+	}
+	client.Send(replyBytes)
+}
+
+func wsResume(client *core.WSClient, scope string, seq *int64) {
+	if seq == nil || *seq < 0 || scope == core.WSScopeAllWorlds {
+		wsReply(client, map[string]interface{}{"messageType": "error", "error": "Resume needs a worldId and a seq"})
+		return
+	}
+
+	canvasKey := "canvas-" + scope
+	if scope == core.WSScopeMain {
+		canvasKey = "canvas-" + core.AFKBackend.CanvasConfig.Round
+	}
+
+	ctx := context.Background()
+	lastSeq := *seq
 	for {
-		// TODO: exit on close in backend?
-		// TODO: handle different message types
-		messageType, p, err := conn.ReadMessage()
+		updates, gap, err := routeutils.GetCanvasUpdatesSince(ctx, canvasKey, lastSeq, wsResumeBatchSize)
 		if err != nil {
-			fmt.Println(err)
+			fmt.Println("Failed to read canvas updates:", err)
+			wsReply(client, map[string]interface{}{"messageType": "error", "error": "Failed to resume"})
 			return
 		}
-		fmt.Println("WS message received: ", messageType, string(p))
+		if gap {
+			// Updates were trimmed, the client must reload the canvas
+			wsReply(client, map[string]interface{}{"messageType": "resyncRequired", "worldId": scope})
+			return
+		}
+		if len(updates) == 0 {
+			break
+		}
+
+		updatesBytes, err := json.Marshal(updates)
+		if err != nil {
+			fmt.Println("Failed to marshal canvas updates")
+			return
+		}
+		client.Send(updatesBytes)
+
+		lastSeq, err = strconv.ParseInt(updates[len(updates)-1]["seq"], 10, 64)
+		if err != nil || len(updates) < wsResumeBatchSize {
+			break
+		}
+	}
+
+	wsReply(client, map[string]interface{}{"messageType": "resumed", "worldId": scope, "seq": lastSeq})
+}
+
+func wsReader(client *core.WSClient, messageType int, p []byte) {
+	if messageType != websocket.TextMessage {
+		return
+	}
+
+	request := WsClientRequest{}
+	if err := json.Unmarshal(p, &request); err != nil {
+		wsReply(client, map[string]interface{}{"messageType": "error", "error": "Invalid JSON message"})
+		return
+	}
+
+	scope, err := wsRequestScope(request.WorldId)
+	if err != nil {
+		wsReply(client, map[string]interface{}{"messageType": "error", "error": err.Error()})
+		return
+	}
+
+	switch request.Op {
+	case "subscribe":
+		client.Subscribe(scope, request.Types)
+		wsReply(client, map[string]interface{}{"messageType": "subscribed", "worldId": scope, "types": request.Types})
+	case "unsubscribe":
+		client.Unsubscribe(scope, request.Types)
+		wsReply(client, map[string]interface{}{"messageType": "unsubscribed", "worldId": scope, "types": request.Types})
+	case "resume":
+		wsResume(client, scope, request.Seq)
+	default:
+		wsReply(client, map[string]interface{}{"messageType": "error", "error": "Unknown op " + request.Op})
 	}
 }
 
@@ -68,8 +234,8 @@ func wsEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	core.AFKBackend.WSConnectionsLock.Lock()
-	core.AFKBackend.WSConnections = append(core.AFKBackend.WSConnections, ws)
-	core.AFKBackend.WSConnectionsLock.Unlock()
-	wsReader(ws)
+	client := core.AFKBackend.WSHub.Register(ws)
+	client.ReadMessages(func(messageType int, p []byte) {
+		wsReader(client, messageType, p)
+	})
 }
@@ -17,6 +17,8 @@ import (
 // TODO: check-worlds-name-unique?
 func InitWorldsRoutes() {
 	http.HandleFunc("/get-world-canvas", getWorldCanvas)
+	http.HandleFunc("/get-world-canvas-at", getWorldCanvasAt)
+	http.HandleFunc("/get-world-canvas-region", getWorldCanvasRegion)
 	http.HandleFunc("/get-world-id", getWorldId)
 	http.HandleFunc("/get-world", getWorld)
 	http.HandleFunc("/get-worlds", getWorlds)
@@ -63,16 +65,53 @@ func getWorldCanvas(w http.ResponseWriter, r *http.Request) {
 	canvasName := "canvas-" + worldId
 
 	ctx := context.Background()
-	val, err := core.AFKBackend.Databases.Redis.Get(ctx, canvasName).Result()
+	val, seq, err := routeutils.GetCanvasWithSeq(ctx, canvasName)
 	if err != nil {
 		fmt.Println("error getWorldCanvas", err)
 		routeutils.WriteErrorJson(w, http.StatusInternalServerError, "Failed to get canvas")
 		return
 	}
 
+	w.Header().Set("X-Canvas-Sequence", strconv.FormatInt(seq, 10))
 	w.Write([]byte(val))
 }
 
+func getWorldCanvasAt(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	worldId, err := strconv.Atoi(r.URL.Query().Get("worldId"))
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid worldId")
+		return
+	}
+
+	history, err := routeutils.WorldCanvasHistory(worldId)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "World not found")
+		return
+	}
+
+	writeCanvasAt(w, r, history)
+}
+
+func getWorldCanvasRegion(w http.ResponseWriter, r *http.Request) {
+	routeutils.SetupAccessHeaders(w)
+
+	worldId, err := strconv.Atoi(r.URL.Query().Get("worldId"))
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusBadRequest, "Invalid worldId")
+		return
+	}
+
+	history, err := routeutils.WorldCanvasHistory(worldId)
+	if err != nil {
+		routeutils.WriteErrorJson(w, http.StatusNotFound, "World not found")
+		return
+	}
+
+	writeCanvasRegion(w, r, history.CanvasKey, history.Width, history.Height)
+}
+
 type WorldData struct {
 	WorldId           int        `json:"worldId"`
 	Host              string     `json:"host"`
@@ -452,7 +491,7 @@ func getWorldsPixelInfo(w http.ResponseWriter, r *http.Request) {
 	queryRes, err := core.PostgresQueryOne[PixelInfo](`
     SELECT p.address, COALESCE(u.name, '') as name FROM WorldsPixels p
     LEFT JOIN Users u ON p.address = u.address WHERE p.position = $1 and p.world_id = $2
-    ORDER BY p.time DESC LIMIT 1`, position, worldId)
+    ORDER BY p.block_number DESC, p.key DESC LIMIT 1`, position, worldId)
 	if err != nil {
 		routeutils.WriteDataJson(w, "\"0x0000000000000000000000000000000000000000000000000000000000000000\"")
 		return
@@ -511,10 +550,10 @@ func getTopWorlds(w http.ResponseWriter, r *http.Request) {
 }
 
 func getFavoriteWorlds(w http.ResponseWriter, r *http.Request) {
-	address := r.URL.Query().Get("address")
-	if address == "" {
-		address = "0"
+	if routeutils.AuthMiddleware(w, r) {
+		return
 	}
+	address := routeutils.GetAuthAddress(r)
 	pageLength, err := strconv.Atoi(r.URL.Query().Get("pageLength"))
 	if err != nil || pageLength <= 0 {
 		pageLength = 25
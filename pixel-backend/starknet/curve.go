@@ -0,0 +1,105 @@
+package starknet
+
+import (
+	"math/big"
+)
+
+// Stark curve: y^2 = x^3 + alpha*x + beta over the field of size FieldPrime
+var (
+	FieldPrime  = hexToBig("0x800000000000011000000000000000000000000000000000000000000000001")
+	CurveOrder  = hexToBig("0x800000000000010ffffffffffffffffb781126dcae7b2321e66a241adc64d2f")
+	CurveAlpha  = big.NewInt(1)
+	CurveBeta   = hexToBig("0x6f21413efbe40de150e596d72f7a8c5609ad26c15c915c1f4cdfcb99cee9e89")
+	MaxFelt     = new(big.Int).Sub(FieldPrime, big.NewInt(1))
+	GeneratorPt = &Point{
+		X: hexToBig("0x1ef15c18599971b7beced415a40f0c7deacfd9b0d1819e03d723d8bc943cfca"),
+		Y: hexToBig("0x5668060aa49730b7be4801df46ec62de53ecd11abe43a32873000c36e8dc1f"),
+	}
+)
+
+// Point on the stark curve, nil coordinates represent the point at infinity
+type Point struct {
+	X *big.Int
+	Y *big.Int
+}
+
+func hexToBig(hex string) *big.Int {
+	value, ok := new(big.Int).SetString(hex, 0)
+	if !ok {
+		panic("invalid hex constant: " + hex)
+	}
+	return value
+}
+
+func (p *Point) IsInfinity() bool {
+	return p == nil || p.X == nil || p.Y == nil
+}
+
+func (p *Point) IsOnCurve() bool {
+	if p.IsInfinity() {
+		return true
+	}
+	left := new(big.Int).Mul(p.Y, p.Y)
+	left.Mod(left, FieldPrime)
+
+	right := new(big.Int).Exp(p.X, big.NewInt(3), FieldPrime)
+	right.Add(right, new(big.Int).Mul(CurveAlpha, p.X))
+	right.Add(right, CurveBeta)
+	right.Mod(right, FieldPrime)
+
+	return left.Cmp(right) == 0
+}
+
+func (p *Point) Add(q *Point) *Point {
+	if p.IsInfinity() {
+		return q
+	}
+	if q.IsInfinity() {
+		return p
+	}
+
+	var slope *big.Int
+	if p.X.Cmp(q.X) == 0 {
+		ySum := new(big.Int).Add(p.Y, q.Y)
+		if ySum.Mod(ySum, FieldPrime).Sign() == 0 {
+			return &Point{}
+		}
+		// Tangent slope : (3x^2 + alpha) / 2y
+		num := new(big.Int).Mul(p.X, p.X)
+		num.Mul(num, big.NewInt(3))
+		num.Add(num, CurveAlpha)
+		den := new(big.Int).Lsh(p.Y, 1)
+		den.ModInverse(den, FieldPrime)
+		slope = num.Mul(num, den)
+	} else {
+		num := new(big.Int).Sub(q.Y, p.Y)
+		den := new(big.Int).Sub(q.X, p.X)
+		den.Mod(den, FieldPrime)
+		den.ModInverse(den, FieldPrime)
+		slope = num.Mul(num, den)
+	}
+	slope.Mod(slope, FieldPrime)
+
+	x := new(big.Int).Mul(slope, slope)
+	x.Sub(x, p.X)
+	x.Sub(x, q.X)
+	x.Mod(x, FieldPrime)
+
+	y := new(big.Int).Sub(p.X, x)
+	y.Mul(y, slope)
+	y.Sub(y, p.Y)
+	y.Mod(y, FieldPrime)
+
+	return &Point{X: x, Y: y}
+}
+
+func (p *Point) Mul(scalar *big.Int) *Point {
+	result := &Point{}
+	for i := scalar.BitLen() - 1; i >= 0; i-- {
+		result = result.Add(result)
+		if scalar.Bit(i) == 1 {
+			result = result.Add(p)
+		}
+	}
+	return result
+}
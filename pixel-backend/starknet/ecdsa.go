@@ -0,0 +1,117 @@
+package starknet
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// Stark curve ECDSA as checked by the check_ecdsa_signature syscall : hashes and r are below
+// 2^251, s is the inverse of w = k / ( hash + r * privateKey ) mod the curve order.
+
+var ecdsaMax = new(big.Int).Lsh(big.NewInt(1), 251)
+
+// GetPublicKey returns the public key of a private key, the stark key being its X coordinate
+func GetPublicKey(privateKey *big.Int) *Point {
+	return GeneratorPt.Mul(privateKey)
+}
+
+// ParsePrivateKey parses a hex or decimal private key, which must be in [1, CurveOrder)
+func ParsePrivateKey(value string) (*big.Int, error) {
+	key, ok := new(big.Int).SetString(value, 0)
+	if !ok || key.Sign() <= 0 || key.Cmp(CurveOrder) >= 0 {
+		return nil, fmt.Errorf("invalid stark private key")
+	}
+	return key, nil
+}
+
+// Sign signs a message hash with a random nonce, returning r and s
+func Sign(privateKey *big.Int, messageHash *big.Int) (*big.Int, *big.Int, error) {
+	if messageHash.Sign() < 0 || messageHash.Cmp(ecdsaMax) >= 0 {
+		return nil, nil, fmt.Errorf("message hash out of range")
+	}
+
+	for {
+		k, err := rand.Int(rand.Reader, new(big.Int).Sub(CurveOrder, big.NewInt(1)))
+		if err != nil {
+			return nil, nil, err
+		}
+		k.Add(k, big.NewInt(1))
+
+		r := GeneratorPt.Mul(k).X
+		if r.Sign() == 0 || r.Cmp(ecdsaMax) >= 0 {
+			continue
+		}
+
+		sum := new(big.Int).Mul(r, privateKey)
+		sum.Add(sum, messageHash)
+		sum.Mod(sum, CurveOrder)
+		if sum.Sign() == 0 {
+			continue
+		}
+
+		w := new(big.Int).ModInverse(sum, CurveOrder)
+		w.Mul(w, k)
+		w.Mod(w, CurveOrder)
+		if w.Sign() == 0 || w.Cmp(ecdsaMax) >= 0 {
+			continue
+		}
+
+		s := new(big.Int).ModInverse(w, CurveOrder)
+		return r, s, nil
+	}
+}
+
+// Verify checks a signature of the message hash against the stark key ( public key X )
+func Verify(starkKey *big.Int, messageHash *big.Int, r *big.Int, s *big.Int) bool {
+	if messageHash.Sign() < 0 || messageHash.Cmp(ecdsaMax) >= 0 {
+		return false
+	}
+	if r.Sign() <= 0 || r.Cmp(ecdsaMax) >= 0 || s.Sign() <= 0 || s.Cmp(CurveOrder) >= 0 {
+		return false
+	}
+	w := new(big.Int).ModInverse(s, CurveOrder)
+	if w == nil || w.Cmp(ecdsaMax) >= 0 {
+		return false
+	}
+
+	publicKey, err := pointFromX(starkKey)
+	if err != nil {
+		return false
+	}
+
+	// The stark key is only the X coordinate, accept either Y
+	zG := GeneratorPt.Mul(messageHash)
+	rQ := publicKey.Mul(r)
+	for _, point := range []*Point{zG.Add(rQ), zG.Add(rQ.Negate())} {
+		x := point.Mul(w)
+		if !x.IsInfinity() && x.X.Cmp(r) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Point) Negate() *Point {
+	if p.IsInfinity() {
+		return p
+	}
+	return &Point{X: new(big.Int).Set(p.X), Y: new(big.Int).Sub(FieldPrime, p.Y)}
+}
+
+// pointFromX returns a curve point with the given X coordinate
+func pointFromX(x *big.Int) (*Point, error) {
+	if x.Sign() <= 0 || x.Cmp(FieldPrime) >= 0 {
+		return nil, fmt.Errorf("invalid point x")
+	}
+	ySquared := new(big.Int).Exp(x, big.NewInt(3), FieldPrime)
+	ySquared.Add(ySquared, new(big.Int).Mul(CurveAlpha, x))
+	ySquared.Add(ySquared, CurveBeta)
+	ySquared.Mod(ySquared, FieldPrime)
+
+	y := new(big.Int).ModSqrt(ySquared, FieldPrime)
+	if y == nil {
+		return nil, fmt.Errorf("x is not on the curve")
+	}
+	return &Point{X: new(big.Int).Set(x), Y: y}, nil
+}
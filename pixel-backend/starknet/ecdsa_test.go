@@ -0,0 +1,64 @@
+package starknet
+
+import (
+	"math/big"
+	"testing"
+)
+
+// Key, hash and signature from the starkware signature test data
+var (
+	testPrivateKey = hexToBig("0x3c1e9550e66958296d11b60f8e8e7a7ad990d07fa65d5f7652c4a6c87d4e3cc")
+	testStarkKey   = hexToBig("0x77a3b314db07c45076d11f62b6f9e748a39790441823307743cf00d6597ea43")
+	testHash       = hexToBig("0x397e76d1667c4454bfb83514e120583af836f8e32a516765497823eabe16a3f")
+	testR          = hexToBig("0x173fd03d8b008ee7432977ac27d1e9d1a1f6c98b1a2f05fa84a21c84c44e882")
+	testS          = hexToBig("0x4b6d75385aed025aa222f28a0adc6d58db78ff17e51c3f59e259b131cd5a1cc")
+)
+
+func TestGetPublicKey(t *testing.T) {
+	if got := GetPublicKey(testPrivateKey).X; got.Cmp(testStarkKey) != 0 {
+		t.Errorf("stark key = %s, want %s", FeltToHex(got), FeltToHex(testStarkKey))
+	}
+}
+
+func TestVerifyKnownSignature(t *testing.T) {
+	if !Verify(testStarkKey, testHash, testR, testS) {
+		t.Fatalf("known signature rejected")
+	}
+	if Verify(testStarkKey, new(big.Int).Add(testHash, big.NewInt(1)), testR, testS) {
+		t.Errorf("signature accepted for another hash")
+	}
+	if Verify(testStarkKey, testHash, testR, new(big.Int).Add(testS, big.NewInt(1))) {
+		t.Errorf("tampered signature accepted")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	r, s, err := Sign(testPrivateKey, testHash)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !Verify(testStarkKey, testHash, r, s) {
+		t.Errorf("signature rejected")
+	}
+
+	if _, _, err := Sign(testPrivateKey, ecdsaMax); err == nil {
+		t.Errorf("expected an error on a hash of 251 bits or more")
+	}
+}
+
+func TestPedersen(t *testing.T) {
+	a := hexToBig("0x3d937c035c878245caf64531a5756109c53068da139362728feb561405371cb")
+	b := hexToBig("0x208a0a10250e382e1e4bbe2880906c2791bf6275695e02fbbc6aeff9cd8b31a")
+	want := hexToBig("0x30e480bed5fe53fa909cc0f8c4d99b8f9f2c016be4c41e13a4848797979c662")
+	if got := Pedersen(a, b); got.Cmp(want) != 0 {
+		t.Errorf("Pedersen = %s, want %s", FeltToHex(got), FeltToHex(want))
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	for _, invalid := range []string{"", "0x0", "zz", "-1", FeltToHex(CurveOrder)} {
+		if _, err := ParsePrivateKey(invalid); err == nil {
+			t.Errorf("ParsePrivateKey(%s) expected an error", invalid)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package starknet
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+var keccakMask = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 250), big.NewInt(1))
+
+// ParseFelt parses a hex ( 0x prefixed ) or decimal string into a field element
+func ParseFelt(value string) (*big.Int, error) {
+	felt, ok := new(big.Int).SetString(strings.TrimSpace(value), 0)
+	if !ok {
+		return nil, fmt.Errorf("invalid felt: %s", value)
+	}
+	if felt.Sign() < 0 || felt.Cmp(FieldPrime) >= 0 {
+		return nil, fmt.Errorf("felt out of range: %s", value)
+	}
+	return felt, nil
+}
+
+func FeltToHex(felt *big.Int) string {
+	return "0x" + felt.Text(16)
+}
+
+// NormalizeAddress returns the address as 64 lowercase hex chars without 0x prefix,
+// which is how addresses are stored in postgres
+func NormalizeAddress(address string) (string, error) {
+	if !strings.HasPrefix(address, "0x") && !strings.HasPrefix(address, "0X") {
+		address = "0x" + address
+	}
+	felt, err := ParseFelt(address)
+	if err != nil {
+		return "", err
+	}
+	if felt.Sign() == 0 {
+		return "", fmt.Errorf("zero address")
+	}
+	return fmt.Sprintf("%064x", felt), nil
+}
+
+// EncodeShortString encodes an ascii string of at most 31 chars as a felt
+func EncodeShortString(value string) (*big.Int, error) {
+	if len(value) > 31 {
+		return nil, fmt.Errorf("short string too long: %s", value)
+	}
+	for _, char := range value {
+		if char > 127 {
+			return nil, fmt.Errorf("short string is not ascii: %s", value)
+		}
+	}
+	return new(big.Int).SetBytes([]byte(value)), nil
+}
+
+// DecodeShortString decodes a felt into an ascii string, dropping null bytes
+func DecodeShortString(felt *big.Int) string {
+	return strings.Trim(string(felt.Bytes()), "\x00")
+}
+
+// StarknetKeccak is keccak256 truncated to 250 bits
+func StarknetKeccak(data []byte) *big.Int {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(data)
+	hash := new(big.Int).SetBytes(hasher.Sum(nil))
+	return hash.And(hash, keccakMask)
+}
+
+func GetSelectorFromName(name string) *big.Int {
+	return StarknetKeccak([]byte(name))
+}
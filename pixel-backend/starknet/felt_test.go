@@ -0,0 +1,25 @@
+package starknet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	want := strings.Repeat("0", 61) + "abc"
+	for _, address := range []string{"0xabc", "0xABC", "0X0000abc", "abc"} {
+		normalized, err := NormalizeAddress(address)
+		if err != nil {
+			t.Fatalf("NormalizeAddress(%s): %v", address, err)
+		}
+		if normalized != want {
+			t.Errorf("NormalizeAddress(%s) = %s, want %s", address, normalized, want)
+		}
+	}
+
+	for _, invalid := range []string{"", "zz", "-0x1", "0x0", "0x1" + strings.Repeat("0", 63)} {
+		if _, err := NormalizeAddress(invalid); err == nil {
+			t.Errorf("NormalizeAddress(%s) expected an error", invalid)
+		}
+	}
+}
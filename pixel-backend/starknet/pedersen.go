@@ -0,0 +1,54 @@
+package starknet
+
+import (
+	"math/big"
+)
+
+var pedersenShiftPoint = &Point{
+	X: hexToBig("0x49ee3eba8c1600700ee1b87eb599f16716b0b1022947733551fde4050ca6804"),
+	Y: hexToBig("0x3ca0cfe4b3bc6ddf346d49d06ea0ed34e621062c0e056c1d0405d266e10268a"),
+}
+
+// Constant points P1..P4 used for the low/high parts of both hash inputs
+var pedersenPoints = []*Point{
+	{
+		X: hexToBig("0x234287dcbaffe7f969c748655fca9e58fa8120b6d56eb0c1080d17957ebe47b"),
+		Y: hexToBig("0x3b056f100f96fb21e889527d41f4e39940135dd7a6c94cc6ed0268ee89e5615"),
+	},
+	{
+		X: hexToBig("0x4fa56f376c83db33f9dab2656558f3399099ec1de5e3018b7a6932dba8aa378"),
+		Y: hexToBig("0x3fa0984c931c9e38113e0c0e47e4401562761f92a7a23b45168f4e80ff5b54d"),
+	},
+	{
+		X: hexToBig("0x4ba4cc166be8dec764910f75b45f74b40c690c74709e90f3aa372f0bd2d6997"),
+		Y: hexToBig("0x40301cf5c1751f4b971e46c4ede85fcac5c59a5ce5ae7c48151f27b24b219c"),
+	},
+	{
+		X: hexToBig("0x54302dcb0e6cc1c6e44cca8f61a63bb2ca65048d53fb325d36ff12c49a58202"),
+		Y: hexToBig("0x1b77b3e37d13504b348046268d8ae25ce98ad783c25561a879dcc77e99c2426"),
+	},
+}
+
+var pedersenLowMask = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 248), big.NewInt(1))
+
+// Pedersen hash of two felts as defined by Starknet
+func Pedersen(a *big.Int, b *big.Int) *big.Int {
+	result := pedersenShiftPoint
+	for idx, value := range []*big.Int{a, b} {
+		element := new(big.Int).Mod(value, FieldPrime)
+		low := new(big.Int).And(element, pedersenLowMask)
+		high := new(big.Int).Rsh(element, 248)
+		result = result.Add(pedersenPoints[2*idx].Mul(low))
+		result = result.Add(pedersenPoints[2*idx+1].Mul(high))
+	}
+	return result.X
+}
+
+// ComputeHashOnElements chains pedersen over the elements and finishes with the length
+func ComputeHashOnElements(elements []*big.Int) *big.Int {
+	hash := big.NewInt(0)
+	for _, element := range elements {
+		hash = Pedersen(hash, element)
+	}
+	return Pedersen(hash, big.NewInt(int64(len(elements))))
+}
@@ -0,0 +1,139 @@
+package starknet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+)
+
+var rpcClient = &http.Client{Timeout: 10 * time.Second}
+
+type rpcRequest struct {
+	Jsonrpc string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+	Id      int         `json:"id"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type FunctionCall struct {
+	ContractAddress    string   `json:"contract_address"`
+	EntryPointSelector string   `json:"entry_point_selector"`
+	Calldata           []string `json:"calldata"`
+}
+
+func RpcUrl() string {
+	return os.Getenv("STARKNET_RPC_URL")
+}
+
+func rpcCall(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	url := RpcUrl()
+	if url == "" {
+		return nil, fmt.Errorf("STARKNET_RPC_URL is not set")
+	}
+
+	body, err := json.Marshal(rpcRequest{Jsonrpc: "2.0", Method: method, Params: params, Id: 1})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := rpcClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response rpcResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, fmt.Errorf("invalid rpc response: %w", err)
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("rpc error %d: %s %s", response.Error.Code, response.Error.Message, string(response.Error.Data))
+	}
+	return response.Result, nil
+}
+
+// Call runs a read only contract call against the latest block
+func Call(ctx context.Context, contract string, entrypoint string, calldata []*big.Int) ([]*big.Int, error) {
+	call := FunctionCall{
+		ContractAddress:    contract,
+		EntryPointSelector: FeltToHex(GetSelectorFromName(entrypoint)),
+		Calldata:           make([]string, len(calldata)),
+	}
+	for idx, value := range calldata {
+		call.Calldata[idx] = FeltToHex(value)
+	}
+
+	result, err := rpcCall(ctx, "starknet_call", map[string]interface{}{
+		"request":  call,
+		"block_id": "latest",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	if err := json.Unmarshal(result, &values); err != nil {
+		return nil, fmt.Errorf("invalid call result: %w", err)
+	}
+
+	felts := make([]*big.Int, len(values))
+	for idx, value := range values {
+		felts[idx], err = ParseFelt(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return felts, nil
+}
+
+var isValidSignatureMagic, _ = EncodeShortString("VALID")
+
+// IsValidSignature asks the account contract whether the signature is valid for the hash
+func IsValidSignature(ctx context.Context, account string, hash *big.Int, signature []*big.Int) (bool, error) {
+	calldata := []*big.Int{hash, big.NewInt(int64(len(signature)))}
+	calldata = append(calldata, signature...)
+
+	result, err := Call(ctx, account, "is_valid_signature", calldata)
+	if err != nil {
+		return false, err
+	}
+	return isValidSignatureResult(result), nil
+}
+
+// isValidSignatureResult reads the is_valid_signature result : Cairo 1 accounts return 'VALID',
+// some older ones return 1. An empty result is invalid, any contract returning nothing from
+// the entrypoint would otherwise accept every signature.
+func isValidSignatureResult(result []*big.Int) bool {
+	if len(result) == 0 {
+		return false
+	}
+	return result[0].Cmp(isValidSignatureMagic) == 0 || result[0].Cmp(big.NewInt(1)) == 0
+}
@@ -0,0 +1,182 @@
+package starknet
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SNIP-12 typed data ( revision 0, pedersen based ) as signed by starknet wallets
+
+const TypedDataDomainType = "StarkNetDomain"
+
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+var decimalRegex = regexp.MustCompile(`^[0-9]+$`)
+
+// encodeValueAsFelt follows starknet.js : hex and decimal strings are numbers, anything else is a short string
+func encodeValueAsFelt(value interface{}) (*big.Int, error) {
+	switch v := value.(type) {
+	case string:
+		if strings.HasPrefix(v, "0x") || decimalRegex.MatchString(v) {
+			return ParseFelt(v)
+		}
+		return EncodeShortString(v)
+	case float64:
+		return big.NewInt(int64(v)), nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case bool:
+		if v {
+			return big.NewInt(1), nil
+		}
+		return big.NewInt(0), nil
+	case *big.Int:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("unsupported typed data value: %v", value)
+	}
+}
+
+func (t *TypedData) dependencies(typeName string, found []string) []string {
+	for _, dep := range found {
+		if dep == typeName {
+			return found
+		}
+	}
+	fields, ok := t.Types[typeName]
+	if !ok {
+		return found
+	}
+	found = append(found, typeName)
+	for _, field := range fields {
+		found = t.dependencies(strings.TrimSuffix(field.Type, "*"), found)
+	}
+	return found
+}
+
+func (t *TypedData) EncodeType(typeName string) string {
+	deps := t.dependencies(typeName, nil)
+	if len(deps) > 1 {
+		rest := deps[1:]
+		sort.Strings(rest)
+	}
+
+	var encoded strings.Builder
+	for _, dep := range deps {
+		encoded.WriteString(dep + "(")
+		for idx, field := range t.Types[dep] {
+			if idx > 0 {
+				encoded.WriteString(",")
+			}
+			encoded.WriteString(field.Name + ":" + field.Type)
+		}
+		encoded.WriteString(")")
+	}
+	return encoded.String()
+}
+
+func (t *TypedData) TypeHash(typeName string) *big.Int {
+	return StarknetKeccak([]byte(t.EncodeType(typeName)))
+}
+
+func (t *TypedData) encodeValue(typeName string, value interface{}) (*big.Int, error) {
+	if _, ok := t.Types[typeName]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected object for type %s", typeName)
+		}
+		return t.HashStruct(typeName, data)
+	}
+
+	if strings.HasSuffix(typeName, "*") {
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected array for type %s", typeName)
+		}
+		elemType := strings.TrimSuffix(typeName, "*")
+		var elements []*big.Int
+		for _, elem := range values {
+			encoded, err := t.encodeValue(elemType, elem)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, encoded)
+		}
+		return ComputeHashOnElements(elements), nil
+	}
+
+	switch typeName {
+	case "felt", "string", "shortstring", "bool", "ContractAddress":
+		return encodeValueAsFelt(value)
+	case "selector":
+		name, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string selector")
+		}
+		if strings.HasPrefix(name, "0x") {
+			return ParseFelt(name)
+		}
+		return GetSelectorFromName(name), nil
+	default:
+		return nil, fmt.Errorf("unsupported typed data type: %s", typeName)
+	}
+}
+
+func (t *TypedData) HashStruct(typeName string, data map[string]interface{}) (*big.Int, error) {
+	fields, ok := t.Types[typeName]
+	if !ok {
+		return nil, fmt.Errorf("unknown typed data type: %s", typeName)
+	}
+
+	elements := []*big.Int{t.TypeHash(typeName)}
+	for _, field := range fields {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing field %s in %s", field.Name, typeName)
+		}
+		encoded, err := t.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		elements = append(elements, encoded)
+	}
+	return ComputeHashOnElements(elements), nil
+}
+
+// MessageHash is the hash an account signs for the given typed data
+func (t *TypedData) MessageHash(accountAddress string) (*big.Int, error) {
+	account, err := ParseFelt(accountAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	domainHash, err := t.HashStruct(TypedDataDomainType, t.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	messageHash, err := t.HashStruct(t.PrimaryType, t.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, _ := EncodeShortString("StarkNet Message")
+	return ComputeHashOnElements([]*big.Int{prefix, domainHash, account, messageHash}), nil
+}
@@ -0,0 +1,88 @@
+package starknet
+
+import (
+	"math/big"
+	"testing"
+)
+
+// Mail example of the SNIP-12 revision 0 spec, hashes as computed by starknet.js
+func mailTypedData() *TypedData {
+	return &TypedData{
+		Types: map[string][]TypedDataField{
+			TypedDataDomainType: {{Name: "name", Type: "felt"}, {Name: "version", Type: "felt"}, {Name: "chainId", Type: "felt"}},
+			"Person":            {{Name: "name", Type: "felt"}, {Name: "wallet", Type: "felt"}},
+			"Mail":              {{Name: "from", Type: "Person"}, {Name: "to", Type: "Person"}, {Name: "contents", Type: "felt"}},
+		},
+		PrimaryType: "Mail",
+		Domain:      map[string]interface{}{"name": "StarkNet Mail", "version": "1", "chainId": float64(1)},
+		Message: map[string]interface{}{
+			"from":     map[string]interface{}{"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+			"to":       map[string]interface{}{"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+			"contents": "Hello, Bob!",
+		},
+	}
+}
+
+func TestTypedDataHashes(t *testing.T) {
+	typedData := mailTypedData()
+
+	if encoded := typedData.EncodeType("Mail"); encoded != "Mail(from:Person,to:Person,contents:felt)Person(name:felt,wallet:felt)" {
+		t.Errorf("EncodeType(Mail) = %s", encoded)
+	}
+
+	domainHash, err := typedData.HashStruct(TypedDataDomainType, typedData.Domain)
+	if err != nil {
+		t.Fatalf("HashStruct(domain): %v", err)
+	}
+	mailHash, err := typedData.HashStruct("Mail", typedData.Message)
+	if err != nil {
+		t.Fatalf("HashStruct(Mail): %v", err)
+	}
+	messageHash, err := typedData.MessageHash("0xcd2a3d9f938e13cd947ec05abc7fe734df8dd826")
+	if err != nil {
+		t.Fatalf("MessageHash: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		got  *big.Int
+		want string
+	}{
+		{"domain type hash", typedData.TypeHash(TypedDataDomainType), "0x1bfc207425a47a5dfa1a50a4f5241203f50624ca5fdf5e18755765416b8e288"},
+		{"mail type hash", typedData.TypeHash("Mail"), "0x13d89452df9512bf750f539ba3001b945576243288137ddb6c788457d4b2f79"},
+		{"domain hash", domainHash, "0x54833b121883a3e3aebff48ec08a962f5742e5f7b973469c1f8f4f55d470b07"},
+		{"mail hash", mailHash, "0x4758f1ed5e7503120c228cbcaba626f61514559e9ef5ed653b0b885e0f38aec"},
+		{"message hash", messageHash, "0x6fcff244f63e38b9d88b9e3378d44757710d1b244282b435cb472053c8d78d0"},
+	}
+	for _, c := range cases {
+		if FeltToHex(c.got) != c.want {
+			t.Errorf("%s = %s, want %s", c.name, FeltToHex(c.got), c.want)
+		}
+	}
+}
+
+func TestTypedDataMissingField(t *testing.T) {
+	typedData := mailTypedData()
+	delete(typedData.Message, "contents")
+	if _, err := typedData.MessageHash("0x1"); err == nil {
+		t.Errorf("expected an error on a missing field")
+	}
+}
+
+func TestIsValidSignatureResult(t *testing.T) {
+	cases := []struct {
+		name   string
+		result []*big.Int
+		want   bool
+	}{
+		{"empty", []*big.Int{}, false},
+		{"VALID", []*big.Int{isValidSignatureMagic}, true},
+		{"legacy 1", []*big.Int{big.NewInt(1)}, true},
+		{"zero", []*big.Int{big.NewInt(0)}, false},
+	}
+	for _, c := range cases {
+		if got := isValidSignatureResult(c.result); got != c.want {
+			t.Errorf("%s: isValidSignatureResult = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+// APNG is written from the chunks of each frame encoded by image/png: the IHDR, PLTE and tRNS of
+// the first frame, then an fcTL before each frame's data, IDAT for the first and fdAT after it.
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+func readPngChunks(encoded []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(encoded, pngSignature) {
+		return nil, fmt.Errorf("missing png signature")
+	}
+	chunks := make([]pngChunk, 0)
+	rest := encoded[len(pngSignature):]
+	for len(rest) >= 12 {
+		length := binary.BigEndian.Uint32(rest[0:4])
+		if uint32(len(rest)-12) < length {
+			return nil, fmt.Errorf("truncated png chunk")
+		}
+		chunks = append(chunks, pngChunk{kind: string(rest[4:8]), data: rest[8 : 8+length]})
+		rest = rest[12+length:]
+	}
+	return chunks, nil
+}
+
+func writePngChunk(w io.Writer, kind string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], uint32(len(data)))
+	copy(header[4:8], kind)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:8])
+	crc.Write(data)
+	footer := binary.BigEndian.AppendUint32(nil, crc.Sum32())
+
+	for _, part := range [][]byte{header, data, footer} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeAPNG writes the frames, all of the same size and palette, looping forever
+func encodeAPNG(w io.Writer, frames []*image.Paletted, delaysMs []int) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames")
+	}
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+
+	encoder := &png.Encoder{CompressionLevel: png.BestCompression}
+	sequence := uint32(0)
+	for idx, frame := range frames {
+		var buffer bytes.Buffer
+		if err := encoder.Encode(&buffer, frame); err != nil {
+			return err
+		}
+		chunks, err := readPngChunks(buffer.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if idx == 0 {
+			for _, chunk := range chunks {
+				if chunk.kind == "IHDR" || chunk.kind == "PLTE" || chunk.kind == "tRNS" {
+					if err := writePngChunk(w, chunk.kind, chunk.data); err != nil {
+						return err
+					}
+				}
+				if chunk.kind == "IHDR" {
+					// acTL: frames, plays ( 0 loops forever )
+					actl := binary.BigEndian.AppendUint32(nil, uint32(len(frames)))
+					actl = binary.BigEndian.AppendUint32(actl, 0)
+					if err := writePngChunk(w, "acTL", actl); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		// fcTL: sequence, size, offset, delay in ms, no dispose, source blend
+		bounds := frame.Bounds()
+		fctl := binary.BigEndian.AppendUint32(nil, sequence)
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(bounds.Dx()))
+		fctl = binary.BigEndian.AppendUint32(fctl, uint32(bounds.Dy()))
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint32(fctl, 0)
+		fctl = binary.BigEndian.AppendUint16(fctl, uint16(delaysMs[idx]))
+		fctl = binary.BigEndian.AppendUint16(fctl, 1000)
+		fctl = append(fctl, 0, 0)
+		if err := writePngChunk(w, "fcTL", fctl); err != nil {
+			return err
+		}
+		sequence++
+
+		for _, chunk := range chunks {
+			if chunk.kind != "IDAT" {
+				continue
+			}
+			if idx == 0 {
+				err = writePngChunk(w, "IDAT", chunk.data)
+			} else {
+				err = writePngChunk(w, "fdAT", append(binary.BigEndian.AppendUint32(nil, sequence), chunk.data...))
+				sequence++
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return writePngChunk(w, "IEND", nil)
+}
@@ -0,0 +1,126 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func testFrames() []*image.Paletted {
+	palette := color.Palette{color.Transparent, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+	frames := make([]*image.Paletted, 3)
+	for idx := range frames {
+		frames[idx] = image.NewPaletted(image.Rect(0, 0, 4, 3), palette)
+		frames[idx].Pix[idx] = uint8(idx%2 + 1)
+	}
+	return frames
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	frames := testFrames()
+	var buffer bytes.Buffer
+	if err := encodeAPNG(&buffer, frames, []int{100, 200, 300}); err != nil {
+		t.Fatalf("encodeAPNG: %v", err)
+	}
+
+	// Every chunk keeps a valid crc
+	rest := buffer.Bytes()[len(pngSignature):]
+	for len(rest) >= 12 {
+		length := binary.BigEndian.Uint32(rest[0:4])
+		crc := binary.BigEndian.Uint32(rest[8+length : 12+length])
+		if crc32.ChecksumIEEE(rest[4:8+length]) != crc {
+			t.Errorf("bad crc on %s chunk", rest[4:8])
+		}
+		rest = rest[12+length:]
+	}
+	if len(rest) != 0 {
+		t.Errorf("%d trailing bytes", len(rest))
+	}
+
+	chunks, err := readPngChunks(buffer.Bytes())
+	if err != nil {
+		t.Fatalf("readPngChunks: %v", err)
+	}
+	kinds := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		kinds = append(kinds, chunk.kind)
+	}
+	if kinds[0] != "IHDR" || kinds[1] != "acTL" || kinds[len(kinds)-1] != "IEND" {
+		t.Fatalf("chunks = %v, want IHDR, acTL first and IEND last", kinds)
+	}
+
+	// acTL: frames then plays, the sequence numbers of fcTL and fdAT follow each other
+	actl := chunks[1].data
+	if binary.BigEndian.Uint32(actl[0:4]) != 3 || binary.BigEndian.Uint32(actl[4:8]) != 0 {
+		t.Errorf("acTL = %v, want 3 frames looping forever", actl)
+	}
+	sequence := uint32(0)
+	delays := make([]uint16, 0)
+	idats := 0
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "fcTL":
+			if got := binary.BigEndian.Uint32(chunk.data[0:4]); got != sequence {
+				t.Errorf("fcTL sequence = %d, want %d", got, sequence)
+			}
+			if binary.BigEndian.Uint32(chunk.data[4:8]) != 4 || binary.BigEndian.Uint32(chunk.data[8:12]) != 3 {
+				t.Errorf("fcTL size = %v, want 4x3", chunk.data[4:12])
+			}
+			delays = append(delays, binary.BigEndian.Uint16(chunk.data[20:22]))
+			sequence++
+		case "fdAT":
+			if got := binary.BigEndian.Uint32(chunk.data[0:4]); got != sequence {
+				t.Errorf("fdAT sequence = %d, want %d", got, sequence)
+			}
+			sequence++
+		case "IDAT":
+			if len(delays) != 1 {
+				t.Errorf("IDAT outside of the first frame")
+			}
+			idats++
+		}
+	}
+	if len(delays) != 3 || delays[0] != 100 || delays[1] != 200 || delays[2] != 300 {
+		t.Errorf("delays = %v, want [100 200 300]", delays)
+	}
+	if idats == 0 {
+		t.Errorf("missing IDAT")
+	}
+
+	// Decoders without APNG support show the first frame
+	decoded, err := png.Decode(bytes.NewReader(buffer.Bytes()))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	for idx := range frames[0].Pix {
+		x, y := idx%4, idx/4
+		r, g, b, a := decoded.At(x, y).RGBA()
+		wr, wg, wb, wa := frames[0].At(x, y).RGBA()
+		if r != wr || g != wg || b != wb || a != wa {
+			t.Errorf("pixel %d,%d = %v, want %v", x, y, decoded.At(x, y), frames[0].At(x, y))
+		}
+	}
+}
+
+func TestEncodeAPNGNoFrames(t *testing.T) {
+	if err := encodeAPNG(&bytes.Buffer{}, nil, nil); err == nil {
+		t.Errorf("expected an error without frames")
+	}
+}
+
+func TestReadPngChunksTruncated(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, testFrames()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPngChunks(buffer.Bytes()[:len(buffer.Bytes())-20]); err == nil {
+		t.Errorf("expected an error on a truncated png")
+	}
+	if _, err := readPngChunks([]byte("GIF89a")); err == nil {
+		t.Errorf("expected an error without the png signature")
+	}
+}
@@ -0,0 +1,334 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/core"
+	routeutils "github.com/AFK_AlignedFamKernel/afk_monorepo/pixel-backend/routes/utils"
+)
+
+// Timelapses replay the Pixels / WorldsPixels of a canvas in chain order and take a frame every
+// N placements or every N seconds of block time, encoded as an animated gif or apng. Each
+// generation is a row of Timelapses and its file is written to TimelapsesDir.
+
+const (
+	TimelapsesDir = "timelapses"
+
+	FormatGif  = "gif"
+	FormatApng = "apng"
+
+	defaultEveryPixels  = 100
+	defaultFrameDelayMs = 100
+	defaultMaxFrames    = 300
+	maxTimelapseFrames  = 1000
+	maxTimelapseScale   = 8
+	// Frames are kept in memory until encoded, their total size caps the frame count
+	maxTimelapseBytes = 256 << 20
+	// A timelapse still running after this was left by a process that stopped while rendering it
+	staleTimelapseAfter = time.Hour
+)
+
+type TimelapseOptions struct {
+	Format       string `json:"format"`
+	EveryPixels  int    `json:"everyPixels"`
+	EverySeconds int    `json:"everySeconds"`
+	Scale        int    `json:"scale"`
+	FrameDelayMs int    `json:"frameDelayMs"`
+	MaxFrames    int    `json:"maxFrames"`
+}
+
+type Timelapse struct {
+	Id           int        `json:"id"`
+	CanvasKey    string     `json:"canvasKey"`
+	Format       string     `json:"format"`
+	EveryPixels  int        `json:"everyPixels"`
+	EverySeconds int        `json:"everySeconds"`
+	Scale        int        `json:"scale"`
+	FrameDelayMs int        `json:"frameDelayMs"`
+	MaxFrames    int        `json:"maxFrames"`
+	Status       string     `json:"status"`
+	Frames       int        `json:"frames"`
+	Pixels       int        `json:"pixels"`
+	File         string     `json:"file"`
+	Error        string     `json:"error"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	StartedAt    *time.Time `json:"startedAt"`
+	FinishedAt   *time.Time `json:"finishedAt"`
+}
+
+const timelapseColumns = "id, canvas_key, format, every_pixels, every_seconds, scale, frame_delay_ms, max_frames, status, frames, pixels, file, error, created_at, started_at, finished_at"
+
+// Only one timelapse is rendered at a time by a process, the frames are kept in memory
+var timelapseLock = &sync.Mutex{}
+
+// Validate fills the defaults and checks the options
+func (o *TimelapseOptions) Validate() error {
+	if o.Format == "" {
+		o.Format = FormatGif
+	}
+	if o.Format != FormatGif && o.Format != FormatApng {
+		return fmt.Errorf("unknown format %s", o.Format)
+	}
+	if o.EveryPixels < 0 || o.EverySeconds < 0 {
+		return fmt.Errorf("frame interval must be positive")
+	}
+	if o.EveryPixels > 0 && o.EverySeconds > 0 {
+		return fmt.Errorf("frames are taken every pixels or every seconds, not both")
+	}
+	if o.EveryPixels == 0 && o.EverySeconds == 0 {
+		o.EveryPixels = defaultEveryPixels
+	}
+	if o.Scale == 0 {
+		o.Scale = 1
+	}
+	if o.Scale < 1 || o.Scale > maxTimelapseScale {
+		return fmt.Errorf("scale must be between 1 and %d", maxTimelapseScale)
+	}
+	if o.FrameDelayMs == 0 {
+		o.FrameDelayMs = defaultFrameDelayMs
+	}
+	if o.FrameDelayMs < 10 || o.FrameDelayMs > 10000 {
+		return fmt.Errorf("frame delay must be between 10 and 10000 ms")
+	}
+	if o.MaxFrames == 0 {
+		o.MaxFrames = defaultMaxFrames
+	}
+	if o.MaxFrames < 1 || o.MaxFrames > maxTimelapseFrames {
+		return fmt.Errorf("max frames must be between 1 and %d", maxTimelapseFrames)
+	}
+	return nil
+}
+
+// CreateTimelapse validates the options and records a pending timelapse of the canvas
+func CreateTimelapse(history *routeutils.CanvasHistory, options TimelapseOptions) (*Timelapse, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return core.PostgresQueryOne[Timelapse]("INSERT INTO Timelapses (canvas_key, format, every_pixels, every_seconds, scale, frame_delay_ms, max_frames) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING "+timelapseColumns, history.CanvasKey, options.Format, options.EveryPixels, options.EverySeconds, options.Scale, options.FrameDelayMs, options.MaxFrames)
+}
+
+func GetTimelapse(id int) (*Timelapse, error) {
+	return core.PostgresQueryOne[Timelapse]("SELECT "+timelapseColumns+" FROM Timelapses WHERE id = $1", id)
+}
+
+// ListTimelapses returns the timelapses of a canvas, or of all canvases if canvasKey is empty, newest first
+func ListTimelapses(canvasKey string, limit int, offset int) ([]Timelapse, error) {
+	if canvasKey == "" {
+		return core.PostgresQuery[Timelapse]("SELECT "+timelapseColumns+" FROM Timelapses ORDER BY id DESC LIMIT $1 OFFSET $2", limit, offset)
+	}
+	return core.PostgresQuery[Timelapse]("SELECT "+timelapseColumns+" FROM Timelapses WHERE canvas_key = $1 ORDER BY id DESC LIMIT $2 OFFSET $3", canvasKey, limit, offset)
+}
+
+// FailStaleTimelapses marks the timelapses running for longer than staleTimelapseAfter as failed,
+// their process stopped before recording the result
+func FailStaleTimelapses() (int64, error) {
+	tag, err := core.AFKBackend.Databases.Postgres.Exec(context.Background(), "UPDATE Timelapses SET status = 'failed', error = 'stopped while rendering', finished_at = CURRENT_TIMESTAMP WHERE status = 'running' AND started_at < CURRENT_TIMESTAMP - $1::interval", fmt.Sprintf("%d seconds", int(staleTimelapseAfter.Seconds())))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// RunTimelapse claims a pending timelapse, renders it and records its file, or why it failed
+func RunTimelapse(timelapse *Timelapse, history *routeutils.CanvasHistory) error {
+	timelapseLock.Lock()
+	defer timelapseLock.Unlock()
+
+	// The status is the claim, a timelapse is rendered once across the backend and video-gen
+	ctx := context.Background()
+	tag, err := core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE Timelapses SET status = 'running', started_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'pending'", timelapse.Id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("timelapse %d is not pending", timelapse.Id)
+	}
+	timelapse.Status = "running"
+
+	file, frames, pixels, err := renderTimelapse(timelapse, history)
+	if err != nil {
+		_, updateErr := core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE Timelapses SET status = 'failed', error = $2, finished_at = CURRENT_TIMESTAMP WHERE id = $1", timelapse.Id, err.Error())
+		if updateErr != nil {
+			fmt.Println("Failed to record timelapse failure", timelapse.Id, updateErr)
+		}
+		return err
+	}
+
+	_, err = core.AFKBackend.Databases.Postgres.Exec(ctx, "UPDATE Timelapses SET status = 'done', frames = $2, pixels = $3, file = $4, finished_at = CURRENT_TIMESTAMP WHERE id = $1", timelapse.Id, frames, pixels, file)
+	if err != nil {
+		return err
+	}
+	timelapse.Status = "done"
+	timelapse.Frames = frames
+	timelapse.Pixels = pixels
+	timelapse.File = file
+	return nil
+}
+
+type pixelsRange struct {
+	Pixels int        `json:"pixels"`
+	First  *time.Time `json:"first"`
+	Last   *time.Time `json:"last"`
+}
+
+func pixelsSource(history *routeutils.CanvasHistory) string {
+	if history.IsWorld {
+		return "WorldsPixels WHERE world_id = " + strconv.Itoa(history.WorldId)
+	}
+	return "Pixels"
+}
+
+// timelapsePalette indexes the canvas colors by color key, missing keys are transparent
+func timelapsePalette(history *routeutils.CanvasHistory) (color.Palette, error) {
+	colors, err := history.CanvasPalette()
+	if err != nil {
+		return nil, err
+	}
+	size := 1
+	for key := range colors {
+		if key >= 0 && key < 256 && int(key)+1 > size {
+			size = int(key) + 1
+		}
+	}
+	palette := make(color.Palette, size)
+	for idx := range palette {
+		palette[idx] = colors[int64(idx)]
+	}
+	return palette, nil
+}
+
+// renderTimelapse replays the pixels into frames and writes the encoded file
+func renderTimelapse(timelapse *Timelapse, history *routeutils.CanvasHistory) (string, int, int, error) {
+	ctx := context.Background()
+	source := pixelsSource(history)
+
+	pixelsInfo, err := core.PostgresQueryOne[pixelsRange]("SELECT COUNT(*) AS pixels, MIN(COALESCE(block_time, time)) AS first, MAX(COALESCE(block_time, time)) AS last FROM " + source)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if pixelsInfo.Pixels == 0 || pixelsInfo.First == nil || pixelsInfo.Last == nil {
+		return "", 0, 0, fmt.Errorf("no pixels placed on %s", history.CanvasKey)
+	}
+
+	palette, err := timelapsePalette(history)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	// Widen the interval so the frames fit in maxFrames and the memory budget
+	scale := timelapse.Scale
+	width, height := int(history.Width), int(history.Height)
+	maxFrames := timelapse.MaxFrames
+	if budget := maxTimelapseBytes / (width * height * scale * scale); budget < maxFrames {
+		maxFrames = max(budget, 1)
+	}
+	everyPixels := timelapse.EveryPixels
+	everySeconds := timelapse.EverySeconds
+	if everyPixels > 0 && (pixelsInfo.Pixels+everyPixels-1)/everyPixels > maxFrames {
+		everyPixels = (pixelsInfo.Pixels + maxFrames - 1) / maxFrames
+	}
+	if everySeconds > 0 {
+		span := int(pixelsInfo.Last.Sub(*pixelsInfo.First).Seconds())
+		if span/everySeconds+1 > maxFrames {
+			everySeconds = span/max(maxFrames-1, 1) + 1
+		}
+	}
+
+	frames := make([]*image.Paletted, 0)
+	delaysMs := make([]int, 0)
+	state := make([]uint8, width*height)
+	addFrame := func(intervals int) {
+		frame := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), palette)
+		for y := 0; y < height*scale; y++ {
+			row := (y / scale) * width
+			for x := 0; x < width*scale; x++ {
+				frame.Pix[y*frame.Stride+x] = state[row+x/scale]
+			}
+		}
+		frames = append(frames, frame)
+		// Intervals without pixels hold the frame instead of repeating it
+		delaysMs = append(delaysMs, min(timelapse.FrameDelayMs*intervals, 65535))
+	}
+
+	rows, err := core.AFKBackend.Databases.Postgres.Query(ctx, "SELECT position, color, COALESCE(block_time, time) FROM "+source+" ORDER BY block_number ASC, key ASC")
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer rows.Close()
+
+	pixels := 0
+	sinceFrame := 0
+	nextFrameAt := pixelsInfo.First.Add(time.Duration(everySeconds) * time.Second)
+	for rows.Next() {
+		var position, colorKey int
+		var placedAt time.Time
+		if err := rows.Scan(&position, &colorKey, &placedAt); err != nil {
+			return "", 0, 0, err
+		}
+
+		if everySeconds > 0 && !placedAt.Before(nextFrameAt) {
+			intervals := 0
+			for !placedAt.Before(nextFrameAt) {
+				nextFrameAt = nextFrameAt.Add(time.Duration(everySeconds) * time.Second)
+				intervals++
+			}
+			addFrame(intervals)
+			sinceFrame = 0
+		}
+
+		pixels++
+		if position < 0 || position >= len(state) || colorKey < 0 || colorKey >= len(palette) {
+			continue
+		}
+		state[position] = uint8(colorKey)
+		sinceFrame++
+
+		if everyPixels > 0 && sinceFrame >= everyPixels {
+			addFrame(1)
+			sinceFrame = 0
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", 0, 0, err
+	}
+	if sinceFrame > 0 || len(frames) == 0 {
+		addFrame(1)
+	}
+
+	if err := os.MkdirAll(TimelapsesDir, os.ModePerm); err != nil {
+		return "", 0, 0, err
+	}
+	extension := "gif"
+	if timelapse.Format == FormatApng {
+		extension = "png"
+	}
+	file := fmt.Sprintf("%s-%d.%s", history.CanvasKey, timelapse.Id, extension)
+	output, err := os.Create(filepath.Join(TimelapsesDir, file))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer output.Close()
+
+	if timelapse.Format == FormatApng {
+		err = encodeAPNG(output, frames, delaysMs)
+	} else {
+		delays := make([]int, len(delaysMs))
+		for idx, delayMs := range delaysMs {
+			delays[idx] = delayMs / 10
+		}
+		err = gif.EncodeAll(output, &gif.GIF{Image: frames, Delay: delays})
+	}
+	if err != nil {
+		return "", 0, 0, err
+	}
+	return file, len(frames), pixels, nil
+}